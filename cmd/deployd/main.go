@@ -1,19 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/golang/protobuf/proto"
 	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/health"
 	"github.com/navikt/deployment/common/pkg/kafka"
 	"github.com/navikt/deployment/common/pkg/logging"
+	"github.com/navikt/deployment/common/pkg/tracing"
 	"github.com/navikt/deployment/deployd/pkg/config"
 	"github.com/navikt/deployment/deployd/pkg/deployd"
+	"github.com/navikt/deployment/deployd/pkg/deploylog"
 	"github.com/navikt/deployment/deployd/pkg/kubeclient"
 	"github.com/navikt/deployment/deployd/pkg/metrics"
 	"github.com/navikt/deployment/pkg/crypto"
@@ -21,7 +26,11 @@ import (
 	flag "github.com/spf13/pflag"
 )
 
-var cfg = config.DefaultConfig()
+var (
+	cfg           = config.DefaultConfig()
+	healthTimeout = time.Second * 5
+	logQueueSize  = 4096
+)
 
 func init() {
 	flag.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "Log format, either 'json' or 'text'.")
@@ -32,8 +41,12 @@ func init() {
 	flag.BoolVar(&cfg.TeamNamespaces, "team-namespaces", cfg.TeamNamespaces, "Set to true if team service accounts live in team's own namespace.")
 	flag.BoolVar(&cfg.AutoCreateServiceAccount, "auto-create-service-account", cfg.AutoCreateServiceAccount, "Set to true to automatically create service accounts.")
 	flag.StringVar(&cfg.EncryptionKey, "encryption-key", cfg.EncryptionKey, "Pre-shared key used for message encryption over Kafka.")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "Time to wait for rollout monitors to finish when shutting down.")
+	flag.DurationVar(&cfg.RolloutTimeout, "rollout-timeout", cfg.RolloutTimeout, "Time to wait for a rollout to complete, unless the deployment request specifies a timeout.")
+	flag.StringVar(&cfg.ConcurrencyPolicy, "concurrency-policy", cfg.ConcurrencyPolicy, "What to do when a deployment arrives while an older deployment of the same application is running; either 'supersede' or 'queue'.")
 
 	kafka.SetupFlags(&cfg.Kafka)
+	tracing.SetupFlags(&cfg.Tracing)
 }
 
 func run() error {
@@ -50,8 +63,20 @@ func run() error {
 
 	sarama.Logger = kafkaLogger
 
+	if err := tracing.Setup("deployd", cfg.Tracing.Exporter, cfg.Tracing.File); err != nil {
+		return err
+	}
+
+	switch cfg.ConcurrencyPolicy {
+	case config.ConcurrencyPolicySupersede, config.ConcurrencyPolicyQueue:
+	default:
+		return fmt.Errorf("unknown concurrency policy '%s'", cfg.ConcurrencyPolicy)
+	}
+
 	log.Infof("deployd starting up")
 	log.Infof("cluster.................: %s", cfg.Cluster)
+	log.Infof("concurrency policy......: %s", cfg.ConcurrencyPolicy)
+	log.Infof("trace exporter..........: %s", cfg.Tracing.Exporter)
 
 	kube, err := kubeclient.New()
 	if err != nil {
@@ -61,6 +86,7 @@ func run() error {
 
 	log.Infof("kafka topic for requests: %s", cfg.Kafka.RequestTopic)
 	log.Infof("kafka topic for statuses: %s", cfg.Kafka.StatusTopic)
+	log.Infof("kafka topic for logs....: %s", cfg.Kafka.LogTopic)
 	log.Infof("kafka consumer group....: %s", cfg.Kafka.GroupID)
 	log.Infof("kafka brokers...........: %+v", cfg.Kafka.Brokers)
 
@@ -80,19 +106,74 @@ func run() error {
 
 	go client.ConsumerLoop()
 
-	statusChan := make(chan *deployment.DeploymentStatus, 1024)
+	// Send log lines about deployments to hookd, where they are shown on the deployment log page.
+	shipper := deploylog.NewShipper(cfg.Cluster, logQueueSize, func(event *deployment.LogEvent) error {
+		return SendLogEvent(event, client, encryptionKey, cfg.Kafka.LogTopic)
+	})
+	log.AddHook(shipper)
+	stopShipper := make(chan struct{})
+	shipperDone := make(chan struct{})
+	go func() {
+		shipper.Run(stopShipper)
+		close(shipperDone)
+	}()
 
-	metricsServer := http.NewServeMux()
-	metricsServer.Handle(cfg.MetricsPath, metrics.Handler())
-	log.Infof("Serving metrics on %s endpoint %s", cfg.MetricsListenAddr, cfg.MetricsPath)
-	go http.ListenAndServe(cfg.MetricsListenAddr, metricsServer)
+	statusChan := make(chan *deployment.DeploymentStatus, 1024)
+	monitors := deployd.NewMonitors()
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle(cfg.MetricsPath, metrics.Handler())
+	metricsMux.Handle("/healthz", health.LivenessHandler())
+	metricsMux.Handle("/readyz", health.ReadinessHandler(
+		healthTimeout,
+		health.CheckFunc("kafka-consumer", client.PingConsumer),
+		health.CheckFunc("kafka-producer", client.PingProducer),
+		health.NewChecker("kubernetes", kube),
+	))
+	metricsServer := &http.Server{
+		Addr:    cfg.MetricsListenAddr,
+		Handler: metricsMux,
+	}
+	log.Infof("Serving metrics on %s endpoint %s, and health checks on /healthz and /readyz", cfg.MetricsListenAddr, cfg.MetricsPath)
+	go func() {
+		err := metricsServer.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Error(err)
+		}
+	}()
 
-	// Trap SIGINT to trigger a shutdown.
+	// Trap SIGINT and SIGTERM to trigger a shutdown.
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
 	var m sarama.ConsumerMessage
+	var sig os.Signal
+
+	handleStatus := func(status *deployment.DeploymentStatus) {
+		logger := log.WithFields(status.LogFields())
+		switch {
+		case status.GetDryRun():
+			logger.Infof(status.GetDescription())
+		case status.GetState() == deployment.GithubDeploymentState_error:
+			fallthrough
+		case status.GetState() == deployment.GithubDeploymentState_failure:
+			metrics.DeployFailed.Inc()
+			logger.Errorf(status.GetDescription())
+		case status.GetState() == deployment.GithubDeploymentState_inactive:
+			metrics.DeployCancelled.Inc()
+			logger.Warnf(status.GetDescription())
+		default:
+			metrics.DeploySuccessful.Inc()
+			logger.Infof(status.GetDescription())
+		}
+
+		err := SendDeploymentStatus(status, client, encryptionKey)
+		if err != nil {
+			logger.Errorf("While reporting deployment status: %s", err)
+		}
+	}
 
+LOOP:
 	for {
 	SEL:
 		select {
@@ -113,35 +194,87 @@ func run() error {
 			}
 
 			// Check the validity and authenticity of the message.
-			deployd.Run(&logger, &req, *cfg, kube, statusChan)
+			deployd.Run(&logger, &req, *cfg, kube, statusChan, monitors)
 
 		case status := <-statusChan:
-			logger := log.WithFields(status.LogFields())
-			switch {
-			case status == nil:
+			if status == nil {
 				metrics.DeployIgnored.Inc()
 				break SEL
-			case status.GetState() == deployment.GithubDeploymentState_error:
-				fallthrough
-			case status.GetState() == deployment.GithubDeploymentState_failure:
-				metrics.DeployFailed.Inc()
-				logger.Errorf(status.GetDescription())
-			default:
-				metrics.DeploySuccessful.Inc()
-				logger.Infof(status.GetDescription())
 			}
+			handleStatus(status)
 
-			err = SendDeploymentStatus(status, client, encryptionKey)
-			if err != nil {
-				logger.Errorf("While reporting deployment status: %s", err)
+		case sig = <-signals:
+			break LOOP
+		}
+
+		client.Consumer.MarkOffset(&m, "")
+	}
+
+	log.Infof("Received %s; shutting down", sig)
+
+	// Stop consuming deployment requests. Messages that have not been
+	// processed yet are left for the next deployd instance.
+	if err := client.Consumer.Close(); err != nil {
+		log.Errorf("While closing Kafka consumer: %s", err)
+	}
+
+	// Give running rollout monitors some time to finish. Monitors that are still
+	// running after the timeout are stopped, and their deployments are handed over to the next instance.
+	log.Infof("Waiting up to %s for rollout monitors to finish", cfg.ShutdownTimeout)
+	done := make(chan []*deployment.DeploymentRequest)
+	go func() {
+		done <- monitors.Shutdown(cfg.ShutdownTimeout)
+	}()
+
+DRAIN:
+	for {
+		select {
+		case status := <-statusChan:
+			if status != nil {
+				handleStatus(status)
 			}
 
-		case <-signals:
-			return nil
+		case handedOff := <-done:
+			if len(handedOff) > 0 {
+				log.Warnf("Handing over %d deployments whose rollouts did not finish within %s", len(handedOff), cfg.ShutdownTimeout)
+			}
+			for _, req := range handedOff {
+				logger := log.WithFields(req.LogFields())
+				err := SendDeploymentRequest(req, client, encryptionKey, cfg.Kafka.RequestTopic)
+				if err != nil {
+					logger.Errorf("While handing over rollout monitoring: %s", err)
+					continue
+				}
+				logger.Infof("Handed over rollout monitoring to the next deployd instance")
+			}
+			break DRAIN
 		}
+	}
 
-		client.Consumer.MarkOffset(&m, "")
+	// Monitors send their status before returning, so the queue holds everything that is left.
+	for len(statusChan) > 0 {
+		if status := <-statusChan; status != nil {
+			handleStatus(status)
+		}
+	}
+
+	// Send the remaining log lines while the producer is still open.
+	close(stopShipper)
+	<-shipperDone
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := metricsServer.Shutdown(ctx); err != nil {
+		log.Errorf("While shutting down metrics server: %s", err)
+	}
+
+	if err := client.Close(); err != nil {
+		return err
 	}
+
+	log.Infof("Shutdown complete")
+
+	return nil
 }
 
 func SendDeploymentStatus(status *deployment.DeploymentStatus, client *kafka.DualClient, key []byte) error {
@@ -176,6 +309,54 @@ func SendDeploymentStatus(status *deployment.DeploymentStatus, client *kafka.Dua
 	return nil
 }
 
+// SendDeploymentRequest publishes a deployment request whose rollout is still in progress back to the request topic,
+// so that the next deployd instance resumes monitoring it.
+func SendDeploymentRequest(req *deployment.DeploymentRequest, client *kafka.DualClient, key []byte, topic string) error {
+	req.Resume = true
+
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("while marshalling deployment request Protobuf message: %s", err)
+	}
+
+	ciphertext, err := crypto.Encrypt(payload, key)
+	if err != nil {
+		return fmt.Errorf("encrypt deployment request: %s", err)
+	}
+
+	_, _, err = client.Producer.SendMessage(&sarama.ProducerMessage{
+		Topic:     topic,
+		Timestamp: time.Now(),
+		Value:     sarama.StringEncoder(ciphertext),
+	})
+	if err != nil {
+		return fmt.Errorf("while sending deployment request over Kafka: %s", err)
+	}
+	return nil
+}
+
+// SendLogEvent sends a log line about a deployment to hookd.
+// Lines are keyed by delivery ID, so that the lines of a deployment end up in the same partition, and stay in order.
+func SendLogEvent(event *deployment.LogEvent, client *kafka.DualClient, key []byte, topic string) error {
+	payload, err := proto.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("while marshalling log event Protobuf message: %s", err)
+	}
+
+	ciphertext, err := crypto.Encrypt(payload, key)
+	if err != nil {
+		return fmt.Errorf("encrypt log event: %s", err)
+	}
+
+	_, _, err = client.Producer.SendMessage(&sarama.ProducerMessage{
+		Topic:     topic,
+		Key:       sarama.StringEncoder(event.GetDeliveryID()),
+		Timestamp: time.Unix(0, event.GetTimestamp()),
+		Value:     sarama.StringEncoder(ciphertext),
+	})
+	return err
+}
+
 func main() {
 	err := run()
 	if err != nil {
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -15,30 +19,59 @@ import (
 	"github.com/golang/protobuf/proto"
 	gh "github.com/google/go-github/v27/github"
 	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/health"
 	"github.com/navikt/deployment/common/pkg/kafka"
 	"github.com/navikt/deployment/common/pkg/logging"
+	"github.com/navikt/deployment/common/pkg/tracing"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/apikey"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/approval"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/audit"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/cancel"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/dora"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/freeze"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/logs"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/notification"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/openapi"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/outbox"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/provision"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/status"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/audit"
 	"github.com/navikt/deployment/hookd/pkg/auth"
+	"github.com/navikt/deployment/hookd/pkg/cache"
 	"github.com/navikt/deployment/hookd/pkg/config"
+	"github.com/navikt/deployment/hookd/pkg/dora"
+	"github.com/navikt/deployment/hookd/pkg/dryrun"
+	"github.com/navikt/deployment/hookd/pkg/freeze"
 	"github.com/navikt/deployment/hookd/pkg/github"
+	"github.com/navikt/deployment/hookd/pkg/history"
 	"github.com/navikt/deployment/hookd/pkg/logproxy"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
 	"github.com/navikt/deployment/hookd/pkg/metrics"
 	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/notification"
+	"github.com/navikt/deployment/hookd/pkg/outbox"
 	"github.com/navikt/deployment/hookd/pkg/persistence"
+	"github.com/navikt/deployment/hookd/pkg/reporter"
 	"github.com/navikt/deployment/hookd/pkg/server"
 	"github.com/navikt/deployment/pkg/crypto"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 )
 
 var (
-	cfg            = config.DefaultConfig()
-	retryInterval  = time.Second * 5
-	queueSize      = 32
-	requestTimeout = time.Second * 10
+	cfg              = config.DefaultConfig()
+	retryInterval    = time.Second * 5
+	expireInterval   = time.Minute
+	queueSize        = 32
+	requestTimeout   = time.Second * 10
+	historySize      = 100
+	healthTimeout    = time.Second * 5
+	logPruneInterval = time.Hour
 )
 
 func init() {
@@ -49,6 +82,7 @@ func init() {
 	flag.StringVar(&cfg.Github.KeyFile, "github-key-file", cfg.Github.KeyFile, "Path to PEM key owned by Github App.")
 	flag.StringVar(&cfg.Github.ClientID, "github-client-id", cfg.Github.ClientID, "Client ID of the Github App.")
 	flag.StringVar(&cfg.Github.ClientSecret, "github-client-secret", cfg.Github.ClientSecret, "Client secret of the GitHub App.")
+	flag.StringVar(&cfg.Github.Organization, "github-organization", cfg.Github.Organization, "GitHub organization whose team maintainers can manage their team's API keys.")
 
 	flag.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "Base URL where hookd can be reached.")
 	flag.StringVar(&cfg.ListenAddress, "listen-address", cfg.ListenAddress, "IP:PORT")
@@ -56,8 +90,45 @@ func init() {
 	flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Logging verbosity level.")
 	flag.StringSliceVar(&cfg.Clusters, "clusters", cfg.Clusters, "Comma-separated list of valid clusters that can be deployed to.")
 	flag.StringVar(&cfg.ProvisionKey, "provision-key", cfg.ProvisionKey, "Pre-shared key for /api/v1/provision endpoint.")
+	flag.StringVar(&cfg.AdminKey, "admin-key", cfg.AdminKey, "Pre-shared key for administrative API endpoints.")
+	flag.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "Directory where hookd keeps its local state.")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "Time to wait for in-flight requests and queued messages when shutting down.")
+	flag.StringSliceVar(&cfg.Approval.Clusters, "approval-clusters", cfg.Approval.Clusters, "Comma-separated list of clusters where deployments must be manually approved.")
+	flag.DurationVar(&cfg.Approval.Timeout, "approval-timeout", cfg.Approval.Timeout, "Discard deployment requests that are not approved within this time.")
+	flag.DurationVar(&cfg.Timeouts.RolloutMin, "rollout-timeout-min", cfg.Timeouts.RolloutMin, "Shortest rollout timeout a deployment request may specify.")
+	flag.DurationVar(&cfg.Timeouts.RolloutMax, "rollout-timeout-max", cfg.Timeouts.RolloutMax, "Longest rollout timeout a deployment request may specify.")
+	flag.StringToStringVar(&cfg.Timeouts.RolloutBounds, "rollout-timeout-bounds", cfg.Timeouts.RolloutBounds, "Override rollout timeout bounds per cluster, e.g. 'dev-fss=30s-30m,prod-fss=5m-2h'.")
+	flag.DurationVar(&cfg.Timeouts.MaxTTL, "request-ttl-max", cfg.Timeouts.MaxTTL, "Longest time to live a deployment request may specify.")
+	flag.DurationVar(&cfg.Timeouts.DryRun, "dry-run-timeout", cfg.Timeouts.DryRun, "Time to wait for the results of a dry run from deployd. Must be shorter than --deploy-timeout.")
+	flag.DurationVar(&cfg.Timeouts.Deploy, "deploy-timeout", cfg.Timeouts.Deploy, "Time allowed for a deploy request to complete, including the wait for dry run results.")
+	flag.IntVar(&cfg.Notification.MaxAttempts, "notification-max-attempts", cfg.Notification.MaxAttempts, "Give up delivering a notification after this many attempts.")
+	flag.DurationVar(&cfg.Notification.Backoff, "notification-backoff", cfg.Notification.Backoff, "Time to wait before retrying a failed notification; doubled for each attempt.")
+	flag.IntVar(&cfg.Notification.Workers, "notification-workers", cfg.Notification.Workers, "Number of notifications delivered concurrently.")
+	flag.IntVar(&cfg.Notification.QueueSize, "notification-queue-size", cfg.Notification.QueueSize, "Maximum number of notifications waiting for delivery; further notifications are discarded.")
+	flag.StringSliceVar(&cfg.Reporters.Enabled, "status-reporters", cfg.Reporters.Enabled, "Comma-separated list of status reporters to run; any of 'github', 'github-inactive', 'history', 'notification', 'file' and 'http'.")
+	flag.StringVar(&cfg.Reporters.FilePath, "status-file", cfg.Reporters.FilePath, "Append deployment statuses to this file when using the 'file' status reporter.")
+	flag.StringVar(&cfg.Reporters.HTTPURL, "status-http-url", cfg.Reporters.HTTPURL, "Post deployment statuses to this URL when using the 'http' status reporter.")
+	flag.StringToIntVar(&cfg.Reporters.MaxAttempts, "status-max-attempts", cfg.Reporters.MaxAttempts, "Override maximum number of attempts per status reporter, e.g. 'github=10,http=3'. Zero means retry forever.")
+	flag.StringToStringVar(&cfg.Reporters.RetryInterval, "status-retry-interval", cfg.Reporters.RetryInterval, "Override retry interval per status reporter, e.g. 'github=5s,http=1m'.")
+	flag.IntVar(&cfg.Reporters.HistorySize, "history-size", cfg.Reporters.HistorySize, "Number of deployments to keep in the local deployment history.")
+	flag.DurationVar(&cfg.DORA.Window, "dora-window", cfg.DORA.Window, "Time window covered by the DORA metrics exposed to Prometheus, and the default time range of DORA reports.")
+	flag.DurationVar(&cfg.Logs.Retention, "log-retention", cfg.Logs.Retention, "Time to keep the logs of deployments after the last log line was received.")
+	flag.StringVar(&cfg.Logs.Backend, "log-backend", cfg.Logs.Backend, "Where deployment log links go; one of 'builtin', 'kibana', 'loki' and 'template'.")
+	flag.StringToStringVar(&cfg.Logs.ClusterBackend, "log-backend-cluster", cfg.Logs.ClusterBackend, "Override log backend per cluster, e.g. 'dev-fss=kibana,dev-gcp=loki'.")
+	flag.StringVar(&cfg.Logs.KibanaURL, "log-kibana-url", cfg.Logs.KibanaURL, "Base URL of Kibana when using the 'kibana' log backend.")
+	flag.StringVar(&cfg.Logs.KibanaIndex, "log-kibana-index", cfg.Logs.KibanaIndex, "Kibana index pattern to search when using the 'kibana' log backend.")
+	flag.StringVar(&cfg.Logs.LokiURL, "log-loki-url", cfg.Logs.LokiURL, "Base URL of Grafana when using the 'loki' log backend.")
+	flag.StringVar(&cfg.Logs.LokiDatasource, "log-loki-datasource", cfg.Logs.LokiDatasource, "Name of the Loki data source in Grafana when using the 'loki' log backend.")
+	flag.StringVar(&cfg.Logs.LokiSelector, "log-loki-selector", cfg.Logs.LokiSelector, "LogQL stream selector matching deployd's logs when using the 'loki' log backend.")
+	flag.StringVar(&cfg.Logs.URLTemplate, "log-url-template", cfg.Logs.URLTemplate, "Log URL with {delivery_id}, {timestamp}, {team} and {cluster} placeholders when using the 'template' log backend.")
+	flag.IntVar(&cfg.Outbox.Capacity, "outbox-capacity", cfg.Outbox.Capacity, "Maximum number of queued items in each outbox.")
+	flag.DurationVar(&cfg.Outbox.MaxBackoff, "outbox-max-backoff", cfg.Outbox.MaxBackoff, "Maximum time to wait between retries of a failed outbox item.")
+	flag.IntVar(&cfg.Outbox.RequestMaxAttempts, "outbox-request-max-attempts", cfg.Outbox.RequestMaxAttempts, "Give up publishing a deployment request to Kafka after this many attempts.")
 	flag.StringVar(&cfg.EncryptionKey, "encryption-key", cfg.EncryptionKey, "Pre-shared key used for message encryption over Kafka.")
 
+	flag.StringVar(&cfg.TeamRepositories.Backend, "team-repository-backend", cfg.TeamRepositories.Backend, "Where to store repository team access; one of 's3', 'file', 'sqlite' or 'memory'.")
+	flag.StringVar(&cfg.TeamRepositories.Path, "team-repository-path", cfg.TeamRepositories.Path, "Directory or database file used by the 'file' and 'sqlite' team repository backends. Defaults to a location in --data-dir.")
+
 	flag.StringVar(&cfg.S3.Endpoint, "s3-endpoint", cfg.S3.Endpoint, "S3 endpoint for state storage.")
 	flag.StringVar(&cfg.S3.AccessKey, "s3-access-key", cfg.S3.AccessKey, "S3 access key.")
 	flag.StringVar(&cfg.S3.SecretKey, "s3-secret-key", cfg.S3.SecretKey, "S3 secret key.")
@@ -65,15 +136,27 @@ func init() {
 	flag.StringVar(&cfg.S3.BucketLocation, "s3-bucket-location", cfg.S3.BucketLocation, "S3 bucket location.")
 	flag.BoolVar(&cfg.S3.UseTLS, "s3-secure", cfg.S3.UseTLS, "Use TLS for S3 connections.")
 
-	flag.StringVar(&cfg.Vault.Path, "vault-path", cfg.Vault.Path, "Base path to Vault KV API key store.")
+	flag.StringSliceVar(&cfg.Audit.Sinks, "audit-sinks", cfg.Audit.Sinks, "Comma-separated list of audit log sinks; any of 'file' and 'kafka'.")
+	flag.StringVar(&cfg.Audit.File, "audit-file", cfg.Audit.File, "Audit log file used by the 'file' audit sink. Defaults to a location in --data-dir.")
+	flag.StringVar(&cfg.Audit.KafkaTopic, "audit-kafka-topic", cfg.Audit.KafkaTopic, "Kafka topic used by the 'kafka' audit sink.")
+	flag.StringVar(&cfg.Audit.Key, "audit-key", cfg.Audit.Key, "Hex encoded secret key for the audit log hash chain. Keep it outside the audit log's storage.")
+
+	flag.DurationVar(&cfg.Cache.TTL, "cache-ttl", cfg.Cache.TTL, "Time to cache API keys, GitHub team access checks and signed in users' teams. Zero disables caching.")
+	flag.DurationVar(&cfg.Cache.NegativeTTL, "cache-negative-ttl", cfg.Cache.NegativeTTL, "Time to cache missing API keys and denied GitHub team access. Zero disables negative caching.")
+	flag.StringVar(&cfg.ApiKeys.Backend, "api-key-backend", cfg.ApiKeys.Backend, "Where to store team API keys; one of 'vault', 'vault-kv2', 'kubernetes' or 'file'.")
+	flag.StringVar(&cfg.ApiKeys.Namespace, "api-key-namespace", cfg.ApiKeys.Namespace, "Namespace holding API key secrets when using the 'kubernetes' backend.")
+	flag.StringVar(&cfg.ApiKeys.File, "api-key-file", cfg.ApiKeys.File, "JSON file holding API keys when using the 'file' backend. Defaults to a file in --data-dir.")
+
+	flag.StringVar(&cfg.Vault.Path, "vault-path", cfg.Vault.Path, "Base path to Vault KV API key store; the mount path of the secrets engine when using the 'vault-kv2' backend.")
 	flag.StringVar(&cfg.Vault.AuthPath, "vault-auth-path", cfg.Vault.AuthPath, "Path to Vault authentication endpoint.")
 	flag.StringVar(&cfg.Vault.AuthRole, "vault-auth-role", cfg.Vault.AuthRole, "Role used for Vault authentication.")
 	flag.StringVar(&cfg.Vault.Address, "vault-address", cfg.Vault.Address, "Address to Vault server.")
-	flag.StringVar(&cfg.Vault.KeyName, "vault-key-name", cfg.Vault.KeyName, "API keys are stored in this key.")
+	flag.StringVar(&cfg.Vault.KeyName, "vault-key-name", cfg.Vault.KeyName, "API keys are stored in this key, both in Vault and in Kubernetes secrets.")
 	flag.StringVar(&cfg.Vault.CredentialsFile, "vault-credentials-file", cfg.Vault.CredentialsFile, "Credentials for authenticating against Vault retrieved from this file (overrides --vault-token).")
 	flag.StringVar(&cfg.Vault.Token, "vault-token", cfg.Vault.Token, "Vault static token.")
 
 	kafka.SetupFlags(&cfg.Kafka)
+	tracing.SetupFlags(&cfg.Tracing)
 }
 
 func run() error {
@@ -83,6 +166,10 @@ func run() error {
 		return err
 	}
 
+	if err := tracing.Setup("hookd", cfg.Tracing.Exporter, cfg.Tracing.File); err != nil {
+		return err
+	}
+
 	kafkaLogger, err := logging.New(cfg.Kafka.Verbosity, cfg.LogFormat)
 	if err != nil {
 		return err
@@ -91,9 +178,11 @@ func run() error {
 	log.Info("hookd is starting")
 	log.Infof("kafka topic for requests: %s", cfg.Kafka.RequestTopic)
 	log.Infof("kafka topic for statuses: %s", cfg.Kafka.StatusTopic)
+	log.Infof("kafka topic for logs....: %s", cfg.Kafka.LogTopic)
 	log.Infof("kafka consumer group....: %s", cfg.Kafka.GroupID)
 	log.Infof("kafka brokers...........: %+v", cfg.Kafka.Brokers)
 	log.Infof("web frontend templates..: %s", auth.TemplateLocation)
+	log.Infof("trace exporter..........: %s", cfg.Tracing.Exporter)
 
 	sarama.Logger = kafkaLogger
 
@@ -101,25 +190,88 @@ func run() error {
 		return fmt.Errorf("--github-install-id and --github-app-id must be specified when --github-enabled=true")
 	}
 
+	// The dry run wait happens within the deploy request, and must end before the request times out.
+	if cfg.Timeouts.DryRun >= cfg.Timeouts.Deploy {
+		return fmt.Errorf("--dry-run-timeout (%s) must be shorter than --deploy-timeout (%s)", cfg.Timeouts.DryRun, cfg.Timeouts.Deploy)
+	}
+
 	provisionKey, err := hex.DecodeString(cfg.ProvisionKey)
 	if err != nil {
 		return fmt.Errorf("provisioning pre-shared key must be a hex encoded string")
 	}
 
+	adminKey, err := hex.DecodeString(cfg.AdminKey)
+	if err != nil {
+		return fmt.Errorf("administrator pre-shared key must be a hex encoded string")
+	}
+
 	encryptionKey, err := crypto.KeyFromHexString(cfg.EncryptionKey)
 	if err != nil {
 		return err
 	}
 
-	teamRepositoryStorage, err := persistence.NewS3StorageBackend(cfg.S3)
+	freezeStorage, err := freeze.NewFileStorage(filepath.Join(cfg.DataDir, "freeze-windows.json"))
 	if err != nil {
-		return fmt.Errorf("while setting up S3 backend: %s", err)
+		return fmt.Errorf("while loading freeze windows: %s", err)
+	}
+
+	subscriptionStorage, err := notification.NewFileStorage(filepath.Join(cfg.DataDir, "notification-subscriptions.json"))
+	if err != nil {
+		return fmt.Errorf("while loading notification subscriptions: %s", err)
+	}
+
+	notificationHistory, err := notification.NewHistory(filepath.Join(cfg.DataDir, "notification-history.json"), historySize)
+	if err != nil {
+		return fmt.Errorf("while loading notification history: %s", err)
+	}
+
+	notifier := &notification.Notifier{
+		Storage:     subscriptionStorage,
+		History:     notificationHistory,
+		HTTPClient:  &http.Client{Timeout: requestTimeout},
+		BaseURL:     cfg.BaseURL,
+		MaxAttempts: cfg.Notification.MaxAttempts,
+		Backoff:     cfg.Notification.Backoff,
+		Workers:     cfg.Notification.Workers,
+		QueueSize:   cfg.Notification.QueueSize,
+	}
+
+	historyStore, err := history.NewFileStore(filepath.Join(cfg.DataDir, "deployment-history.json"), cfg.Reporters.HistorySize)
+	if err != nil {
+		return fmt.Errorf("while loading deployment history: %s", err)
+	}
+
+	logStore, err := logstore.NewFileStore(filepath.Join(cfg.DataDir, "logs"))
+	if err != nil {
+		return fmt.Errorf("while setting up deployment log storage: %s", err)
+	}
+
+	pruneLogs := func() {
+		removed, err := logStore.Prune(time.Now().Add(-cfg.Logs.Retention))
+		if err != nil {
+			log.Errorf("While pruning deployment logs: %s", err)
+		}
+		if removed > 0 {
+			log.Infof("Removed %d deployment logs older than %s", removed, cfg.Logs.Retention)
+		}
+	}
+	pruneLogs()
+
+	prometheus.MustRegister(&dora.Collector{
+		Store:  historyStore,
+		Window: cfg.DORA.Window,
+	})
+
+	teamRepositoryStorage, err := persistence.NewTeamRepositoryStorage(cfg.TeamRepositories, cfg.S3, cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("while setting up team repository storage: %s", err)
 	}
 
 	kafkaClient, err := kafka.NewDualClient(
 		cfg.Kafka,
 		cfg.Kafka.StatusTopic,
 		cfg.Kafka.RequestTopic,
+		cfg.Kafka.LogTopic,
 	)
 	if err != nil {
 		return fmt.Errorf("while setting up Kafka: %s", err)
@@ -127,6 +279,11 @@ func run() error {
 
 	go kafkaClient.ConsumerLoop()
 
+	auditLog, err := newAuditLog(kafkaClient)
+	if err != nil {
+		return fmt.Errorf("while setting up audit log: %s", err)
+	}
+
 	var installationClient *gh.Client
 	var githubClient github.Client
 
@@ -140,49 +297,153 @@ func run() error {
 		githubClient = github.FakeClient()
 	}
 
-	apiKeys := &persistence.VaultApiKeyStorage{
-		Address:    cfg.Vault.Address,
-		Path:       cfg.Vault.Path,
-		AuthPath:   cfg.Vault.AuthPath,
-		AuthRole:   cfg.Vault.AuthRole,
-		KeyName:    cfg.Vault.KeyName,
-		Token:      cfg.Vault.Token,
-		HttpClient: http.DefaultClient,
+	apiKeys, err := apiKeyStorage()
+	if err != nil {
+		return fmt.Errorf("while setting up API key storage: %s", err)
 	}
 
-	if len(cfg.Vault.CredentialsFile) > 0 {
-		credentials, err := ioutil.ReadFile(cfg.Vault.CredentialsFile)
-		if err != nil {
-			return fmt.Errorf("read Vault token file: %s", err)
-		}
-		apiKeys.Credentials = string(credentials)
-		apiKeys.Token = ""
-
-		go apiKeys.RefreshLoop()
-	}
+	// Cache API keys and team access checks, as they are looked up on every request.
+	// Writing an API key through the cache invalidates the cached key.
+	cachedApiKeys := persistence.NewCachedApiKeyStorage(apiKeys, cfg.Cache.TTL, cfg.Cache.NegativeTTL)
+	githubClient = github.NewCachedClient(githubClient, cfg.Cache.TTL, cfg.Cache.NegativeTTL)
 
 	prometheusMiddleware := middleware.PrometheusMiddleware("hookd")
 
 	requestChan := make(chan deployment.DeploymentRequest, queueSize)
 	statusChan := make(chan deployment.DeploymentStatus, queueSize)
 
+	requestOutbox, err := outbox.New(
+		filepath.Join(cfg.DataDir, "outbox-requests.json"),
+		"requests",
+		cfg.Outbox.Capacity,
+		outbox.Policy{
+			MaxAttempts: cfg.Outbox.RequestMaxAttempts,
+			Backoff:     retryInterval,
+			MaxBackoff:  cfg.Outbox.MaxBackoff,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("while loading deployment request outbox: %s", err)
+	}
+
+	dispatcher, err := statusReporters(installationClient, historyStore, notifier)
+	if err != nil {
+		return fmt.Errorf("while setting up status reporters: %s", err)
+	}
+
+	log.Infof("Status reporters: %s", dispatcher)
+
+	defaultLogBackend, clusterLogBackends, err := logBackends()
+	if err != nil {
+		return fmt.Errorf("while setting up log backends: %s", err)
+	}
+
+	approvalGate, err := approval.NewGate(
+		filepath.Join(cfg.DataDir, "pending-approvals.json"),
+		cfg.Approval.Clusters,
+		cfg.Approval.Timeout,
+		requestChan,
+		statusChan,
+	)
+	if err != nil {
+		return fmt.Errorf("while loading pending approvals: %s", err)
+	}
+
+	go approvalGate.ExpireLoop(expireInterval)
+
+	deploymentTimeouts, err := timeouts()
+	if err != nil {
+		return err
+	}
+
+	dryRuns := dryrun.New()
+
 	deploymentHandler := &api_v1_deploy.DeploymentHandler{
 		BaseURL:           cfg.BaseURL,
 		DeploymentRequest: requestChan,
 		DeploymentStatus:  statusChan,
 		GithubClient:      githubClient,
-		APIKeyStorage:     apiKeys,
+		APIKeyStorage:     cachedApiKeys,
 		Clusters:          cfg.Clusters,
+		Timeouts:          deploymentTimeouts,
+		FreezeStorage:     freezeStorage,
+		ApprovalGate:      approvalGate,
+		AuditLog:          auditLog,
+		DryRuns:           dryRuns,
+		DryRunTimeout:     cfg.Timeouts.DryRun,
+	}
+
+	cancelHandler := &api_v1_cancel.Handler{
+		APIKeyStorage:     cachedApiKeys,
+		History:           historyStore,
+		Approvals:         approvalGate,
+		Outbox:            requestOutbox,
+		DeploymentRequest: requestChan,
+		DeploymentStatus:  statusChan,
+		AuditLog:          auditLog,
+	}
+
+	logsHandler := &api_v1_logs.Handler{
+		APIKeyStorage: cachedApiKeys,
+		Logs:          logStore,
+		History:       historyStore,
+		AuditLog:      auditLog,
 	}
 
 	statusHandler := &api_v1_status.StatusHandler{
 		GithubClient:  githubClient,
-		APIKeyStorage: apiKeys,
+		APIKeyStorage: cachedApiKeys,
+		AuditLog:      auditLog,
 	}
 
 	provisionHandler := &api_v1_provision.Handler{
-		APIKeyStorage: apiKeys,
+		APIKeyStorage: cachedApiKeys,
 		SecretKey:     provisionKey,
+		AuditLog:      auditLog,
+	}
+
+	freezeHandler := &api_v1_freeze.Handler{
+		Storage:   freezeStorage,
+		SecretKey: adminKey,
+		AuditLog:  auditLog,
+	}
+
+	approvalHandler := &api_v1_approval.Handler{
+		Gate:              approvalGate,
+		ApplicationClient: installationClient,
+		AuditLog:          auditLog,
+	}
+
+	apiKeyHandler := &api_v1_apikey.Handler{
+		APIKeyStorage: cachedApiKeys,
+		Organization:  cfg.Github.Organization,
+		AuditLog:      auditLog,
+	}
+
+	notificationHandler := &api_v1_notification.Handler{
+		APIKeyStorage: cachedApiKeys,
+		Storage:       subscriptionStorage,
+		History:       notificationHistory,
+		AuditLog:      auditLog,
+	}
+
+	doraHandler := &api_v1_dora.Handler{
+		APIKeyStorage: cachedApiKeys,
+		History:       historyStore,
+		AuditLog:      auditLog,
+		DefaultRange:  cfg.DORA.Window,
+	}
+
+	outboxHandler := &api_v1_outbox.Handler{
+		Outboxes:  append([]*outbox.Outbox{requestOutbox}, dispatcher.Outboxes()...),
+		SecretKey: adminKey,
+		AuditLog:  auditLog,
+	}
+
+	auditHandler := &api_v1_audit.Handler{
+		Reader:    auditLog.Reader(),
+		Log:       auditLog,
+		SecretKey: adminKey,
 	}
 
 	githubDeploymentHandler := &server.GithubDeploymentHandler{
@@ -191,18 +452,65 @@ func run() error {
 		SecretToken:           cfg.Github.WebhookSecret,
 		TeamRepositoryStorage: teamRepositoryStorage,
 		Clusters:              cfg.Clusters,
+		FreezeStorage:         freezeStorage,
+		ApprovalGate:          approvalGate,
+		AuditLog:              auditLog,
 	}
 
 	// Pre-populate request metrics
+	prometheusMiddleware.Initialize("/api/v1/openapi.json", http.MethodGet, http.StatusOK)
 	for _, code := range api_v1_deploy.StatusCodes {
 		prometheusMiddleware.Initialize("/api/v1/deploy", http.MethodPost, code)
 	}
+	for _, code := range api_v1_cancel.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/deploy/{id}/cancel", http.MethodPost, code)
+	}
+	for _, code := range api_v1_logs.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/deploy/{id}/logs", http.MethodPost, code)
+	}
 	for _, code := range api_v1_status.StatusCodes {
 		prometheusMiddleware.Initialize("/api/v1/status", http.MethodPost, code)
 	}
 	for _, code := range api_v1_provision.StatusCodes {
 		prometheusMiddleware.Initialize("/api/v1/provision", http.MethodPost, code)
 	}
+	for _, code := range api_v1_freeze.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/freeze/list", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/freeze/create", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/freeze/delete", http.MethodPost, code)
+	}
+
+	for _, code := range api_v1_approval.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/approval/list", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/approval/approve", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/approval/reject", http.MethodPost, code)
+	}
+
+	for _, code := range api_v1_apikey.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/apikey/list", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/apikey/rotate", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/apikey/revoke", http.MethodPost, code)
+	}
+
+	for _, code := range api_v1_notification.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/notification/list", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/notification/create", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/notification/delete", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/notification/history", http.MethodPost, code)
+	}
+
+	for _, code := range api_v1_dora.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/dora/report", http.MethodPost, code)
+	}
+
+	for _, code := range api_v1_outbox.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/outbox/inspect", http.MethodPost, code)
+		prometheusMiddleware.Initialize("/api/v1/outbox/purge", http.MethodPost, code)
+	}
+
+	for _, code := range api_v1_audit.StatusCodes {
+		prometheusMiddleware.Initialize("/api/v1/audit/query", http.MethodPost, code)
+	}
 
 	// Base settings for all requests
 	router := chi.NewRouter()
@@ -215,28 +523,79 @@ func run() error {
 	// Mount /metrics endpoint with no authentication
 	router.Get(cfg.MetricsPath, promhttp.Handler().ServeHTTP)
 
+	// Liveness and readiness endpoints with no authentication
+	router.Get("/healthz", health.LivenessHandler())
+	router.Get("/readyz", health.ReadinessHandler(healthTimeout, healthCheckers(kafkaClient, apiKeys, teamRepositoryStorage, installationClient)...))
+
+	// The dashboard and the log viewer look up the signed in user and their teams on every page load.
+	deploymentsHandler := &auth.DeploymentsHandler{
+		History:      historyStore,
+		Organization: cfg.Github.Organization,
+		BaseURL:      cfg.BaseURL,
+		Cache:        cache.New("github_users", cfg.Cache.TTL, 0, nil),
+	}
+
 	// Deployment logs accessible via shorthand URL
-	router.HandleFunc("/logs", logproxy.HandleFunc)
+	router.Handle("/logs", &logproxy.Handler{
+		Logs:          logStore,
+		History:       historyStore,
+		Backends:      clusterLogBackends,
+		Default:       defaultLogBackend,
+		Authenticator: deploymentsHandler,
+	})
 
 	// Mount /api/v1 for API requests
 	// Only application/json content type allowed
 	router.Route("/api/v1", func(r chi.Router) {
 		r.Use(
+			middleware.Tracing(),
 			chi_middleware.AllowContentType("application/json"),
-			chi_middleware.Timeout(requestTimeout),
 		)
-		r.Post("/deploy", deploymentHandler.ServeHTTP)
-		r.Post("/status", statusHandler.ServeHTTP)
-		if len(provisionKey) == 0 {
-			log.Error("Refusing to set up team API provisioning endpoint without pre-shared secret; try using --provision-key")
-			log.Error("Note: /api/v1/provision will be unavailable")
-		} else {
-			r.Post("/provision", provisionHandler.ServeHTTP)
-		}
+		// Deploy requests may wait for the results of a dry run, and have a timeout of their own.
+		r.With(chi_middleware.Timeout(cfg.Timeouts.Deploy)).Post("/deploy", deploymentHandler.ServeHTTP)
+		r.Group(func(r chi.Router) {
+			r.Use(chi_middleware.Timeout(requestTimeout))
+			r.Get("/openapi.json", api_v1_openapi.Handler)
+			r.Post("/deploy/{id}/cancel", cancelHandler.ServeHTTP)
+			r.Post("/deploy/{id}/logs", logsHandler.ServeHTTP)
+			r.Post("/status", statusHandler.ServeHTTP)
+			r.Post("/approval/list", approvalHandler.List)
+			r.Post("/approval/approve", approvalHandler.Approve)
+			r.Post("/approval/reject", approvalHandler.Reject)
+			r.Post("/apikey/list", apiKeyHandler.List)
+			r.Post("/apikey/rotate", apiKeyHandler.Rotate)
+			r.Post("/apikey/revoke", apiKeyHandler.Revoke)
+			r.Post("/notification/list", notificationHandler.List)
+			r.Post("/notification/create", notificationHandler.Create)
+			r.Post("/notification/delete", notificationHandler.Delete)
+			r.Post("/notification/history", notificationHandler.ListHistory)
+			r.Post("/dora/report", doraHandler.ServeHTTP)
+			if len(provisionKey) == 0 {
+				log.Error("Refusing to set up team API provisioning endpoint without pre-shared secret; try using --provision-key")
+				log.Error("Note: /api/v1/provision will be unavailable")
+			} else {
+				r.Post("/provision", provisionHandler.ServeHTTP)
+			}
+			if len(adminKey) == 0 {
+				log.Error("Refusing to set up administrative endpoints without pre-shared secret; try using --admin-key")
+				log.Error("Note: /api/v1/freeze, /api/v1/outbox and /api/v1/audit will be unavailable")
+			} else {
+				r.Post("/freeze/list", freezeHandler.List)
+				r.Post("/freeze/create", freezeHandler.Create)
+				r.Post("/freeze/delete", freezeHandler.Delete)
+				r.Post("/outbox/inspect", outboxHandler.Inspect)
+				r.Post("/outbox/purge", outboxHandler.Purge)
+				if auditHandler.Reader == nil {
+					log.Warn("No readable audit sink configured; /api/v1/audit/query will be unavailable")
+				} else {
+					r.Post("/audit/query", auditHandler.Query)
+				}
+			}
+		})
 	})
 
 	// Mount /events for "legacy" GitHub deployment handling
-	router.Post("/events", githubDeploymentHandler.ServeHTTP)
+	router.With(middleware.Tracing()).Post("/events", githubDeploymentHandler.ServeHTTP)
 
 	// "Legacy" user authentication and repository/team connections
 	router.Route("/auth", func(r chi.Router) {
@@ -252,13 +611,29 @@ func run() error {
 		submittedFormHandler := &auth.SubmittedFormHandler{
 			TeamRepositoryStorage: teamRepositoryStorage,
 			ApplicationClient:     installationClient,
+			AuditLog:              auditLog,
+		}
+		approvalsHandler := &auth.ApprovalsHandler{
+			Gate:              approvalGate,
+			ApplicationClient: installationClient,
+			AuditLog:          auditLog,
+		}
+		apiKeysHandler := &auth.ApiKeysHandler{
+			APIKeyStorage: cachedApiKeys,
+			Organization:  cfg.Github.Organization,
+			AuditLog:      auditLog,
 		}
-
 		r.Get("/login", loginHandler.ServeHTTP)
 		r.Get("/logout", logoutHandler.ServeHTTP)
 		r.Get("/callback", callbackHandler.ServeHTTP)
 		r.Get("/form", formHandler.ServeHTTP)
 		r.Post("/submit", submittedFormHandler.ServeHTTP)
+		r.Get("/approvals", approvalsHandler.ServeHTTP)
+		r.Post("/approvals", approvalsHandler.ServeHTTP)
+		r.Get("/apikeys", apiKeysHandler.ServeHTTP)
+		r.Post("/apikeys", apiKeysHandler.ServeHTTP)
+		r.Get("/deployments", deploymentsHandler.List)
+		r.Get("/deployments/{id}", deploymentsHandler.Get)
 
 	})
 
@@ -280,9 +655,14 @@ func run() error {
 	)
 	router.Handle("/assets/*", staticHandler)
 
+	httpServer := &http.Server{
+		Addr:    cfg.ListenAddress,
+		Handler: router,
+	}
+
 	go func() {
-		err := http.ListenAndServe(cfg.ListenAddress, router)
-		if err != nil {
+		err := httpServer.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
 			log.Error(err)
 		}
 	}()
@@ -290,7 +670,55 @@ func run() error {
 	log.Infof("Ready to accept connections")
 
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	stop := make(chan struct{})
+	runners := &sync.WaitGroup{}
+
+	runners.Add(1)
+	go func() {
+		defer runners.Done()
+		requestOutbox.Run(publishRequest(kafkaClient, encryptionKey, statusChan), stop)
+	}()
+	runners.Add(1)
+	go func() {
+		defer runners.Done()
+		notifier.Run(stop)
+	}()
+	dispatcher.Start(stop)
+
+	queueRequest := func(req deployment.DeploymentRequest) {
+		logger := log.WithFields(req.LogFields())
+
+		// Cancellations are queued behind the deployment they cancel.
+		key := req.GetDeliveryID()
+		if req.GetCancellation() != nil {
+			key = req.GetCancellation().GetDeliveryID()
+		}
+
+		payload, err := proto.Marshal(&req)
+		if err == nil {
+			err = requestOutbox.Push(key, req.GetDeliveryID(), payload)
+		}
+
+		metrics.DeploymentRequestQueueSize.Set(float64(requestOutbox.Len()))
+
+		if err != nil {
+			logger.Errorf("Unable to queue deployment request: %s", err)
+			if req.ReportsStatus() {
+				statusChan <- *deployment.NewErrorStatus(req, fmt.Errorf("unable to queue deployment request: %s", err))
+			}
+		}
+	}
+
+	queueStatus := func(status deployment.DeploymentStatus) {
+		metrics.GithubStatusQueueSize.Set(float64(len(statusChan)))
+		metrics.UpdateQueue(status)
+
+		dispatcher.Dispatch(status)
+	}
+
+	var sig os.Signal
 
 	// Three loops:
 	//
@@ -298,11 +726,17 @@ func run() error {
 	//      deployment status queue.
 	//
 	//   2) Process the deployment request queue.
-	//      Requests are put on the Kafka queue. Failed messages are put on the queue again.
+	//      Requests are put in a persistent outbox, which publishes them to Kafka and retries failed messages.
 	//
 	//   3) Process the deployment status queue.
-	//      Statuses are posted to Github. Failed messages are put on the queue again.
+	//      Statuses are put in the persistent outbox of each status reporter.
+	//
+	// Deployment logs past their retention are pruned every hour.
 	//
+	pruneTicker := time.NewTicker(logPruneInterval)
+	defer pruneTicker.Stop()
+
+LOOP:
 	for {
 		select {
 		case m := <-kafkaClient.RecvQ:
@@ -318,6 +752,18 @@ func run() error {
 				continue
 			}
 
+			// Log lines are stored as they come; they are not deployment statuses.
+			if m.Topic == cfg.Kafka.LogTopic {
+				event := deployment.LogEvent{}
+				if err := proto.Unmarshal(payload, &event); err != nil {
+					logger.Errorf("Discarding incoming log event: %s", err)
+				} else if err := logStore.Add(event.GetDeliveryID(), logstore.EventFromMessage(event)); err != nil {
+					logger.Errorf("Unable to store log event of deployment %s: %s", event.GetDeliveryID(), err)
+				}
+				kafkaClient.Consumer.MarkOffset(&m, "")
+				continue
+			}
+
 			err = proto.Unmarshal(payload, &status)
 			if err != nil {
 				logger.Errorf("Discarding incoming message: %s", err)
@@ -325,90 +771,461 @@ func run() error {
 				continue
 			}
 
+			receivedStatus(status, m.Topic)
+
+			// Dry run results go straight to the API request waiting for them.
+			if status.GetDryRun() {
+				if !dryRuns.Deliver(status) {
+					logger.WithFields(status.LogFields()).Warnf("Discarding dry run result; nobody is waiting for it")
+				}
+				kafkaClient.Consumer.MarkOffset(&m, "")
+				continue
+			}
+
 			statusChan <- status
 			kafkaClient.Consumer.MarkOffset(&m, "")
 
 		case req := <-requestChan:
-			metrics.DeploymentRequestQueueSize.Set(float64(len(requestChan)))
+			queueRequest(req)
+
+		case status := <-statusChan:
+			queueStatus(status)
+
+		case <-pruneTicker.C:
+			pruneLogs()
 
-			logger := log.WithFields(req.LogFields())
+		case sig = <-signals:
+			break LOOP
+		}
+	}
+
+	log.Infof("Received %s; shutting down", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections, and wait for in-flight API requests and webhooks.
+	// Requests and statuses they produce are queued below.
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- httpServer.Shutdown(ctx)
+	}()
 
-			payload, err := proto.Marshal(&req)
+	// Stop consuming deployment statuses. Messages that have not been
+	// marked as processed are consumed again on the next startup.
+	if err := kafkaClient.Consumer.Close(); err != nil {
+		log.Errorf("While closing Kafka consumer: %s", err)
+	}
+
+	// Stop the outbox runners after their current item. Anything left in the
+	// outboxes is persisted to disk, and picked up again on the next startup.
+	close(stop)
+	stopped := make(chan struct{})
+	go func() {
+		runners.Wait()
+		dispatcher.Wait()
+		close(stopped)
+	}()
+
+	// Keep queueing requests and statuses while the HTTP server and outbox runners wind down.
+DRAIN:
+	for {
+		select {
+		case err := <-shutdownErr:
 			if err != nil {
-				logger.Errorf("Marshal JSON for Kafka message: %s", err)
-				continue
+				log.Errorf("While shutting down HTTP server: %s", err)
+			}
+			shutdownErr = nil
+
+		case <-stopped:
+			stopped = nil
+
+		case req := <-requestChan:
+			queueRequest(req)
+
+		case status := <-statusChan:
+			queueStatus(status)
+
+		case <-ctx.Done():
+			log.Warnf("Shutdown did not complete within %s", cfg.ShutdownTimeout)
+			break DRAIN
+		}
+
+		if shutdownErr == nil && stopped == nil {
+			break DRAIN
+		}
+	}
+
+	for len(requestChan) > 0 {
+		queueRequest(<-requestChan)
+	}
+	for len(statusChan) > 0 {
+		queueStatus(<-statusChan)
+	}
+
+	if err := kafkaClient.Close(); err != nil {
+		return err
+	}
+
+	log.Infof("Shutdown complete")
+
+	return nil
+}
+
+func publishRequest(kafkaClient *kafka.DualClient, encryptionKey []byte, statusChan chan<- deployment.DeploymentStatus) outbox.Handler {
+	return func(payload []byte) (err error) {
+		req := deployment.DeploymentRequest{}
+		if err := proto.Unmarshal(payload, &req); err != nil {
+			return outbox.Permanent(fmt.Errorf("decode deployment request: %s", err))
+		}
+
+		// deployd continues the trace as a child of the publishing span.
+		ctx := tracing.ContextWithTraceparent(context.Background(), req.GetTraceparent())
+		_, span := tracing.Start(ctx, "kafka.publish", tracing.KindProducer)
+		span.SetAttribute("messaging.destination", kafkaClient.ProducerTopic)
+		span.SetAttribute(deployment.LogFieldDeliveryID, req.GetDeliveryID())
+		defer func() {
+			span.SetError(err)
+			span.Finish()
+		}()
+
+		req.Traceparent = span.Traceparent()
+		payload, err = proto.Marshal(&req)
+		if err != nil {
+			return outbox.Permanent(fmt.Errorf("encode deployment request: %s", err))
+		}
+
+		logger := log.WithFields(req.LogFields())
+
+		if time.Now().Unix() > req.GetDeadline() {
+			err = fmt.Errorf("deployment request expired before it could be published to Kafka")
+			if req.ReportsStatus() {
+				statusChan <- *deployment.NewErrorStatus(req, err)
 			}
+			return outbox.Permanent(err)
+		}
+
+		ciphertext, err := crypto.Encrypt(payload, encryptionKey)
+		if err != nil {
+			return outbox.Permanent(fmt.Errorf("unable to encrypt Kafka message: %s", err))
+		}
+
+		msg := sarama.ProducerMessage{
+			Topic:     kafkaClient.ProducerTopic,
+			Value:     sarama.StringEncoder(ciphertext),
+			Timestamp: time.Unix(req.GetTimestamp(), 0),
+		}
+
+		_, _, err = kafkaClient.Producer.SendMessage(&msg)
+		if err != nil {
+			return fmt.Errorf("publishing message to Kafka: %s", err)
+		}
+
+		metrics.Dispatched.Inc()
+
+		// Cancellations and dry runs do not have a deployment status of their own.
+		switch {
+		case req.GetCancellation() != nil:
+			logger.Info("Cancellation request published to Kafka")
+			return nil
+		case req.GetDryRun():
+			logger.Info("Dry run request published to Kafka")
+			return nil
+		}
 
-			ciphertext, err := crypto.Encrypt(payload, encryptionKey)
+		logger.Info("Deployment request published to Kafka")
+		statusChan <- *deployment.NewQueuedStatus(req)
+
+		return nil
+	}
+}
+
+// receivedStatus records the arrival of a deployment status from deployd in the trace of its deployment request.
+func receivedStatus(status deployment.DeploymentStatus, topic string) {
+	if len(status.GetTraceparent()) == 0 {
+		return
+	}
+	ctx := tracing.ContextWithTraceparent(context.Background(), status.GetTraceparent())
+	_, span := tracing.Start(ctx, "kafka.receive", tracing.KindConsumer)
+	span.SetAttribute("messaging.destination", topic)
+	span.SetAttribute(deployment.LogFieldDeliveryID, status.GetDeliveryID())
+	span.SetAttribute(deployment.LogFieldDeploymentStatusType, status.GetState().String())
+	span.Finish()
+}
+
+// Default retry policies for status reporters. Statuses are retried for a long time
+// against GitHub, because a missing final status leaves the deployment hanging.
+var retryPolicies = map[string]outbox.Policy{
+	"github":          {MaxAttempts: 50, Backoff: retryInterval},
+	"github-inactive": {MaxAttempts: 10, Backoff: retryInterval},
+	"history":         {MaxAttempts: 3, Backoff: time.Second},
+	"notification":    {MaxAttempts: 1},
+	"file":            {MaxAttempts: 3, Backoff: time.Second},
+	"http":            {MaxAttempts: 10, Backoff: retryInterval},
+}
+
+// apiKeyStorage sets up the configured API key storage backend.
+func apiKeyStorage() (persistence.ApiKeyStorage, error) {
+	switch cfg.ApiKeys.Backend {
+	case persistence.ApiKeyBackendVault, persistence.ApiKeyBackendVaultKV2:
+		vault := persistence.VaultApiKeyStorage{
+			Address:    cfg.Vault.Address,
+			Path:       cfg.Vault.Path,
+			AuthPath:   cfg.Vault.AuthPath,
+			AuthRole:   cfg.Vault.AuthRole,
+			KeyName:    cfg.Vault.KeyName,
+			Token:      cfg.Vault.Token,
+			HttpClient: http.DefaultClient,
+		}
+
+		if len(cfg.Vault.CredentialsFile) > 0 {
+			credentials, err := ioutil.ReadFile(cfg.Vault.CredentialsFile)
 			if err != nil {
-				logger.Errorf("Unable to encrypt Kafka message: %s", err)
-				continue
+				return nil, fmt.Errorf("read Vault token file: %s", err)
 			}
+			vault.Credentials = string(credentials)
+			vault.Token = ""
+		}
 
-			msg := sarama.ProducerMessage{
-				Topic:     kafkaClient.ProducerTopic,
-				Value:     sarama.StringEncoder(ciphertext),
-				Timestamp: time.Unix(req.GetTimestamp(), 0),
+		if cfg.ApiKeys.Backend == persistence.ApiKeyBackendVault {
+			if len(vault.Credentials) > 0 {
+				go vault.RefreshLoop()
 			}
+			return &vault, nil
+		}
 
-			_, _, err = kafkaClient.Producer.SendMessage(&msg)
-			if err == nil {
-				metrics.Dispatched.Inc()
-				logger.Info("Deployment request published to Kafka")
-				st := deployment.NewQueuedStatus(req)
-				statusChan <- *st
-				continue
+		kv2 := &persistence.VaultKV2ApiKeyStorage{VaultApiKeyStorage: vault}
+		if len(kv2.Credentials) > 0 {
+			go kv2.RefreshLoop()
+		}
+		return kv2, nil
+
+	case persistence.ApiKeyBackendKubernetes:
+		return persistence.NewKubernetesApiKeyStorage(cfg.ApiKeys.Namespace, cfg.Vault.KeyName)
+
+	case persistence.ApiKeyBackendFile:
+		path := cfg.ApiKeys.File
+		if len(path) == 0 {
+			path = filepath.Join(cfg.DataDir, "apikeys.json")
+		}
+		return persistence.NewFileApiKeyStorage(path)
+
+	default:
+		return nil, fmt.Errorf("unknown API key storage backend '%s'", cfg.ApiKeys.Backend)
+	}
+}
+
+// newAuditLog sets up the audit log with the configured sinks.
+func newAuditLog(kafkaClient *kafka.DualClient) (*audit.Log, error) {
+	sinks := make([]audit.Sink, 0, len(cfg.Audit.Sinks))
+	for _, name := range cfg.Audit.Sinks {
+		switch name {
+		case audit.SinkFile:
+			path := cfg.Audit.File
+			if len(path) == 0 {
+				path = filepath.Join(cfg.DataDir, "audit.log")
 			}
+			sinks = append(sinks, audit.NewFileSink(path))
+		case audit.SinkKafka:
+			sinks = append(sinks, &audit.KafkaSink{
+				Producer: kafkaClient.Producer,
+				Topic:    cfg.Audit.KafkaTopic,
+			})
+		default:
+			return nil, fmt.Errorf("unknown audit sink '%s'", name)
+		}
+	}
 
-			logger.Errorf("Publishing message to Kafka: %s", err)
-			go func() {
-				logger.Tracef("Retrying in %.0f seconds", retryInterval.Seconds())
-				time.Sleep(retryInterval)
-				requestChan <- req
-				logger.Tracef("Request resubmitted to queue")
-			}()
+	key, err := hex.DecodeString(cfg.Audit.Key)
+	if err != nil {
+		return nil, fmt.Errorf("audit key must be a hex encoded string")
+	}
+	if len(sinks) > 0 && len(key) == 0 {
+		log.Warn("No --audit-key configured; anyone who can write to the audit log can rewrite its hash chain undetected")
+	}
 
-		case status := <-statusChan:
-			metrics.GithubStatusQueueSize.Set(float64(len(statusChan)))
-			metrics.UpdateQueue(status)
+	auditLog, err := audit.New(key, sinks...)
+	if err != nil {
+		return nil, err
+	}
+
+	if sequence, hash := auditLog.Head(); sequence > 0 {
+		log.Infof("Audit log continues from record %d with hash %s", sequence, hash)
+	}
+
+	return auditLog, nil
+}
+
+// healthCheckers returns readiness checks for all external dependencies.
+func healthCheckers(kafkaClient *kafka.DualClient, apiKeys persistence.ApiKeyStorage, teamRepositoryStorage persistence.TeamRepositoryStorage, installationClient *gh.Client) []health.Checker {
+	checkers := []health.Checker{
+		health.CheckFunc("kafka-consumer", kafkaClient.PingConsumer),
+		health.CheckFunc("kafka-producer", kafkaClient.PingProducer),
+	}
+
+	if pinger, ok := apiKeys.(health.Pinger); ok {
+		checkers = append(checkers, health.NewChecker(cfg.ApiKeys.Backend, pinger))
+	}
+
+	if pinger, ok := teamRepositoryStorage.(health.Pinger); ok {
+		checkers = append(checkers, health.NewChecker(cfg.TeamRepositories.Backend, pinger))
+	}
+
+	if installationClient != nil {
+		checkers = append(checkers, health.CheckFunc("github", func(ctx context.Context) error {
+			return github.CheckInstallation(ctx, installationClient)
+		}))
+	}
+
+	return checkers
+}
+
+// logBackend returns the log backend with the given name. The built-in log viewer has no backend.
+func logBackend(name string) (logproxy.Backend, error) {
+	switch name {
+	case "builtin":
+		return nil, nil
+	case "kibana":
+		return &logproxy.Kibana{
+			BaseURL: cfg.Logs.KibanaURL,
+			Index:   cfg.Logs.KibanaIndex,
+		}, nil
+	case "loki":
+		if len(cfg.Logs.LokiURL) == 0 {
+			return nil, fmt.Errorf("--log-loki-url must be specified when using the 'loki' log backend")
+		}
+		return &logproxy.Loki{
+			BaseURL:    cfg.Logs.LokiURL,
+			Datasource: cfg.Logs.LokiDatasource,
+			Selector:   cfg.Logs.LokiSelector,
+		}, nil
+	case "template":
+		return logproxy.NewTemplate(cfg.Logs.URLTemplate)
+	default:
+		return nil, fmt.Errorf("unknown log backend '%s'", name)
+	}
+}
+
+// logBackends returns the default log backend, and the log backend of each cluster that overrides it.
+func logBackends() (logproxy.Backend, map[string]logproxy.Backend, error) {
+	defaultBackend, err := logBackend(cfg.Logs.Backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Infof("Log backend: %s", cfg.Logs.Backend)
+
+	backends := make(map[string]logproxy.Backend)
+	for cluster, name := range cfg.Logs.ClusterBackend {
+		backends[cluster], err = logBackend(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("log backend for cluster '%s': %s", cluster, err)
+		}
+		log.Infof("Log backend for %s: %s", cluster, name)
+	}
+
+	return defaultBackend, backends, nil
+}
+
+func statusReporters(installationClient *gh.Client, historyStore history.Store, notifier *notification.Notifier) (*reporter.Dispatcher, error) {
+	dispatcher := &reporter.Dispatcher{}
+
+	// Cancellation, logs and DORA metrics look deployments up in the history, so it is always recorded.
+	enabled := cfg.Reporters.Enabled
+	if !contains(enabled, "history") {
+		log.Infof("Enabling the 'history' status reporter, which is required by the deployment API")
+		enabled = append(enabled, "history")
+	}
 
-			logger := log.WithFields(status.LogFields())
+	for _, name := range enabled {
+		var r reporter.StatusReporter
 
+		switch name {
+		case "github":
 			if !cfg.Github.Enabled {
-				logger.Warn("Process deployment status: discarding message due to GitHub being disabled")
-				metrics.DeploymentStatus(status, 0)
+				log.Warn("Not reporting deployment statuses to GitHub because GitHub integration is not enabled")
 				continue
 			}
-
-			ghs, req, err := github.CreateDeploymentStatus(installationClient, &status, cfg.BaseURL)
-			metrics.DeploymentStatus(status, req.StatusCode)
-
-			if err == nil {
-				logger = logger.WithFields(log.Fields{
-					deployment.LogFieldDeploymentStatusID: ghs.GetID(),
-				})
-				logger.Infof("Published deployment status to GitHub: %s", status.GetDescription())
+			r = &reporter.GithubReporter{
+				Client:  installationClient,
+				BaseURL: cfg.BaseURL,
+			}
+		case "github-inactive":
+			if !cfg.Github.Enabled {
+				log.Warn("Not marking previous GitHub deployments as inactive because GitHub integration is not enabled")
 				continue
 			}
+			r = &reporter.GithubInactiveReporter{
+				Client: installationClient,
+			}
+		case "history":
+			r = &reporter.HistoryReporter{
+				Store: historyStore,
+			}
+		case "notification":
+			r = &reporter.NotificationReporter{
+				Notifier: notifier,
+			}
+		case "file":
+			r = &reporter.FileReporter{
+				Path: cfg.Reporters.FilePath,
+			}
+		case "http":
+			if len(cfg.Reporters.HTTPURL) == 0 {
+				return nil, fmt.Errorf("--status-http-url must be specified when using the 'http' status reporter")
+			}
+			r = &reporter.HTTPReporter{
+				URL:     cfg.Reporters.HTTPURL,
+				BaseURL: cfg.BaseURL,
+				Client:  &http.Client{Timeout: requestTimeout},
+			}
+		default:
+			return nil, fmt.Errorf("unknown status reporter '%s'", name)
+		}
 
-			logger.Errorf("Sending deployment status to Github: %s", err)
-
-			if err == github.ErrEmptyRepository || err == github.ErrEmptyDeployment {
-				logger.Tracef("Error is non-retriable; giving up")
-				continue
+		policy := retryPolicies[name]
+		if attempts, ok := cfg.Reporters.MaxAttempts[name]; ok {
+			policy.MaxAttempts = attempts
+		}
+		if interval, ok := cfg.Reporters.RetryInterval[name]; ok {
+			duration, err := time.ParseDuration(interval)
+			if err != nil {
+				return nil, fmt.Errorf("retry interval for status reporter '%s': %s", name, err)
 			}
+			policy.Backoff = duration
+		}
+		policy.MaxBackoff = cfg.Outbox.MaxBackoff
 
-			go func() {
-				logger.Tracef("Retrying in %.0f seconds", retryInterval.Seconds())
-				time.Sleep(retryInterval)
-				statusChan <- status
-				logger.Tracef("Deployment status resubmitted to queue")
-			}()
+		box, err := outbox.New(filepath.Join(cfg.DataDir, "outbox-"+name+".json"), name, cfg.Outbox.Capacity, policy)
+		if err != nil {
+			return nil, fmt.Errorf("while loading outbox for status reporter '%s': %s", name, err)
+		}
 
-		case <-signals:
-			return nil
+		dispatcher.Reporters = append(dispatcher.Reporters, reporter.New(r, box))
+	}
+
+	return dispatcher, nil
+}
+
+// timeouts sets up the limits on rollout timeouts and time to live of deployment requests.
+func timeouts() (api_v1.Timeouts, error) {
+	t := api_v1.Timeouts{
+		Bounds: api_v1.TimeoutBounds{
+			Min: cfg.Timeouts.RolloutMin,
+			Max: cfg.Timeouts.RolloutMax,
+		},
+		Clusters: make(map[string]api_v1.TimeoutBounds),
+		MaxTTL:   cfg.Timeouts.MaxTTL,
+	}
+
+	for cluster, str := range cfg.Timeouts.RolloutBounds {
+		bounds, err := api_v1.ParseTimeoutBounds(str)
+		if err != nil {
+			return t, fmt.Errorf("rollout timeout bounds for cluster '%s': %s", cluster, err)
 		}
+		t.Clusters[cluster] = bounds
 	}
+
+	return t, nil
 }
 
 func main() {
@@ -418,3 +1235,12 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
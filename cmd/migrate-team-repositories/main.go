@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/config"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+var cfg = config.DefaultConfig()
+
+var help = `
+migrate-team-repositories copies all repository team access mappings
+from the S3 bucket used by hookd into another team repository backend.
+`
+
+func init() {
+	flag.ErrHelp = fmt.Errorf(help)
+
+	flag.StringVar(&cfg.TeamRepositories.Backend, "team-repository-backend", "sqlite", "Destination backend; one of 'file' or 'sqlite'.")
+	flag.StringVar(&cfg.TeamRepositories.Path, "team-repository-path", cfg.TeamRepositories.Path, "Directory or database file used by the destination backend. Defaults to a location in --data-dir.")
+	flag.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "Directory where hookd keeps its local state.")
+
+	flag.StringVar(&cfg.S3.Endpoint, "s3-endpoint", cfg.S3.Endpoint, "S3 endpoint to migrate from.")
+	flag.StringVar(&cfg.S3.AccessKey, "s3-access-key", cfg.S3.AccessKey, "S3 access key.")
+	flag.StringVar(&cfg.S3.SecretKey, "s3-secret-key", cfg.S3.SecretKey, "S3 secret key.")
+	flag.StringVar(&cfg.S3.BucketName, "s3-bucket-name", cfg.S3.BucketName, "S3 bucket name.")
+	flag.StringVar(&cfg.S3.BucketLocation, "s3-bucket-location", cfg.S3.BucketLocation, "S3 bucket location.")
+	flag.BoolVar(&cfg.S3.UseTLS, "s3-secure", cfg.S3.UseTLS, "Use TLS for S3 connections.")
+
+	flag.Parse()
+
+	log.SetOutput(os.Stderr)
+
+	log.SetFormatter(&log.TextFormatter{
+		FullTimestamp:          true,
+		TimestampFormat:        time.RFC3339Nano,
+		DisableLevelTruncation: true,
+	})
+}
+
+func run() error {
+	switch cfg.TeamRepositories.Backend {
+	case persistence.TeamRepositoryBackendFile, persistence.TeamRepositoryBackendSQLite:
+	default:
+		return fmt.Errorf("cannot migrate into the '%s' backend; use either 'file' or 'sqlite'", cfg.TeamRepositories.Backend)
+	}
+
+	source, err := persistence.NewS3StorageBackend(cfg.S3)
+	if err != nil {
+		return fmt.Errorf("while setting up S3 backend: %s", err)
+	}
+
+	destination, err := persistence.NewTeamRepositoryStorage(cfg.TeamRepositories, cfg.S3, cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("while setting up destination backend: %s", err)
+	}
+
+	log.Infof("Migrating team repositories from S3 bucket '%s' at %s into the '%s' backend...", cfg.S3.BucketName, cfg.S3.Endpoint, cfg.TeamRepositories.Backend)
+
+	copied, err := persistence.MigrateTeamRepositories(source, destination)
+	if err != nil {
+		return fmt.Errorf("migration stopped after %d repositories: %s", copied, err)
+	}
+
+	log.Infof("Successfully migrated %d repositories", copied)
+
+	return nil
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		log.Errorf("fatal: %s", err)
+		os.Exit(1)
+	}
+}
@@ -1,18 +1,14 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"net/url"
 	"os"
 	"time"
 
 	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/client"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/provision"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
@@ -32,8 +28,7 @@ provision provisions team API keys.
 `
 
 const (
-	provisionAPIPath = "/api/v1/provision"
-	defaultServer    = "https://deployment.prod-sbs.nais.io"
+	defaultServer = "https://deployment.prod-sbs.nais.io"
 )
 
 type ExitCode int
@@ -68,76 +63,35 @@ func init() {
 	})
 }
 
-func mkpayload(w io.Writer) error {
-	req := api_v1_provision.Request{
-		Team:      cfg.Team,
-		Rotate:    cfg.Rotate,
-		Timestamp: api_v1.Timestamp(time.Now().Unix()),
-	}
-
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-
-	return enc.Encode(req)
-}
-
-func sign(data, key []byte) string {
-	return hex.EncodeToString(api_v1.GenMAC(data, key))
-}
-
 func run() (ExitCode, error) {
-	var err error
-
-	targetURL, err := url.Parse(cfg.ServerURL)
-	if err != nil {
-		return ExitFailure, fmt.Errorf("wrong format of base URL: %s", err)
-	}
-	targetURL.Path = provisionAPIPath
-
-	data := make([]byte, 0)
-	buf := bytes.NewBuffer(data)
-
-	err = mkpayload(buf)
-	if err != nil {
-		return ExitFailure, err
-	}
-
 	decoded, err := hex.DecodeString(cfg.Secret)
 	if err != nil {
 		return ExitFailure, fmt.Errorf("API key must be a hex encoded string: %s", err)
 	}
-	sig := sign(buf.Bytes(), decoded)
 
-	req, err := http.NewRequest(http.MethodPost, targetURL.String(), buf)
+	client, err := api_v1_client.New(cfg.ServerURL, nil)
 	if err != nil {
-		return ExitFailure, fmt.Errorf("internal error creating http request: %v", err)
+		return ExitFailure, fmt.Errorf("wrong format of base URL: %s", err)
 	}
 
-	req.Header.Add("content-type", "application/json")
-	req.Header.Add(api_v1.SignatureHeader, fmt.Sprintf("%s", sig))
+	req := api_v1_provision.Request{
+		Team:      cfg.Team,
+		Rotate:    cfg.Rotate,
+		Timestamp: api_v1.Timestamp(time.Now().Unix()),
+	}
 
-	log.Infof("Submitting provision request to %s...", targetURL.String())
-	client := http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
+	log.Infof("Submitting provision request to %s...", cfg.ServerURL)
+	response, resp, err := client.Provision(context.Background(), decoded, req)
+	if resp == nil {
 		return ExitFailure, err
 	}
 
-	data, _ = ioutil.ReadAll(resp.Body)
-	response := &api_v1_provision.Response{}
-	err = json.Unmarshal(data, response)
 	if err != nil {
-		response.Message = string(data)
-	}
-
-	msg := fmt.Sprintf("%s: %s", resp.Status, response.Message)
-
-	if resp.StatusCode >= 400 {
-		log.Error(msg)
+		log.Error(err)
 		return ExitFailure, nil
 	}
 
-	log.Info(msg)
+	log.Infof("%s: %s", resp.Status, response.Message)
 	return ExitSuccess, nil
 }
 
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/config"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+var cfg = config.DefaultConfig()
+
+var anchor string
+
+var help = `
+verify-audit-log checks that an audit log written by hookd has not been tampered with.
+Every record's hash is recomputed with the audit key, and each record must refer to the hash of the record before it.
+Records removed from the end of the log are detected by passing the hash of a record known to be in the log as --anchor,
+such as the hash hookd logs on startup.
+Records consumed from the audit Kafka topic can be verified by writing them to a file, one JSON object per line.
+`
+
+func init() {
+	flag.ErrHelp = fmt.Errorf(help)
+
+	flag.StringVar(&cfg.Audit.File, "audit-file", cfg.Audit.File, "Audit log file to verify. Defaults to a location in --data-dir.")
+	flag.StringVar(&cfg.Audit.Key, "audit-key", cfg.Audit.Key, "Hex encoded secret key the audit log was written with.")
+	flag.StringVar(&anchor, "anchor", anchor, "Hash of a record that must be present in the audit log.")
+	flag.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "Directory where hookd keeps its local state.")
+
+	flag.Parse()
+
+	log.SetOutput(os.Stderr)
+
+	log.SetFormatter(&log.TextFormatter{
+		FullTimestamp:          true,
+		TimestampFormat:        time.RFC3339Nano,
+		DisableLevelTruncation: true,
+	})
+}
+
+func run() error {
+	path := cfg.Audit.File
+	if len(path) == 0 {
+		path = filepath.Join(cfg.DataDir, "audit.log")
+	}
+
+	key, err := hex.DecodeString(cfg.Audit.Key)
+	if err != nil {
+		return fmt.Errorf("audit key must be a hex encoded string")
+	}
+	if len(key) == 0 {
+		log.Warn("No --audit-key given; only logs written without a key can be verified, and rewritten chains are not detected")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	records, err := audit.NewFileSink(path).Records()
+	if err != nil {
+		return err
+	}
+
+	err = audit.Verify(records, key)
+	if err == nil && len(anchor) > 0 {
+		err = audit.VerifyAnchor(records, anchor)
+	}
+	if err != nil {
+		return fmt.Errorf("audit log '%s' failed verification: %s", path, err)
+	}
+
+	if len(records) == 0 {
+		log.Infof("Audit log '%s' is empty", path)
+		return nil
+	}
+
+	last := records[len(records)-1]
+	log.Infof("Verified %d audit records in '%s'; last record written %s with hash %s", len(records), path, last.Time.Format(time.RFC3339), last.Hash)
+
+	return nil
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		log.Errorf("fatal: %s", err)
+		os.Exit(1)
+	}
+}
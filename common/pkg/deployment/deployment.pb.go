@@ -249,23 +249,95 @@ func (m *Payload) GetKubernetes() *Kubernetes {
 	return nil
 }
 
+// Cancellation stops the rollout of a running deployment.
+type Cancellation struct {
+	// Delivery ID of the deployment to cancel.
+	DeliveryID string `protobuf:"bytes,1,opt,name=deliveryID,proto3" json:"deliveryID,omitempty"`
+	// User who cancelled the deployment.
+	User string `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	// Re-apply the previous version of the deployed resources.
+	Rollback             bool     `protobuf:"varint,3,opt,name=rollback,proto3" json:"rollback,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Cancellation) Reset()         { *m = Cancellation{} }
+func (m *Cancellation) String() string { return proto.CompactTextString(m) }
+func (*Cancellation) ProtoMessage()    {}
+func (*Cancellation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fac0ec10f8e4d7ff, []int{4}
+}
+
+func (m *Cancellation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Cancellation.Unmarshal(m, b)
+}
+func (m *Cancellation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Cancellation.Marshal(b, m, deterministic)
+}
+func (m *Cancellation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Cancellation.Merge(m, src)
+}
+func (m *Cancellation) XXX_Size() int {
+	return xxx_messageInfo_Cancellation.Size(m)
+}
+func (m *Cancellation) XXX_DiscardUnknown() {
+	xxx_messageInfo_Cancellation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Cancellation proto.InternalMessageInfo
+
+func (m *Cancellation) GetDeliveryID() string {
+	if m != nil {
+		return m.DeliveryID
+	}
+	return ""
+}
+
+func (m *Cancellation) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Cancellation) GetRollback() bool {
+	if m != nil {
+		return m.Rollback
+	}
+	return false
+}
+
 type DeploymentRequest struct {
-	Deployment           *DeploymentSpec `protobuf:"bytes,1,opt,name=deployment,proto3" json:"deployment,omitempty"`
-	Timestamp            int64           `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Deadline             int64           `protobuf:"varint,3,opt,name=deadline,proto3" json:"deadline,omitempty"`
-	Cluster              string          `protobuf:"bytes,5,opt,name=cluster,proto3" json:"cluster,omitempty"`
-	DeliveryID           string          `protobuf:"bytes,6,opt,name=deliveryID,proto3" json:"deliveryID,omitempty"`
-	PayloadSpec          *Payload        `protobuf:"bytes,7,opt,name=payloadSpec,proto3" json:"payloadSpec,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	Deployment  *DeploymentSpec `protobuf:"bytes,1,opt,name=deployment,proto3" json:"deployment,omitempty"`
+	Timestamp   int64           `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Deadline    int64           `protobuf:"varint,3,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	Cluster     string          `protobuf:"bytes,5,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	DeliveryID  string          `protobuf:"bytes,6,opt,name=deliveryID,proto3" json:"deliveryID,omitempty"`
+	PayloadSpec *Payload        `protobuf:"bytes,7,opt,name=payloadSpec,proto3" json:"payloadSpec,omitempty"`
+	// If set, the request cancels another deployment, and carries no payload.
+	Cancellation *Cancellation `protobuf:"bytes,8,opt,name=cancellation,proto3" json:"cancellation,omitempty"`
+	// Seconds to wait for the rollout to complete; zero means the deployd default.
+	RolloutTimeout int64 `protobuf:"varint,9,opt,name=rolloutTimeout,proto3" json:"rolloutTimeout,omitempty"`
+	// Submit resources to Kubernetes without persisting them.
+	DryRun bool `protobuf:"varint,10,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
+	// Include a diff against the running version in the dry run results.
+	Diff bool `protobuf:"varint,11,opt,name=diff,proto3" json:"diff,omitempty"`
+	// W3C trace context of the request.
+	Traceparent string `protobuf:"bytes,12,opt,name=traceparent,proto3" json:"traceparent,omitempty"`
+	// Monitor the rollout of resources that another deployd instance has already applied,
+	// without applying them again. Set when a deployd hands over monitoring on shutdown.
+	Resume               bool     `protobuf:"varint,13,opt,name=resume,proto3" json:"resume,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *DeploymentRequest) Reset()         { *m = DeploymentRequest{} }
 func (m *DeploymentRequest) String() string { return proto.CompactTextString(m) }
 func (*DeploymentRequest) ProtoMessage()    {}
 func (*DeploymentRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_fac0ec10f8e4d7ff, []int{4}
+	return fileDescriptor_fac0ec10f8e4d7ff, []int{5}
 }
 
 func (m *DeploymentRequest) XXX_Unmarshal(b []byte) error {
@@ -328,24 +400,70 @@ func (m *DeploymentRequest) GetPayloadSpec() *Payload {
 	return nil
 }
 
+func (m *DeploymentRequest) GetCancellation() *Cancellation {
+	if m != nil {
+		return m.Cancellation
+	}
+	return nil
+}
+
+func (m *DeploymentRequest) GetRolloutTimeout() int64 {
+	if m != nil {
+		return m.RolloutTimeout
+	}
+	return 0
+}
+
+func (m *DeploymentRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+func (m *DeploymentRequest) GetDiff() bool {
+	if m != nil {
+		return m.Diff
+	}
+	return false
+}
+
+func (m *DeploymentRequest) GetTraceparent() string {
+	if m != nil {
+		return m.Traceparent
+	}
+	return ""
+}
+
+func (m *DeploymentRequest) GetResume() bool {
+	if m != nil {
+		return m.Resume
+	}
+	return false
+}
+
 type DeploymentStatus struct {
-	Deployment           *DeploymentSpec       `protobuf:"bytes,1,opt,name=deployment,proto3" json:"deployment,omitempty"`
-	State                GithubDeploymentState `protobuf:"varint,2,opt,name=state,proto3,enum=deployment.GithubDeploymentState" json:"state,omitempty"`
-	Description          string                `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	DeliveryID           string                `protobuf:"bytes,4,opt,name=deliveryID,proto3" json:"deliveryID,omitempty"`
-	Team                 string                `protobuf:"bytes,5,opt,name=team,proto3" json:"team,omitempty"`
-	Cluster              string                `protobuf:"bytes,6,opt,name=cluster,proto3" json:"cluster,omitempty"`
-	Timestamp            int64                 `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
-	XXX_unrecognized     []byte                `json:"-"`
-	XXX_sizecache        int32                 `json:"-"`
+	Deployment  *DeploymentSpec       `protobuf:"bytes,1,opt,name=deployment,proto3" json:"deployment,omitempty"`
+	State       GithubDeploymentState `protobuf:"varint,2,opt,name=state,proto3,enum=deployment.GithubDeploymentState" json:"state,omitempty"`
+	Description string                `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	DeliveryID  string                `protobuf:"bytes,4,opt,name=deliveryID,proto3" json:"deliveryID,omitempty"`
+	Team        string                `protobuf:"bytes,5,opt,name=team,proto3" json:"team,omitempty"`
+	Cluster     string                `protobuf:"bytes,6,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	Timestamp   int64                 `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	DryRun      bool                  `protobuf:"varint,8,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
+	// Results of a dry run, one for each resource.
+	Resources            []*ResourceResult `protobuf:"bytes,9,rep,name=resources,proto3" json:"resources,omitempty"`
+	Traceparent          string            `protobuf:"bytes,10,opt,name=traceparent,proto3" json:"traceparent,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
 }
 
 func (m *DeploymentStatus) Reset()         { *m = DeploymentStatus{} }
 func (m *DeploymentStatus) String() string { return proto.CompactTextString(m) }
 func (*DeploymentStatus) ProtoMessage()    {}
 func (*DeploymentStatus) Descriptor() ([]byte, []int) {
-	return fileDescriptor_fac0ec10f8e4d7ff, []int{5}
+	return fileDescriptor_fac0ec10f8e4d7ff, []int{6}
 }
 
 func (m *DeploymentStatus) XXX_Unmarshal(b []byte) error {
@@ -415,6 +533,180 @@ func (m *DeploymentStatus) GetTimestamp() int64 {
 	return 0
 }
 
+func (m *DeploymentStatus) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+func (m *DeploymentStatus) GetResources() []*ResourceResult {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+func (m *DeploymentStatus) GetTraceparent() string {
+	if m != nil {
+		return m.Traceparent
+	}
+	return ""
+}
+
+type ResourceResult struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Namespace            string   `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Error                string   `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Diff                 string   `protobuf:"bytes,5,opt,name=diff,proto3" json:"diff,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResourceResult) Reset()         { *m = ResourceResult{} }
+func (m *ResourceResult) String() string { return proto.CompactTextString(m) }
+func (*ResourceResult) ProtoMessage()    {}
+func (*ResourceResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fac0ec10f8e4d7ff, []int{7}
+}
+
+func (m *ResourceResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResourceResult.Unmarshal(m, b)
+}
+func (m *ResourceResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResourceResult.Marshal(b, m, deterministic)
+}
+func (m *ResourceResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResourceResult.Merge(m, src)
+}
+func (m *ResourceResult) XXX_Size() int {
+	return xxx_messageInfo_ResourceResult.Size(m)
+}
+func (m *ResourceResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResourceResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResourceResult proto.InternalMessageInfo
+
+func (m *ResourceResult) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *ResourceResult) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *ResourceResult) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ResourceResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResourceResult) GetDiff() string {
+	if m != nil {
+		return m.Diff
+	}
+	return ""
+}
+
+// LogEvent is a log line about a deployment, shipped from deployd to hookd.
+type LogEvent struct {
+	DeliveryID string `protobuf:"bytes,1,opt,name=deliveryID,proto3" json:"deliveryID,omitempty"`
+	Team       string `protobuf:"bytes,2,opt,name=team,proto3" json:"team,omitempty"`
+	Cluster    string `protobuf:"bytes,3,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	// Nanoseconds since the Unix epoch.
+	Timestamp            int64    `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Level                string   `protobuf:"bytes,5,opt,name=level,proto3" json:"level,omitempty"`
+	Message              string   `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LogEvent) Reset()         { *m = LogEvent{} }
+func (m *LogEvent) String() string { return proto.CompactTextString(m) }
+func (*LogEvent) ProtoMessage()    {}
+func (*LogEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fac0ec10f8e4d7ff, []int{8}
+}
+
+func (m *LogEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LogEvent.Unmarshal(m, b)
+}
+func (m *LogEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LogEvent.Marshal(b, m, deterministic)
+}
+func (m *LogEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogEvent.Merge(m, src)
+}
+func (m *LogEvent) XXX_Size() int {
+	return xxx_messageInfo_LogEvent.Size(m)
+}
+func (m *LogEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogEvent proto.InternalMessageInfo
+
+func (m *LogEvent) GetDeliveryID() string {
+	if m != nil {
+		return m.DeliveryID
+	}
+	return ""
+}
+
+func (m *LogEvent) GetTeam() string {
+	if m != nil {
+		return m.Team
+	}
+	return ""
+}
+
+func (m *LogEvent) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *LogEvent) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *LogEvent) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+func (m *LogEvent) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+// SignedMessage wraps a serialized message together with its HMAC signature.
 type SignedMessage struct {
 	Message              []byte   `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
 	Signature            []byte   `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
@@ -427,7 +719,7 @@ func (m *SignedMessage) Reset()         { *m = SignedMessage{} }
 func (m *SignedMessage) String() string { return proto.CompactTextString(m) }
 func (*SignedMessage) ProtoMessage()    {}
 func (*SignedMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_fac0ec10f8e4d7ff, []int{6}
+	return fileDescriptor_fac0ec10f8e4d7ff, []int{9}
 }
 
 func (m *SignedMessage) XXX_Unmarshal(b []byte) error {
@@ -468,48 +760,66 @@ func init() {
 	proto.RegisterType((*DeploymentSpec)(nil), "deployment.DeploymentSpec")
 	proto.RegisterType((*Kubernetes)(nil), "deployment.Kubernetes")
 	proto.RegisterType((*Payload)(nil), "deployment.Payload")
+	proto.RegisterType((*Cancellation)(nil), "deployment.Cancellation")
 	proto.RegisterType((*DeploymentRequest)(nil), "deployment.DeploymentRequest")
 	proto.RegisterType((*DeploymentStatus)(nil), "deployment.DeploymentStatus")
+	proto.RegisterType((*ResourceResult)(nil), "deployment.ResourceResult")
+	proto.RegisterType((*LogEvent)(nil), "deployment.LogEvent")
 	proto.RegisterType((*SignedMessage)(nil), "deployment.SignedMessage")
 }
 
 func init() { proto.RegisterFile("deployment.proto", fileDescriptor_fac0ec10f8e4d7ff) }
 
 var fileDescriptor_fac0ec10f8e4d7ff = []byte{
-	// 551 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x53, 0x5d, 0x6f, 0xd3, 0x30,
-	0x14, 0xa5, 0x4d, 0xd3, 0xac, 0x37, 0x65, 0x04, 0xf3, 0x15, 0x4d, 0x13, 0x1a, 0x79, 0x9a, 0x78,
-	0xe8, 0xa4, 0xa1, 0x81, 0x84, 0xf6, 0xc6, 0xa4, 0x69, 0x20, 0x24, 0xe4, 0xfd, 0x00, 0xe4, 0x26,
-	0x77, 0xc1, 0x22, 0xb1, 0x33, 0x7f, 0x14, 0xf5, 0x27, 0xf0, 0x03, 0xf8, 0xbf, 0x28, 0x4e, 0xd3,
-	0xb8, 0x1d, 0x6f, 0xbc, 0xf9, 0x9e, 0x1c, 0xfb, 0x9e, 0x73, 0xee, 0x0d, 0x24, 0x05, 0x36, 0x95,
-	0x5c, 0xd7, 0x28, 0xcc, 0xa2, 0x51, 0xd2, 0x48, 0x02, 0x03, 0x72, 0x74, 0x5c, 0x4a, 0x59, 0x56,
-	0x78, 0xe6, 0xbe, 0x2c, 0xed, 0xdd, 0x99, 0x36, 0xca, 0xe6, 0x1b, 0x66, 0x76, 0x09, 0xc9, 0x35,
-	0x37, 0x3f, 0xec, 0x92, 0x62, 0x23, 0x35, 0x37, 0x52, 0xad, 0xc9, 0x73, 0x08, 0xe5, 0x2f, 0x81,
-	0x2a, 0x1d, 0x9d, 0x8c, 0x4e, 0x67, 0xb4, 0x2b, 0x08, 0x81, 0x89, 0x60, 0x35, 0xa6, 0x63, 0x07,
-	0xba, 0x73, 0xa6, 0xe0, 0xf0, 0x6a, 0xdb, 0xe9, 0xb6, 0xc1, 0x9c, 0x5c, 0x02, 0xa8, 0xed, 0x4b,
-	0xee, 0x81, 0xf8, 0xfc, 0x78, 0xe1, 0x09, 0xdc, 0xef, 0x46, 0x3d, 0x3e, 0xc9, 0x60, 0x3e, 0x50,
-	0x6f, 0xae, 0x5c, 0xaf, 0x80, 0xee, 0x60, 0xd9, 0x27, 0x80, 0x2f, 0x76, 0x89, 0x4a, 0xa0, 0x41,
-	0x4d, 0x2e, 0x60, 0xa6, 0x50, 0x4b, 0xab, 0x72, 0xd4, 0xe9, 0xe8, 0x24, 0x38, 0x8d, 0xcf, 0x5f,
-	0x2d, 0x3a, 0xc7, 0x8b, 0xde, 0xf1, 0xe2, 0xd6, 0x39, 0xa6, 0x03, 0x33, 0x93, 0x10, 0x7d, 0x63,
-	0xeb, 0x4a, 0xb2, 0x82, 0xa4, 0x10, 0xad, 0x50, 0x69, 0x2e, 0x85, 0xbb, 0x1f, 0xd2, 0xbe, 0x6c,
-	0x1d, 0x1b, 0x64, 0x75, 0xef, 0xb8, 0x3d, 0x93, 0xf7, 0x00, 0x3f, 0xb7, 0xdd, 0xd3, 0xc0, 0xf9,
-	0x7b, 0xe9, 0xfb, 0x1b, 0xb4, 0x51, 0x8f, 0x99, 0xfd, 0x1e, 0xc3, 0xd3, 0x21, 0x2a, 0x8a, 0xf7,
-	0x16, 0xb5, 0x21, 0x1f, 0xc1, 0x9b, 0xd4, 0x26, 0xad, 0x23, 0xff, 0xb5, 0xdd, 0x74, 0xa9, 0xc7,
-	0x26, 0xc7, 0x30, 0x33, 0xbc, 0x46, 0x6d, 0x58, 0xdd, 0x6c, 0x82, 0x1a, 0x00, 0x72, 0x04, 0x07,
-	0x05, 0xb2, 0xa2, 0xe2, 0x02, 0x9d, 0xca, 0x80, 0x6e, 0xeb, 0xd6, 0x71, 0x5e, 0x59, 0x6d, 0x50,
-	0xa5, 0xa1, 0xb3, 0xd6, 0x97, 0xe4, 0x75, 0xab, 0xa7, 0xe2, 0x2b, 0x54, 0xeb, 0x9b, 0xab, 0x74,
-	0xea, 0x3e, 0x7a, 0x08, 0xb9, 0x80, 0xb8, 0xe9, 0x62, 0x6b, 0xe5, 0xa4, 0x91, 0x13, 0xfc, 0xcc,
-	0x17, 0xbc, 0x49, 0x95, 0xfa, 0xbc, 0xcf, 0x93, 0x83, 0x49, 0x12, 0xd2, 0x68, 0x03, 0x65, 0x7f,
-	0xc6, 0x90, 0x78, 0xc6, 0x0c, 0x33, 0x56, 0xff, 0x57, 0x14, 0x1f, 0x20, 0xd4, 0x86, 0x99, 0x6e,
-	0x37, 0x0f, 0xcf, 0xdf, 0x3c, 0xdc, 0xb7, 0xdd, 0x76, 0x48, 0x3b, 0x3e, 0x39, 0x81, 0xb8, 0x40,
-	0x9d, 0x2b, 0xde, 0x98, 0x76, 0xfe, 0x81, 0x33, 0xec, 0x43, 0x7b, 0x89, 0x4c, 0x1e, 0x24, 0xd2,
-	0xef, 0x48, 0xe8, 0xed, 0x88, 0x97, 0xef, 0x74, 0x37, 0xdf, 0x9d, 0x99, 0x45, 0x7b, 0x33, 0xcb,
-	0xae, 0xe1, 0xf1, 0x2d, 0x2f, 0x05, 0x16, 0x5f, 0x51, 0x6b, 0x56, 0xba, 0x41, 0xd5, 0xdd, 0xd1,
-	0x05, 0x32, 0xa7, 0x7d, 0xd9, 0x3e, 0xa4, 0x79, 0x29, 0x98, 0xb1, 0xaa, 0x73, 0x3d, 0xa7, 0x03,
-	0xf0, 0xd6, 0xc0, 0x8b, 0x7f, 0xda, 0x26, 0x31, 0x44, 0xda, 0xe6, 0x39, 0x6a, 0x9d, 0x3c, 0x22,
-	0x33, 0x08, 0x51, 0x29, 0xa9, 0x92, 0x51, 0x8b, 0xdf, 0x31, 0x5e, 0x59, 0x85, 0xc9, 0x98, 0xcc,
-	0xe1, 0x80, 0x0b, 0x96, 0x1b, 0xbe, 0xc2, 0x24, 0x20, 0x4f, 0x20, 0xe6, 0xe2, 0x7b, 0xa3, 0x64,
-	0xa9, 0xda, 0x6b, 0x13, 0x02, 0x30, 0xbd, 0xb7, 0x68, 0xb1, 0x48, 0xc2, 0xf6, 0x5e, 0x83, 0xa2,
-	0xe0, 0xa2, 0x4c, 0xa6, 0xcb, 0xa9, 0xfb, 0xdf, 0xde, 0xfd, 0x0d, 0x00, 0x00, 0xff, 0xff, 0x6a,
-	0xc4, 0x9f, 0xe1, 0x8f, 0x04, 0x00, 0x00,
+	// 787 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0xcd, 0x8e, 0xe3, 0x44,
+	0x10, 0x26, 0xeb, 0x38, 0x3f, 0x95, 0x6c, 0x30, 0xcd, 0xb2, 0x58, 0xa3, 0x11, 0x0a, 0x3e, 0xa0,
+	0x15, 0x87, 0xac, 0x34, 0x68, 0x01, 0xa1, 0xb9, 0xed, 0xa0, 0xd5, 0xf2, 0x23, 0xa1, 0x1e, 0xce,
+	0xa0, 0x8e, 0x5d, 0x31, 0xad, 0xb1, 0xdd, 0xde, 0xfe, 0x09, 0xca, 0x8d, 0x2b, 0x2f, 0xc2, 0x1b,
+	0xf1, 0x3e, 0xa8, 0xdb, 0x8e, 0xdd, 0x76, 0x46, 0x08, 0x89, 0x5b, 0x57, 0xb9, 0xba, 0xaa, 0xbe,
+	0xef, 0xab, 0x2e, 0x43, 0x94, 0x61, 0x5d, 0x88, 0x53, 0x89, 0x95, 0xde, 0xd5, 0x52, 0x68, 0x41,
+	0xa0, 0xf7, 0x5c, 0x5d, 0xe7, 0x42, 0xe4, 0x05, 0xbe, 0x74, 0x5f, 0xf6, 0xe6, 0xf0, 0x52, 0x69,
+	0x69, 0xd2, 0x36, 0x32, 0xb9, 0x85, 0xe8, 0x0d, 0xd7, 0xbf, 0x99, 0x3d, 0xc5, 0x5a, 0x28, 0xae,
+	0x85, 0x3c, 0x91, 0x67, 0x10, 0x8a, 0xdf, 0x2b, 0x94, 0xf1, 0x64, 0x3b, 0x79, 0xb1, 0xa4, 0x8d,
+	0x41, 0x08, 0x4c, 0x2b, 0x56, 0x62, 0xfc, 0xc4, 0x39, 0xdd, 0x39, 0x91, 0xb0, 0xb9, 0xeb, 0x2a,
+	0xdd, 0xd7, 0x98, 0x92, 0x5b, 0x00, 0xd9, 0x65, 0x72, 0x09, 0x56, 0x37, 0xd7, 0x3b, 0xaf, 0xc1,
+	0x71, 0x35, 0xea, 0xc5, 0x93, 0x04, 0xd6, 0x7d, 0xe8, 0xdb, 0x3b, 0x57, 0x2b, 0xa0, 0x03, 0x5f,
+	0xf2, 0x1a, 0xe0, 0x7b, 0xb3, 0x47, 0x59, 0xa1, 0x46, 0x45, 0x5e, 0xc1, 0x52, 0xa2, 0x12, 0x46,
+	0xa6, 0xa8, 0xe2, 0xc9, 0x36, 0x78, 0xb1, 0xba, 0xf9, 0x78, 0xd7, 0x20, 0xde, 0x9d, 0x11, 0xef,
+	0xee, 0x1d, 0x62, 0xda, 0x47, 0x26, 0x02, 0xe6, 0x3f, 0xb1, 0x53, 0x21, 0x58, 0x46, 0x62, 0x98,
+	0x1f, 0x51, 0x2a, 0x2e, 0x2a, 0x77, 0x3f, 0xa4, 0x67, 0xd3, 0x22, 0xd6, 0xc8, 0xca, 0x33, 0x62,
+	0x7b, 0x26, 0x5f, 0x02, 0x3c, 0x74, 0xd5, 0xe3, 0xc0, 0xe1, 0x7b, 0xee, 0xe3, 0xeb, 0x7b, 0xa3,
+	0x5e, 0x64, 0xf2, 0x0b, 0xac, 0x5f, 0xb3, 0x2a, 0xc5, 0xa2, 0x60, 0xda, 0xe6, 0xfe, 0x04, 0x20,
+	0xc3, 0x82, 0x1f, 0x51, 0x9e, 0xde, 0xde, 0xb5, 0x44, 0x7b, 0x1e, 0x5b, 0xdb, 0x28, 0x94, 0xe7,
+	0xda, 0xf6, 0x4c, 0xae, 0x60, 0x21, 0x45, 0x51, 0xec, 0x59, 0xfa, 0xe0, 0x2a, 0x2f, 0x68, 0x67,
+	0x27, 0x7f, 0x07, 0xf0, 0x41, 0x2f, 0x05, 0xc5, 0x77, 0x06, 0x95, 0x26, 0xdf, 0x80, 0x37, 0x09,
+	0xad, 0x1a, 0x57, 0x7e, 0xb7, 0x43, 0xf5, 0xa8, 0x17, 0x4d, 0xae, 0x61, 0xa9, 0x79, 0x89, 0x4a,
+	0xb3, 0xb2, 0x6e, 0x85, 0xe8, 0x1d, 0xb6, 0x97, 0x0c, 0x59, 0x56, 0xf0, 0x0a, 0x5d, 0x2f, 0x01,
+	0xed, 0x6c, 0xcb, 0x68, 0x5a, 0x18, 0xa5, 0x51, 0xc6, 0xa1, 0x6b, 0xff, 0x6c, 0x8e, 0x50, 0xcf,
+	0x2e, 0x50, 0xbf, 0x82, 0x55, 0xdd, 0xc8, 0x62, 0xdb, 0x89, 0xe7, 0xae, 0xe1, 0x0f, 0xfd, 0x86,
+	0x5b, 0xd5, 0xa8, 0x1f, 0x47, 0x6e, 0x61, 0x9d, 0x7a, 0xe4, 0xc6, 0x0b, 0x77, 0x2f, 0xf6, 0xef,
+	0xf9, 0xe4, 0xd3, 0x41, 0x34, 0xf9, 0x0c, 0x36, 0x96, 0x46, 0x61, 0xf4, 0xcf, 0xbc, 0x44, 0x61,
+	0x74, 0xbc, 0x74, 0x80, 0x46, 0x5e, 0xf2, 0x1c, 0x66, 0x99, 0x3c, 0x51, 0x53, 0xc5, 0xe0, 0xc8,
+	0x6f, 0x2d, 0x2b, 0x55, 0xc6, 0x0f, 0x87, 0x78, 0xe5, 0xbc, 0xee, 0x4c, 0xb6, 0xb0, 0xd2, 0x92,
+	0xa5, 0x58, 0x33, 0x69, 0x99, 0x5f, 0x3b, 0xa4, 0xbe, 0xcb, 0x66, 0x93, 0xa8, 0x4c, 0x89, 0xf1,
+	0xd3, 0x26, 0x5b, 0x63, 0x7d, 0x37, 0x5d, 0x4c, 0xa3, 0x90, 0xce, 0x5b, 0x78, 0xc9, 0x9f, 0x01,
+	0x44, 0x9e, 0x48, 0x9a, 0x69, 0xa3, 0xfe, 0x97, 0xac, 0x5f, 0x41, 0xa8, 0x34, 0xd3, 0xcd, 0x3b,
+	0xde, 0xdc, 0x7c, 0x7a, 0xf9, 0x36, 0x87, 0xe5, 0x90, 0x36, 0xf1, 0x16, 0x52, 0x86, 0x2a, 0x95,
+	0xbc, 0x76, 0x1c, 0x07, 0x0d, 0x24, 0xcf, 0x35, 0x52, 0x77, 0xfa, 0xd8, 0x4c, 0xbb, 0xf7, 0x14,
+	0x7a, 0xef, 0xc9, 0x9b, 0x95, 0xd9, 0x70, 0x56, 0x06, 0xf3, 0x37, 0x1f, 0xcf, 0x5f, 0x2f, 0xc6,
+	0x62, 0x20, 0xc6, 0xd7, 0xfe, 0x3e, 0x58, 0x6e, 0x83, 0x31, 0x33, 0xb4, 0xfd, 0x48, 0x51, 0x99,
+	0xc2, 0x5f, 0x09, 0x63, 0xc9, 0xe0, 0x42, 0xb2, 0xe4, 0x8f, 0x09, 0x6c, 0x86, 0xf7, 0x2d, 0xa4,
+	0x07, 0x5e, 0x65, 0xed, 0x03, 0x76, 0x67, 0xdb, 0xb8, 0x5d, 0x8e, 0xaa, 0x66, 0xe9, 0x79, 0x5b,
+	0xf6, 0x8e, 0x6e, 0x8d, 0x06, 0xfd, 0x1a, 0xb5, 0x0b, 0x17, 0xa5, 0x14, 0xb2, 0xe5, 0xac, 0x31,
+	0xba, 0xb9, 0x6a, 0xe9, 0xb2, 0xe7, 0xe4, 0xaf, 0x09, 0x2c, 0x7e, 0x10, 0xf9, 0xb7, 0x47, 0x2b,
+	0xe5, 0x7f, 0xd8, 0x21, 0x17, 0xfb, 0xcb, 0xe3, 0x3b, 0xf8, 0x17, 0xbe, 0xa7, 0x63, 0xbe, 0x9f,
+	0x41, 0x58, 0xe0, 0x11, 0x8b, 0xb6, 0x9b, 0xc6, 0xb0, 0xd9, 0x4a, 0x54, 0x8a, 0xe5, 0x78, 0x56,
+	0xaf, 0x35, 0x93, 0x37, 0xf0, 0xf4, 0x9e, 0xe7, 0x15, 0x66, 0x3f, 0x36, 0x0e, 0x3f, 0xd4, 0x76,
+	0xba, 0xee, 0x42, 0x6d, 0x61, 0xc5, 0xf3, 0x8a, 0x69, 0x23, 0x1b, 0xbe, 0xd6, 0xb4, 0x77, 0x7c,
+	0xae, 0xe1, 0xa3, 0x47, 0xc7, 0x92, 0xac, 0x60, 0xae, 0x4c, 0x9a, 0xa2, 0x52, 0xd1, 0x7b, 0x64,
+	0xd9, 0x32, 0x18, 0x4d, 0xac, 0xff, 0xc0, 0x78, 0x61, 0x24, 0x46, 0x4f, 0xc8, 0x1a, 0x16, 0xbc,
+	0x62, 0xa9, 0xe6, 0x47, 0x8c, 0x02, 0xf2, 0x3e, 0xac, 0x78, 0xf5, 0x6b, 0x2d, 0x45, 0x2e, 0xed,
+	0xb5, 0x29, 0x01, 0x98, 0xbd, 0x33, 0x68, 0x30, 0x8b, 0x42, 0x7b, 0xaf, 0xc6, 0x2a, 0xe3, 0x55,
+	0x1e, 0xcd, 0xf6, 0x33, 0xf7, 0xef, 0xf8, 0xe2, 0x9f, 0x01, 0x00, 0xdf, 0xf6, 0x20, 0x88, 0x5b,
+	0x07, 0x00, 0x00,
 }
@@ -1,6 +1,7 @@
 package deployment
 
 import (
+	"github.com/navikt/deployment/common/pkg/tracing"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -13,25 +14,36 @@ const (
 	LogFieldEventType            = "event_type"
 	LogFieldDeploymentStatusID   = "deployment_status_id"
 	LogFieldDeploymentStatusType = "deployment_status"
+	LogFieldCancelledDeliveryID  = "cancelled_delivery_id"
+	LogFieldCancelledBy          = "cancelled_by"
+	LogFieldTraceID              = "trace_id"
 )
 
 func (m *DeploymentStatus) LogFields() log.Fields {
-	return log.Fields{
+	return withTraceID(m.GetTraceparent(), log.Fields{
 		LogFieldDeliveryID:           m.GetDeliveryID(),
 		LogFieldRepository:           m.GetDeployment().GetRepository().FullName(),
 		LogFieldDeploymentID:         m.GetDeployment().GetDeploymentID(),
 		LogFieldDeploymentStatusType: m.GetState().String(),
 		LogFieldTeam:                 m.GetTeam(),
 		LogFieldCluster:              m.GetCluster(),
-	}
+	})
 }
 
 func (m *DeploymentRequest) LogFields() log.Fields {
-	return log.Fields{
+	return withTraceID(m.GetTraceparent(), log.Fields{
 		LogFieldDeliveryID:   m.GetDeliveryID(),
 		LogFieldDeploymentID: m.GetDeployment().GetDeploymentID(),
 		LogFieldTeam:         m.GetPayloadSpec().GetTeam(),
 		LogFieldCluster:      m.GetCluster(),
 		LogFieldRepository:   m.GetDeployment().GetRepository().FullName(),
+	})
+}
+
+// withTraceID adds the trace ID to log fields, so that log lines can be matched with spans of the same trace.
+func withTraceID(traceparent string, fields log.Fields) log.Fields {
+	if sc, err := tracing.ParseTraceparent(traceparent); err == nil {
+		fields[LogFieldTraceID] = sc.TraceID.String()
 	}
+	return fields
 }
@@ -59,3 +59,20 @@ func (m *Payload) JSONResources() ([]json.RawMessage, error) {
 
 	return msgs, nil
 }
+
+// ResourceIdentifiers returns the kind, namespace and name of each Kubernetes resource in the payload.
+func (m *Payload) ResourceIdentifiers() []*ResourceResult {
+	resources := m.GetKubernetes().GetResources()
+	identifiers := make([]*ResourceResult, len(resources))
+
+	for i, r := range resources {
+		metadata := r.GetFields()["metadata"].GetStructValue().GetFields()
+		identifiers[i] = &ResourceResult{
+			Kind:      r.GetFields()["kind"].GetStringValue(),
+			Namespace: metadata["namespace"].GetStringValue(),
+			Name:      metadata["name"].GetStringValue(),
+		}
+	}
+
+	return identifiers
+}
@@ -30,3 +30,23 @@ func TestJSONPayload(t *testing.T) {
 	assert.Equal(t, "bar", fs.Foo)
 	assert.Equal(t, []int{564}, fs.Baz)
 }
+
+func TestResourceIdentifiers(t *testing.T) {
+	p, err := deployment.PayloadFromJSON([]byte(`{
+		"team": "foo",
+		"kubernetes": {
+			"resources": [
+				{ "kind": "Application", "metadata": { "name": "myapp", "namespace": "default" } },
+				{ "kind": "Namespace", "metadata": { "name": "foo" } },
+				{ "foo": "bar" }
+			]
+		}
+	}`))
+	assert.NoError(t, err)
+
+	identifiers := p.ResourceIdentifiers()
+	assert.Len(t, identifiers, 3)
+	assert.Equal(t, &deployment.ResourceResult{Kind: "Application", Namespace: "default", Name: "myapp"}, identifiers[0])
+	assert.Equal(t, &deployment.ResourceResult{Kind: "Namespace", Name: "foo"}, identifiers[1])
+	assert.Equal(t, &deployment.ResourceResult{}, identifiers[2], "resources without metadata are not an error")
+}
@@ -0,0 +1,7 @@
+package deployment
+
+// ReportsStatus returns true if the request has a deployment status of its own.
+// Cancellations report through the deployment they cancel, and dry runs return their results directly to the caller.
+func (m *DeploymentRequest) ReportsStatus() bool {
+	return m.GetCancellation() == nil && !m.GetDryRun()
+}
@@ -13,6 +13,7 @@ func NewErrorStatus(req DeploymentRequest, err error) *DeploymentStatus {
 		Team:        req.GetPayloadSpec().GetTeam(),
 		Cluster:     req.GetCluster(),
 		Timestamp:   req.GetTimestamp(),
+		Traceparent: req.GetTraceparent(),
 	}
 }
 
@@ -25,6 +26,7 @@ func NewFailureStatus(req DeploymentRequest, err error) *DeploymentStatus {
 		Team:        req.GetPayloadSpec().GetTeam(),
 		Cluster:     req.GetCluster(),
 		Timestamp:   req.GetTimestamp(),
+		Traceparent: req.GetTraceparent(),
 	}
 }
 
@@ -37,6 +39,7 @@ func NewInProgressStatus(req DeploymentRequest) *DeploymentStatus {
 		Team:        req.GetPayloadSpec().GetTeam(),
 		Cluster:     req.GetCluster(),
 		Timestamp:   req.GetTimestamp(),
+		Traceparent: req.GetTraceparent(),
 	}
 }
 
@@ -48,6 +51,8 @@ func NewQueuedStatus(req DeploymentRequest) *DeploymentStatus {
 		Description: "deployment request has been put on the queue for further processing",
 		Team:        req.GetPayloadSpec().GetTeam(),
 		Cluster:     req.GetCluster(),
+		Resources:   req.GetPayloadSpec().ResourceIdentifiers(),
+		Traceparent: req.GetTraceparent(),
 	}
 }
 
@@ -60,5 +65,57 @@ func NewSuccessStatus(req DeploymentRequest) *DeploymentStatus {
 		Team:        req.GetPayloadSpec().GetTeam(),
 		Cluster:     req.GetCluster(),
 		Timestamp:   req.GetTimestamp(),
+		Traceparent: req.GetTraceparent(),
 	}
 }
+
+func NewPendingStatus(req DeploymentRequest) *DeploymentStatus {
+	return &DeploymentStatus{
+		Deployment:  req.GetDeployment(),
+		DeliveryID:  req.GetDeliveryID(),
+		State:       GithubDeploymentState_pending,
+		Description: "deployment request is waiting for manual approval",
+		Team:        req.GetPayloadSpec().GetTeam(),
+		Cluster:     req.GetCluster(),
+		Timestamp:   req.GetTimestamp(),
+		Traceparent: req.GetTraceparent(),
+	}
+}
+
+// NewInactiveStatus marks a deployment as inactive, because it was cancelled by a user or superseded by a newer deployment.
+func NewInactiveStatus(req DeploymentRequest, description string) *DeploymentStatus {
+	return &DeploymentStatus{
+		Deployment:  req.GetDeployment(),
+		Description: description,
+		State:       GithubDeploymentState_inactive,
+		DeliveryID:  req.GetDeliveryID(),
+		Team:        req.GetPayloadSpec().GetTeam(),
+		Cluster:     req.GetCluster(),
+		Timestamp:   req.GetTimestamp(),
+		Traceparent: req.GetTraceparent(),
+	}
+}
+
+// NewDryRunStatus reports the results of a dry run. The dry run fails if any of the resources were rejected.
+func NewDryRunStatus(req DeploymentRequest, results []*ResourceResult) *DeploymentStatus {
+	status := &DeploymentStatus{
+		Deployment:  req.GetDeployment(),
+		Description: "Dry run succeeded; all resources were accepted by Kubernetes.",
+		State:       GithubDeploymentState_success,
+		DeliveryID:  req.GetDeliveryID(),
+		Team:        req.GetPayloadSpec().GetTeam(),
+		Cluster:     req.GetCluster(),
+		Timestamp:   req.GetTimestamp(),
+		Traceparent: req.GetTraceparent(),
+		DryRun:      true,
+		Resources:   results,
+	}
+	for _, result := range results {
+		if len(result.GetError()) > 0 {
+			status.State = GithubDeploymentState_failure
+			status.Description = "Dry run failed; one or more resources were rejected by Kubernetes."
+			break
+		}
+	}
+	return status
+}
@@ -0,0 +1,159 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrTimeout = fmt.Errorf("health check timed out")
+)
+
+// Checker checks whether a single dependency is available.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// Pinger is implemented by clients that can check their own connection.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type checkFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c *checkFunc) Name() string {
+	return c.name
+}
+
+func (c *checkFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// CheckFunc returns a named Checker that runs the specified function.
+func CheckFunc(name string, fn func(ctx context.Context) error) Checker {
+	return &checkFunc{
+		name: name,
+		fn:   fn,
+	}
+}
+
+// NewChecker returns a named Checker that pings a client.
+func NewChecker(name string, pinger Pinger) Checker {
+	return CheckFunc(name, pinger.Ping)
+}
+
+// Result is the outcome of a single health check.
+type Result struct {
+	Name      string        `json:"name"`
+	Healthy   bool          `json:"healthy"`
+	Error     string        `json:"error,omitempty"`
+	Latency   time.Duration `json:"-"`
+	LatencyMS float64       `json:"latencyMs"`
+}
+
+// Report is the outcome of all health checks. It is healthy only if every check succeeded.
+type Report struct {
+	Healthy bool     `json:"healthy"`
+	Checks  []Result `json:"checks"`
+}
+
+// Run performs all health checks concurrently.
+// Checks that have not completed within the timeout are reported as unhealthy.
+func Run(ctx context.Context, timeout time.Duration, checkers ...Checker) Report {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := Report{
+		Healthy: true,
+		Checks:  make([]Result, len(checkers)),
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range checkers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = check(ctx, checkers[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		report.Healthy = report.Healthy && result.Healthy
+	}
+
+	return report
+}
+
+func check(ctx context.Context, checker Checker) Result {
+	errs := make(chan error, 1)
+	start := time.Now()
+
+	// Not all clients support cancellation, so don't wait for the check after the deadline.
+	go func() {
+		errs <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+
+	latency := time.Since(start)
+	result := Result{
+		Name:      checker.Name(),
+		Healthy:   err == nil,
+		Latency:   latency,
+		LatencyMS: float64(latency) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func (r Report) render(w http.ResponseWriter) {
+	w.Header().Set("content-type", "application/json")
+	if r.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(r)
+}
+
+// LivenessHandler reports that the process is up and serving requests.
+func LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		Report{
+			Healthy: true,
+			Checks:  []Result{},
+		}.render(w)
+	}
+}
+
+// ReadinessHandler runs all health checks, and responds with 503 Service Unavailable if any of them fail.
+func ReadinessHandler(timeout time.Duration, checkers ...Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), timeout, checkers...)
+		for _, result := range report.Checks {
+			if !result.Healthy {
+				log.WithField("dependency", result.Name).Warnf("Readiness check failed: %s", result.Error)
+			}
+		}
+		report.render(w)
+	}
+}
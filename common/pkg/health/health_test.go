@@ -0,0 +1,79 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func ok(ctx context.Context) error {
+	return nil
+}
+
+func broken(ctx context.Context) error {
+	return fmt.Errorf("connection refused")
+}
+
+func hanging(ctx context.Context) error {
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	report := health.Run(context.Background(), time.Millisecond*50,
+		health.CheckFunc("ok", ok),
+		health.CheckFunc("broken", broken),
+		health.CheckFunc("hanging", hanging),
+	)
+
+	assert.False(t, report.Healthy)
+	assert.Len(t, report.Checks, 3)
+
+	assert.Equal(t, "ok", report.Checks[0].Name)
+	assert.True(t, report.Checks[0].Healthy)
+	assert.Empty(t, report.Checks[0].Error)
+
+	assert.Equal(t, "broken", report.Checks[1].Name)
+	assert.False(t, report.Checks[1].Healthy)
+	assert.Equal(t, "connection refused", report.Checks[1].Error)
+
+	assert.Equal(t, "hanging", report.Checks[2].Name)
+	assert.False(t, report.Checks[2].Healthy)
+	assert.Equal(t, health.ErrTimeout.Error(), report.Checks[2].Error)
+	assert.True(t, report.Checks[2].Latency < time.Second)
+}
+
+func TestReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkers   []health.Checker
+		statusCode int
+	}{
+		{"no dependencies", nil, http.StatusOK},
+		{"all dependencies healthy", []health.Checker{health.CheckFunc("ok", ok)}, http.StatusOK},
+		{"one dependency failing", []health.Checker{health.CheckFunc("ok", ok), health.CheckFunc("broken", broken)}, http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			health.ReadinessHandler(time.Second, test.checkers...).ServeHTTP(recorder, request)
+
+			report := health.Report{}
+			err := json.NewDecoder(recorder.Body).Decode(&report)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.statusCode, recorder.Code)
+			assert.Equal(t, test.statusCode == http.StatusOK, report.Healthy)
+			assert.Len(t, report.Checks, len(test.checkers))
+		})
+	}
+}
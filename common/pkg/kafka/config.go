@@ -24,6 +24,7 @@ type Config struct {
 	Brokers      []string
 	RequestTopic string
 	StatusTopic  string
+	LogTopic     string
 	ClientID     string
 	GroupID      string
 	Verbosity    string
@@ -46,6 +47,7 @@ func DefaultConfig() Config {
 		Brokers:      []string{"localhost:9092"},
 		RequestTopic: "deploymentRequest",
 		StatusTopic:  "deploymentStatus",
+		LogTopic:     "deploymentLog",
 		SignatureKey: os.Getenv("KAFKA_HMAC_KEY"),
 		ClientID:     defaultGroup,
 		GroupID:      defaultGroup,
@@ -62,6 +64,7 @@ func SetupFlags(cfg *Config) {
 	flag.StringSliceVar(&cfg.Brokers, "kafka-brokers", cfg.Brokers, "Comma-separated list of Kafka brokers, HOST:PORT.")
 	flag.StringVar(&cfg.RequestTopic, "kafka-topic-request", cfg.RequestTopic, "Kafka topic for deployment requests.")
 	flag.StringVar(&cfg.StatusTopic, "kafka-topic-status", cfg.StatusTopic, "Kafka topic for deployment statuses.")
+	flag.StringVar(&cfg.LogTopic, "kafka-topic-log", cfg.LogTopic, "Kafka topic for deployment log events.")
 	flag.StringVar(&cfg.ClientID, "kafka-client-id", cfg.ClientID, "Kafka client ID.")
 	flag.StringVar(&cfg.GroupID, "kafka-group-id", cfg.GroupID, "Kafka consumer group ID.")
 	flag.StringVar(&cfg.Verbosity, "kafka-log-verbosity", cfg.Verbosity, "Log verbosity for Kafka client.")
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 
@@ -13,8 +14,12 @@ type DualClient struct {
 	RecvQ         chan sarama.ConsumerMessage
 	Consumer      *cluster.Consumer
 	Producer      sarama.SyncProducer
+	ConsumerTopic string
 	ProducerTopic string
 	SignatureKey  string
+
+	consumerClient *cluster.Client
+	producerClient sarama.Client
 }
 
 func tlsConfig(t TLS) *tls.Config {
@@ -23,7 +28,9 @@ func tlsConfig(t TLS) *tls.Config {
 	}
 }
 
-func NewDualClient(cfg Config, consumerTopic, producerTopic string) (*DualClient, error) {
+// NewDualClient creates a client consuming consumerTopic, and producing to producerTopic by default.
+// The consumer also subscribes to any additional topics; use the topic of each message to tell them apart.
+func NewDualClient(cfg Config, consumerTopic, producerTopic string, additionalTopics ...string) (*DualClient, error) {
 	var err error
 	client := &DualClient{}
 
@@ -39,7 +46,13 @@ func NewDualClient(cfg Config, consumerTopic, producerTopic string) (*DualClient
 	consumerCfg.Net.TLS.Enable = cfg.TLS.Enabled
 	consumerCfg.Net.TLS.Config = tlsConfig(cfg.TLS)
 
-	client.Consumer, err = cluster.NewConsumer(cfg.Brokers, cfg.GroupID, []string{consumerTopic}, consumerCfg)
+	client.consumerClient, err = cluster.NewClient(cfg.Brokers, consumerCfg)
+	if err != nil {
+		return nil, fmt.Errorf("while setting up Kafka consumer: %s", err)
+	}
+
+	topics := append([]string{consumerTopic}, additionalTopics...)
+	client.Consumer, err = cluster.NewConsumerFromClient(client.consumerClient, cfg.GroupID, topics)
 	if err != nil {
 		return nil, fmt.Errorf("while setting up Kafka consumer: %s", err)
 	}
@@ -55,12 +68,18 @@ func NewDualClient(cfg Config, consumerTopic, producerTopic string) (*DualClient
 	producerCfg.Net.TLS.Enable = cfg.TLS.Enabled
 	producerCfg.Net.TLS.Config = tlsConfig(cfg.TLS)
 
-	client.Producer, err = sarama.NewSyncProducer(cfg.Brokers, producerCfg)
+	client.producerClient, err = sarama.NewClient(cfg.Brokers, producerCfg)
+	if err != nil {
+		return nil, fmt.Errorf("while setting up Kafka producer: %s", err)
+	}
+
+	client.Producer, err = sarama.NewSyncProducerFromClient(client.producerClient)
 	if err != nil {
 		return nil, fmt.Errorf("while setting up Kafka producer: %s", err)
 	}
 
 	client.RecvQ = make(chan sarama.ConsumerMessage, 1024)
+	client.ConsumerTopic = consumerTopic
 	client.ProducerTopic = producerTopic
 	client.SignatureKey = cfg.SignatureKey
 
@@ -92,12 +111,67 @@ func (client *DualClient) ConsumerLoop() {
 				log.Errorf("kafka consumer error: %s", err)
 			}
 
-		case notif := <-client.Consumer.Notifications():
-			log.Warnf("kafka consumer notification: %+v", notif)
+		case notif, op := <-client.Consumer.Notifications():
+			if op {
+				log.Warnf("kafka consumer notification: %+v", notif)
+			}
 		}
 	}
 }
 
+// Close shuts down the consumer and the producer. Offsets that have been
+// marked as processed are committed before the consumer is closed.
+// The consumer may already have been closed in order to stop consuming messages.
+func (client *DualClient) Close() error {
+	consumerErr := client.Consumer.Close()
+	if err := client.consumerClient.Close(); consumerErr == nil && err != sarama.ErrClosedClient {
+		consumerErr = err
+	}
+
+	producerErr := client.Producer.Close()
+	if err := client.producerClient.Close(); producerErr == nil && err != sarama.ErrClosedClient {
+		producerErr = err
+	}
+
+	if consumerErr != nil {
+		return fmt.Errorf("while closing Kafka consumer: %s", consumerErr)
+	}
+	if producerErr != nil {
+		return fmt.Errorf("while closing Kafka producer: %s", producerErr)
+	}
+	return nil
+}
+
+// PingConsumer checks that the consumer is able to retrieve metadata for its topic from the Kafka brokers.
+func (client *DualClient) PingConsumer(ctx context.Context) error {
+	return ping(ctx, client.consumerClient, client.ConsumerTopic)
+}
+
+// PingProducer checks that the producer is able to retrieve metadata for its topic from the Kafka brokers.
+func (client *DualClient) PingProducer(ctx context.Context) error {
+	return ping(ctx, client.producerClient, client.ProducerTopic)
+}
+
+// ping refreshes the metadata of a topic. The Kafka client does not take a context,
+// so ping returns when the context is done without waiting for the refresh to finish.
+func ping(ctx context.Context, c sarama.Client, topic string) error {
+	if c.Closed() {
+		return fmt.Errorf("client is closed")
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.RefreshMetadata(topic)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("refresh metadata for topic %s: %s", topic, ctx.Err())
+	}
+}
+
 func ConsumerMessageLogger(msg *sarama.ConsumerMessage) log.Entry {
 	return *log.WithFields(log.Fields{
 		"kafka_offset": msg.Offset,
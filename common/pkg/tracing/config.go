@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"os"
+
+	flag "github.com/spf13/pflag"
+)
+
+type Config struct {
+	Exporter string
+	File     string
+}
+
+func DefaultConfig() Config {
+	exporter, ok := os.LookupEnv("TRACE_EXPORTER")
+	if !ok {
+		exporter = ExporterNone
+	}
+	return Config{
+		Exporter: exporter,
+		File:     os.Getenv("TRACE_FILE"),
+	}
+}
+
+func SetupFlags(cfg *Config) {
+	flag.StringVar(&cfg.Exporter, "trace-exporter", cfg.Exporter, "Where to export trace spans; either 'none', 'stdout' or 'file'. Spans are written as OTLP/JSON lines.")
+	flag.StringVar(&cfg.File, "trace-file", cfg.File, "File to append trace spans to, when using the file exporter.")
+}
@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// instrumentationScope names the instrumentation that produced the spans in OTLP output.
+const instrumentationScope = "github.com/navikt/deployment/common/pkg/tracing"
+
+// Exporter receives finished spans.
+type Exporter interface {
+	Export(span *Span) error
+}
+
+type nopExporter struct{}
+
+func (e nopExporter) Export(span *Span) error {
+	return nil
+}
+
+// OTLPJSONExporter writes each span as a line of OTLP/JSON, in the same format as the OpenTelemetry
+// collector's file exporter. The output can be loaded into a collector or inspected offline.
+type OTLPJSONExporter struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+// NewOTLPJSONExporter creates an exporter writing to w.
+func NewOTLPJSONExporter(w io.Writer) *OTLPJSONExporter {
+	return &OTLPJSONExporter{writer: w}
+}
+
+func (e *OTLPJSONExporter) Export(span *Span) error {
+	data, err := json.Marshal(otlpRequest(span))
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	_, err = e.writer.Write(data)
+	return err
+}
+
+// NewExporter creates the exporter of the given kind; either 'none', 'stdout' or 'file'.
+// Spans are appended to path if the kind is 'file'.
+func NewExporter(kind, path string) (Exporter, error) {
+	switch kind {
+	case ExporterNone:
+		return nopExporter{}, nil
+	case ExporterStdout:
+		return NewOTLPJSONExporter(os.Stdout), nil
+	case ExporterFile:
+		if len(path) == 0 {
+			return nil, fmt.Errorf("trace file must be specified when using the file exporter")
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("open trace file: %s", err)
+		}
+		return NewOTLPJSONExporter(file), nil
+	default:
+		return nil, fmt.Errorf("trace exporter '%s' is not recognized", kind)
+	}
+}
+
+var (
+	globalLock     sync.RWMutex
+	globalService  string
+	globalExporter Exporter = nopExporter{}
+)
+
+// Setup configures the service name attached to spans, and the exporter that receives them.
+func Setup(service, exporter, path string) error {
+	e, err := NewExporter(exporter, path)
+	if err != nil {
+		return err
+	}
+	SetExporter(service, e)
+	return nil
+}
+
+// SetExporter sets the service name attached to spans, and the exporter that receives them.
+// Until it is called, or if exporter is nil, spans are propagated but not exported.
+func SetExporter(service string, exporter Exporter) {
+	if exporter == nil {
+		exporter = nopExporter{}
+	}
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	globalService = service
+	globalExporter = exporter
+}
+
+func service() string {
+	globalLock.RLock()
+	defer globalLock.RUnlock()
+	return globalService
+}
+
+func export(span *Span) {
+	globalLock.RLock()
+	exporter := globalExporter
+	globalLock.RUnlock()
+
+	if err := exporter.Export(span); err != nil {
+		log.Warnf("Unable to export span '%s': %s", span.Name, err)
+	}
+}
+
+// The types below are the subset of the OTLP/JSON trace format that is needed to represent a span.
+// See https://github.com/open-telemetry/opentelemetry-proto for the full definition.
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const otlpStatusError = 2
+
+func otlpValue(value interface{}) otlpAnyValue {
+	var s string
+	switch v := value.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		s = strconv.FormatInt(int64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	case int32:
+		s = strconv.FormatInt(int64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	case int64:
+		s = strconv.FormatInt(v, 10)
+		return otlpAnyValue{IntValue: &s}
+	default:
+		s = fmt.Sprint(v)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func otlpRequest(span *Span) otlpExportRequest {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	s := otlpSpan{
+		TraceID:           span.Context.TraceID.String(),
+		SpanID:            span.Context.SpanID.String(),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+	}
+	if span.Parent.IsValid() {
+		s.ParentSpanID = span.Parent.String()
+	}
+	if len(span.Error) > 0 {
+		s.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+	}
+
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.Attributes = append(s.Attributes, otlpKeyValue{Key: key, Value: otlpValue(span.Attributes[key])})
+	}
+
+	return otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpKeyValue{
+						{Key: "service.name", Value: otlpValue(span.Service)},
+					},
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: instrumentationScope},
+						Spans: []otlpSpan{s},
+					},
+				},
+			},
+		},
+	}
+}
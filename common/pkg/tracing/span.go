@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship between a span and the remote side of the operation, as in OTLP.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+	KindProducer SpanKind = 4
+	KindConsumer SpanKind = 5
+)
+
+// Span records a single operation within a trace.
+// Spans are safe for concurrent use, and are exported when End is called.
+type Span struct {
+	Name    string
+	Kind    SpanKind
+	Context SpanContext
+	// Parent is the span ID of the parent span, or the zero value for root spans.
+	Parent     SpanID
+	Service    string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	// Error is the error message of a failed operation, if any.
+	Error string
+
+	lock  sync.Mutex
+	ended bool
+}
+
+// Start starts a span as a child of the span in ctx, or as the root of a new trace.
+// The returned context carries the new span, so that it can be passed on to child operations.
+//
+// Call Finish on the returned span when the operation is complete.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		Name:       name,
+		Kind:       kind,
+		Service:    service(),
+		Start:      time.Now(),
+		Attributes: make(map[string]interface{}),
+	}
+
+	parent, ok := SpanContextFromContext(ctx)
+	if ok {
+		span.Context.TraceID = parent.TraceID
+		span.Context.Sampled = parent.Sampled
+		span.Parent = parent.SpanID
+	} else {
+		span.Context.TraceID = newTraceID()
+		span.Context.Sampled = true
+	}
+	span.Context.SpanID = newSpanID()
+
+	return ContextWithSpanContext(ctx, span.Context), span
+}
+
+// Traceparent returns the traceparent value that makes remote operations children of this span.
+func (s *Span) Traceparent() string {
+	return s.Context.Traceparent()
+}
+
+// SetAttribute records a property of the operation. Strings, integers and booleans are exported as such,
+// other values are formatted as strings.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Attributes[key] = value
+}
+
+// SetError marks the operation as failed. A nil error is ignored.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Error = err.Error()
+}
+
+// Finish ends the span, and hands it to the exporter if the trace is sampled.
+// Only the first call has any effect.
+func (s *Span) Finish() {
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.lock.Unlock()
+
+	if s.Context.Sampled {
+		export(s)
+	}
+}
@@ -0,0 +1,193 @@
+// Package tracing implements W3C Trace Context propagation and a minimal span recorder.
+//
+// Trace context enters the system through the traceparent header of API requests,
+// travels to deployd inside deployment requests, and comes back inside deployment statuses.
+// Finished spans are handed to an Exporter; see https://www.w3.org/TR/trace-context/.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the HTTP header carrying the trace context.
+const TraceparentHeader = "traceparent"
+
+const (
+	traceparentVersion = "00"
+	flagSampled        = 0x01
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid reports whether the trace ID is non-zero, as required by the specification.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// IsValid reports whether the span ID is non-zero, as required by the specification.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext identifies a span within a trace, and is the part of a span that is propagated between services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a traceparent header value.
+// An empty string is returned for invalid span contexts.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := 0
+	if sc.Sampled {
+		flags |= flagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a traceparent header value.
+//
+// Values with a version other than 00 are accepted as long as they start with the fields of version 00,
+// so that newer clients can still take part in the trace.
+func ParseTraceparent(value string) (SpanContext, error) {
+	sc := SpanContext{}
+	parts := strings.Split(strings.TrimSpace(value), "-")
+
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("traceparent must have the form version-traceid-spanid-flags")
+	}
+
+	version, err := decodeHex(parts[0], 1)
+	if err != nil {
+		return sc, fmt.Errorf("invalid version: %s", err)
+	}
+	if version[0] == 0xff {
+		return sc, fmt.Errorf("version ff is not allowed")
+	}
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, fmt.Errorf("version 00 must have exactly four fields")
+	}
+
+	traceID, err := decodeHex(parts[1], len(sc.TraceID))
+	if err != nil {
+		return sc, fmt.Errorf("invalid trace ID: %s", err)
+	}
+	spanID, err := decodeHex(parts[2], len(sc.SpanID))
+	if err != nil {
+		return sc, fmt.Errorf("invalid parent ID: %s", err)
+	}
+	flags, err := decodeHex(parts[3], 1)
+	if err != nil {
+		return sc, fmt.Errorf("invalid trace flags: %s", err)
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&flagSampled != 0
+
+	if !sc.TraceID.IsValid() {
+		return sc, fmt.Errorf("trace ID must not be all zeroes")
+	}
+	if !sc.SpanID.IsValid() {
+		return sc, fmt.Errorf("parent ID must not be all zeroes")
+	}
+
+	return sc, nil
+}
+
+// decodeHex decodes a lowercase hex string of exactly size bytes.
+func decodeHex(s string, size int) ([]byte, error) {
+	if len(s) != size*2 {
+		return nil, fmt.Errorf("expected %d hex characters, got %d", size*2, len(s))
+	}
+	if strings.ToLower(s) != s {
+		return nil, fmt.Errorf("hex characters must be lowercase")
+	}
+	return hex.DecodeString(s)
+}
+
+func newTraceID() TraceID {
+	id := TraceID{}
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	id := SpanID{}
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+type contextKey struct{}
+
+var spanContextKey = contextKey{}
+
+// ContextWithSpanContext returns a context whose spans become children of sc.
+// Use it to continue a trace that was started in another process.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey, sc)
+}
+
+// ContextWithTraceparent continues the trace identified by a traceparent value.
+// Invalid or empty values are ignored, and spans started from the returned context start a new trace.
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// SpanContextFromContext returns the span context of the current span, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey).(SpanContext)
+	return sc, ok
+}
+
+// Traceparent returns the traceparent value of the current span, or an empty string if there is none.
+func Traceparent(ctx context.Context) string {
+	sc, _ := SpanContextFromContext(ctx)
+	return sc.Traceparent()
+}
+
+// Inject sets the traceparent header of an outgoing request, if the context holds a span.
+func Inject(ctx context.Context, header http.Header) {
+	if traceparent := Traceparent(ctx); len(traceparent) > 0 {
+		header.Set(TraceparentHeader, traceparent)
+	}
+}
+
+// Extract returns the context of an incoming request, continuing the trace from its traceparent header.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return ContextWithTraceparent(ctx, header.Get(TraceparentHeader))
+}
@@ -0,0 +1,167 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/navikt/deployment/common/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := tracing.ParseTraceparent(traceparent)
+	assert.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, traceparent, sc.Traceparent())
+
+	sc, err = tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.NoError(t, err)
+	assert.False(t, sc.Sampled)
+
+	_, err = tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future")
+	assert.NoError(t, err, "newer versions may add fields")
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bx-01",
+	}
+	for _, value := range invalid {
+		_, err := tracing.ParseTraceparent(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx := tracing.ContextWithTraceparent(context.Background(), traceparent)
+
+	ctx, parent := tracing.Start(ctx, "parent", tracing.KindServer)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parent.Context.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", parent.Parent.String())
+	assert.Equal(t, parent.Traceparent(), tracing.Traceparent(ctx))
+
+	_, child := tracing.Start(ctx, "child", tracing.KindInternal)
+	assert.Equal(t, parent.Context.TraceID, child.Context.TraceID)
+	assert.Equal(t, parent.Context.SpanID, child.Parent)
+	assert.NotEqual(t, parent.Context.SpanID, child.Context.SpanID)
+
+	_, root := tracing.Start(context.Background(), "root", tracing.KindInternal)
+	assert.True(t, root.Context.IsValid())
+	assert.True(t, root.Context.Sampled)
+	assert.False(t, root.Parent.IsValid())
+	assert.NotEqual(t, parent.Context.TraceID, root.Context.TraceID)
+}
+
+func TestContextWithInvalidTraceparent(t *testing.T) {
+	ctx := tracing.ContextWithTraceparent(context.Background(), "garbage")
+	_, ok := tracing.SpanContextFromContext(ctx)
+	assert.False(t, ok)
+	assert.Empty(t, tracing.Traceparent(ctx))
+}
+
+func TestInjectExtract(t *testing.T) {
+	header := http.Header{}
+	tracing.Inject(context.Background(), header)
+	assert.Empty(t, header.Get(tracing.TraceparentHeader))
+
+	header.Set(tracing.TraceparentHeader, traceparent)
+	ctx := tracing.Extract(context.Background(), header)
+	assert.Equal(t, traceparent, tracing.Traceparent(ctx))
+
+	outgoing := http.Header{}
+	tracing.Inject(ctx, outgoing)
+	assert.Equal(t, traceparent, outgoing.Get(tracing.TraceparentHeader))
+}
+
+func TestOTLPJSONExporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tracing.SetExporter("hookd", tracing.NewOTLPJSONExporter(buf))
+	defer tracing.SetExporter("", nil)
+
+	ctx := tracing.ContextWithTraceparent(context.Background(), traceparent)
+	_, span := tracing.Start(ctx, "kafka.publish", tracing.KindProducer)
+	span.SetAttribute("delivery_id", "abc")
+	span.SetAttribute("attempt", 2)
+	span.SetError(fmt.Errorf("broker unavailable"))
+	span.Finish()
+	span.Finish()
+
+	_, unsampled := tracing.Start(tracing.ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"), "unsampled", tracing.KindInternal)
+	unsampled.Finish()
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 1, "spans are exported once, and only if sampled")
+
+	output := struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value struct{ StringValue string }
+				}
+			}
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string
+					SpanID       string
+					ParentSpanID string
+					Name         string
+					Kind         int
+					Attributes   []struct {
+						Key   string
+						Value map[string]interface{}
+					}
+					Status struct {
+						Code    int
+						Message string
+					}
+				}
+			}
+		}
+	}{}
+	assert.NoError(t, json.Unmarshal(lines[0], &output))
+
+	resource := output.ResourceSpans[0]
+	assert.Equal(t, "service.name", resource.Resource.Attributes[0].Key)
+	assert.Equal(t, "hookd", resource.Resource.Attributes[0].Value.StringValue)
+
+	exported := resource.ScopeSpans[0].Spans[0]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exported.TraceID)
+	assert.Equal(t, span.Context.SpanID.String(), exported.SpanID)
+	assert.Equal(t, "00f067aa0ba902b7", exported.ParentSpanID)
+	assert.Equal(t, "kafka.publish", exported.Name)
+	assert.Equal(t, 4, exported.Kind)
+	assert.Equal(t, 2, exported.Status.Code)
+	assert.Equal(t, "broker unavailable", exported.Status.Message)
+
+	assert.Len(t, exported.Attributes, 2)
+	assert.Equal(t, "attempt", exported.Attributes[0].Key)
+	assert.Equal(t, "2", exported.Attributes[0].Value["intValue"])
+	assert.Equal(t, "delivery_id", exported.Attributes[1].Key)
+	assert.Equal(t, "abc", exported.Attributes[1].Value["stringValue"])
+}
+
+func TestNewExporter(t *testing.T) {
+	_, err := tracing.NewExporter(tracing.ExporterNone, "")
+	assert.NoError(t, err)
+
+	_, err = tracing.NewExporter(tracing.ExporterFile, "")
+	assert.Error(t, err)
+
+	_, err = tracing.NewExporter("jaeger", "")
+	assert.Error(t, err)
+}
@@ -15,16 +15,27 @@ type Config struct {
 	APIKey          string
 	DeployServerURL string
 	Cluster         string
+	Command         string
+	CorrelationID   string
 	Environment     string
+	FreezeOverride  bool
 	PrintPayload    bool
 	DryRun          bool
+	Output          string
 	Owner           string
 	PollInterval    time.Duration
 	Quiet           bool
 	Ref             string
 	Repository      string
 	Resource        []string
+	Retries         int
+	RetryInterval   time.Duration
+	Rollback        bool
 	Team            string
+	Timeout         time.Duration
+	Traceparent     string
+	TTL             time.Duration
+	User            string
 	Variables       []string
 	VariablesFile   string
 	Wait            bool
@@ -33,29 +44,41 @@ type Config struct {
 var cfg Config
 
 func init() {
-	flag.ErrHelp = fmt.Errorf("\ndeploy prepares and submits Kubernetes resources to a NAIS cluster.\n")
+	flag.ErrHelp = fmt.Errorf("\ndeploy prepares and submits Kubernetes resources to a NAIS cluster.\n\nUse 'deploy diff' to see what a deployment would change in the cluster, without deploying it.\nUse 'deploy cancel --correlation-id ID' to cancel a deployment that has not yet finished.\nUse 'deploy logs --correlation-id ID' to show the log of a deployment; add --wait to follow it until the deployment finishes.\n")
 
 	flag.BoolVar(&cfg.Actions, "actions", getEnvBool("ACTIONS"), "Use GitHub Actions compatible error and warning messages. (env ACTIONS)")
 	flag.StringVar(&cfg.APIKey, "apikey", os.Getenv("APIKEY"), "NAIS Deploy API key. (env APIKEY)")
 	flag.StringVar(&cfg.DeployServerURL, "deploy-server", getEnv("DEPLOY_SERVER", DefaultDeployServer), "URL to API server. (env DEPLOY_SERVER)")
 	flag.StringVar(&cfg.Cluster, "cluster", os.Getenv("CLUSTER"), "NAIS cluster to deploy into. (env CLUSTER)")
+	flag.StringVar(&cfg.CorrelationID, "correlation-id", os.Getenv("CORRELATION_ID"), "Correlation ID of the deployment to cancel or show the log of. (env CORRELATION_ID)")
 	flag.StringVar(&cfg.Environment, "environment", os.Getenv("ENVIRONMENT"), "Environment for GitHub deployment. Autodetected from nais.yaml if not specified. (env ENVIRONMENT)")
 	flag.BoolVar(&cfg.DryRun, "dry-run", getEnvBool("DRY_RUN"), "Run templating, but don't actually make any requests. (env DRY_RUN)")
+	flag.BoolVar(&cfg.FreezeOverride, "freeze-override", getEnvBool("FREEZE_OVERRIDE"), "Deploy even if a deployment freeze is in effect. Use for emergency fixes only; overrides are audited. (env FREEZE_OVERRIDE)")
+	flag.StringVar(&cfg.Output, "output", getEnv("OUTPUT", OutputText), "Output format of 'deploy diff'; either 'text' or 'json'. (env OUTPUT)")
 	flag.StringVar(&cfg.Owner, "owner", getEnv("OWNER", DefaultOwner), "Owner of GitHub repository. (env OWNER)")
 	flag.BoolVar(&cfg.PrintPayload, "print-payload", getEnvBool("PRINT_PAYLOAD"), "Print templated resources to standard output. (env PRINT_PAYLOAD)")
 	flag.BoolVar(&cfg.Quiet, "quiet", getEnvBool("QUIET"), "Suppress printing of informational messages except errors. (env QUIET)")
 	flag.StringVar(&cfg.Ref, "ref", getEnv("REF", DefaultRef), "Git commit hash, tag, or branch of the code being deployed. (env REF)")
 	flag.StringSliceVar(&cfg.Resource, "resource", getEnvStringSlice("RESOURCE"), "File with Kubernetes resource. Can be specified multiple times. (env RESOURCE)")
+	flag.IntVar(&cfg.Retries, "retries", getEnvInt("RETRIES", DefaultRetries), "Number of times to retry the deployment request if the server reports a temporary error. (env RETRIES)")
 	flag.StringVar(&cfg.Repository, "repository", os.Getenv("REPOSITORY"), "Name of GitHub repository. (env REPOSITORY)")
+	flag.BoolVar(&cfg.Rollback, "rollback", getEnvBool("ROLLBACK"), "When cancelling, roll resources back to their previous version. (env ROLLBACK)")
 	flag.StringVar(&cfg.Team, "team", os.Getenv("TEAM"), "Team making the deployment. Auto-detected from nais.yaml if possible. (env TEAM)")
+	flag.DurationVar(&cfg.Timeout, "timeout", getEnvDuration("TIMEOUT"), "Time to wait for the rollout to complete before the deployment fails. Uses the cluster default if not specified. (env TIMEOUT)")
+	flag.StringVar(&cfg.Traceparent, "traceparent", os.Getenv("TRACEPARENT"), "W3C trace context of the pipeline making the deployment, which hookd and deployd continue. (env TRACEPARENT)")
+	flag.DurationVar(&cfg.TTL, "ttl", getEnvDuration("TTL"), "Discard the deployment request if it has not been processed within this time. Uses the server default if not specified. (env TTL)")
+	flag.StringVar(&cfg.User, "user", getEnv("GITHUB_ACTOR", os.Getenv("USER")), "Person deploying or cancelling; may not approve their own deployment, and is shown in the status of cancelled deployments. (env GITHUB_ACTOR or USER)")
 	flag.StringSliceVar(&cfg.Variables, "var", getEnvStringSlice("VAR"), "Template variable in the form KEY=VALUE. Can be specified multiple times. (env VAR)")
 	flag.StringVar(&cfg.VariablesFile, "vars", os.Getenv("VARS"), "File containing template variables. (env VARS)")
-	flag.BoolVar(&cfg.Wait, "wait", getEnvBool("WAIT"), "Block until deployment reaches final state (success, failure, error). (env WAIT)")
+	flag.BoolVar(&cfg.Wait, "wait", getEnvBool("WAIT"), "Block until deployment reaches final state (success, failure, error). With 'deploy logs', follow the log until then. (env WAIT)")
 
-	// Purposely do not expose the PollInterval variable
+	// Purposely do not expose the PollInterval and RetryInterval variables
 	cfg.PollInterval = DefaultPollInterval
+	cfg.RetryInterval = DefaultRetryInterval
 
 	flag.Parse()
+
+	cfg.Command = flag.Arg(0)
 }
 
 // config return user input and default values as Config.
@@ -80,6 +103,24 @@ func getEnvStringSlice(key string) []string {
 	return []string{}
 }
 
+func getEnvInt(key string, fallback int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return i
+}
+
+func getEnvDuration(key string) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return 0
+	}
+
+	return d
+}
+
 func getEnvBool(key string) bool {
 	b, err := strconv.ParseBool(os.Getenv(key))
 	if err != nil {
@@ -2,8 +2,7 @@ package deployer
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -18,7 +17,10 @@ import (
 	"github.com/aymerick/raymond"
 	"github.com/ghodss/yaml"
 	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/tracing"
 	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/cancel"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/client"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/status"
 	log "github.com/sirupsen/logrus"
@@ -31,19 +33,26 @@ type ActionsFormatter struct{}
 type ExitCode int
 
 const (
-	DeployAPIPath       = "/api/v1/deploy"
-	StatusAPIPath       = "/api/v1/status"
-	DefaultPollInterval = time.Second * 5
-	DefaultRef          = "master"
-	DefaultOwner        = "navikt"
-	DefaultDeployServer = "https://deployment.prod-sbs.nais.io"
-
-	ResourceRequiredMsg   = "at least one Kubernetes resource is required to make sense of the deployment"
-	APIKeyRequiredMsg     = "API key required"
-	MalformedURLMsg       = "wrong format of deployment server URL"
-	ClusterRequiredMsg    = "cluster required; see https://doc.nais.io/clusters"
-	RepositoryRequiredMsg = "repository required"
-	MalformedAPIKeyMsg    = "API key must be a hex encoded string"
+	DefaultPollInterval  = time.Second * 5
+	DefaultRetries       = 3
+	DefaultRetryInterval = time.Second * 5
+	DefaultRef           = "master"
+	DefaultOwner         = "navikt"
+	DefaultDeployServer  = "https://deployment.prod-sbs.nais.io"
+
+	ResourceRequiredMsg      = "at least one Kubernetes resource is required to make sense of the deployment"
+	APIKeyRequiredMsg        = "API key required"
+	MalformedURLMsg          = "wrong format of deployment server URL"
+	ClusterRequiredMsg       = "cluster required; see https://doc.nais.io/clusters"
+	RepositoryRequiredMsg    = "repository required"
+	MalformedAPIKeyMsg       = "API key must be a hex encoded string"
+	TeamRequiredMsg          = "team required"
+	CorrelationIDRequiredMsg = "correlation ID of the deployment required"
+	OutputFormatMsg          = "output format must be either 'text' or 'json'"
+	MalformedTraceparentMsg  = "traceparent must be a W3C trace context header value"
+	CancelCommand            = "cancel"
+	DiffCommand              = "diff"
+	LogsCommand              = "logs"
 )
 
 // Kept separate to avoid skewing exit codes
@@ -57,16 +66,35 @@ const (
 	ExitInvocationFailure
 	ExitInternalError
 	ExitTemplateError
+	ExitAuthenticationFailure
+	ExitDeploymentFrozen
+	ExitDryRunFailure
 )
 
 type Deployer struct {
 	Client       *http.Client
 	DeployServer string
+	// Stdout receives diffs. Defaults to os.Stdout.
+	Stdout io.Writer
 }
 
 func (d *Deployer) Run(cfg Config) (ExitCode, error) {
 	setupLogging(cfg.Actions, cfg.Quiet)
 
+	switch cfg.Command {
+	case "":
+	case CancelCommand:
+		return d.Cancel(cfg)
+	case LogsCommand:
+		return d.Logs(cfg)
+	case DiffCommand:
+		if cfg.Output != OutputText && cfg.Output != OutputJSON {
+			return ExitInvocationFailure, fmt.Errorf(OutputFormatMsg)
+		}
+	default:
+		return ExitInvocationFailure, fmt.Errorf("unknown command '%s'", cfg.Command)
+	}
+
 	if err := validate(cfg); err != nil {
 		if !cfg.DryRun {
 			return ExitInvocationFailure, err
@@ -78,9 +106,6 @@ func (d *Deployer) Run(cfg Config) (ExitCode, error) {
 	var err error
 	var templateVariables = make(TemplateVariables)
 
-	targetURL, _ := url.Parse(d.DeployServer)
-	targetURL.Path = DeployAPIPath
-
 	if len(cfg.VariablesFile) > 0 {
 		templateVariables, err = templateVariablesFromFile(cfg.VariablesFile)
 		if err != nil {
@@ -154,22 +179,19 @@ func (d *Deployer) Run(cfg Config) (ExitCode, error) {
 		}
 	}
 
-	data := make([]byte, 0)
-	buf := bytes.NewBuffer(data)
 	allResources, err := wrapResources(resources)
-
 	if err != nil {
 		return ExitInvocationFailure, err
 	}
 
-	err = mkpayload(buf, allResources, cfg)
-
-	if err != nil {
-		return ExitInvocationFailure, err
-	}
+	request := mkrequest(allResources, cfg)
 
 	if cfg.PrintPayload {
-		fmt.Printf(buf.String())
+		payload, err := json.MarshalIndent(request, "", "  ")
+		if err != nil {
+			return ExitInvocationFailure, err
+		}
+		fmt.Println(string(payload))
 	}
 
 	if cfg.DryRun {
@@ -182,41 +204,49 @@ func (d *Deployer) Run(cfg Config) (ExitCode, error) {
 		return ExitInvocationFailure, fmt.Errorf("%s: %s", MalformedAPIKeyMsg, err)
 	}
 
-	sig := sign(buf.Bytes(), decoded)
-
-	req, err := http.NewRequest(http.MethodPost, targetURL.String(), buf)
+	ctx, err := traceContext(cfg)
+	if err != nil {
+		return ExitInvocationFailure, err
+	}
 
+	client, err := api_v1_client.New(d.DeployServer, d.Client)
 	if err != nil {
-		return ExitInternalError, fmt.Errorf("internal error creating http request: %v", err)
+		return ExitInvocationFailure, fmt.Errorf("%s: %s", MalformedURLMsg, err)
 	}
 
-	req.Header.Add("content-type", "application/json")
-	req.Header.Add(api_v1.SignatureHeader, fmt.Sprintf("%s", sig))
-	log.Infof("Submitting deployment request to %s...", targetURL.String())
-	resp, err := d.Client.Do(req)
+	if cfg.Command == DiffCommand {
+		return d.Diff(ctx, client, decoded, request, cfg)
+	}
 
-	if err != nil {
+	log.Infof("Submitting deployment request to %s...", d.DeployServer)
+	response, resp, err := submit(ctx, client, decoded, request, cfg)
+
+	if resp == nil {
 		return ExitUnavailable, err
 	}
 
 	log.Infof("status....: %s", resp.Status)
-	response := &api_v1_deploy.DeploymentResponse{}
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(response)
 
-	if err != nil {
-		return ExitUnavailable, fmt.Errorf("received invalid response from server: %s", err)
+	errorResponse, failed := err.(*api_v1_client.ErrorResponse)
+	if err != nil && !failed {
+		return ExitUnavailable, err
 	}
 
 	log.Infof("message...: %s", response.Message)
+	log.Infof("id........: %s", response.CorrelationID)
 	log.Infof("logs......: %s", response.LogURL)
 
 	if response.GithubDeployment != nil {
 		log.Infof("github....: %s", response.GithubDeployment.GetURL())
 	}
 
-	if resp.StatusCode != http.StatusCreated {
-		return ExitNoDeployment, fmt.Errorf("deployment failed: %s", response.Message)
+	if failed {
+		if errorResponse.Details != nil {
+			for _, field := range errorResponse.Details.Fields {
+				log.Errorf("%s: %s", field.Field, field.Message)
+			}
+		}
+		return exitCode(errorResponse), fmt.Errorf("deployment failed: %s", errorResponse)
 	}
 
 	if !cfg.Wait {
@@ -226,7 +256,7 @@ func (d *Deployer) Run(cfg Config) (ExitCode, error) {
 	log.Infof("Polling deployment status until it has reached its final state...")
 
 	for {
-		cont, status, err := check(response.GithubDeployment.GetID(), decoded, *targetURL, cfg)
+		cont, status, err := check(ctx, client, response.GithubDeployment.GetID(), decoded, cfg)
 		if !cont {
 			return status, err
 		}
@@ -237,6 +267,116 @@ func (d *Deployer) Run(cfg Config) (ExitCode, error) {
 	}
 }
 
+// Cancel asks hookd to cancel the deployment identified by cfg.CorrelationID.
+// The cancellation is carried out asynchronously; use --wait on the original deployment to follow its final status.
+func (d *Deployer) Cancel(cfg Config) (ExitCode, error) {
+	if err := validateCorrelated(cfg); err != nil {
+		return ExitInvocationFailure, err
+	}
+
+	decoded, err := hex.DecodeString(cfg.APIKey)
+	if err != nil {
+		return ExitInvocationFailure, fmt.Errorf("%s: %s", MalformedAPIKeyMsg, err)
+	}
+
+	ctx, err := traceContext(cfg)
+	if err != nil {
+		return ExitInvocationFailure, err
+	}
+
+	client, err := api_v1_client.New(d.DeployServer, d.Client)
+	if err != nil {
+		return ExitInvocationFailure, fmt.Errorf("%s: %s", MalformedURLMsg, err)
+	}
+
+	request := api_v1_cancel.CancelRequest{
+		Team:      cfg.Team,
+		User:      cfg.User,
+		Rollback:  cfg.Rollback,
+		Timestamp: api_v1.Timestamp(time.Now().Unix()),
+	}
+
+	log.Infof("Submitting cancellation of deployment %s to %s...", cfg.CorrelationID, d.DeployServer)
+	response, resp, err := client.Cancel(ctx, decoded, cfg.CorrelationID, request)
+
+	if resp == nil {
+		return ExitUnavailable, err
+	}
+
+	log.Infof("status....: %s", resp.Status)
+
+	errorResponse, failed := err.(*api_v1_client.ErrorResponse)
+	if err != nil && !failed {
+		return ExitUnavailable, err
+	}
+
+	log.Infof("message...: %s", response.Message)
+
+	if failed {
+		return exitCode(errorResponse), fmt.Errorf("cancellation failed: %s", errorResponse)
+	}
+
+	return ExitSuccess, nil
+}
+
+// submit sends the deployment request, and retries it as long as the server reports a temporary error.
+func submit(ctx context.Context, client *api_v1_client.Client, key []byte, request api_v1_deploy.DeploymentRequest, cfg Config) (*api_v1_deploy.DeploymentResponse, *http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		response, resp, err := client.Deploy(ctx, key, request)
+		errorResponse, ok := err.(*api_v1_client.ErrorResponse)
+		if !ok || !errorResponse.Retryable() || attempt > cfg.Retries {
+			return response, resp, err
+		}
+		log.Warnf("Deployment request failed: %s", errorResponse)
+		log.Warnf("Retrying in %s (retry %d of %d)", cfg.RetryInterval, attempt, cfg.Retries)
+		time.Sleep(cfg.RetryInterval)
+		request.Timestamp = api_v1.Timestamp(time.Now().Unix())
+	}
+}
+
+// traceContext returns the context of requests to hookd.
+// If a traceparent was given, hookd continues that trace instead of starting a new one.
+func traceContext(cfg Config) (context.Context, error) {
+	ctx := context.Background()
+	if len(cfg.Traceparent) == 0 {
+		return ctx, nil
+	}
+
+	sc, err := tracing.ParseTraceparent(cfg.Traceparent)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", MalformedTraceparentMsg, err)
+	}
+
+	log.Infof("Continuing trace %s", sc.TraceID)
+
+	return tracing.ContextWithSpanContext(ctx, sc), nil
+}
+
+// exitCode maps a failed deployment request to an exit code, using the error code if the server provided one.
+func exitCode(errorResponse *api_v1_client.ErrorResponse) ExitCode {
+	if errorResponse.Details == nil {
+		return ExitNoDeployment
+	}
+
+	switch errorResponse.Details.Code {
+	case api_v1.ErrorCodeValidationFailed, api_v1.ErrorCodeClusterUnknown, api_v1.ErrorCodeBodyMalformed:
+		return ExitInvocationFailure
+	case api_v1.ErrorCodeSignatureMalformed, api_v1.ErrorCodeSignatureInvalid, api_v1.ErrorCodeTeamNoAPIKey,
+		api_v1.ErrorCodeTeamNotOnGithub, api_v1.ErrorCodeTeamNoRepoAccess:
+		return ExitAuthenticationFailure
+	case api_v1.ErrorCodeDeploymentFrozen:
+		return ExitDeploymentFrozen
+	case api_v1.ErrorCodeDryRunFailed:
+		return ExitDryRunFailure
+	}
+
+	if errorResponse.Details.Retryable {
+		return ExitUnavailable
+	}
+
+	return ExitNoDeployment
+}
+
 func setupLogging(actions, quiet bool) {
 	log.SetOutput(os.Stderr)
 
@@ -258,8 +398,8 @@ func setupLogging(actions, quiet bool) {
 // Check if a deployment has reached a terminal state.
 // The first return value is true if the state might change, false otherwise.
 // Additionally, returns an error if any error occurred.
-func check(deploymentID int64, key []byte, targetURL url.URL, cfg Config) (bool, ExitCode, error) {
-	statusReq := &api_v1_status.StatusRequest{
+func check(ctx context.Context, client *api_v1_client.Client, deploymentID int64, key []byte, cfg Config) (bool, ExitCode, error) {
+	statusReq := api_v1_status.StatusRequest{
 		DeploymentID: deploymentID,
 		Team:         cfg.Team,
 		Owner:        cfg.Owner,
@@ -267,46 +407,27 @@ func check(deploymentID int64, key []byte, targetURL url.URL, cfg Config) (bool,
 		Timestamp:    api_v1.Timestamp(time.Now().Unix()),
 	}
 
-	payload, err := json.Marshal(statusReq)
-	if err != nil {
-		return false, ExitInternalError, fmt.Errorf("unable to marshal status request: %s", err)
+	response, resp, err := client.Status(ctx, key, statusReq)
+	if resp == nil {
+		return true, ExitInternalError, fmt.Errorf("error making request: %s", err)
 	}
 
-	targetURL.Path = StatusAPIPath
-	buf := bytes.NewBuffer(payload)
-	req, err := http.NewRequest(http.MethodPost, targetURL.String(), buf)
-	if err != nil {
-		return false, ExitInternalError, fmt.Errorf("internal error creating http request: %v", err)
+	if errorResponse, ok := err.(*api_v1_client.ErrorResponse); ok {
+		if !errorResponse.Retryable() {
+			return false, ExitInternalError, fmt.Errorf("bad request: %s", errorResponse)
+		}
+		log.Infof("status....: %s", resp.Status)
+		log.Infof("message...: %s", errorResponse.Message)
+		return true, ExitSuccess, nil
 	}
 
-	signature := sign(payload, key)
-	req.Header.Add("content-type", "application/json")
-	req.Header.Add(api_v1.SignatureHeader, signature)
-
-	resp, err := http.DefaultClient.Do(req)
-	if resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 {
-		return false, ExitInternalError, fmt.Errorf("bad request: %s", err)
-	}
 	if err != nil {
-		return true, ExitInternalError, fmt.Errorf("error making request: %s", err)
+		return true, ExitInternalError, err
 	}
 
 	if resp.StatusCode == http.StatusNoContent {
 		log.Info("deployment: pending creation on GitHub")
 		return true, ExitSuccess, nil
-	} else if resp.StatusCode != http.StatusOK {
-		log.Infof("status....: %s", resp.Status)
-	}
-
-	response := &api_v1_status.StatusResponse{}
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(response)
-	if err != nil {
-		return true, ExitInternalError, fmt.Errorf("received invalid response from server: %s", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		log.Infof("message...: %s", response.Message)
 	}
 
 	if response.Status == nil {
@@ -315,45 +436,49 @@ func check(deploymentID int64, key []byte, targetURL url.URL, cfg Config) (bool,
 
 	log.Infof("deployment: %s", *response.Status)
 
-	status := types.GithubDeploymentState(types.GithubDeploymentState_value[*response.Status])
-	switch status {
+	code, final := stateExitCode(*response.Status)
+	return !final, code, nil
+}
+
+// stateExitCode returns the exit code for a deployment state, and whether the state is final.
+func stateExitCode(state string) (ExitCode, bool) {
+	switch types.GithubDeploymentState(types.GithubDeploymentState_value[state]) {
 	case types.GithubDeploymentState_success:
-		return false, ExitSuccess, nil
+		return ExitSuccess, true
 	case types.GithubDeploymentState_error:
-		return false, ExitDeploymentError, nil
+		return ExitDeploymentError, true
 	case types.GithubDeploymentState_failure:
-		return false, ExitDeploymentFailure, nil
+		return ExitDeploymentFailure, true
 	case types.GithubDeploymentState_inactive:
-		return false, ExitDeploymentInactive, nil
+		return ExitDeploymentInactive, true
 	}
 
-	return true, ExitSuccess, nil
+	return ExitSuccess, false
 }
 
-func mkpayload(w io.Writer, resources json.RawMessage, cfg Config) error {
-	req := api_v1_deploy.DeploymentRequest{
-		Resources:   resources,
-		Team:        cfg.Team,
-		Cluster:     cfg.Cluster,
-		Environment: cfg.Environment,
-		Ref:         cfg.Ref,
-		Owner:       cfg.Owner,
-		Repository:  cfg.Repository,
-		Timestamp:   time.Now().Unix(),
+func mkrequest(resources json.RawMessage, cfg Config) api_v1_deploy.DeploymentRequest {
+	request := api_v1_deploy.DeploymentRequest{
+		Resources:      resources,
+		Team:           cfg.Team,
+		Cluster:        cfg.Cluster,
+		Environment:    cfg.Environment,
+		Ref:            cfg.Ref,
+		Owner:          cfg.Owner,
+		Repository:     cfg.Repository,
+		FreezeOverride: cfg.FreezeOverride,
+		User:           cfg.User,
+		Timestamp:      api_v1.Timestamp(time.Now().Unix()),
 	}
 
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-
-	return enc.Encode(req)
-}
+	if cfg.Timeout > 0 {
+		request.Timeout = cfg.Timeout.String()
+	}
 
-func sign(data, key []byte) string {
-	hasher := hmac.New(sha256.New, key)
-	hasher.Write(data)
-	sum := hasher.Sum(nil)
+	if cfg.TTL > 0 {
+		request.TTL = cfg.TTL.String()
+	}
 
-	return hex.EncodeToString(sum)
+	return request
 }
 
 func detectTeam(resource json.RawMessage) string {
@@ -496,6 +621,23 @@ func (a *ActionsFormatter) Format(e *log.Entry) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// validateCorrelated validates the configuration of commands acting on an existing deployment.
+func validateCorrelated(cfg Config) error {
+	if len(cfg.CorrelationID) == 0 {
+		return fmt.Errorf(CorrelationIDRequiredMsg)
+	}
+
+	if len(cfg.Team) == 0 {
+		return fmt.Errorf(TeamRequiredMsg)
+	}
+
+	if len(cfg.APIKey) == 0 {
+		return fmt.Errorf(APIKeyRequiredMsg)
+	}
+
+	return nil
+}
+
 func validate(cfg Config) error {
 	if len(cfg.Resource) == 0 {
 		return fmt.Errorf(ResourceRequiredMsg)
@@ -1,6 +1,7 @@
 package deployer_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -8,9 +9,14 @@ import (
 	"time"
 
 	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/tracing"
 	"github.com/navikt/deployment/deploy/pkg/deployer"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/cancel"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/logs"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/status"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,6 +34,7 @@ func TestHappyPath(t *testing.T) {
 		assert.Equal(t, deployRequest.Team, "aura", "auto-detection of team works")
 		assert.Equal(t, deployRequest.Owner, deployer.DefaultOwner, "defaulting works")
 		assert.Equal(t, deployRequest.Environment, "dev-fss:nais", "auto-detection of environment works")
+		assert.Empty(t, deployRequest.Timeout, "cluster default rollout timeout is used")
 
 		b, err := json.Marshal(&api_v1_deploy.DeploymentResponse{})
 
@@ -45,6 +52,31 @@ func TestHappyPath(t *testing.T) {
 	assert.Equal(t, exitCode, deployer.ExitSuccess)
 }
 
+func TestTimeouts(t *testing.T) {
+	cfg := validConfig()
+	cfg.Timeout = 45 * time.Minute
+	cfg.TTL = 5 * time.Minute
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deployRequest := api_v1_deploy.DeploymentRequest{}
+
+		if err := json.NewDecoder(r.Body).Decode(&deployRequest); err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, "45m0s", deployRequest.Timeout)
+		assert.Equal(t, "5m0s", deployRequest.TTL)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&api_v1_deploy.DeploymentResponse{})
+	}))
+
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL}
+
+	exitCode, err := d.Run(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, deployer.ExitSuccess, exitCode)
+}
+
 func TestWaitForComplete(t *testing.T) {
 	requests := 0
 	cfg := validConfig()
@@ -119,6 +151,68 @@ func TestWaitForTheInevitableEventualFailure(t *testing.T) {
 	assert.Equal(t, exitCode, deployer.ExitDeploymentFailure)
 }
 
+func TestRetryTemporaryErrors(t *testing.T) {
+	requests := 0
+	cfg := validConfig()
+	cfg.RetryInterval = time.Millisecond * 1
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(&api_v1_deploy.DeploymentResponse{
+				Error: api_v1.NewError(api_v1.ErrorCodeGithubUnavailable),
+			})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&api_v1_deploy.DeploymentResponse{})
+	}))
+
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL}
+
+	exitCode, err := d.Run(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, deployer.ExitSuccess, exitCode)
+	assert.Equal(t, 3, requests)
+}
+
+func TestErrorCodes(t *testing.T) {
+	for _, testCase := range []struct {
+		statusCode int
+		err        *api_v1.Error
+		exitCode   deployer.ExitCode
+		requests   int
+	}{
+		{http.StatusBadRequest, api_v1.NewError(api_v1.ErrorCodeClusterUnknown), deployer.ExitInvocationFailure, 1},
+		{http.StatusForbidden, api_v1.NewError(api_v1.ErrorCodeTeamNoRepoAccess), deployer.ExitAuthenticationFailure, 1},
+		{http.StatusLocked, api_v1.NewError(api_v1.ErrorCodeDeploymentFrozen), deployer.ExitDeploymentFrozen, 1},
+		{http.StatusBadGateway, api_v1.NewError(api_v1.ErrorCodeGithubUnavailable), deployer.ExitUnavailable, 3},
+		{http.StatusInternalServerError, api_v1.NewError(api_v1.ErrorCodeDeploymentAborted), deployer.ExitNoDeployment, 1},
+		{http.StatusInternalServerError, nil, deployer.ExitNoDeployment, 3},
+	} {
+		requests := 0
+		cfg := validConfig()
+		cfg.Retries = 2
+		cfg.RetryInterval = time.Millisecond * 1
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(testCase.statusCode)
+			json.NewEncoder(w).Encode(&api_v1_deploy.DeploymentResponse{Error: testCase.err})
+		}))
+
+		d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL}
+
+		exitCode, err := d.Run(cfg)
+		assert.Error(t, err)
+		assert.Equal(t, testCase.exitCode, exitCode)
+		assert.Equal(t, testCase.requests, requests)
+
+		server.Close()
+	}
+}
+
 func TestValidationFailures(t *testing.T) {
 	for _, testCase := range []struct {
 		errorMsg  string
@@ -139,6 +233,250 @@ func TestValidationFailures(t *testing.T) {
 	}
 }
 
+func TestCancel(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.CancelCommand
+	cfg.CorrelationID = "abc"
+	cfg.Team = "aura"
+	cfg.User = "jane"
+	cfg.Rollback = true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancelRequest := api_v1_cancel.CancelRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&cancelRequest); err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, "/api/v1/deploy/abc/cancel", r.URL.Path)
+		assert.Equal(t, "aura", cancelRequest.Team)
+		assert.Equal(t, "jane", cancelRequest.User)
+		assert.True(t, cancelRequest.Rollback)
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(&api_v1_cancel.CancelResponse{})
+	}))
+	defer server.Close()
+
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL}
+
+	exitCode, err := d.Run(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, deployer.ExitSuccess, exitCode)
+}
+
+func TestCancelFinishedDeployment(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.CancelCommand
+	cfg.CorrelationID = "abc"
+	cfg.Team = "aura"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(&api_v1_cancel.CancelResponse{Error: api_v1.NewError(api_v1.ErrorCodeDeploymentFinished)})
+	}))
+	defer server.Close()
+
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL}
+
+	exitCode, err := d.Run(cfg)
+	assert.Error(t, err)
+	assert.Equal(t, deployer.ExitNoDeployment, exitCode)
+}
+
+func TestCancelValidationFailures(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.CancelCommand
+	cfg.Team = "aura"
+
+	d := deployer.Deployer{}
+	exitCode, err := d.Run(cfg)
+	assert.Equal(t, deployer.ExitInvocationFailure, exitCode)
+	assert.Contains(t, err.Error(), deployer.CorrelationIDRequiredMsg)
+
+	cfg.CorrelationID = "abc"
+	cfg.Team = ""
+	exitCode, err = d.Run(cfg)
+	assert.Equal(t, deployer.ExitInvocationFailure, exitCode)
+	assert.Contains(t, err.Error(), deployer.TeamRequiredMsg)
+}
+
+func TestLogs(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.LogsCommand
+	cfg.CorrelationID = "abc"
+	cfg.Team = "aura"
+	cfg.Wait = true
+	cfg.PollInterval = time.Millisecond
+
+	ts := time.Date(2019, 11, 1, 12, 0, 0, 0, time.UTC)
+	lines := []logstore.Event{
+		{Time: ts, Cluster: "dev-fss", Level: "info", Message: "Resource 1: applied"},
+		{Time: ts, Cluster: "dev-fss", Level: "error", Message: "Deployment failed"},
+	}
+
+	offsets := make([]int, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := api_v1_logs.LogsRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, "/api/v1/deploy/abc/logs", r.URL.Path)
+		assert.Equal(t, "aura", request.Team)
+		offsets = append(offsets, request.Offset)
+
+		// one new line per request; finished once all lines have been sent
+		response := api_v1_logs.LogsResponse{}
+		if request.Offset < len(lines) {
+			response.Events = lines[request.Offset : request.Offset+1]
+		} else {
+			response.State = "failure"
+			response.Finished = true
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL, Stdout: out}
+
+	exitCode, err := d.Run(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, deployer.ExitDeploymentFailure, exitCode)
+	assert.Equal(t, []int{0, 1, 2}, offsets)
+	assert.Equal(t, "2019-11-01T12:00:00Z dev-fss [info] Resource 1: applied\n2019-11-01T12:00:00Z dev-fss [error] Deployment failed\n", out.String())
+}
+
+func TestLogsOtherTeam(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.LogsCommand
+	cfg.CorrelationID = "abc"
+	cfg.Team = "aura"
+	cfg.Wait = true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&api_v1_logs.LogsResponse{Error: api_v1.NewError(api_v1.ErrorCodeDeploymentNotFound)})
+	}))
+	defer server.Close()
+
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL}
+
+	exitCode, err := d.Run(cfg)
+	assert.Error(t, err)
+	assert.Equal(t, deployer.ExitNoDeployment, exitCode)
+}
+
+func diffServer(t *testing.T, statusCode int, response api_v1_deploy.DeploymentResponse) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/deploy", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("diff"))
+
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(&response)
+	}))
+}
+
+func TestDiff(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.DiffCommand
+
+	server := diffServer(t, http.StatusOK, api_v1_deploy.DeploymentResponse{
+		Resources: []api_v1_deploy.ResourceResult{
+			{Kind: "Application", Namespace: "nais", Name: "myapp", Diff: "--- live/Application/nais/myapp\n+++ desired/Application/nais/myapp\n@@ -1 +1 @@\n-image: foo:1\n+image: foo:2\n"},
+			{Kind: "Alert", Namespace: "nais", Name: "myapp"},
+		},
+	})
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL, Stdout: out}
+
+	exitCode, err := d.Run(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, deployer.ExitSuccess, exitCode)
+	assert.Equal(t, "--- live/Application/nais/myapp\n+++ desired/Application/nais/myapp\n@@ -1 +1 @@\n-image: foo:1\n+image: foo:2\n", out.String(), "diffs are printed without colour when not writing to a terminal")
+}
+
+func TestDiffJSON(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.DiffCommand
+	cfg.Output = deployer.OutputJSON
+
+	resources := []api_v1_deploy.ResourceResult{
+		{Kind: "Application", Namespace: "nais", Name: "myapp", Diff: "+image: foo:2\n"},
+	}
+	server := diffServer(t, http.StatusOK, api_v1_deploy.DeploymentResponse{Resources: resources})
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL, Stdout: out}
+
+	exitCode, err := d.Run(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, deployer.ExitSuccess, exitCode)
+
+	decoded := make([]api_v1_deploy.ResourceResult, 0)
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, resources, decoded)
+}
+
+func TestDiffRejected(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.DiffCommand
+	cfg.Output = deployer.OutputJSON
+
+	server := diffServer(t, http.StatusUnprocessableEntity, api_v1_deploy.DeploymentResponse{
+		Resources: []api_v1_deploy.ResourceResult{
+			{Kind: "Application", Namespace: "nais", Name: "myapp", Error: "admission webhook denied the request"},
+		},
+		Error: api_v1.NewError(api_v1.ErrorCodeDryRunFailed),
+	})
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL, Stdout: out}
+
+	exitCode, err := d.Run(cfg)
+	assert.Error(t, err)
+	assert.Equal(t, deployer.ExitDryRunFailure, exitCode)
+	assert.Contains(t, out.String(), "admission webhook denied the request", "rejected resources are part of the output")
+}
+
+func TestDiffInvalidOutput(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployer.DiffCommand
+	cfg.Output = "yaml"
+
+	d := deployer.Deployer{}
+	exitCode, err := d.Run(cfg)
+	assert.Equal(t, deployer.ExitInvocationFailure, exitCode)
+	assert.Contains(t, err.Error(), deployer.OutputFormatMsg)
+}
+
+func TestTraceparent(t *testing.T) {
+	cfg := validConfig()
+	cfg.Traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, cfg.Traceparent, r.Header.Get(tracing.TraceparentHeader), "trace context is forwarded to hookd")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api_v1_deploy.DeploymentResponse{})
+	}))
+
+	d := deployer.Deployer{Client: server.Client(), DeployServer: server.URL}
+
+	exitCode, err := d.Run(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, deployer.ExitSuccess, exitCode)
+
+	cfg.Traceparent = "not a traceparent"
+	exitCode, err = d.Run(cfg)
+	assert.Equal(t, deployer.ExitInvocationFailure, exitCode)
+	assert.Contains(t, err.Error(), deployer.MalformedTraceparentMsg)
+}
+
 func TestExitCodeZero(t *testing.T) {
 	assert.Equal(t, deployer.ExitCode(0), deployer.ExitSuccess)
 }
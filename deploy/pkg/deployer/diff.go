@@ -0,0 +1,142 @@
+package deployer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1/client"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// Diff asks hookd for a dry run of the deployment request, and prints how each resource
+// differs from the version currently running in the cluster. Nothing is deployed.
+func (d *Deployer) Diff(ctx context.Context, client *api_v1_client.Client, key []byte, request api_v1_deploy.DeploymentRequest, cfg Config) (ExitCode, error) {
+	log.Infof("Requesting diff from %s...", d.DeployServer)
+	response, resp, err := client.Diff(ctx, key, request)
+
+	if resp == nil {
+		return ExitUnavailable, err
+	}
+
+	log.Infof("status....: %s", resp.Status)
+
+	errorResponse, failed := err.(*api_v1_client.ErrorResponse)
+	if err != nil && !failed {
+		return ExitUnavailable, err
+	}
+
+	log.Infof("message...: %s", response.Message)
+
+	out := d.stdout()
+	switch cfg.Output {
+	case OutputJSON:
+		err = printDiffJSON(out, response.Resources)
+	default:
+		err = printDiffText(out, response.Resources, isTerminal(out))
+	}
+	if err != nil {
+		return ExitInternalError, fmt.Errorf("print diff: %s", err)
+	}
+
+	if failed {
+		if errorResponse.Details != nil {
+			for _, field := range errorResponse.Details.Fields {
+				log.Errorf("%s: %s", field.Field, field.Message)
+			}
+		}
+		return exitCode(errorResponse), fmt.Errorf("diff failed: %s", errorResponse)
+	}
+
+	return ExitSuccess, nil
+}
+
+func (d *Deployer) stdout() io.Writer {
+	if d.Stdout == nil {
+		return os.Stdout
+	}
+	return d.Stdout
+}
+
+// isTerminal reports whether output is written directly to a terminal, in which case diffs are coloured.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
+
+func printDiffJSON(w io.Writer, resources []api_v1_deploy.ResourceResult) error {
+	if resources == nil {
+		resources = make([]api_v1_deploy.ResourceResult, 0)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(resources)
+}
+
+// printDiffText prints the unified diff of each changed resource. Unchanged and rejected resources are logged.
+func printDiffText(w io.Writer, resources []api_v1_deploy.ResourceResult, color bool) error {
+	buf := bufio.NewWriter(w)
+
+	for _, resource := range resources {
+		name := fmt.Sprintf("%s %s", resource.Kind, resource.Name)
+		if len(resource.Namespace) > 0 {
+			name = fmt.Sprintf("%s %s/%s", resource.Kind, resource.Namespace, resource.Name)
+		}
+
+		switch {
+		case len(resource.Error) > 0:
+			log.Errorf("%s: %s", name, resource.Error)
+		case len(resource.Diff) == 0:
+			log.Infof("%s: no changes", name)
+		default:
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(resource.Diff, "\n"), "\n") {
+				buf.WriteString(colorize(strings.TrimSuffix(line, "\n"), color))
+				buf.WriteString("\n")
+			}
+		}
+	}
+
+	return buf.Flush()
+}
+
+// colorize highlights a line of a unified diff with ANSI escape codes.
+func colorize(line string, color bool) string {
+	if !color {
+		return line
+	}
+
+	var code string
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		code = colorBold
+	case strings.HasPrefix(line, "@@"):
+		code = colorCyan
+	case strings.HasPrefix(line, "+"):
+		code = colorGreen
+	case strings.HasPrefix(line, "-"):
+		code = colorRed
+	default:
+		return line
+	}
+
+	return code + line + colorReset
+}
@@ -0,0 +1,83 @@
+package deployer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/client"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/logs"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
+	log "github.com/sirupsen/logrus"
+)
+
+// Logs prints the log lines deployd has sent about the deployment identified by cfg.CorrelationID.
+// With cfg.Wait, it keeps polling for new lines until the deployment has reached a final state,
+// and exits with the same code as waiting for the deployment would.
+func (d *Deployer) Logs(cfg Config) (ExitCode, error) {
+	if err := validateCorrelated(cfg); err != nil {
+		return ExitInvocationFailure, err
+	}
+
+	decoded, err := hex.DecodeString(cfg.APIKey)
+	if err != nil {
+		return ExitInvocationFailure, fmt.Errorf("%s: %s", MalformedAPIKeyMsg, err)
+	}
+
+	ctx, err := traceContext(cfg)
+	if err != nil {
+		return ExitInvocationFailure, err
+	}
+
+	client, err := api_v1_client.New(d.DeployServer, d.Client)
+	if err != nil {
+		return ExitInvocationFailure, fmt.Errorf("%s: %s", MalformedURLMsg, err)
+	}
+
+	offset := 0
+
+	for {
+		request := api_v1_logs.LogsRequest{
+			Team:      cfg.Team,
+			Offset:    offset,
+			Timestamp: api_v1.Timestamp(time.Now().Unix()),
+		}
+
+		response, resp, err := client.Logs(ctx, decoded, cfg.CorrelationID, request)
+
+		errorResponse, failed := err.(*api_v1_client.ErrorResponse)
+		switch {
+		case resp == nil || (err != nil && !failed):
+			if !cfg.Wait {
+				return ExitUnavailable, err
+			}
+			log.Error(err)
+		case failed && (!cfg.Wait || !errorResponse.Retryable()):
+			return exitCode(errorResponse), fmt.Errorf("fetching logs failed: %s", errorResponse)
+		case failed:
+			log.Error(errorResponse)
+		default:
+			for _, event := range response.Events {
+				d.printEvent(event)
+			}
+			offset += len(response.Events)
+
+			if !cfg.Wait {
+				return ExitSuccess, nil
+			}
+
+			if response.Finished {
+				log.Infof("deployment: %s", response.State)
+				code, _ := stateExitCode(response.State)
+				return code, nil
+			}
+		}
+
+		time.Sleep(cfg.PollInterval)
+	}
+}
+
+func (d *Deployer) printEvent(event logstore.Event) {
+	fmt.Fprintf(d.stdout(), "%s %s [%s] %s\n", event.Time.Format(time.RFC3339Nano), event.Cluster, event.Level, event.Message)
+}
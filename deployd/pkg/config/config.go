@@ -2,8 +2,17 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/navikt/deployment/common/pkg/kafka"
+	"github.com/navikt/deployment/common/pkg/tracing"
+)
+
+const (
+	// ConcurrencyPolicySupersede stops monitoring older deployments of an application when a newer one arrives.
+	ConcurrencyPolicySupersede = "supersede"
+	// ConcurrencyPolicyQueue holds newer deployments of an application until older ones have finished.
+	ConcurrencyPolicyQueue = "queue"
 )
 
 type Config struct {
@@ -15,7 +24,11 @@ type Config struct {
 	TeamNamespaces           bool
 	AutoCreateServiceAccount bool
 	EncryptionKey            string
+	ShutdownTimeout          time.Duration
+	RolloutTimeout           time.Duration
+	ConcurrencyPolicy        string
 	Kafka                    kafka.Config
+	Tracing                  tracing.Config
 }
 
 func getEnv(key, fallback string) string {
@@ -34,7 +47,11 @@ func DefaultConfig() *Config {
 		MetricsPath:              "/metrics",
 		TeamNamespaces:           false,
 		AutoCreateServiceAccount: true,
+		ShutdownTimeout:          time.Second * 20,
+		RolloutTimeout:           time.Minute * 30,
+		ConcurrencyPolicy:        ConcurrencyPolicySupersede,
 		Kafka:                    kafka.DefaultConfig(),
+		Tracing:                  tracing.DefaultConfig(),
 		EncryptionKey:            getEnv("ENCRYPTION_KEY", "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"),
 	}
 }
@@ -1,23 +1,26 @@
 package deployd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/tracing"
 	"github.com/navikt/deployment/deployd/pkg/config"
+	"github.com/navikt/deployment/deployd/pkg/diff"
 	"github.com/navikt/deployment/deployd/pkg/kubeclient"
 	"github.com/navikt/deployment/deployd/pkg/metrics"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var (
 	ErrNotMyCluster     = fmt.Errorf("your message belongs in another cluster")
 	ErrDeadlineExceeded = fmt.Errorf("deadline exceeded")
-
-	deploymentTimeout = time.Minute * 30
 )
 
 const (
@@ -40,6 +43,14 @@ func meetsDeadline(req deployment.DeploymentRequest) error {
 	return nil
 }
 
+// rolloutTimeout returns the rollout timeout requested by the deployment, or the default if it did not specify one.
+func rolloutTimeout(req deployment.DeploymentRequest, fallback time.Duration) time.Duration {
+	if req.GetRolloutTimeout() > 0 {
+		return time.Duration(req.GetRolloutTimeout()) * time.Second
+	}
+	return fallback
+}
+
 func monitorableResource(resource *unstructured.Unstructured) bool {
 	gvk := resource.GroupVersionKind()
 	if gvk.Kind == "Application" && gvk.Group == "nais.io" {
@@ -72,6 +83,18 @@ func addCorrelationID(resource *unstructured.Unstructured, correlationID string)
 	resource.SetAnnotations(anno)
 }
 
+// startSpan starts a span in the trace of a deployment request, and records which resource it concerns.
+func startSpan(ctx context.Context, req *deployment.DeploymentRequest, name string, resource *unstructured.Unstructured) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(tracing.ContextWithTraceparent(ctx, req.GetTraceparent()), name, tracing.KindInternal)
+	span.SetAttribute(deployment.LogFieldDeliveryID, req.GetDeliveryID())
+	if resource != nil {
+		span.SetAttribute("kind", resource.GetKind())
+		span.SetAttribute("namespace", resource.GetNamespace())
+		span.SetAttribute("name", resource.GetName())
+	}
+	return ctx, span
+}
+
 // Prepare decodes a string of bytes into a deployment request,
 // and decides whether or not to allow a deployment.
 //
@@ -82,7 +105,11 @@ func Prepare(req *deployment.DeploymentRequest, cluster string) error {
 		return err
 	}
 
-	// Messages that are too old are discarded
+	// Messages that are too old are discarded. Resumed deployments were accepted by another instance,
+	// and their rollout must be monitored to completion.
+	if req.GetResume() {
+		return nil
+	}
 	if err := meetsDeadline(*req); err != nil {
 		return err
 	}
@@ -90,7 +117,7 @@ func Prepare(req *deployment.DeploymentRequest, cluster string) error {
 	return nil
 }
 
-func Run(logger *log.Entry, req *deployment.DeploymentRequest, cfg config.Config, kube kubeclient.TeamClientProvider, deployStatus chan *deployment.DeploymentStatus) {
+func Run(logger *log.Entry, req *deployment.DeploymentRequest, cfg config.Config, kube kubeclient.TeamClientProvider, deployStatus chan *deployment.DeploymentStatus, monitors *Monitors) {
 	var namespace string
 
 	// Check the validity of the message.
@@ -101,6 +128,11 @@ func Run(logger *log.Entry, req *deployment.DeploymentRequest, cfg config.Config
 	if err != nil {
 		if err != ErrNotMyCluster {
 			logger.Tracef("Drop message: %s", err)
+			if !req.ReportsStatus() {
+				// The deployment being cancelled owns the GitHub deployment status, not the cancellation.
+				// Nobody is waiting for the results of an expired dry run.
+				return
+			}
 			deployStatus <- deployment.NewFailureStatus(*req, err)
 		} else {
 			logger.Tracef("Drop message: running in %s, but deployment is addressed to %s", cfg.Cluster, req.GetCluster())
@@ -108,6 +140,26 @@ func Run(logger *log.Entry, req *deployment.DeploymentRequest, cfg config.Config
 		return
 	}
 
+	// Spans and statuses produced while handling the request are part of this span.
+	ctx := tracing.ContextWithTraceparent(context.Background(), req.GetTraceparent())
+	_, span := tracing.Start(ctx, "kafka.receive", tracing.KindConsumer)
+	span.SetAttribute(deployment.LogFieldDeliveryID, req.GetDeliveryID())
+	span.SetAttribute(deployment.LogFieldCluster, req.GetCluster())
+	defer span.Finish()
+	req.Traceparent = span.Traceparent()
+
+	if req.GetCancellation() != nil {
+		Cancel(logger, req, monitors)
+		return
+	}
+
+	// Errors in dry runs are returned to the caller, and are not reported as deployment statuses.
+	errorStatus := func(err error) *deployment.DeploymentStatus {
+		status := deployment.NewErrorStatus(*req, err)
+		status.DryRun = req.GetDryRun()
+		return status
+	}
+
 	p := req.GetPayloadSpec()
 	logger.Data["team"] = p.Team
 
@@ -119,28 +171,100 @@ func Run(logger *log.Entry, req *deployment.DeploymentRequest, cfg config.Config
 
 	teamClient, err := kube.TeamClient(p.Team, namespace, cfg.AutoCreateServiceAccount)
 	if err != nil {
-		deployStatus <- deployment.NewErrorStatus(*req, err)
+		deployStatus <- errorStatus(err)
 		return
 	}
 
 	rawResources, err := p.JSONResources()
 	if err != nil {
-		deployStatus <- deployment.NewErrorStatus(*req, fmt.Errorf("unserializing kubernetes resources: %s", err))
+		deployStatus <- errorStatus(fmt.Errorf("unserializing kubernetes resources: %s", err))
 		return
 	}
 
 	if len(rawResources) == 0 {
-		deployStatus <- deployment.NewErrorStatus(*req, fmt.Errorf("no resources to deploy"))
+		deployStatus <- errorStatus(fmt.Errorf("no resources to deploy"))
 		return
 	}
 
 	resources, err := jsonToResources(rawResources)
 	if err != nil {
-		deployStatus <- deployment.NewErrorStatus(*req, err)
+		deployStatus <- errorStatus(err)
+		return
+	}
+
+	if req.GetDryRun() {
+		logger.Infof("Accepting incoming dry run request")
+		deployStatus <- DryRun(logger, req, teamClient, resources)
 		return
 	}
 
-	logger.Infof("Accepting incoming deployment request")
+	start := func(ctx context.Context) {
+		apply(ctx, logger, req, teamClient, resources, rolloutTimeout(*req, cfg.RolloutTimeout), deployStatus, monitors)
+	}
+
+	if req.GetResume() {
+		// Check ownership before locking the resources, so that newer deployments are not superseded.
+		if status := resumable(teamClient, req, resources); status != nil {
+			deployStatus <- status
+			return
+		}
+		logger.Infof("Resuming rollout monitoring handed over by another deployd instance")
+		start = func(ctx context.Context) {
+			resume(ctx, logger, req, teamClient, resources, rolloutTimeout(*req, cfg.RolloutTimeout), deployStatus, monitors)
+		}
+	} else {
+		logger.Infof("Accepting incoming deployment request")
+	}
+
+	queued := func(holders []string) {
+		logger.Infof("Waiting for deployment %s of the same application to finish", strings.Join(holders, ", "))
+		status := deployment.NewQueuedStatus(*req)
+		status.Description = fmt.Sprintf("deployment request is waiting for deployment %s of the same application to finish", strings.Join(holders, ", "))
+		status.Timestamp = req.GetTimestamp()
+		deployStatus <- status
+	}
+
+	superseded := monitors.Acquire(req, lockKeys(cfg.Cluster, namespace, resources), cfg.ConcurrencyPolicy, queued, start)
+
+	if len(superseded) > 0 && cfg.ConcurrencyPolicy == config.ConcurrencyPolicySupersede {
+		logger.Infof("Superseded deployment %s of the same application", strings.Join(superseded, ", "))
+	}
+}
+
+// lockKeys returns the keys identifying the monitorable resources of a deployment,
+// in the form cluster/namespace/name. Deployments sharing any of these keys are serialized.
+func lockKeys(cluster, namespace string, resources []unstructured.Unstructured) []string {
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, resource := range resources {
+		if !monitorableResource(&resource) {
+			continue
+		}
+		ns := resource.GetNamespace()
+		if len(ns) == 0 {
+			ns = namespace
+		}
+		key := strings.Join([]string{cluster, ns, resource.GetName()}, "/")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// apply deploys resources to the cluster, and starts monitoring the rollout of monitorable resources.
+func apply(ctx context.Context, logger *log.Entry, req *deployment.DeploymentRequest, teamClient kubeclient.TeamClient, resources []unstructured.Unstructured, timeout time.Duration, deployStatus chan *deployment.DeploymentStatus, monitors *Monitors) {
+	// Deployments may have been interrupted, or deployd may be shutting down, while waiting for their turn.
+	if ctx.Err() != nil {
+		if interruption := monitors.Interruption(req.GetDeliveryID()); interruption != nil {
+			deployStatus <- interrupted(logger, teamClient, *req, interruption, nil, "before it was started")
+			return
+		}
+		deployStatus <- deployment.NewErrorStatus(*req, fmt.Errorf("deployd shut down before the deployment was started"))
+		return
+	}
 
 	monitorable := 0
 
@@ -152,19 +276,24 @@ func Run(logger *log.Entry, req *deployment.DeploymentRequest, cfg config.Config
 			monitorable += 1
 			ns := resource.GetNamespace()
 			n := resource.GetName()
-			logger.Infof("Monitoring rollout status of deployment '%s' in namespace '%s' for %s", n, ns, deploymentTimeout.String())
-
-			go func() {
-				err := teamClient.WaitForDeployment(logger, resource, time.Now().Add(deploymentTimeout))
-				if err == nil {
-					deployStatus <- deployment.NewSuccessStatus(*req)
-				} else {
-					deployStatus <- deployment.NewFailureStatus(*req, err)
+			logger.Infof("Monitoring rollout status of deployment '%s' in namespace '%s' for %s", n, ns, timeout.String())
+
+			// Keep the current version around, so that the rollout can be reverted if the deployment is cancelled.
+			previous, err := teamClient.Get(resource)
+			if err != nil {
+				if !errors.IsNotFound(err) {
+					logger.Warnf("Resource %d: unable to retrieve current version; rollback will not be possible: %s", index+1, err)
 				}
-			}()
+				previous = nil
+			}
+
+			monitors.Go(req.GetDeliveryID(), monitor(logger, req, teamClient, resource, previous, timeout, deployStatus, monitors))
 		}
 
+		_, span := startSpan(ctx, req, "kubernetes.apply", &resource)
 		deployed, err := teamClient.DeployUnstructured(resource)
+		span.SetError(err)
+		span.Finish()
 		if err != nil {
 			deployStatus <- deployment.NewFailureStatus(*req, fmt.Errorf("resource %d: %s", index+1, err))
 			return
@@ -181,3 +310,200 @@ func Run(logger *log.Entry, req *deployment.DeploymentRequest, cfg config.Config
 		deployStatus <- deployment.NewSuccessStatus(*req)
 	}
 }
+
+// monitor returns a rollout monitor for a resource, which reports the final status of the deployment.
+func monitor(logger *log.Entry, req *deployment.DeploymentRequest, teamClient kubeclient.TeamClient, resource unstructured.Unstructured, previous *unstructured.Unstructured, timeout time.Duration, deployStatus chan *deployment.DeploymentStatus, monitors *Monitors) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		_, span := startSpan(ctx, req, "rollout.wait", &resource)
+		defer span.Finish()
+
+		err := teamClient.WaitForDeployment(ctx, logger, resource)
+		span.SetError(err)
+		switch {
+		case err == nil:
+			deployStatus <- deployment.NewSuccessStatus(*req)
+		case ctx.Err() == context.Canceled:
+			if interruption := monitors.Interruption(req.GetDeliveryID()); interruption != nil {
+				deployStatus <- interrupted(logger, teamClient, *req, interruption, previous, "")
+				return
+			}
+			// deployd is shutting down. The rollout status is unknown, so monitoring is handed over to the next instance.
+			logger.Infof("Rollout of %s is still in progress; handing over monitoring", resource.GetName())
+			monitors.handOff(req.GetDeliveryID())
+		default:
+			deployStatus <- deployment.NewFailureStatus(*req, err)
+		}
+	}
+}
+
+// resumable checks that the resources of a resumed deployment still belong to it.
+// Returns the final status of the deployment if any of them has been replaced by another deployment,
+// or could not be retrieved; nil otherwise.
+func resumable(teamClient kubeclient.TeamClient, req *deployment.DeploymentRequest, resources []unstructured.Unstructured) *deployment.DeploymentStatus {
+	for index, resource := range resources {
+		if !monitorableResource(&resource) {
+			continue
+		}
+
+		live, err := teamClient.Get(resource)
+		if err != nil {
+			return deployment.NewErrorStatus(*req, fmt.Errorf("resource %d: unable to retrieve running version: %s", index+1, err))
+		}
+		if live.GetAnnotations()[kubeclient.CorrelationIDAnnotation] != req.GetDeliveryID() {
+			return deployment.NewInactiveStatus(*req, "Deployment was superseded by another deployment while monitoring was handed over between deployd instances.")
+		}
+	}
+	return nil
+}
+
+// resume monitors the rollout of resources that were applied by another deployd instance.
+// The previous versions of the resources are not known, so the deployment can not be rolled back if it is cancelled.
+func resume(ctx context.Context, logger *log.Entry, req *deployment.DeploymentRequest, teamClient kubeclient.TeamClient, resources []unstructured.Unstructured, timeout time.Duration, deployStatus chan *deployment.DeploymentStatus, monitors *Monitors) {
+	if ctx.Err() != nil {
+		if interruption := monitors.Interruption(req.GetDeliveryID()); interruption != nil {
+			deployStatus <- interrupted(logger, teamClient, *req, interruption, nil, "before monitoring was resumed")
+			return
+		}
+		monitors.handOff(req.GetDeliveryID())
+		return
+	}
+
+	for _, resource := range resources {
+		if !monitorableResource(&resource) {
+			continue
+		}
+		addCorrelationID(&resource, req.GetDeliveryID())
+		logger.Infof("Monitoring rollout status of deployment '%s' in namespace '%s' for %s", resource.GetName(), resource.GetNamespace(), timeout.String())
+		monitors.Go(req.GetDeliveryID(), monitor(logger, req, teamClient, resource, nil, timeout, deployStatus, monitors))
+	}
+}
+
+// resourceDiff compares the version of a resource that is running in the cluster with the result of its dry run.
+// Comparing with the dry run result rather than the submitted resource means that defaults and mutations applied
+// by the API server show up on both sides, and only real changes end up in the diff.
+func resourceDiff(teamClient kubeclient.TeamClient, resource unstructured.Unstructured, desired *unstructured.Unstructured) (string, error) {
+	live, err := teamClient.Get(resource)
+	if errors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to retrieve running version: %s", err)
+	}
+
+	return diff.Unified(live, desired)
+}
+
+// DryRun submits resources to Kubernetes without persisting them, and returns the result for each resource.
+// The team's service account is used, so that permissions are checked as well as the resources themselves.
+func DryRun(logger *log.Entry, req *deployment.DeploymentRequest, teamClient kubeclient.TeamClient, resources []unstructured.Unstructured) *deployment.DeploymentStatus {
+	results := make([]*deployment.ResourceResult, len(resources))
+
+	for index, resource := range resources {
+		addCorrelationID(&resource, req.GetDeliveryID())
+
+		results[index] = &deployment.ResourceResult{
+			Kind:      resource.GetKind(),
+			Namespace: resource.GetNamespace(),
+			Name:      resource.GetName(),
+		}
+
+		_, span := startSpan(context.Background(), req, "kubernetes.dry_run", &resource)
+		desired, err := teamClient.DryRunUnstructured(resource)
+		span.SetError(err)
+		span.Finish()
+		if err != nil {
+			results[index].Error = err.Error()
+			logger.Infof("Resource %d: dry run failed: %s", index+1, err)
+			continue
+		}
+
+		logger.Infof("Resource %d: dry run succeeded", index+1)
+
+		if !req.GetDiff() {
+			continue
+		}
+
+		results[index].Diff, err = resourceDiff(teamClient, resource, desired)
+		if err != nil {
+			results[index].Error = err.Error()
+			logger.Infof("Resource %d: unable to compute diff: %s", index+1, err)
+		}
+	}
+
+	return deployment.NewDryRunStatus(*req, results)
+}
+
+// Cancel stops monitoring the rollout of the deployment targeted by a cancellation request.
+// The monitors of the cancelled deployment report its final status.
+func Cancel(logger *log.Entry, req *deployment.DeploymentRequest, monitors *Monitors) {
+	cancellation := req.GetCancellation()
+	logger = logger.WithFields(log.Fields{
+		deployment.LogFieldCancelledDeliveryID: cancellation.GetDeliveryID(),
+		deployment.LogFieldCancelledBy:         cancellation.GetUser(),
+	})
+
+	if !monitors.Cancel(cancellation) {
+		logger.Warnf("Ignoring cancellation: deployment is not being monitored by this instance")
+		return
+	}
+
+	logger.Infof("Deployment cancelled; stopping rollout monitors")
+}
+
+// interrupted returns the final status of a deployment that was cancelled or superseded.
+// If the deployment was interrupted before it was started, when is set to "before it was started".
+func interrupted(logger *log.Entry, teamClient kubeclient.TeamClient, req deployment.DeploymentRequest, interruption *Interruption, previous *unstructured.Unstructured, when string) *deployment.DeploymentStatus {
+	if interruption.Cancellation == nil {
+		if len(when) > 0 {
+			return deployment.NewInactiveStatus(req, fmt.Sprintf("Deployment was superseded by deployment %s %s.", interruption.SupersededBy, when))
+		}
+		return deployment.NewInactiveStatus(req, fmt.Sprintf("Deployment was superseded by deployment %s; rollout is no longer monitored.", interruption.SupersededBy))
+	}
+	if len(when) > 0 {
+		return deployment.NewInactiveStatus(req, fmt.Sprintf("Deployment was cancelled by %s %s.", cancelledBy(interruption.Cancellation), when))
+	}
+	return cancelled(logger, teamClient, req, interruption.Cancellation, previous)
+}
+
+func cancelledBy(cancellation *deployment.Cancellation) string {
+	user := cancellation.GetUser()
+	if len(user) == 0 {
+		return "a user"
+	}
+	return user
+}
+
+// cancelled optionally rolls a resource back to its previous version,
+// and returns the final status of a cancelled deployment.
+func cancelled(logger *log.Entry, teamClient kubeclient.TeamClient, req deployment.DeploymentRequest, cancellation *deployment.Cancellation, previous *unstructured.Unstructured) *deployment.DeploymentStatus {
+	user := cancelledBy(cancellation)
+
+	if !cancellation.GetRollback() {
+		return deployment.NewInactiveStatus(req, fmt.Sprintf("Deployment was cancelled by %s; rollout is no longer monitored.", user))
+	}
+
+	err := rollback(teamClient, previous)
+	if err != nil {
+		return deployment.NewErrorStatus(req, fmt.Errorf("deployment was cancelled by %s, but rollback failed: %s", user, err))
+	}
+
+	logger.Infof("Rolled back %s to its previous version", previous.GetSelfLink())
+
+	return deployment.NewInactiveStatus(req, fmt.Sprintf("Deployment was cancelled by %s; resources have been rolled back to their previous version.", user))
+}
+
+// rollback re-applies the version of a resource that was present in the cluster before the deployment.
+func rollback(teamClient kubeclient.TeamClient, previous *unstructured.Unstructured) error {
+	if previous == nil {
+		return fmt.Errorf("no previous version of the resource is known")
+	}
+
+	resource := previous.DeepCopy()
+	resource.SetResourceVersion("")
+	unstructured.RemoveNestedField(resource.Object, "status")
+
+	_, err := teamClient.DeployUnstructured(*resource)
+	return err
+}
@@ -0,0 +1,281 @@
+package deployd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/deployd/pkg/config"
+)
+
+// Interruption describes why a deployment was stopped before its rollout completed.
+type Interruption struct {
+	// Cancellation is set if the deployment was cancelled by a user.
+	Cancellation *deployment.Cancellation
+	// SupersededBy holds the delivery ID of a newer deployment of the same resources.
+	SupersededBy string
+}
+
+// Monitors keeps track of running deployments and their rollout monitors, so that deployd
+// can wait for them to finish, or hand them over to the next instance, when shutting down.
+//
+// Monitors also serializes deployments of the same application. Each deployment holds a lock
+// on its monitorable resources from the time it is applied until its rollout monitors finish.
+type Monitors struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+	running map[string]*monitorGroup
+	owners  map[string]string
+	waiting []string
+	// handedOff holds the deployments whose rollout monitors were stopped by Shutdown.
+	handedOff []*deployment.DeploymentRequest
+}
+
+// monitorGroup holds the state of a single deployment.
+type monitorGroup struct {
+	request      *deployment.DeploymentRequest
+	ctx          context.Context
+	cancel       context.CancelFunc
+	count        int
+	keys         []string
+	interruption *Interruption
+	handedOff    bool
+	// start is set while the deployment is waiting for its resources to become available.
+	start func(ctx context.Context)
+}
+
+func NewMonitors() *Monitors {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Monitors{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]*monitorGroup),
+		owners:  make(map[string]string),
+	}
+}
+
+// Acquire locks the resources identified by keys for a deployment request, and calls start to apply it.
+//
+// If other deployments hold any of the resources, the concurrency policy decides what happens.
+// With config.ConcurrencyPolicySupersede, the other deployments are interrupted, and start is called right away.
+// With config.ConcurrencyPolicyQueue, queued is called, and start is called in the background
+// once the other deployments have finished.
+//
+// The context passed to start is cancelled if the deployment is interrupted or deployd shuts down.
+// Returns the delivery IDs of the deployments holding the resources.
+func (m *Monitors) Acquire(req *deployment.DeploymentRequest, keys []string, policy string, queued func(holders []string), start func(ctx context.Context)) []string {
+	m.lock.Lock()
+
+	deliveryID := req.GetDeliveryID()
+	holders := m.holders(deliveryID, keys)
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	group := &monitorGroup{
+		request: req,
+		ctx:     ctx,
+		cancel:  cancel,
+		count:   1,
+		keys:    keys,
+	}
+	m.running[deliveryID] = group
+
+	if len(holders) > 0 && policy == config.ConcurrencyPolicyQueue {
+		group.start = start
+		m.waiting = append(m.waiting, deliveryID)
+		m.lock.Unlock()
+		queued(holders)
+		return holders
+	}
+
+	for _, holder := range holders {
+		m.interrupt(holder, Interruption{SupersededBy: deliveryID})
+	}
+	m.claim(deliveryID, keys)
+	m.lock.Unlock()
+
+	defer m.release(deliveryID)
+	start(ctx)
+
+	return holders
+}
+
+// holders returns the deployments other than deliveryID holding any of the resources.
+func (m *Monitors) holders(deliveryID string, keys []string) []string {
+	holders := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		owner, ok := m.owners[key]
+		if !ok || owner == deliveryID || seen[owner] {
+			continue
+		}
+		seen[owner] = true
+		holders = append(holders, owner)
+	}
+	return holders
+}
+
+func (m *Monitors) claim(deliveryID string, keys []string) {
+	for _, key := range keys {
+		m.owners[key] = deliveryID
+	}
+}
+
+// schedule starts a waiting deployment in the background.
+func (m *Monitors) schedule(deliveryID string) {
+	group := m.running[deliveryID]
+	start := group.start
+	group.start = nil
+
+	for i := range m.waiting {
+		if m.waiting[i] == deliveryID {
+			m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+			break
+		}
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.release(deliveryID)
+		start(group.ctx)
+	}()
+}
+
+// dequeue starts waiting deployments whose resources have become available, in the order they arrived.
+func (m *Monitors) dequeue() {
+	blocked := make(map[string]bool)
+	for _, deliveryID := range append([]string{}, m.waiting...) {
+		group := m.running[deliveryID]
+		available := len(m.holders(deliveryID, group.keys)) == 0
+		for _, key := range group.keys {
+			available = available && !blocked[key]
+			blocked[key] = true
+		}
+		if available {
+			m.claim(deliveryID, group.keys)
+			m.schedule(deliveryID)
+		}
+	}
+}
+
+// Go runs a rollout monitor for the deployment with the given delivery ID in the background.
+// Must only be called from the start function given to Acquire.
+func (m *Monitors) Go(deliveryID string, monitor func(ctx context.Context)) {
+	m.lock.Lock()
+	group := m.running[deliveryID]
+	group.count++
+	m.lock.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.release(deliveryID)
+		monitor(group.ctx)
+	}()
+}
+
+func (m *Monitors) release(deliveryID string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	group := m.running[deliveryID]
+	group.count--
+	if group.count > 0 {
+		return
+	}
+
+	group.cancel()
+	delete(m.running, deliveryID)
+	for _, key := range group.keys {
+		if m.owners[key] == deliveryID {
+			delete(m.owners, key)
+		}
+	}
+
+	m.dequeue()
+}
+
+// interrupt stops a deployment. Waiting deployments are started, so that they can report their status.
+func (m *Monitors) interrupt(deliveryID string, interruption Interruption) bool {
+	group, ok := m.running[deliveryID]
+	if !ok {
+		return false
+	}
+	if group.interruption == nil {
+		group.interruption = &interruption
+	}
+	group.cancel()
+	if group.start != nil {
+		m.schedule(deliveryID)
+	}
+	return true
+}
+
+// Cancel aborts the deployment targeted by the cancellation.
+// Returns false if the deployment is not known to this instance.
+func (m *Monitors) Cancel(cancellation *deployment.Cancellation) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.interrupt(cancellation.GetDeliveryID(), Interruption{Cancellation: cancellation})
+}
+
+// Interruption returns the reason a deployment was interrupted, or nil if it has not been interrupted.
+// Only valid while the deployment is running.
+func (m *Monitors) Interruption(deliveryID string) *Interruption {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	group, ok := m.running[deliveryID]
+	if !ok {
+		return nil
+	}
+	return group.interruption
+}
+
+// handOff records that a rollout monitor of a deployment was stopped because deployd is shutting down.
+// The rollout status is unknown, so the monitor does not report a status; the deployment is
+// returned by Shutdown instead, so that the next deployd instance can resume monitoring it.
+func (m *Monitors) handOff(deliveryID string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	group, ok := m.running[deliveryID]
+	if !ok || group.handedOff {
+		return
+	}
+	group.handedOff = true
+	m.handedOff = append(m.handedOff, group.request)
+}
+
+// Shutdown waits for running monitors to finish. Monitors that are still running
+// after the timeout are stopped without reporting a status, and Shutdown returns
+// the deployments they were monitoring, so that they can be handed over to the next deployd instance.
+// Deployments waiting for their resources are started when the monitors holding them finish,
+// and report an error if deployd is shutting down, as they have not been applied.
+// Returns nil if all monitors finished by themselves.
+func (m *Monitors) Shutdown(timeout time.Duration) []*deployment.DeploymentRequest {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		m.cancel()
+		<-done
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.handedOff
+}
@@ -0,0 +1,349 @@
+package deployd_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/deployd/pkg/config"
+	"github.com/navikt/deployment/deployd/pkg/deployd"
+	"github.com/navikt/deployment/deployd/pkg/kubeclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const application = `[{"apiVersion": "nais.io/v1alpha1", "kind": "Application", "metadata": {"name": "myapp", "namespace": "aura"}}]`
+
+// fakeTeamClient records deployed resources, and blocks rollout monitors until the test finishes the rollout.
+type fakeTeamClient struct {
+	lock     sync.Mutex
+	deployed []string
+	live     map[string]*unstructured.Unstructured
+	rollouts map[string]chan error
+}
+
+func newFakeTeamClient() *fakeTeamClient {
+	return &fakeTeamClient{
+		live:     make(map[string]*unstructured.Unstructured),
+		rollouts: make(map[string]chan error),
+	}
+}
+
+func (c *fakeTeamClient) TeamClient(team, namespace string, autoCreateServiceAccount bool) (kubeclient.TeamClient, error) {
+	return c, nil
+}
+
+func (c *fakeTeamClient) DeployUnstructured(resource unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.deployed = append(c.deployed, correlationID(resource))
+	c.live[resource.GetName()] = resource.DeepCopy()
+	return &resource, nil
+}
+
+func (c *fakeTeamClient) DryRunUnstructured(resource unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return &resource, nil
+}
+
+func (c *fakeTeamClient) Get(resource unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	live, ok := c.live[resource.GetName()]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", resource.GetName())
+	}
+	return live.DeepCopy(), nil
+}
+
+func (c *fakeTeamClient) WaitForDeployment(ctx context.Context, logger *log.Entry, resource unstructured.Unstructured) error {
+	select {
+	case err := <-c.rollout(correlationID(resource)):
+		return err
+	case <-ctx.Done():
+		return kubeclient.ErrMonitoringAborted
+	}
+}
+
+func (c *fakeTeamClient) rollout(deliveryID string) chan error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.rollouts[deliveryID]; !ok {
+		c.rollouts[deliveryID] = make(chan error, 1)
+	}
+	return c.rollouts[deliveryID]
+}
+
+// finish completes the rollout of a deployment.
+func (c *fakeTeamClient) finish(deliveryID string, err error) {
+	c.rollout(deliveryID) <- err
+}
+
+func (c *fakeTeamClient) deployments() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]string{}, c.deployed...)
+}
+
+func correlationID(resource unstructured.Unstructured) string {
+	return resource.GetAnnotations()[kubeclient.CorrelationIDAnnotation]
+}
+
+func request(t *testing.T, deliveryID string) *deployment.DeploymentRequest {
+	kube, err := deployment.KubernetesFromJSONResources([]byte(application))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &deployment.DeploymentRequest{
+		DeliveryID: deliveryID,
+		Cluster:    "local",
+		Deadline:   time.Now().Add(time.Minute).Unix(),
+		PayloadSpec: &deployment.Payload{
+			Team:       "aura",
+			Kubernetes: kube,
+		},
+	}
+}
+
+func cancellation(deliveryID string) *deployment.DeploymentRequest {
+	return &deployment.DeploymentRequest{
+		DeliveryID: "cancel-" + deliveryID,
+		Cluster:    "local",
+		Deadline:   time.Now().Add(time.Minute).Unix(),
+		Cancellation: &deployment.Cancellation{
+			DeliveryID: deliveryID,
+			User:       "alice",
+		},
+	}
+}
+
+type harness struct {
+	t        *testing.T
+	cfg      config.Config
+	client   *fakeTeamClient
+	statuses chan *deployment.DeploymentStatus
+	monitors *deployd.Monitors
+}
+
+func newHarness(t *testing.T, policy string) *harness {
+	cfg := *config.DefaultConfig()
+	cfg.ConcurrencyPolicy = policy
+	return &harness{
+		t:        t,
+		cfg:      cfg,
+		client:   newFakeTeamClient(),
+		statuses: make(chan *deployment.DeploymentStatus, 16),
+		monitors: deployd.NewMonitors(),
+	}
+}
+
+func (h *harness) run(req *deployment.DeploymentRequest) {
+	deployd.Run(log.NewEntry(log.StandardLogger()), req, h.cfg, h.client, h.statuses, h.monitors)
+}
+
+// expect waits for the next status, and checks that it belongs to deliveryID and has the expected state.
+func (h *harness) expect(deliveryID string, state deployment.GithubDeploymentState) *deployment.DeploymentStatus {
+	select {
+	case status := <-h.statuses:
+		assert.Equal(h.t, deliveryID, status.GetDeliveryID())
+		assert.Equal(h.t, state, status.GetState(), status.GetDescription())
+		return status
+	case <-time.After(5 * time.Second):
+		h.t.Fatalf("timed out waiting for %s status of %s", state, deliveryID)
+		return nil
+	}
+}
+
+// expectAll waits for one status for each of the given deployments, in any order.
+func (h *harness) expectAll(states map[string]deployment.GithubDeploymentState) map[string]*deployment.DeploymentStatus {
+	received := make(map[string]*deployment.DeploymentStatus)
+	for range states {
+		select {
+		case status := <-h.statuses:
+			received[status.GetDeliveryID()] = status
+		case <-time.After(5 * time.Second):
+			h.t.Fatalf("timed out waiting for statuses")
+		}
+	}
+	for deliveryID, state := range states {
+		if assert.Contains(h.t, received, deliveryID) {
+			assert.Equal(h.t, state, received[deliveryID].GetState(), received[deliveryID].GetDescription())
+		}
+	}
+	return received
+}
+
+func (h *harness) expectNone() {
+	select {
+	case status := <-h.statuses:
+		h.t.Errorf("unexpected %s status of %s: %s", status.GetState(), status.GetDeliveryID(), status.GetDescription())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSupersede(t *testing.T) {
+	h := newHarness(t, config.ConcurrencyPolicySupersede)
+
+	h.run(request(t, "first"))
+	h.expect("first", deployment.GithubDeploymentState_in_progress)
+
+	h.run(request(t, "second"))
+	statuses := h.expectAll(map[string]deployment.GithubDeploymentState{
+		"first":  deployment.GithubDeploymentState_inactive,
+		"second": deployment.GithubDeploymentState_in_progress,
+	})
+	assert.Contains(t, statuses["first"].GetDescription(), "superseded by deployment second")
+
+	h.client.finish("second", nil)
+	h.expect("second", deployment.GithubDeploymentState_success)
+
+	assert.Equal(t, []string{"first", "second"}, h.client.deployments())
+	assert.Empty(t, h.monitors.Shutdown(time.Second))
+	h.expectNone()
+}
+
+func TestQueue(t *testing.T) {
+	h := newHarness(t, config.ConcurrencyPolicyQueue)
+
+	h.run(request(t, "first"))
+	h.expect("first", deployment.GithubDeploymentState_in_progress)
+
+	h.run(request(t, "second"))
+	status := h.expect("second", deployment.GithubDeploymentState_queued)
+	assert.Contains(t, status.GetDescription(), "waiting for deployment first")
+
+	h.run(request(t, "third"))
+	status = h.expect("third", deployment.GithubDeploymentState_queued)
+	assert.Contains(t, status.GetDescription(), "waiting for deployment first")
+
+	assert.Equal(t, []string{"first"}, h.client.deployments())
+
+	h.client.finish("first", nil)
+	h.expectAll(map[string]deployment.GithubDeploymentState{
+		"first":  deployment.GithubDeploymentState_success,
+		"second": deployment.GithubDeploymentState_in_progress,
+	})
+	h.expectNone()
+
+	h.client.finish("second", fmt.Errorf("crash loop"))
+	h.expectAll(map[string]deployment.GithubDeploymentState{
+		"second": deployment.GithubDeploymentState_failure,
+		"third":  deployment.GithubDeploymentState_in_progress,
+	})
+
+	h.client.finish("third", nil)
+	h.expect("third", deployment.GithubDeploymentState_success)
+
+	assert.Equal(t, []string{"first", "second", "third"}, h.client.deployments())
+	assert.Empty(t, h.monitors.Shutdown(time.Second))
+}
+
+func TestCancelQueued(t *testing.T) {
+	h := newHarness(t, config.ConcurrencyPolicyQueue)
+
+	h.run(request(t, "first"))
+	h.expect("first", deployment.GithubDeploymentState_in_progress)
+
+	h.run(request(t, "second"))
+	h.expect("second", deployment.GithubDeploymentState_queued)
+
+	h.run(cancellation("second"))
+	status := h.expect("second", deployment.GithubDeploymentState_inactive)
+	assert.Equal(t, "Deployment was cancelled by alice before it was started.", status.GetDescription())
+
+	h.client.finish("first", nil)
+	h.expect("first", deployment.GithubDeploymentState_success)
+
+	assert.Equal(t, []string{"first"}, h.client.deployments())
+	assert.Empty(t, h.monitors.Shutdown(time.Second))
+	h.expectNone()
+}
+
+func TestCancelUnknown(t *testing.T) {
+	h := newHarness(t, config.ConcurrencyPolicyQueue)
+
+	h.run(cancellation("unknown"))
+	h.expectNone()
+}
+
+func TestShutdownWithWaiting(t *testing.T) {
+	h := newHarness(t, config.ConcurrencyPolicyQueue)
+
+	h.run(request(t, "first"))
+	h.expect("first", deployment.GithubDeploymentState_in_progress)
+
+	h.run(request(t, "second"))
+	h.expect("second", deployment.GithubDeploymentState_queued)
+
+	handedOff := h.monitors.Shutdown(10 * time.Millisecond)
+
+	// The running deployment is handed over without a status, as its rollout may still succeed.
+	// The waiting deployment was never applied, and reports an error.
+	if assert.Len(t, handedOff, 1) {
+		assert.Equal(t, "first", handedOff[0].GetDeliveryID())
+	}
+	status := h.expect("second", deployment.GithubDeploymentState_error)
+	assert.Contains(t, status.GetDescription(), "shut down before the deployment was started")
+	h.expectNone()
+
+	assert.Equal(t, []string{"first"}, h.client.deployments())
+}
+
+func TestResume(t *testing.T) {
+	h := newHarness(t, config.ConcurrencyPolicySupersede)
+
+	h.run(request(t, "first"))
+	h.expect("first", deployment.GithubDeploymentState_in_progress)
+
+	handedOff := h.monitors.Shutdown(10 * time.Millisecond)
+	if !assert.Len(t, handedOff, 1) {
+		return
+	}
+	h.expectNone()
+
+	// The next instance monitors the rollout without applying the resources again.
+	h.monitors = deployd.NewMonitors()
+	resumed := handedOff[0]
+	resumed.Resume = true
+	resumed.Deadline = time.Now().Add(-time.Minute).Unix()
+	h.run(resumed)
+	h.expectNone()
+
+	h.client.finish("first", nil)
+	h.expect("first", deployment.GithubDeploymentState_success)
+	assert.Equal(t, []string{"first"}, h.client.deployments())
+	assert.Empty(t, h.monitors.Shutdown(time.Second))
+}
+
+func TestResumeSuperseded(t *testing.T) {
+	h := newHarness(t, config.ConcurrencyPolicySupersede)
+
+	h.run(request(t, "first"))
+	h.expect("first", deployment.GithubDeploymentState_in_progress)
+	handedOff := h.monitors.Shutdown(10 * time.Millisecond)
+	if !assert.Len(t, handedOff, 1) {
+		return
+	}
+
+	// A newer deployment reaches the next instance before the handed over one.
+	h.monitors = deployd.NewMonitors()
+	h.run(request(t, "second"))
+	h.expect("second", deployment.GithubDeploymentState_in_progress)
+
+	resumed := handedOff[0]
+	resumed.Resume = true
+	h.run(resumed)
+	status := h.expect("first", deployment.GithubDeploymentState_inactive)
+	assert.Contains(t, status.GetDescription(), "superseded by another deployment")
+
+	h.client.finish("second", nil)
+	h.expect("second", deployment.GithubDeploymentState_success)
+	assert.Equal(t, []string{"first", "second"}, h.client.deployments())
+}
@@ -0,0 +1,95 @@
+package deploylog
+
+import (
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/deployd/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Shipper is a log hook capturing every log line about a deployment, that is, every line with a delivery ID,
+// and sending it to hookd. Trace level lines are left out.
+//
+// Log lines are queued and sent in the background, so that logging never waits for Kafka.
+// If the queue is full, lines are dropped.
+type Shipper struct {
+	cluster string
+	events  chan *deployment.LogEvent
+	send    func(event *deployment.LogEvent) error
+}
+
+// NewShipper creates a shipper queueing at most capacity log lines, and sending them with send.
+func NewShipper(cluster string, capacity int, send func(event *deployment.LogEvent) error) *Shipper {
+	return &Shipper{
+		cluster: cluster,
+		events:  make(chan *deployment.LogEvent, capacity),
+		send:    send,
+	}
+}
+
+func (s *Shipper) Levels() []log.Level {
+	return []log.Level{
+		log.PanicLevel,
+		log.FatalLevel,
+		log.ErrorLevel,
+		log.WarnLevel,
+		log.InfoLevel,
+		log.DebugLevel,
+	}
+}
+
+// Fire queues a log line if it is about a deployment.
+// It is called with the logger locked, and must not log anything itself.
+func (s *Shipper) Fire(entry *log.Entry) error {
+	deliveryID, _ := entry.Data[deployment.LogFieldDeliveryID].(string)
+	if len(deliveryID) == 0 {
+		return nil
+	}
+
+	team, _ := entry.Data[deployment.LogFieldTeam].(string)
+
+	event := &deployment.LogEvent{
+		DeliveryID: deliveryID,
+		Team:       team,
+		Cluster:    s.cluster,
+		Timestamp:  entry.Time.UnixNano(),
+		Level:      entry.Level.String(),
+		Message:    entry.Message,
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		metrics.LogEventsDropped.Inc()
+	}
+
+	return nil
+}
+
+// Run sends queued log lines until stop is closed. Lines queued by then are sent before it returns.
+func (s *Shipper) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case event := <-s.events:
+			s.ship(event)
+		case <-stop:
+			for {
+				select {
+				case event := <-s.events:
+					s.ship(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Shipper) ship(event *deployment.LogEvent) {
+	if err := s.send(event); err != nil {
+		metrics.LogEventsDropped.Inc()
+		// Logged without the delivery ID, so that the error is not shipped itself.
+		log.Errorf("While sending log event: %s", err)
+		return
+	}
+	metrics.LogEventsSent.Inc()
+}
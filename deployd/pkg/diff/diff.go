@@ -0,0 +1,157 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/navikt/deployment/deployd/pkg/kubeclient"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	contextLines = 3
+
+	// Placeholders for Secret values, which must never be shown in a diff.
+	redacted        = "<redacted>"
+	redactedChanged = "<redacted, changed>"
+)
+
+// Fields of a Secret holding secret values.
+var secretFields = []string{"data", "stringData"}
+
+// Metadata fields that are maintained by the Kubernetes API server, and change without the resource itself changing.
+var serverManagedMetadata = []string{
+	"creationTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// Annotations that are set on every deployment, and would show up in every diff.
+var ignoredAnnotations = []string{
+	kubeclient.CorrelationIDAnnotation,
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+}
+
+// Normalise returns a copy of a resource without its status and server managed fields.
+// The values of Secrets are replaced with a placeholder.
+func Normalise(resource *unstructured.Unstructured) *unstructured.Unstructured {
+	if resource == nil {
+		return nil
+	}
+
+	normalised := resource.DeepCopy()
+	unstructured.RemoveNestedField(normalised.Object, "status")
+
+	for _, field := range serverManagedMetadata {
+		unstructured.RemoveNestedField(normalised.Object, "metadata", field)
+	}
+
+	annotations := normalised.GetAnnotations()
+	for _, annotation := range ignoredAnnotations {
+		delete(annotations, annotation)
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(normalised.Object, "metadata", "annotations")
+	} else {
+		normalised.SetAnnotations(annotations)
+	}
+
+	if isSecret(normalised) {
+		for _, field := range secretFields {
+			values, found, _ := unstructured.NestedMap(normalised.Object, field)
+			if !found {
+				continue
+			}
+			for key := range values {
+				values[key] = redacted
+			}
+			_ = unstructured.SetNestedMap(normalised.Object, values, field)
+		}
+	}
+
+	return normalised
+}
+
+func isSecret(resource *unstructured.Unstructured) bool {
+	gvk := resource.GroupVersionKind()
+	return len(gvk.Group) == 0 && gvk.Kind == "Secret"
+}
+
+// markChangedSecretValues replaces the placeholders of the Secret values in normalised that differ from the live version,
+// so that changed values show up in the diff without being revealed.
+func markChangedSecretValues(live, desired, normalised *unstructured.Unstructured) {
+	if live == nil || !isSecret(desired) {
+		return
+	}
+
+	for _, field := range secretFields {
+		values, _, _ := unstructured.NestedMap(desired.Object, field)
+		for key, value := range values {
+			previous, found, _ := unstructured.NestedFieldNoCopy(live.Object, field, key)
+			if !found || !reflect.DeepEqual(previous, value) {
+				_ = unstructured.SetNestedField(normalised.Object, redactedChanged, field, key)
+			}
+		}
+	}
+}
+
+// Unified returns a unified diff between the version of a resource currently running in the cluster,
+// and the version that is about to be applied. Both versions are normalised before they are compared.
+// Changed Secret values are shown as changed, but not revealed.
+//
+// If the resource is not running, live must be nil, and the whole resource is shown as added.
+// Returns an empty string if there are no differences.
+func Unified(live, desired *unstructured.Unstructured) (string, error) {
+	a, err := render(Normalise(live))
+	if err != nil {
+		return "", fmt.Errorf("render live resource: %s", err)
+	}
+
+	normalised := Normalise(desired)
+	markChangedSecretValues(live, desired, normalised)
+
+	b, err := render(normalised)
+	if err != nil {
+		return "", fmt.Errorf("render desired resource: %s", err)
+	}
+
+	name := strings.Join([]string{desired.GetKind(), desired.GetNamespace(), desired.GetName()}, "/")
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines(a),
+		B:        lines(b),
+		FromFile: "live/" + name,
+		ToFile:   "desired/" + name,
+		Context:  contextLines,
+	})
+}
+
+// render serialises a resource as YAML. Keys are sorted, so that equal resources render equally.
+func render(resource *unstructured.Unstructured) (string, error) {
+	if resource == nil {
+		return "", nil
+	}
+
+	data, err := yaml.Marshal(resource.Object)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// lines splits text into lines, keeping the line endings.
+func lines(text string) []string {
+	split := strings.SplitAfter(text, "\n")
+	if len(split[len(split)-1]) == 0 {
+		split = split[:len(split)-1]
+	}
+	return split
+}
@@ -0,0 +1,217 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/navikt/deployment/deployd/pkg/diff"
+	"github.com/navikt/deployment/deployd/pkg/kubeclient"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func resource(object map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: object}
+}
+
+func deployment(replicas int64, metadata map[string]interface{}) *unstructured.Unstructured {
+	meta := map[string]interface{}{
+		"name":      "myapp",
+		"namespace": "aura",
+	}
+	for key, value := range metadata {
+		meta[key] = value
+	}
+	return resource(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   meta,
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+}
+
+func secret(field string, data map[string]interface{}) *unstructured.Unstructured {
+	return resource(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      "myapp",
+			"namespace": "aura",
+		},
+		field: data,
+	})
+}
+
+func TestNormalise(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource *unstructured.Unstructured
+		expected *unstructured.Unstructured
+	}{
+		{
+			name: "missing resource",
+		},
+		{
+			name: "status and server managed fields are removed",
+			resource: func() *unstructured.Unstructured {
+				r := deployment(1, map[string]interface{}{
+					"creationTimestamp": "2019-11-05T08:33:09Z",
+					"generation":        int64(4),
+					"resourceVersion":   "12345",
+					"selfLink":          "/apis/apps/v1/namespaces/aura/deployments/myapp",
+					"uid":               "9a0d1702-e7c5-448f-8a90-1e5ee29a043b",
+					"managedFields":     []interface{}{},
+				})
+				r.Object["status"] = map[string]interface{}{"replicas": int64(1)}
+				return r
+			}(),
+			expected: deployment(1, nil),
+		},
+		{
+			name: "ignored annotations are removed",
+			resource: deployment(1, map[string]interface{}{
+				"annotations": map[string]interface{}{
+					kubeclient.CorrelationIDAnnotation:                 "9a0d1702-e7c5-448f-8a90-1e5ee29a043b",
+					"kubectl.kubernetes.io/last-applied-configuration": "{}",
+					"deployment.kubernetes.io/revision":                "3",
+					"nais.io/team":                                     "aura",
+				},
+			}),
+			expected: deployment(1, map[string]interface{}{
+				"annotations": map[string]interface{}{
+					"nais.io/team": "aura",
+				},
+			}),
+		},
+		{
+			name: "annotations are removed when only ignored annotations are set",
+			resource: deployment(1, map[string]interface{}{
+				"annotations": map[string]interface{}{
+					kubeclient.CorrelationIDAnnotation: "9a0d1702-e7c5-448f-8a90-1e5ee29a043b",
+				},
+			}),
+			expected: deployment(1, nil),
+		},
+		{
+			name:     "secret data is redacted",
+			resource: secret("data", map[string]interface{}{"password": "aHVudGVyMg=="}),
+			expected: secret("data", map[string]interface{}{"password": "<redacted>"}),
+		},
+		{
+			name:     "secret string data is redacted",
+			resource: secret("stringData", map[string]interface{}{"password": "hunter2"}),
+			expected: secret("stringData", map[string]interface{}{"password": "<redacted>"}),
+		},
+		{
+			name: "other kinds of data are kept",
+			resource: resource(map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"data":       map[string]interface{}{"key": "value"},
+			}),
+			expected: resource(map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"data":       map[string]interface{}{"key": "value"},
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var original *unstructured.Unstructured
+			if test.resource != nil {
+				original = test.resource.DeepCopy()
+			}
+
+			assert.Equal(t, test.expected, diff.Normalise(test.resource))
+			assert.Equal(t, original, test.resource, "the resource itself is not modified")
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     *unstructured.Unstructured
+		desired  *unstructured.Unstructured
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "no changes",
+			live: deployment(1, map[string]interface{}{
+				"resourceVersion": "12345",
+				"annotations": map[string]interface{}{
+					kubeclient.CorrelationIDAnnotation: "old",
+				},
+			}),
+			desired: deployment(1, map[string]interface{}{
+				"annotations": map[string]interface{}{
+					kubeclient.CorrelationIDAnnotation: "new",
+				},
+			}),
+		},
+		{
+			name:    "changed field",
+			live:    deployment(1, nil),
+			desired: deployment(2, nil),
+			contains: []string{
+				"--- live/Deployment/aura/myapp\n",
+				"+++ desired/Deployment/aura/myapp\n",
+				"-  replicas: 1\n",
+				"+  replicas: 2\n",
+			},
+		},
+		{
+			name:    "missing live resource",
+			desired: deployment(1, nil),
+			contains: []string{
+				"+apiVersion: apps/v1\n",
+				"+kind: Deployment\n",
+				"+  replicas: 1\n",
+			},
+		},
+		{
+			name:    "unchanged secret",
+			live:    secret("data", map[string]interface{}{"password": "aHVudGVyMg=="}),
+			desired: secret("data", map[string]interface{}{"password": "aHVudGVyMg=="}),
+		},
+		{
+			name:    "changed secret values are not revealed",
+			live:    secret("data", map[string]interface{}{"password": "aHVudGVyMg==", "username": "YWRtaW4="}),
+			desired: secret("data", map[string]interface{}{"password": "c3dvcmRmaXNo", "username": "YWRtaW4="}),
+			contains: []string{
+				"-  password: <redacted>\n",
+				"+  password: <redacted, changed>\n",
+				"   username: <redacted>\n",
+			},
+			excludes: []string{"aHVudGVyMg==", "c3dvcmRmaXNo", "YWRtaW4="},
+		},
+		{
+			name:     "new secret values are not revealed",
+			desired:  secret("stringData", map[string]interface{}{"password": "hunter2"}),
+			contains: []string{"+  password: <redacted>\n"},
+			excludes: []string{"hunter2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unified, err := diff.Unified(test.live, test.desired)
+			assert.NoError(t, err)
+
+			if len(test.contains) == 0 {
+				assert.Empty(t, unified)
+			}
+			for _, s := range test.contains {
+				assert.Contains(t, unified, s)
+			}
+			for _, s := range test.excludes {
+				assert.False(t, strings.Contains(unified, s), "diff contains %s", s)
+			}
+		})
+	}
+}
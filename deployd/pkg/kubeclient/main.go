@@ -1,6 +1,7 @@
 package kubeclient
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -54,6 +55,11 @@ func New() (*Client, error) {
 	}, nil
 }
 
+// Ping checks that the Kubernetes API server is reachable by listing the versions of the core API group.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Base.Discovery().RESTClient().Get().AbsPath("/api").Context(ctx).Do().Error()
+}
+
 func (c *Client) teamConfig(team, namespace string, autoCreateServiceAccount bool) (*clientcmdapi.Config, error) {
 	serviceAccountName := serviceAccountName(team)
 
@@ -1,6 +1,7 @@
 package kubeclient
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -21,6 +22,7 @@ import (
 var (
 	requestInterval      = time.Second * 5
 	ErrDeploymentTimeout = fmt.Errorf("timeout while waiting for deployment to succeed")
+	ErrMonitoringAborted = fmt.Errorf("deployd shut down before the rollout completed; rollout status is unknown")
 )
 
 const (
@@ -45,7 +47,9 @@ type teamClient struct {
 
 type TeamClient interface {
 	DeployUnstructured(resource unstructured.Unstructured) (*unstructured.Unstructured, error)
-	WaitForDeployment(logger *log.Entry, resource unstructured.Unstructured, deadline time.Time) error
+	DryRunUnstructured(resource unstructured.Unstructured) (*unstructured.Unstructured, error)
+	Get(resource unstructured.Unstructured) (*unstructured.Unstructured, error)
+	WaitForDeployment(ctx context.Context, logger *log.Entry, resource unstructured.Unstructured) error
 }
 
 // Implement TeamClient interface
@@ -54,6 +58,37 @@ var _ TeamClient = &teamClient{}
 // DeployUnstructured takes a generic unstructured object, discovers its location
 // using the Kubernetes API REST mapper, and deploys it to the cluster.
 func (c *teamClient) DeployUnstructured(resource unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	namespacedResource, err := c.resourceClient(resource)
+	if err != nil {
+		return nil, err
+	}
+	return c.createOrUpdate(namespacedResource, resource, nil)
+}
+
+// DryRunUnstructured submits a resource to the cluster using a server-side dry run.
+// The resource is validated and admitted as if it were deployed, but not persisted.
+// Returns the resource as it would have been stored.
+func (c *teamClient) DryRunUnstructured(resource unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	namespacedResource, err := c.resourceClient(resource)
+	if err != nil {
+		return nil, err
+	}
+	return c.createOrUpdate(namespacedResource, resource, []string{metav1.DryRunAll})
+}
+
+// Get returns the version of a resource that is currently present in the cluster.
+// If the resource does not exist, a Kubernetes NotFound error is returned.
+func (c *teamClient) Get(resource unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	namespacedResource, err := c.resourceClient(resource)
+	if err != nil {
+		return nil, err
+	}
+	return namespacedResource.Get(resource.GetName(), metav1.GetOptions{})
+}
+
+// resourceClient discovers the location of a resource using the Kubernetes API REST mapper,
+// and returns a client for resources of that kind in the resource's namespace.
+func (c *teamClient) resourceClient(resource unstructured.Unstructured) (dynamic.ResourceInterface, error) {
 	groupResources, err := restmapper.GetAPIGroupResources(c.structuredClient.Discovery())
 	if err != nil {
 		return nil, fmt.Errorf("unable to run kubernetes resource discovery: %s", err)
@@ -74,8 +109,7 @@ func (c *teamClient) DeployUnstructured(resource unstructured.Unstructured) (*un
 		return nil, fmt.Errorf("namespace required")
 	}
 
-	namespacedResource := clusterResource.Namespace(ns)
-	return c.createOrUpdate(namespacedResource, resource)
+	return clusterResource.Namespace(ns), nil
 }
 
 // Retrieve the most recent application deployment event.
@@ -135,7 +169,25 @@ func parseAppStatus(resource unstructured.Unstructured) *appStatus {
 	return st
 }
 
-func (c *teamClient) waitForApplication(logger *log.Entry, resource unstructured.Unstructured, deadline time.Time) error {
+// sleep waits for the specified duration, or until the context is done.
+func sleep(ctx context.Context, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// waitError translates a finished context into a monitoring error.
+func waitError(ctx context.Context) error {
+	if ctx.Err() == context.Canceled {
+		return ErrMonitoringAborted
+	}
+	return ErrDeploymentTimeout
+}
+
+func (c *teamClient) waitForApplication(ctx context.Context, logger *log.Entry, resource unstructured.Unstructured) error {
 	var updated *unstructured.Unstructured
 	var err error
 	var status *appStatus
@@ -149,7 +201,7 @@ func (c *teamClient) waitForApplication(logger *log.Entry, resource unstructured
 		Group:    gvk.Group,
 	}).Namespace(resource.GetNamespace())
 
-	for deadline.After(time.Now()) {
+	for ctx.Err() == nil {
 		updated, err = appcli.Get(resource.GetName(), metav1.GetOptions{})
 
 		if err != nil {
@@ -179,16 +231,17 @@ func (c *teamClient) waitForApplication(logger *log.Entry, resource unstructured
 		}
 
 	NEXT:
-		time.Sleep(requestInterval)
+		sleep(ctx, requestInterval)
 		continue
 	}
 
-	return ErrDeploymentTimeout
+	return waitError(ctx)
 }
 
 // Returns nil after the next generation of the deployment is successfully rolled out,
-// or error if it has not succeeded within the specified deadline.
-func (c *teamClient) WaitForDeployment(logger *log.Entry, resource unstructured.Unstructured, deadline time.Time) error {
+// or error if it has not succeeded before the context deadline.
+// If the context is cancelled, ErrMonitoringAborted is returned.
+func (c *teamClient) WaitForDeployment(ctx context.Context, logger *log.Entry, resource unstructured.Unstructured) error {
 	var cur *apps.Deployment
 	var nova *apps.Deployment
 	var err error
@@ -205,11 +258,11 @@ func (c *teamClient) WaitForDeployment(logger *log.Entry, resource unstructured.
 	// For Naiserator applications, rely on Naiserator set a terminal rollout status.
 	gvk := resource.GroupVersionKind()
 	if gvk.Kind == "Application" && gvk.Group == "nais.io" {
-		return c.waitForApplication(logger, resource, deadline)
+		return c.waitForApplication(ctx, logger, resource)
 	}
 
 	// For native Kubernetes deployment objects, get the current deployment object.
-	for deadline.After(time.Now()) {
+	for ctx.Err() == nil {
 		cur, err = cli.Get(resource.GetName(), metav1.GetOptions{})
 		if err == nil {
 			resourceVersion, _ = strconv.Atoi(cur.GetResourceVersion())
@@ -218,17 +271,17 @@ func (c *teamClient) WaitForDeployment(logger *log.Entry, resource unstructured.
 			logger.Tracef("Deployment '%s' in namespace '%s' is not currently present in the cluster.", resource.GetName(), resource.GetNamespace())
 		} else {
 			logger.Tracef("Recoverable error while polling for deployment object: %s", err)
-			time.Sleep(requestInterval)
+			sleep(ctx, requestInterval)
 			continue
 		}
 		break
 	}
 
 	// Wait until the new deployment object is present in the cluster.
-	for deadline.After(time.Now()) {
+	for ctx.Err() == nil {
 		nova, err = cli.Get(resource.GetName(), metav1.GetOptions{})
 		if err != nil {
-			time.Sleep(requestInterval)
+			sleep(ctx, requestInterval)
 			continue
 		}
 
@@ -250,18 +303,18 @@ func (c *teamClient) WaitForDeployment(logger *log.Entry, resource unstructured.
 			"deployment_observed_generation": nova.Status.ObservedGeneration,
 		}).Tracef("Still waiting for deployment to finish rollout...")
 
-		time.Sleep(requestInterval)
+		sleep(ctx, requestInterval)
 	}
 
 	if err != nil {
-		return fmt.Errorf("%s; last error was: %s", ErrDeploymentTimeout, err)
+		return fmt.Errorf("%s; last error was: %s", waitError(ctx), err)
 	}
 
-	return ErrDeploymentTimeout
+	return waitError(ctx)
 }
 
-func (c *teamClient) createOrUpdate(client dynamic.ResourceInterface, resource unstructured.Unstructured) (*unstructured.Unstructured, error) {
-	deployed, err := client.Create(&resource, metav1.CreateOptions{})
+func (c *teamClient) createOrUpdate(client dynamic.ResourceInterface, resource unstructured.Unstructured, dryRun []string) (*unstructured.Unstructured, error) {
+	deployed, err := client.Create(&resource, metav1.CreateOptions{DryRun: dryRun})
 	if !errors.IsAlreadyExists(err) {
 		return deployed, err
 	}
@@ -271,7 +324,7 @@ func (c *teamClient) createOrUpdate(client dynamic.ResourceInterface, resource u
 		return nil, fmt.Errorf("get existing resource: %s", err)
 	}
 	resource.SetResourceVersion(existing.GetResourceVersion())
-	return client.Update(&resource, metav1.UpdateOptions{})
+	return client.Update(&resource, metav1.UpdateOptions{DryRun: dryRun})
 }
 
 // deploymentComplete considers a deployment to be complete once all of its desired replicas
@@ -25,14 +25,20 @@ var (
 	DeploySuccessful    = counter("deploy_successful", "number of successful deployments")
 	DeployFailed        = counter("deploy_failed", "number of failed deployments")
 	DeployIgnored       = counter("deploy_ignored", "number of ignored/discarded deployments")
+	DeployCancelled     = counter("deploy_cancelled", "number of deployments cancelled by a user")
 	KubernetesResources = counter("kubernetes_resources", "number of Kubernetes resources successfully committed to cluster")
+	LogEventsSent       = counter("log_events_sent", "number of deployment log lines sent to hookd")
+	LogEventsDropped    = counter("log_events_dropped", "number of deployment log lines dropped because the queue was full or sending failed")
 )
 
 func init() {
 	prometheus.MustRegister(DeploySuccessful)
 	prometheus.MustRegister(DeployFailed)
 	prometheus.MustRegister(DeployIgnored)
+	prometheus.MustRegister(DeployCancelled)
 	prometheus.MustRegister(KubernetesResources)
+	prometheus.MustRegister(LogEventsSent)
+	prometheus.MustRegister(LogEventsDropped)
 }
 
 func Handler() http.Handler {
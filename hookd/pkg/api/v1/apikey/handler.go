@@ -0,0 +1,212 @@
+package api_v1_apikey
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/apikey"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/github"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler lets team maintainers list, rotate and revoke their team's API keys.
+// Requests are authenticated with a GitHub OAuth token in the Authorization header.
+// The token must have the read:org scope, so that team memberships can be looked up.
+type Handler struct {
+	APIKeyStorage persistence.ApiKeyStorage
+	Organization  string
+	AuditLog      *audit.Log
+}
+
+type Request struct {
+	Team string `json:"team"`
+}
+
+type Response struct {
+	Message string        `json:"message,omitempty"`
+	Teams   []apikey.Team `json:"teams,omitempty"`
+	Team    string        `json:"team,omitempty"`
+	Key     string        `json:"key,omitempty"`
+}
+
+func (r *Response) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *Request) validate() error {
+	if len(r.Team) == 0 {
+		return fmt.Errorf("no team specified")
+	}
+	return nil
+}
+
+// authenticate returns the GitHub login of the user owning the bearer token,
+// along with the teams that user is a maintainer of.
+// If anything goes wrong, an error response is written and an empty string is returned.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request, logger *log.Entry) (string, []string) {
+	var response Response
+
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if len(header) == 0 || token == header {
+		w.WriteHeader(http.StatusUnauthorized)
+		response.Message = "a GitHub token must be supplied in the Authorization header"
+		response.render(w)
+		logger.Error(response.Message)
+		return "", nil
+	}
+
+	client := github.UserClient(token)
+
+	user, _, err := client.Users.Get(r.Context(), "")
+	if err != nil {
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.ActorAnonymous,
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": "invalid GitHub token",
+			},
+		})
+		w.WriteHeader(http.StatusUnauthorized)
+		response.Message = api_v1.FailedAuthenticationMsg
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return "", nil
+	}
+
+	teams, err := apikey.MaintainedTeams(r.Context(), client, h.Organization, user.GetLogin())
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		response.Message = "unable to check team membership on GitHub"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return "", nil
+	}
+
+	return user.GetLogin(), teams
+}
+
+// List returns all teams maintained by the user, and whether or not they have an API key.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	login, teams := h.authenticate(w, r, logger)
+	if len(login) == 0 {
+		return
+	}
+
+	result, err := apikey.List(h.APIKeyStorage, teams)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		response.Message = "unable to communicate with team API key backend"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Teams = result
+	response.Message = fmt.Sprintf("'%s' is maintainer of %d teams", login, len(result))
+	response.render(w)
+}
+
+// Rotate creates a new API key for a team. The key is returned in the response, and is not shown again.
+func (h *Handler) Rotate(w http.ResponseWriter, r *http.Request) {
+	h.manage(w, r, true)
+}
+
+// Revoke deletes a team's API key.
+func (h *Handler) Revoke(w http.ResponseWriter, r *http.Request) {
+	h.manage(w, r, false)
+}
+
+func (h *Handler) manage(w http.ResponseWriter, r *http.Request, rotate bool) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	login, teams := h.authenticate(w, r, logger)
+	if len(login) == 0 {
+		return
+	}
+
+	logger = logger.WithField(api_v1.LogFieldUser, login)
+
+	request := &Request{}
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err == nil {
+		err = request.validate()
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid API key request: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	logger = logger.WithField(types.LogFieldTeam, request.Team)
+	response.Team = request.Team
+
+	err = apikey.Authorize(teams, request.Team)
+	if err != nil {
+		h.AuditLog.Record(apikey.AuditRecord(login, request.Team, rotate, audit.OutcomeDenied, middleware.CorrelationID(r)))
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	if rotate {
+		var key []byte
+		key, err = apikey.Rotate(h.APIKeyStorage, request.Team)
+		response.Key = hex.EncodeToString(key)
+		response.Message = "API key created; store it safely, as it will not be shown again"
+	} else {
+		err = apikey.Revoke(h.APIKeyStorage, request.Team)
+		response.Message = "API key revoked"
+	}
+
+	if err != nil {
+		h.AuditLog.Record(apikey.AuditRecord(login, request.Team, rotate, audit.OutcomeFailure, middleware.CorrelationID(r)))
+		w.WriteHeader(http.StatusBadGateway)
+		response.Key = ""
+		response.Message = "unable to communicate with team API key backend"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	h.AuditLog.Record(apikey.AuditRecord(login, request.Team, rotate, audit.OutcomeSuccess, middleware.CorrelationID(r)))
+
+	if rotate {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	response.render(w)
+
+	logger.WithField(api_v1.LogFieldAudit, true).Infof("Team API key %s by '%s'", action(rotate), login)
+}
+
+func action(rotate bool) string {
+	if rotate {
+		return "rotated"
+	}
+	return "revoked"
+}
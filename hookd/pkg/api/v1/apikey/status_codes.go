@@ -0,0 +1,15 @@
+package api_v1_apikey
+
+import (
+	"net/http"
+)
+
+var StatusCodes = []int{
+	http.StatusOK,
+	http.StatusCreated,
+	http.StatusBadRequest,
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+}
@@ -0,0 +1,68 @@
+// Package apitest contains fixtures shared by the tests of the API handlers.
+package apitest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+)
+
+// SecretKey is the API key of every team in APIKeyStorage.
+var SecretKey = []byte("foobar")
+
+// APIKeyStorage returns SecretKey for all teams, except the team "notfound", which has no API key,
+// and the team "unavailable", whose API key can not be read.
+type APIKeyStorage struct{}
+
+func (a *APIKeyStorage) Read(team string) ([]byte, error) {
+	switch team {
+	case "notfound":
+		return nil, persistence.ErrNotFound
+	case "unavailable":
+		return nil, fmt.Errorf("service unavailable")
+	default:
+		return SecretKey, nil
+	}
+}
+
+func (a *APIKeyStorage) Write(team string, key []byte) error {
+	return nil
+}
+
+func (a *APIKeyStorage) Delete(team string) error {
+	return nil
+}
+
+func (a *APIKeyStorage) IsErrNotFound(err error) bool {
+	return err == persistence.ErrNotFound
+}
+
+// Sign returns the signature of a request body, signed with SecretKey.
+func Sign(body []byte) string {
+	return hex.EncodeToString(api_v1.GenMAC(body, SecretKey))
+}
+
+// TempDir creates a temporary directory, and returns a function removing it.
+func TempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "apitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// History creates an empty deployment history in dir, keeping the last ten deployments.
+func History(t *testing.T, dir string) history.Store {
+	store, err := history.NewFileStore(filepath.Join(dir, "history.json"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
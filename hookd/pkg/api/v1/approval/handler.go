@@ -0,0 +1,204 @@
+package api_v1_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	gh "github.com/google/go-github/v27/github"
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/github"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler lists, approves and rejects deployment requests held by the approval gate.
+// Requests are authenticated with a GitHub OAuth token in the Authorization header.
+type Handler struct {
+	Gate              *approval.Gate
+	ApplicationClient *gh.Client
+	AuditLog          *audit.Log
+}
+
+type Request struct {
+	ID string `json:"id"`
+}
+
+type Response struct {
+	Message string             `json:"message,omitempty"`
+	Pending []approval.Pending `json:"pending,omitempty"`
+}
+
+func (r *Response) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *Request) validate() error {
+	if len(r.ID) == 0 {
+		return fmt.Errorf("no deployment request id specified")
+	}
+	return nil
+}
+
+// authenticate returns the GitHub login of the user owning the bearer token.
+// If anything goes wrong, an error response is written and an empty string is returned.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request, logger *log.Entry) string {
+	var response Response
+
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if len(header) == 0 || token == header {
+		w.WriteHeader(http.StatusUnauthorized)
+		response.Message = "a GitHub token must be supplied in the Authorization header"
+		response.render(w)
+		logger.Error(response.Message)
+		return ""
+	}
+
+	user, _, err := github.UserClient(token).Users.Get(r.Context(), "")
+	if err != nil {
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.ActorAnonymous,
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": "invalid GitHub token",
+			},
+		})
+		w.WriteHeader(http.StatusUnauthorized)
+		response.Message = api_v1.FailedAuthenticationMsg
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return ""
+	}
+
+	return user.GetLogin()
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	if len(h.authenticate(w, r, logger)) == 0 {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Pending = h.Gate.List()
+	response.Message = fmt.Sprintf("%d deployment requests waiting for approval", len(response.Pending))
+	response.render(w)
+}
+
+func (h *Handler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, true)
+}
+
+func (h *Handler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, false)
+}
+
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request, approve bool) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	login := h.authenticate(w, r, logger)
+	if len(login) == 0 {
+		return
+	}
+
+	logger = logger.WithField(api_v1.LogFieldApprover, login)
+
+	request := &Request{}
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err == nil {
+		err = request.validate()
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid approval request: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	logger = logger.WithField(types.LogFieldDeliveryID, request.ID)
+
+	pending, err := h.Gate.Get(request.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	logger = logger.WithFields(log.Fields{
+		types.LogFieldTeam:       pending.Team,
+		types.LogFieldCluster:    pending.Cluster,
+		types.LogFieldRepository: pending.Repository,
+	})
+
+	err = approval.Authorize(h.ApplicationClient, pending, login, approve)
+	switch err {
+	case nil:
+	case approval.ErrNotMaintainer, approval.ErrSelfApproval:
+		h.AuditLog.Record(approval.AuditRecord(login, pending, approve, audit.OutcomeDenied))
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusBadGateway)
+		response.Message = "unable to check team membership on GitHub"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	if approve {
+		err = h.Gate.Approve(request.ID, login)
+		response.Message = "deployment request approved and dispatched"
+	} else {
+		err = h.Gate.Reject(request.ID, login)
+		response.Message = "deployment request rejected"
+	}
+
+	switch err {
+	case nil:
+	case approval.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to update pending deployment request"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	h.AuditLog.Record(approval.AuditRecord(login, pending, approve, audit.OutcomeSuccess))
+
+	w.WriteHeader(http.StatusOK)
+	response.render(w)
+
+	logger.WithField(api_v1.LogFieldAudit, true).Infof("Deployment request %s by '%s'", decision(approve), login)
+}
+
+func decision(approve bool) string {
+	if approve {
+		return "approved"
+	}
+	return "rejected"
+}
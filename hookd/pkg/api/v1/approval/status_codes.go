@@ -0,0 +1,15 @@
+package api_v1_approval
+
+import (
+	"net/http"
+)
+
+var StatusCodes = []int{
+	http.StatusOK,
+	http.StatusBadRequest,
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+}
@@ -0,0 +1,103 @@
+package api_v1_audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler searches the audit log.
+// All requests must be signed with the pre-shared administrator key.
+type Handler struct {
+	Reader    audit.Reader
+	Log       *audit.Log
+	SecretKey []byte
+}
+
+type QueryRequest struct {
+	Filter    audit.Filter     `json:"filter"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type Response struct {
+	Message string         `json:"message,omitempty"`
+	Records []audit.Record `json:"records,omitempty"`
+	// Verified is true if the complete audit log forms an unbroken hash chain.
+	Verified bool `json:"verified"`
+	// VerificationError explains why the audit log could not be verified.
+	VerificationError string `json:"verificationError,omitempty"`
+}
+
+func (r *Response) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	data, err := api_v1.ReadSignedBody(r, h.SecretKey)
+	switch err {
+	case nil:
+	case api_v1.ErrMalformedSignature:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrInvalidSignature:
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = api_v1.FailedAuthenticationMsg
+		response.render(w)
+		logger.Error(err)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	request := &QueryRequest{}
+	err = json.Unmarshal(data, request)
+	if err == nil {
+		err = request.Timestamp.Validate()
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid audit query: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	records, err := h.Reader.Records()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to read audit log"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	err = h.Log.Verify(records)
+	response.Verified = err == nil
+	if err != nil {
+		response.VerificationError = err.Error()
+		logger.Errorf("Audit log verification failed: %s", err)
+	}
+
+	response.Records = request.Filter.Apply(records)
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = fmt.Sprintf("%d matching audit records", len(response.Records))
+	response.render(w)
+}
@@ -0,0 +1,12 @@
+package api_v1_audit
+
+import (
+	"net/http"
+)
+
+var StatusCodes = []int{
+	http.StatusOK,
+	http.StatusBadRequest,
+	http.StatusForbidden,
+	http.StatusInternalServerError,
+}
@@ -0,0 +1,401 @@
+package api_v1_cancel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/github"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/outbox"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// Cancellation request's time to live before it is considered too old.
+	ttl = time.Minute * 1
+)
+
+// Handler cancels deployments that have been dispatched to a cluster, but have not yet reached a final state.
+// Requests must be signed with the API key of the team owning the deployment.
+//
+// Deployments waiting for approval, or waiting to be published to Kafka, are withdrawn right away.
+// Other deployments are looked up in History, and a cancellation is sent to their cluster.
+type Handler struct {
+	APIKeyStorage     persistence.ApiKeyStorage
+	History           history.Store
+	Approvals         *approval.Gate
+	Outbox            *outbox.Outbox
+	DeploymentRequest chan types.DeploymentRequest
+	DeploymentStatus  chan<- types.DeploymentStatus
+	AuditLog          *audit.Log
+}
+
+type CancelRequest struct {
+	Team      string           `json:"team"`
+	User      string           `json:"user,omitempty"`
+	Rollback  bool             `json:"rollback,omitempty"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type CancelResponse struct {
+	Message       string        `json:"message,omitempty"`
+	CorrelationID string        `json:"correlationID,omitempty"`
+	Error         *api_v1.Error `json:"error,omitempty"`
+}
+
+func (r *CancelResponse) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *CancelRequest) validate() error {
+	var errs api_v1.FieldErrors
+
+	if err := r.Timestamp.Validate(); err != nil {
+		errs.Add("timestamp", err.Error())
+	}
+
+	return errs.Err()
+}
+
+// CancellationRequestMessage creates a request to cancel a deployment, addressed to the cluster the deployment was sent to.
+func CancellationRequestMessage(r *CancelRequest, deployment *history.Deployment, deliveryID string) (*types.DeploymentRequest, error) {
+	owner, name, err := github.SplitFullname(deployment.Repository)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &types.DeploymentRequest{
+		Deployment: &types.DeploymentSpec{
+			Repository: &types.GithubRepository{
+				Name:  name,
+				Owner: owner,
+			},
+			DeploymentID: deployment.DeploymentID,
+		},
+		PayloadSpec: &types.Payload{
+			Team: deployment.Team,
+		},
+		Cancellation: &types.Cancellation{
+			DeliveryID: deployment.DeliveryID,
+			User:       r.User,
+			Rollback:   r.Rollback,
+		},
+		DeliveryID: deliveryID,
+		Cluster:    deployment.Cluster,
+		Timestamp:  now.Unix(),
+		Deadline:   now.Add(ttl).Unix(),
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var response CancelResponse
+
+	id := chi.URLParam(r, "id")
+	logger := log.WithFields(middleware.RequestLogFields(r)).WithField(types.LogFieldCancelledDeliveryID, id)
+
+	logger.Tracef("Incoming cancel request")
+
+	team, data, err := api_v1.ReadTeamSignedBody(r, h.APIKeyStorage)
+	logger = logger.WithField(types.LogFieldTeam, team)
+
+	switch err {
+	case nil:
+	case api_v1.ErrMalformedSignature:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrMalformedBody:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrNoTeam:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, api_v1.FieldError{Field: "team", Message: err.Error()})
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrUnknownTeam, api_v1.ErrInvalidSignature:
+		h.audit(r, team, r.URL.Path, audit.ActionAuthenticate, audit.OutcomeDenied, map[string]string{
+			"reason": err.Error(),
+		})
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = api_v1.FailedAuthenticationMsg
+		if err == api_v1.ErrUnknownTeam {
+			response.Error = api_v1.NewError(api_v1.ErrorCodeTeamNoAPIKey)
+		} else {
+			response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureInvalid)
+		}
+		response.render(w)
+		logger.Error(err)
+		return
+	case api_v1.ErrAPIKeyUnavailable:
+		w.WriteHeader(http.StatusBadGateway)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeAPIKeyBackendUnavailable)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyUnreadable)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	logger.Tracef("HMAC signature validated successfully")
+
+	request := &CancelRequest{}
+	if err := json.Unmarshal(data, request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	if err := request.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid cancel request: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	// Deployments that have not been dispatched yet are withdrawn right away.
+	held, err := h.withdraw(id, request)
+	switch {
+	case err == errNotHeld:
+	case err == errOtherTeam:
+		h.notFound(w, &response, id, logger)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to withdraw deployment"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	default:
+		h.audit(r, request.Team, held.Repository, audit.ActionCancel, audit.OutcomeSuccess, map[string]string{
+			"cluster":     held.Cluster,
+			"delivery_id": id,
+			"user":        request.User,
+		})
+
+		w.WriteHeader(http.StatusOK)
+		response.Message = fmt.Sprintf("deployment cancelled before it was %s", held.stage)
+		response.render(w)
+
+		logger.Infof("Deployment %s cancelled by '%s' before it was %s", id, request.User, held.stage)
+		return
+	}
+
+	target, err := h.find(id)
+
+	// Deployments belonging to other teams are reported as missing, so that their existence is not revealed.
+	if err == history.ErrNotFound || (err == nil && target.Team != request.Team) {
+		h.notFound(w, &response, id, logger)
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to look up deployment"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	logger = logger.WithFields(log.Fields{
+		types.LogFieldCluster:    target.Cluster,
+		types.LogFieldRepository: target.Repository,
+	})
+
+	if target.Finished() {
+		w.WriteHeader(http.StatusConflict)
+		response.Message = fmt.Sprintf("deployment %s has already finished with state '%s'", id, target.State)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeDeploymentFinished)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	requestID, err := uuid.NewRandom()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to generate request id"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	response.CorrelationID = requestID.String()
+	logger = logger.WithField(types.LogFieldDeliveryID, response.CorrelationID)
+
+	msg, err := CancellationRequestMessage(request, target, response.CorrelationID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to create cancellation message"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	h.DeploymentRequest <- *msg
+
+	h.audit(r, request.Team, target.Repository, audit.ActionCancel, audit.OutcomeSuccess, map[string]string{
+		"cluster":     target.Cluster,
+		"delivery_id": id,
+		"user":        request.User,
+		"rollback":    fmt.Sprintf("%t", request.Rollback),
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+	response.Message = fmt.Sprintf("cancellation request dispatched to %s", target.Cluster)
+	response.render(w)
+
+	logger.Infof("Cancellation of deployment requested by '%s'", request.User)
+}
+
+func (h *Handler) notFound(w http.ResponseWriter, response *CancelResponse, id string, logger *log.Entry) {
+	w.WriteHeader(http.StatusNotFound)
+	response.Message = fmt.Sprintf("deployment %s does not exist", id)
+	response.Error = api_v1.NewError(api_v1.ErrorCodeDeploymentNotFound)
+	response.render(w)
+	logger.Error(response.Message)
+}
+
+var (
+	errNotHeld   = fmt.Errorf("deployment is not held by hookd")
+	errOtherTeam = fmt.Errorf("deployment belongs to another team")
+)
+
+// heldDeployment is a deployment that was withdrawn before it was dispatched to its cluster.
+type heldDeployment struct {
+	Cluster    string
+	Repository string
+	stage      string
+}
+
+// withdraw removes a deployment that is waiting for approval, or waiting in the request outbox,
+// and gives it an inactive status. Returns errNotHeld if hookd does not hold the deployment.
+func (h *Handler) withdraw(id string, request *CancelRequest) (*heldDeployment, error) {
+	if h.Approvals != nil {
+		pending, err := h.Approvals.Get(id)
+		switch {
+		case err == approval.ErrNotFound:
+		case err != nil:
+			return nil, err
+		case pending.Team != request.Team:
+			return nil, errOtherTeam
+		default:
+			err = h.Approvals.Cancel(id, request.User)
+			if err == nil {
+				return &heldDeployment{Cluster: pending.Cluster, Repository: pending.Repository, stage: "approved"}, nil
+			}
+			// Approved, rejected or expired in the meantime.
+			if err != approval.ErrNotFound {
+				return nil, err
+			}
+		}
+	}
+
+	if h.Outbox == nil {
+		return nil, errNotHeld
+	}
+
+	// Requests that are being published are left alone; they are looked up and cancelled like dispatched ones.
+	item, err := h.Outbox.Withdraw(func(item outbox.Item) bool {
+		req := queuedRequest(item, id)
+		return req != nil && req.GetPayloadSpec().GetTeam() == request.Team
+	})
+	switch err {
+	case nil:
+	case outbox.ErrNotFound, outbox.ErrInFlight:
+		return nil, errNotHeld
+	default:
+		return nil, err
+	}
+
+	user := request.User
+	if len(user) == 0 {
+		user = "a user"
+	}
+	req := queuedRequest(*item, id)
+	h.DeploymentStatus <- *types.NewInactiveStatus(*req, fmt.Sprintf("Deployment was cancelled by %s before it was dispatched to %s.", user, req.GetCluster()))
+
+	return &heldDeployment{Cluster: req.GetCluster(), Repository: req.GetDeployment().GetRepository().FullName(), stage: "dispatched"}, nil
+}
+
+// find looks up a deployment that has been dispatched, or is being dispatched, to its cluster.
+// Returns history.ErrNotFound if the deployment is unknown.
+func (h *Handler) find(id string) (*history.Deployment, error) {
+	deployment, err := h.History.Get(id)
+	if err != history.ErrNotFound || h.Outbox == nil {
+		return deployment, err
+	}
+
+	for _, item := range h.Outbox.Inspect().Items {
+		req := queuedRequest(item, id)
+		if req == nil {
+			continue
+		}
+		return &history.Deployment{
+			DeliveryID:   req.GetDeliveryID(),
+			DeploymentID: req.GetDeployment().GetDeploymentID(),
+			Team:         req.GetPayloadSpec().GetTeam(),
+			Cluster:      req.GetCluster(),
+			Repository:   req.GetDeployment().GetRepository().FullName(),
+		}, nil
+	}
+
+	return nil, history.ErrNotFound
+}
+
+// queuedRequest returns the deployment request with the given delivery ID held by an outbox item,
+// or nil if the item holds something else.
+func queuedRequest(item outbox.Item, id string) *types.DeploymentRequest {
+	req := &types.DeploymentRequest{}
+	if err := proto.Unmarshal(item.Payload, req); err != nil {
+		return nil
+	}
+	if req.GetDeliveryID() != id || !req.ReportsStatus() {
+		return nil
+	}
+	return req
+}
+
+func (h *Handler) audit(r *http.Request, team, target, action, outcome string, details map[string]string) {
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.TeamActor(team),
+		Action:        action,
+		Target:        target,
+		Outcome:       outcome,
+		CorrelationID: middleware.CorrelationID(r),
+		Details:       details,
+	})
+}
@@ -0,0 +1,224 @@
+package api_v1_cancel_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/protobuf/proto"
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/apitest"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/cancel"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func status(deliveryID string, state types.GithubDeploymentState) types.DeploymentStatus {
+	return types.DeploymentStatus{
+		Deployment: &types.DeploymentSpec{
+			Repository: &types.GithubRepository{
+				Owner: "navikt",
+				Name:  "myapp",
+			},
+			DeploymentID: 123,
+		},
+		DeliveryID: deliveryID,
+		State:      state,
+		Team:       "aura",
+		Cluster:    "dev-fss",
+	}
+}
+
+func TestCancelHandler(t *testing.T) {
+	dir, cleanup := apitest.TempDir(t)
+	defer cleanup()
+
+	store := apitest.History(t, dir)
+	assert.NoError(t, store.Add(status("running", types.GithubDeploymentState_in_progress), time.Now()))
+	assert.NoError(t, store.Add(status("finished", types.GithubDeploymentState_success), time.Now()))
+
+	for _, test := range []struct {
+		name      string
+		id        string
+		team      string
+		signature string
+		code      int
+		errorCode api_v1.ErrorCode
+	}{
+		{name: "running deployment", id: "running", team: "aura", code: http.StatusAccepted},
+		{name: "finished deployment", id: "finished", team: "aura", code: http.StatusConflict, errorCode: api_v1.ErrorCodeDeploymentFinished},
+		{name: "unknown deployment", id: "unknown", team: "aura", code: http.StatusNotFound, errorCode: api_v1.ErrorCodeDeploymentNotFound},
+		{name: "other team's deployment", id: "running", team: "other", code: http.StatusNotFound, errorCode: api_v1.ErrorCodeDeploymentNotFound},
+		{name: "team without API key", id: "running", team: "notfound", code: http.StatusForbidden, errorCode: api_v1.ErrorCodeTeamNoAPIKey},
+		{name: "API key backend down", id: "running", team: "unavailable", code: http.StatusBadGateway, errorCode: api_v1.ErrorCodeAPIKeyBackendUnavailable},
+		{name: "wrong signature", id: "running", team: "aura", signature: "abcd", code: http.StatusForbidden, errorCode: api_v1.ErrorCodeSignatureInvalid},
+		{name: "malformed signature", id: "running", team: "aura", signature: "foo", code: http.StatusBadRequest, errorCode: api_v1.ErrorCodeSignatureMalformed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			requests := make(chan types.DeploymentRequest, 1)
+			handler := &api_v1_cancel.Handler{
+				APIKeyStorage:     &apitest.APIKeyStorage{},
+				History:           store,
+				DeploymentRequest: requests,
+			}
+			router := chi.NewRouter()
+			router.Post("/api/v1/deploy/{id}/cancel", handler.ServeHTTP)
+
+			body, _ := json.Marshal(api_v1_cancel.CancelRequest{
+				Team:      test.team,
+				User:      "jane",
+				Rollback:  true,
+				Timestamp: api_v1.Timestamp(time.Now().Unix()),
+			})
+			signature := test.signature
+			if len(signature) == 0 {
+				signature = apitest.Sign(body)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/deploy/"+test.id+"/cancel", bytes.NewReader(body))
+			request.Header.Set(api_v1.SignatureHeader, signature)
+			router.ServeHTTP(recorder, request)
+
+			response := api_v1_cancel.CancelResponse{}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, test.code, recorder.Code)
+
+			if test.code != http.StatusAccepted {
+				assert.Equal(t, test.errorCode, response.Error.Code)
+				assert.Len(t, requests, 0)
+				return
+			}
+
+			assert.Nil(t, response.Error)
+			assert.Len(t, requests, 1)
+			msg := <-requests
+			assert.Equal(t, response.CorrelationID, msg.GetDeliveryID())
+			assert.Equal(t, "dev-fss", msg.GetCluster())
+			assert.Equal(t, "navikt/myapp", msg.GetDeployment().GetRepository().FullName())
+			assert.Equal(t, int64(123), msg.GetDeployment().GetDeploymentID())
+			assert.Equal(t, "running", msg.GetCancellation().GetDeliveryID())
+			assert.Equal(t, "jane", msg.GetCancellation().GetUser())
+			assert.True(t, msg.GetCancellation().GetRollback())
+		})
+	}
+}
+
+func deploymentRequest(deliveryID, team string) types.DeploymentRequest {
+	return types.DeploymentRequest{
+		Deployment: &types.DeploymentSpec{
+			Repository: &types.GithubRepository{
+				Owner: "navikt",
+				Name:  "myapp",
+			},
+			DeploymentID: 123,
+		},
+		PayloadSpec: &types.Payload{
+			Team: team,
+		},
+		DeliveryID: deliveryID,
+		Cluster:    "prod-fss",
+	}
+}
+
+func cancel(handler http.Handler, id, team string) (*httptest.ResponseRecorder, api_v1_cancel.CancelResponse) {
+	router := chi.NewRouter()
+	router.Post("/api/v1/deploy/{id}/cancel", handler.ServeHTTP)
+
+	body, _ := json.Marshal(api_v1_cancel.CancelRequest{
+		Team:      team,
+		User:      "jane",
+		Timestamp: api_v1.Timestamp(time.Now().Unix()),
+	})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/deploy/"+id+"/cancel", bytes.NewReader(body))
+	request.Header.Set(api_v1.SignatureHeader, apitest.Sign(body))
+	router.ServeHTTP(recorder, request)
+
+	response := api_v1_cancel.CancelResponse{}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder, response
+}
+
+func TestCancelHeldDeployment(t *testing.T) {
+	dir, cleanup := apitest.TempDir(t)
+	defer cleanup()
+
+	store := apitest.History(t, dir)
+
+	requests := make(chan types.DeploymentRequest, 4)
+	statuses := make(chan types.DeploymentStatus, 4)
+
+	gate, err := approval.NewGate(filepath.Join(dir, "approvals.json"), []string{"prod-fss"}, time.Hour, requests, statuses)
+	assert.NoError(t, err)
+	_, err = gate.Hold(deploymentRequest("pending", "aura"), "jane")
+	assert.NoError(t, err)
+	<-statuses
+
+	box, err := outbox.New(filepath.Join(dir, "outbox.json"), "requests", 10, outbox.Policy{})
+	assert.NoError(t, err)
+	for _, req := range []types.DeploymentRequest{deploymentRequest("queued", "aura"), deploymentRequest("foreign", "other")} {
+		payload, err := proto.Marshal(&req)
+		assert.NoError(t, err)
+		assert.NoError(t, box.Push(req.GetDeliveryID(), req.GetDeliveryID(), payload))
+	}
+
+	handler := &api_v1_cancel.Handler{
+		APIKeyStorage:     &apitest.APIKeyStorage{},
+		History:           store,
+		Approvals:         gate,
+		Outbox:            box,
+		DeploymentRequest: requests,
+		DeploymentStatus:  statuses,
+	}
+
+	t.Run("deployment waiting for approval", func(t *testing.T) {
+		recorder, response := cancel(handler, "pending", "aura")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Nil(t, response.Error)
+		assert.Equal(t, "deployment cancelled before it was approved", response.Message)
+
+		status := <-statuses
+		assert.Equal(t, "pending", status.GetDeliveryID())
+		assert.Equal(t, types.GithubDeploymentState_inactive, status.GetState())
+		assert.Equal(t, "Deployment was cancelled by jane before it was approved.", status.GetDescription())
+		assert.Empty(t, gate.List())
+	})
+
+	t.Run("deployment waiting in the outbox", func(t *testing.T) {
+		recorder, response := cancel(handler, "queued", "aura")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Nil(t, response.Error)
+		assert.Equal(t, "deployment cancelled before it was dispatched", response.Message)
+
+		status := <-statuses
+		assert.Equal(t, "queued", status.GetDeliveryID())
+		assert.Equal(t, types.GithubDeploymentState_inactive, status.GetState())
+		assert.Equal(t, "Deployment was cancelled by jane before it was dispatched to prod-fss.", status.GetDescription())
+		assert.Equal(t, 1, box.Len())
+	})
+
+	t.Run("other team's deployment waiting in the outbox", func(t *testing.T) {
+		recorder, response := cancel(handler, "foreign", "aura")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		assert.Equal(t, api_v1.ErrorCodeDeploymentNotFound, response.Error.Code)
+		assert.Equal(t, 1, box.Len())
+	})
+
+	t.Run("withdrawn deployment", func(t *testing.T) {
+		recorder, response := cancel(handler, "queued", "aura")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		assert.Equal(t, api_v1.ErrorCodeDeploymentNotFound, response.Error.Code)
+	})
+
+	assert.Len(t, requests, 0)
+	assert.Len(t, statuses, 0)
+}
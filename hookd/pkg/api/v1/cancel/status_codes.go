@@ -0,0 +1,16 @@
+package api_v1_cancel
+
+import (
+	"net/http"
+)
+
+var StatusCodes = []int{
+	http.StatusOK,
+	http.StatusAccepted,
+	http.StatusBadRequest,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusConflict,
+	http.StatusBadGateway,
+	http.StatusInternalServerError,
+}
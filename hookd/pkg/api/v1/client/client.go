@@ -0,0 +1,234 @@
+package api_v1_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/navikt/deployment/common/pkg/tracing"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/cancel"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/dora"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/logs"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/provision"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/status"
+)
+
+const (
+	DeployPath    = "/api/v1/deploy"
+	DryRunPath    = DeployPath + "?dryRun=true"
+	DiffPath      = DeployPath + "?diff=true"
+	StatusPath    = "/api/v1/status"
+	DORAPath      = "/api/v1/dora/report"
+	ProvisionPath = "/api/v1/provision"
+)
+
+// Client makes signed requests to the hookd API.
+type Client struct {
+	baseURL    url.URL
+	httpClient *http.Client
+}
+
+// ErrorResponse is returned when hookd responds with an unexpected status code.
+// Message and Details hold the error message and error code from the response body, if any.
+type ErrorResponse struct {
+	Response *http.Response
+	Message  string
+	Details  *api_v1.Error
+}
+
+// Retryable reports whether the request may succeed if it is retried.
+// Responses without an error code are considered retryable if the status code indicates a server error.
+func (e *ErrorResponse) Retryable() bool {
+	if e.Details != nil {
+		return e.Details.Retryable
+	}
+	return e.Response.StatusCode >= 500
+}
+
+func (e *ErrorResponse) Error() string {
+	status := e.Response.Status
+	if e.Details != nil {
+		status = fmt.Sprintf("%s (%s)", status, e.Details.Code)
+	}
+	if len(e.Message) == 0 {
+		return status
+	}
+	return fmt.Sprintf("%s: %s", status, e.Message)
+}
+
+// New creates a client for the hookd server at baseURL.
+// If httpClient is nil, http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("'%s' is not an absolute URL", baseURL)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    *u,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Sign returns the hex encoded HMAC digest of data, suitable for the signature header.
+func Sign(data, key []byte) string {
+	return hex.EncodeToString(api_v1.GenMAC(data, key))
+}
+
+// Deploy submits a deployment request signed with the team's API key.
+// Both 201 Created and 202 Accepted, when the deployment is waiting for approval, are successful responses.
+func (c *Client) Deploy(ctx context.Context, key []byte, request api_v1_deploy.DeploymentRequest) (*api_v1_deploy.DeploymentResponse, *http.Response, error) {
+	response := &api_v1_deploy.DeploymentResponse{}
+	resp, err := c.do(ctx, DeployPath, key, request, response, http.StatusCreated, http.StatusAccepted)
+	return response, resp, err
+}
+
+// DryRun validates a deployment request and submits its resources to the cluster as a server-side dry run, signed with the team's API key.
+// The server responds with 200 OK if all resources were accepted; the results for each resource are returned in both cases.
+func (c *Client) DryRun(ctx context.Context, key []byte, request api_v1_deploy.DeploymentRequest) (*api_v1_deploy.DeploymentResponse, *http.Response, error) {
+	response := &api_v1_deploy.DeploymentResponse{}
+	resp, err := c.do(ctx, DryRunPath, key, request, response, http.StatusOK)
+	return response, resp, err
+}
+
+// Diff runs a dry run like DryRun, and additionally asks for a diff between each resource and the version running in the cluster.
+func (c *Client) Diff(ctx context.Context, key []byte, request api_v1_deploy.DeploymentRequest) (*api_v1_deploy.DeploymentResponse, *http.Response, error) {
+	response := &api_v1_deploy.DeploymentResponse{}
+	resp, err := c.do(ctx, DiffPath, key, request, response, http.StatusOK)
+	return response, resp, err
+}
+
+// Cancel requests cancellation of the deployment with the given correlation ID, signed with the team's API key.
+// The server responds with 200 OK if the deployment was withdrawn before it was dispatched to the cluster,
+// and with 202 Accepted once the cancellation has been dispatched; the final status is then reported asynchronously.
+func (c *Client) Cancel(ctx context.Context, key []byte, id string, request api_v1_cancel.CancelRequest) (*api_v1_cancel.CancelResponse, *http.Response, error) {
+	response := &api_v1_cancel.CancelResponse{}
+	resp, err := c.do(ctx, CancelPath(id), key, request, response, http.StatusOK, http.StatusAccepted)
+	return response, resp, err
+}
+
+// CancelPath returns the path of the endpoint cancelling the deployment with the given correlation ID.
+func CancelPath(id string) string {
+	return path.Join(DeployPath, id, "cancel")
+}
+
+// Logs retrieves the log lines of the deployment with the given correlation ID, signed with the team's API key.
+// Lines before the offset in the request are left out, so that the log can be tailed by polling.
+func (c *Client) Logs(ctx context.Context, key []byte, id string, request api_v1_logs.LogsRequest) (*api_v1_logs.LogsResponse, *http.Response, error) {
+	response := &api_v1_logs.LogsResponse{}
+	resp, err := c.do(ctx, LogsPath(id), key, request, response, http.StatusOK)
+	return response, resp, err
+}
+
+// LogsPath returns the path of the endpoint returning the log of the deployment with the given correlation ID.
+func LogsPath(id string) string {
+	return path.Join(DeployPath, id, "logs")
+}
+
+// Status polls the status of a deployment, signed with the team's API key.
+// The response status is nil while the deployment has not yet been created on GitHub.
+func (c *Client) Status(ctx context.Context, key []byte, request api_v1_status.StatusRequest) (*api_v1_status.StatusResponse, *http.Response, error) {
+	response := &api_v1_status.StatusResponse{}
+	resp, err := c.do(ctx, StatusPath, key, request, response, http.StatusOK, http.StatusNoContent)
+	return response, resp, err
+}
+
+// DORAReport retrieves the delivery metrics of a team's deployments, signed with the team's API key.
+func (c *Client) DORAReport(ctx context.Context, key []byte, request api_v1_dora.ReportRequest) (*api_v1_dora.ReportResponse, *http.Response, error) {
+	response := &api_v1_dora.ReportResponse{}
+	resp, err := c.do(ctx, DORAPath, key, request, response, http.StatusOK)
+	return response, resp, err
+}
+
+// Provision creates an API key for a team, signed with the pre-shared provisioning key.
+// The server responds with 204 No Content if the team already has a key and rotation was not requested.
+func (c *Client) Provision(ctx context.Context, key []byte, request api_v1_provision.Request) (*api_v1_provision.Response, *http.Response, error) {
+	response := &api_v1_provision.Response{}
+	resp, err := c.do(ctx, ProvisionPath, key, request, response, http.StatusCreated, http.StatusNoContent)
+	return response, resp, err
+}
+
+// do posts the signed request to path, which may include a query string, and decodes the response body into response.
+// If ctx carries trace context, it is propagated in the traceparent header.
+// If the status code is not one of the expected codes, an *ErrorResponse is returned,
+// and response is still populated if the body could be decoded.
+func (c *Client) do(ctx context.Context, path string, key []byte, request, response interface{}, expected ...int) (*http.Response, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal request: %s", err)
+	}
+
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request path: %s", err)
+	}
+
+	target := c.baseURL
+	target.Path = ref.Path
+	target.RawQuery = ref.RawQuery
+
+	req, err := http.NewRequest(http.MethodPost, target.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("internal error creating http request: %s", err)
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set(api_v1.SignatureHeader, Sign(payload, key))
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, fmt.Errorf("unable to read response body: %s", err)
+	}
+
+	var decodeErr error
+	if len(data) > 0 {
+		decodeErr = json.Unmarshal(data, response)
+	}
+
+	for _, code := range expected {
+		if resp.StatusCode != code {
+			continue
+		}
+		if decodeErr != nil {
+			return resp, fmt.Errorf("received invalid response from server: %s", decodeErr)
+		}
+		return resp, nil
+	}
+
+	errorResponse := &ErrorResponse{
+		Response: resp,
+	}
+	body := struct {
+		Message string        `json:"message"`
+		Error   *api_v1.Error `json:"error"`
+	}{}
+	if json.Unmarshal(data, &body) == nil {
+		errorResponse.Message = body.Message
+		errorResponse.Details = body.Error
+	} else {
+		errorResponse.Message = string(data)
+	}
+
+	return resp, errorResponse
+}
@@ -0,0 +1,208 @@
+package api_v1_client_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/navikt/deployment/common/pkg/tracing"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/cancel"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/client"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/logs"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/provision"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/status"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
+	"github.com/stretchr/testify/assert"
+)
+
+var key = []byte("team key")
+
+func server(t *testing.T, code int, body interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		signature, err := hex.DecodeString(r.Header.Get(api_v1.SignatureHeader))
+		assert.NoError(t, err)
+		assert.True(t, api_v1.ValidateMAC(data, signature, key))
+		assert.Equal(t, "application/json", r.Header.Get("content-type"))
+
+		w.WriteHeader(code)
+		if body != nil {
+			json.NewEncoder(w).Encode(body)
+		}
+	}))
+}
+
+func TestDeploy(t *testing.T) {
+	s := server(t, http.StatusCreated, api_v1_deploy.DeploymentResponse{CorrelationID: "abc"})
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	response, resp, err := c.Deploy(context.Background(), key, api_v1_deploy.DeploymentRequest{Team: "aura"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "abc", response.CorrelationID)
+}
+
+func TestErrorResponse(t *testing.T) {
+	s := server(t, http.StatusForbidden, api_v1_deploy.DeploymentResponse{
+		Message: "failed authentication",
+		LogURL:  "https://logs",
+		Error:   api_v1.NewError(api_v1.ErrorCodeSignatureInvalid),
+	})
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	response, resp, err := c.Deploy(context.Background(), key, api_v1_deploy.DeploymentRequest{Team: "aura"})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "https://logs", response.LogURL)
+
+	errorResponse, ok := err.(*api_v1_client.ErrorResponse)
+	assert.True(t, ok)
+	assert.Equal(t, "failed authentication", errorResponse.Message)
+	assert.Equal(t, api_v1.ErrorCodeSignatureInvalid, errorResponse.Details.Code)
+	assert.False(t, errorResponse.Retryable())
+	assert.Equal(t, "403 Forbidden (SIGNATURE_INVALID): failed authentication", err.Error())
+}
+
+func TestRetryableWithoutErrorCode(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("upstream connect error"))
+	}))
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	_, _, err = c.Status(context.Background(), key, api_v1_status.StatusRequest{Team: "aura"})
+	errorResponse, ok := err.(*api_v1_client.ErrorResponse)
+	assert.True(t, ok)
+	assert.Nil(t, errorResponse.Details)
+	assert.Equal(t, "upstream connect error", errorResponse.Message)
+	assert.True(t, errorResponse.Retryable())
+}
+
+func TestStatusNoContent(t *testing.T) {
+	s := server(t, http.StatusNoContent, nil)
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	response, resp, err := c.Status(context.Background(), key, api_v1_status.StatusRequest{Team: "aura"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Nil(t, response.Status)
+}
+
+func TestProvision(t *testing.T) {
+	s := server(t, http.StatusCreated, api_v1_provision.Response{Message: "API key provisioned successfully"})
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	response, _, err := c.Provision(context.Background(), key, api_v1_provision.Request{Team: "aura"})
+	assert.NoError(t, err)
+	assert.Equal(t, "API key provisioned successfully", response.Message)
+}
+
+func TestCancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/deploy/abc/cancel", r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(api_v1_cancel.CancelResponse{Message: "cancellation request dispatched to dev-fss"})
+	}))
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	response, _, err := c.Cancel(context.Background(), key, "abc", api_v1_cancel.CancelRequest{Team: "aura"})
+	assert.NoError(t, err)
+	assert.Equal(t, "cancellation request dispatched to dev-fss", response.Message)
+}
+
+func TestLogs(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/deploy/abc/logs", r.URL.Path)
+		request := api_v1_logs.LogsRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, 2, request.Offset)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api_v1_logs.LogsResponse{
+			Events:   []logstore.Event{{Message: "third"}},
+			Finished: true,
+		})
+	}))
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	response, _, err := c.Logs(context.Background(), key, "abc", api_v1_logs.LogsRequest{Team: "aura", Offset: 2})
+	assert.NoError(t, err)
+	assert.True(t, response.Finished)
+	assert.Len(t, response.Events, 1)
+	assert.Equal(t, "third", response.Events[0].Message)
+}
+
+func TestDryRun(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/deploy", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("dryRun"))
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(api_v1_deploy.DeploymentResponse{
+			Message: "Dry run failed; one or more resources were rejected by Kubernetes.",
+			Resources: []api_v1_deploy.ResourceResult{
+				{Kind: "Application", Name: "myapp", Error: "denied"},
+			},
+			Error: api_v1.NewError(api_v1.ErrorCodeDryRunFailed),
+		})
+	}))
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	response, _, err := c.DryRun(context.Background(), key, api_v1_deploy.DeploymentRequest{Team: "aura"})
+	assert.IsType(t, &api_v1_client.ErrorResponse{}, err)
+	assert.Equal(t, api_v1.ErrorCodeDryRunFailed, err.(*api_v1_client.ErrorResponse).Details.Code)
+	assert.Len(t, response.Resources, 1)
+	assert.Equal(t, "denied", response.Resources[0].Error)
+}
+
+func TestTraceparent(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, traceparent, r.Header.Get(tracing.TraceparentHeader))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api_v1_deploy.DeploymentResponse{CorrelationID: "abc"})
+	}))
+	defer s.Close()
+
+	c, err := api_v1_client.New(s.URL, s.Client())
+	assert.NoError(t, err)
+
+	ctx := tracing.ContextWithTraceparent(context.Background(), traceparent)
+	_, _, err = c.Deploy(ctx, key, api_v1_deploy.DeploymentRequest{Team: "aura"})
+	assert.NoError(t, err)
+}
+
+func TestInvalidBaseURL(t *testing.T) {
+	_, err := api_v1_client.New("deployment.nais.io", nil)
+	assert.Error(t, err)
+}
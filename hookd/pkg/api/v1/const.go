@@ -10,4 +10,10 @@ const (
 	SignatureHeader         = "X-NAIS-Signature"
 	FailedAuthenticationMsg = "failed authentication"
 	DirectDeployGithubTask  = "NAIS_DIRECT_DEPLOY"
+
+	LogFieldAudit          = "audit"
+	LogFieldFreezeWindowID = "freeze_window_id"
+	LogFieldApprover       = "approver"
+	LogFieldUser           = "user"
+	LogFieldDryRun         = "dry_run"
 )
@@ -11,7 +11,7 @@ import (
 )
 
 var (
-	// Deployment request's time to live before it is considered too old.
+	// Deployment request's default time to live before it is considered too old.
 	ttl = time.Minute * 1
 
 	// Payload API version
@@ -19,12 +19,17 @@ var (
 )
 
 // DeploymentRequestMessage creates a deployment request from user input provided to the deployment API.
+// A rollout timeout of zero leaves the choice of timeout to deployd.
 func DeploymentRequestMessage(r *DeploymentRequest, deployment *gh.Deployment, deliveryID string) (*types.DeploymentRequest, error) {
 	kube, err := types.KubernetesFromJSONResources(r.Resources)
 	if err != nil {
 		return nil, err
 	}
-	now := time.Unix(r.Timestamp, 0)
+	timeout, requestTTL := r.timeouts()
+	if requestTTL == 0 {
+		requestTTL = ttl
+	}
+	now := time.Unix(int64(r.Timestamp), 0)
 	return &types.DeploymentRequest{
 		Deployment: &types.DeploymentSpec{
 			Repository: &types.GithubRepository{
@@ -38,10 +43,11 @@ func DeploymentRequestMessage(r *DeploymentRequest, deployment *gh.Deployment, d
 			Version:    payloadVersion,
 			Kubernetes: kube,
 		},
-		DeliveryID: deliveryID,
-		Cluster:    r.Cluster,
-		Timestamp:  now.Unix(),
-		Deadline:   now.Add(ttl).Unix(),
+		DeliveryID:     deliveryID,
+		Cluster:        r.Cluster,
+		Timestamp:      now.Unix(),
+		Deadline:       now.Add(requestTTL).Unix(),
+		RolloutTimeout: int64(timeout.Seconds()),
 	}, nil
 }
 
@@ -54,3 +54,23 @@ func TestDeploymentRequest(t *testing.T) {
 	assert.NotNil(t, val)
 	assert.Equal(t, "bar", val.GetStringValue())
 }
+
+func TestDeploymentRequestTimeouts(t *testing.T) {
+	deploymentRequest := &server.DeploymentRequest{
+		Resources: []byte(`[{"foo": "bar"}]`),
+		Timestamp: 1000,
+	}
+
+	deployMsg, err := server.DeploymentRequestMessage(deploymentRequest, &gh.Deployment{}, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deployMsg.GetRolloutTimeout())
+	assert.Equal(t, int64(1060), deployMsg.GetDeadline())
+
+	deploymentRequest.Timeout = "45m"
+	deploymentRequest.TTL = "5m"
+
+	deployMsg, err = server.DeploymentRequestMessage(deploymentRequest, &gh.Deployment{}, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2700), deployMsg.GetRolloutTimeout())
+	assert.Equal(t, int64(1300), deployMsg.GetDeadline())
+}
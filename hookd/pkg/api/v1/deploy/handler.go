@@ -1,22 +1,29 @@
 package api_v1_deploy
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/dryrun"
+	"github.com/navikt/deployment/hookd/pkg/freeze"
 	"github.com/navikt/deployment/hookd/pkg/github"
 	"github.com/navikt/deployment/hookd/pkg/logproxy"
 	"github.com/navikt/deployment/hookd/pkg/middleware"
 
 	gh "github.com/google/go-github/v27/github"
 	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/tracing"
 	"github.com/navikt/deployment/hookd/pkg/persistence"
 	log "github.com/sirupsen/logrus"
 )
@@ -28,24 +35,46 @@ type DeploymentHandler struct {
 	DeploymentRequest chan types.DeploymentRequest
 	BaseURL           string
 	Clusters          api_v1.ClusterList
+	Timeouts          api_v1.Timeouts
+	FreezeStorage     freeze.Storage
+	ApprovalGate      *approval.Gate
+	AuditLog          *audit.Log
+	DryRuns           *dryrun.Results
+	DryRunTimeout     time.Duration
 }
 
 type DeploymentRequest struct {
-	Resources   json.RawMessage `json:"resources,omitempty"`
-	Team        string          `json:"team,omitempty"`
-	Cluster     string          `json:"cluster,omitempty"`
-	Environment string          `json:"environment,omitempty"`
-	Owner       string          `json:"owner,omitempty"`
-	Repository  string          `json:"repository,omitempty"`
-	Ref         string          `json:"ref,omitempty"`
-	Timestamp   int64           `json:"timestamp"`
+	Resources      json.RawMessage  `json:"resources,omitempty"`
+	Team           string           `json:"team,omitempty"`
+	Cluster        string           `json:"cluster,omitempty"`
+	Environment    string           `json:"environment,omitempty"`
+	Owner          string           `json:"owner,omitempty"`
+	Repository     string           `json:"repository,omitempty"`
+	Ref            string           `json:"ref,omitempty"`
+	FreezeOverride bool             `json:"freezeOverride,omitempty"`
+	Timeout        string           `json:"timeout,omitempty"`
+	TTL            string           `json:"ttl,omitempty"`
+	User           string           `json:"user,omitempty"`
+	Timestamp      api_v1.Timestamp `json:"timestamp"`
 }
 
 type DeploymentResponse struct {
-	Message          string         `json:"message,omitempty"`
-	CorrelationID    string         `json:"correlationID,omitempty"`
-	LogURL           string         `json:"logURL,omitempty"`
-	GithubDeployment *gh.Deployment `json:"githubDeployment,omitempty"`
+	Message          string           `json:"message,omitempty"`
+	CorrelationID    string           `json:"correlationID,omitempty"`
+	LogURL           string           `json:"logURL,omitempty"`
+	GithubDeployment *gh.Deployment   `json:"githubDeployment,omitempty"`
+	Resources        []ResourceResult `json:"resources,omitempty"`
+	Error            *api_v1.Error    `json:"error,omitempty"`
+}
+
+// ResourceResult is the outcome of a dry run for a single Kubernetes resource.
+// Diff is a unified diff against the running version of the resource, and is only set when a diff was requested.
+type ResourceResult struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Diff      string `json:"diff,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 func (r *DeploymentResponse) render(w io.Writer) {
@@ -53,40 +82,87 @@ func (r *DeploymentResponse) render(w io.Writer) {
 }
 
 func (r *DeploymentRequest) validate() error {
+	var errs api_v1.FieldErrors
 
 	if len(r.Owner) == 0 {
-		return fmt.Errorf("no repository owner specified")
+		errs.Add("owner", "no repository owner specified")
 	}
 
 	if len(r.Repository) == 0 {
-		return fmt.Errorf("no repository specified")
+		errs.Add("repository", "no repository specified")
 	}
 
 	if len(r.Cluster) == 0 {
-		return fmt.Errorf("no cluster specified")
+		errs.Add("cluster", "no cluster specified")
 	}
 
 	if len(r.Environment) == 0 {
-		return fmt.Errorf("no environment specified")
+		errs.Add("environment", "no environment specified")
 	}
 
 	if len(r.Team) == 0 {
-		return fmt.Errorf("no team specified")
+		errs.Add("team", "no team specified")
 	}
 
 	if len(r.Ref) == 0 {
-		return fmt.Errorf("no commit ref specified")
+		errs.Add("ref", "no commit ref specified")
+	}
+
+	if _, err := parseDuration(r.Timeout); err != nil {
+		errs.Add("timeout", "rollout timeout must be a positive duration such as '10m'")
+	}
+
+	if _, err := parseDuration(r.TTL); err != nil {
+		errs.Add("ttl", "time to live must be a positive duration such as '5m'")
 	}
 
 	list := make([]interface{}, 0)
 	err := json.Unmarshal(r.Resources, &list)
 	if err != nil {
-		return fmt.Errorf("resources field must be a list")
+		errs.Add("resources", "resources field must be a list")
 	} else if len(list) == 0 {
-		return fmt.Errorf("resources must contain at least one Kubernetes resource")
+		errs.Add("resources", "resources must contain at least one Kubernetes resource")
+	}
+
+	if err := r.Timestamp.Validate(); err != nil {
+		errs.Add("timestamp", err.Error())
+	}
+
+	return errs.Err()
+}
+
+// requireUser checks that deployments held for approval name the person requesting them,
+// as they are not allowed to approve their own deployments.
+func (h *DeploymentHandler) requireUser(r *DeploymentRequest, dryRun bool) error {
+	var errs api_v1.FieldErrors
+
+	if !dryRun && len(r.User) == 0 && h.ApprovalGate.Required(r.Cluster) {
+		errs.Add("user", fmt.Sprintf("deployments to cluster '%s' require approval, and must specify the requesting user", r.Cluster))
 	}
 
-	return nil
+	return errs.Err()
+}
+
+// parseDuration parses an optional duration. Empty strings yield a zero duration.
+func parseDuration(str string) (time.Duration, error) {
+	if len(str) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
+
+// timeouts returns the rollout timeout and time to live of the request, or zero if they are not specified or invalid.
+func (r *DeploymentRequest) timeouts() (time.Duration, time.Duration) {
+	timeout, _ := parseDuration(r.Timeout)
+	ttl, _ := parseDuration(r.TTL)
+	return timeout, ttl
 }
 
 func (r *DeploymentRequest) GithubDeploymentRequest() gh.DeploymentRequest {
@@ -100,6 +176,17 @@ func (r *DeploymentRequest) GithubDeploymentRequest() gh.DeploymentRequest {
 	}
 }
 
+func (h *DeploymentHandler) audit(r *DeploymentRequest, correlationID, action, outcome string, details map[string]string) {
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.TeamActor(r.Team),
+		Action:        action,
+		Target:        fmt.Sprintf("%s/%s", r.Owner, r.Repository),
+		Outcome:       outcome,
+		CorrelationID: correlationID,
+		Details:       details,
+	})
+}
+
 func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var err error
 	var deploymentResponse DeploymentResponse
@@ -112,6 +199,7 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		deploymentResponse.Message = fmt.Sprintf("unable to generate request id")
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
 		deploymentResponse.render(w)
 		logger.Errorf("%s: %s", deploymentResponse.Message, err)
 		return
@@ -127,6 +215,7 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		deploymentResponse.Message = fmt.Sprintf("unable to read request body: %s", err)
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeBodyUnreadable)
 		deploymentResponse.render(w)
 		logger.Error(deploymentResponse.Message)
 		return
@@ -137,6 +226,7 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		deploymentResponse.Message = "HMAC digest must be hex encoded"
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeSignatureMalformed)
 		deploymentResponse.render(w)
 		logger.Errorf("unable to validate team: %s: %s", deploymentResponse.Message, err)
 		return
@@ -148,6 +238,7 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := json.Unmarshal(data, deploymentRequest); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		deploymentResponse.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
 		deploymentResponse.render(w)
 		logger.Error(deploymentResponse.Message)
 		return
@@ -161,9 +252,27 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	logger.Tracef("Request has valid JSON")
 
+	dryRun, diff, err := dryRunParameters(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		deploymentResponse.Message = fmt.Sprintf("invalid deployment request: %s", err)
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
+		deploymentResponse.render(w)
+		logger.Error(deploymentResponse.Message)
+		return
+	}
+
+	timeout, requestTTL := deploymentRequest.timeouts()
+
 	err = deploymentRequest.validate()
-	if err == nil {
-		err = h.Clusters.Contains(deploymentRequest.Cluster)
+	if err != nil {
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
+	} else if err = h.Clusters.Contains(deploymentRequest.Cluster); err != nil {
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeClusterUnknown, api_v1.FieldError{Field: "cluster", Message: err.Error()})
+	} else if err = h.Timeouts.Check(deploymentRequest.Cluster, timeout, requestTTL); err != nil {
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
+	} else if err = h.requireUser(deploymentRequest, dryRun); err != nil {
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
 	}
 
 	if err != nil {
@@ -180,8 +289,12 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if h.APIKeyStorage.IsErrNotFound(err) {
+			h.audit(deploymentRequest, deploymentResponse.CorrelationID, audit.ActionAuthenticate, audit.OutcomeDenied, map[string]string{
+				"reason": "team has no API key",
+			})
 			w.WriteHeader(http.StatusForbidden)
 			deploymentResponse.Message = api_v1.FailedAuthenticationMsg
+			deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeTeamNoAPIKey)
 			deploymentResponse.render(w)
 			logger.Errorf("%s: %s", api_v1.FailedAuthenticationMsg, err)
 			return
@@ -189,6 +302,7 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		w.WriteHeader(http.StatusBadGateway)
 		deploymentResponse.Message = "something wrong happened when communicating with api key service"
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeAPIKeyBackendUnavailable)
 		deploymentResponse.render(w)
 		logger.Errorf("unable to fetch team apikey from storage: %s", err)
 		return
@@ -197,8 +311,12 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger.Tracef("Team API key retrieved from storage")
 
 	if !api_v1.ValidateMAC(data, []byte(signature), token) {
+		h.audit(deploymentRequest, deploymentResponse.CorrelationID, audit.ActionAuthenticate, audit.OutcomeDenied, map[string]string{
+			"reason": "invalid HMAC signature",
+		})
 		w.WriteHeader(http.StatusForbidden)
 		deploymentResponse.Message = api_v1.FailedAuthenticationMsg
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeSignatureInvalid)
 		deploymentResponse.render(w)
 		logger.Errorf("%s: HMAC signature error", api_v1.FailedAuthenticationMsg)
 		return
@@ -213,6 +331,15 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case github.ErrGitHubNotEnabled:
 		logger.Tracef("Skipping team access validation because GitHub integration is not enabled")
 	case github.ErrTeamNotExist, github.ErrTeamNoAccess:
+		if err == github.ErrTeamNotExist {
+			deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeTeamNotOnGithub)
+		} else {
+			deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeTeamNoRepoAccess)
+		}
+		h.audit(deploymentRequest, deploymentResponse.CorrelationID, audit.ActionDeploy, audit.OutcomeDenied, map[string]string{
+			"cluster": deploymentRequest.Cluster,
+			"reason":  err.Error(),
+		})
 		deploymentResponse.Message = err.Error()
 		w.WriteHeader(http.StatusForbidden)
 		deploymentResponse.render(w)
@@ -220,15 +347,62 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	default:
 		deploymentResponse.Message = "unable to communicate with GitHub"
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeGithubUnavailable)
 		w.WriteHeader(http.StatusBadGateway)
 		deploymentResponse.render(w)
 		logger.Errorf("%s: %s", deploymentResponse.Message, err)
 		return
 	}
 
+	// Freeze windows are checked after the team's access to the repository,
+	// so that they are not revealed to teams that may not deploy the repository.
+	window, err := freeze.Check(h.FreezeStorage, deploymentRequest.Cluster, deploymentRequest.Team)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		deploymentResponse.Message = "unable to check deployment freeze windows"
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		deploymentResponse.render(w)
+		logger.Errorf("%s: %s", deploymentResponse.Message, err)
+		return
+	}
+
+	if window != nil {
+		if !deploymentRequest.FreezeOverride {
+			h.audit(deploymentRequest, deploymentResponse.CorrelationID, audit.ActionDeploy, audit.OutcomeDenied, map[string]string{
+				"cluster":          deploymentRequest.Cluster,
+				"reason":           "deployment freeze",
+				"freeze_window_id": window.ID,
+			})
+			w.WriteHeader(http.StatusLocked)
+			deploymentResponse.Message = window.String()
+			deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeDeploymentFrozen)
+			deploymentResponse.render(w)
+			logger.WithField(api_v1.LogFieldFreezeWindowID, window.ID).Errorf("Deployment blocked: %s", deploymentResponse.Message)
+			return
+		}
+		logger.WithFields(log.Fields{
+			api_v1.LogFieldFreezeWindowID: window.ID,
+			api_v1.LogFieldAudit:          true,
+		}).Warnf("Deployment freeze overridden by team '%s': %s", deploymentRequest.Team, window.Reason)
+		h.audit(deploymentRequest, deploymentResponse.CorrelationID, audit.ActionFreezeOverride, audit.OutcomeSuccess, map[string]string{
+			"cluster":          deploymentRequest.Cluster,
+			"freeze_window_id": window.ID,
+		})
+	}
+
+	if dryRun {
+		h.dryRun(w, r, deploymentRequest, diff, &deploymentResponse, logger)
+		return
+	}
+
 	githubRequest := deploymentRequest.GithubDeploymentRequest()
-	githubDeployment, err = h.GithubClient.CreateDeployment(r.Context(), deploymentRequest.Owner, deploymentRequest.Repository, &githubRequest)
+	ctx, span := tracing.Start(r.Context(), "github.create_deployment", tracing.KindClient)
+	githubDeployment, err = h.GithubClient.CreateDeployment(ctx, deploymentRequest.Owner, deploymentRequest.Repository, &githubRequest)
 	deploymentResponse.GithubDeployment = githubDeployment
+	if err != github.ErrGitHubNotEnabled {
+		span.SetError(err)
+	}
+	span.Finish()
 
 	switch err {
 	case nil:
@@ -240,6 +414,7 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	default:
 		w.WriteHeader(http.StatusBadGateway)
 		deploymentResponse.Message = "unable to create GitHub deployment"
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeGithubUnavailable)
 		deploymentResponse.render(w)
 		logger.Errorf("unable to create GitHub deployment: %s", err)
 		return
@@ -249,16 +424,138 @@ func (h *DeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		deploymentResponse.Message = "unable to create deployment message"
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, api_v1.FieldError{Field: "resources", Message: err.Error()})
 		deploymentResponse.render(w)
 		logger.Errorf("unable to create deployment message: %s", err)
 		return
 	}
 
+	deployMsg.Traceparent = tracing.Traceparent(r.Context())
+
+	if h.ApprovalGate.Required(deployMsg.GetCluster()) {
+		_, err = h.ApprovalGate.Hold(*deployMsg, deploymentRequest.User)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			deploymentResponse.Message = "unable to store deployment request for approval"
+			deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeDeploymentAborted)
+			deploymentResponse.render(w)
+			logger.Errorf("%s: %s", deploymentResponse.Message, err)
+			// The GitHub deployment would otherwise be left pending forever.
+			h.DeploymentStatus <- *types.NewErrorStatus(*deployMsg, fmt.Errorf(deploymentResponse.Message))
+			return
+		}
+
+		h.audit(deploymentRequest, deploymentResponse.CorrelationID, audit.ActionDeploy, audit.OutcomeSuccess, map[string]string{
+			"cluster": deploymentRequest.Cluster,
+			"ref":     deploymentRequest.Ref,
+			"status":  "waiting for approval",
+		})
+
+		w.WriteHeader(http.StatusAccepted)
+		deploymentResponse.Message = "deployment request accepted and waiting for approval"
+		deploymentResponse.render(w)
+
+		logger.Info("Deployment request is waiting for approval")
+		return
+	}
+
 	h.DeploymentRequest <- *deployMsg
 
+	h.audit(deploymentRequest, deploymentResponse.CorrelationID, audit.ActionDeploy, audit.OutcomeSuccess, map[string]string{
+		"cluster": deploymentRequest.Cluster,
+		"ref":     deploymentRequest.Ref,
+	})
+
 	w.WriteHeader(http.StatusCreated)
 	deploymentResponse.Message = "deployment request accepted and dispatched"
 	deploymentResponse.render(w)
 
 	logger.Info("Deployment request processed successfully")
 }
+
+// dryRunParameters reads the dryRun and diff query parameters. Asking for a diff implies a dry run.
+func dryRunParameters(r *http.Request) (bool, bool, error) {
+	var errs api_v1.FieldErrors
+
+	parse := func(name string) bool {
+		str := r.URL.Query().Get(name)
+		if len(str) == 0 {
+			return false
+		}
+		value, err := strconv.ParseBool(str)
+		if err != nil {
+			errs.Add(name, fmt.Sprintf("%s must be either 'true' or 'false'", name))
+		}
+		return value
+	}
+
+	dryRun := parse("dryRun")
+	diff := parse("diff")
+
+	return dryRun || diff, diff, errs.Err()
+}
+
+// dryRun forwards a validated deployment request to deployd as a dry run, and waits for the results.
+// If diff is set, deployd also compares each resource with the version running in the cluster.
+// No GitHub deployment is created, and the request is not subject to manual approval.
+func (h *DeploymentHandler) dryRun(w http.ResponseWriter, r *http.Request, deploymentRequest *DeploymentRequest, diff bool, deploymentResponse *DeploymentResponse, logger *log.Entry) {
+	logger = logger.WithField(api_v1.LogFieldDryRun, true)
+
+	deployMsg, err := DeploymentRequestMessage(deploymentRequest, &gh.Deployment{}, deploymentResponse.CorrelationID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		deploymentResponse.Message = "unable to create deployment message"
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, api_v1.FieldError{Field: "resources", Message: err.Error()})
+		deploymentResponse.render(w)
+		logger.Errorf("unable to create deployment message: %s", err)
+		return
+	}
+
+	deployMsg.DryRun = true
+	deployMsg.Diff = diff
+	deployMsg.Traceparent = tracing.Traceparent(r.Context())
+	deployMsg.Deadline = time.Now().Add(h.DryRunTimeout).Unix()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.DryRunTimeout)
+	defer cancel()
+
+	h.DryRuns.Register(deployMsg.GetDeliveryID())
+	h.DeploymentRequest <- *deployMsg
+
+	logger.Info("Dry run request dispatched; waiting for results")
+
+	status, err := h.DryRuns.Wait(ctx, deployMsg.GetDeliveryID())
+	if err != nil {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		deploymentResponse.Message = fmt.Sprintf("no dry run results received from cluster '%s' within %s", deploymentRequest.Cluster, h.DryRunTimeout)
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeDryRunTimeout)
+		deploymentResponse.render(w)
+		logger.Error(deploymentResponse.Message)
+		return
+	}
+
+	for _, result := range status.GetResources() {
+		deploymentResponse.Resources = append(deploymentResponse.Resources, ResourceResult{
+			Kind:      result.GetKind(),
+			Namespace: result.GetNamespace(),
+			Name:      result.GetName(),
+			Diff:      result.GetDiff(),
+			Error:     result.GetError(),
+		})
+	}
+
+	deploymentResponse.Message = status.GetDescription()
+
+	if status.GetState() != types.GithubDeploymentState_success {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		deploymentResponse.Error = api_v1.NewError(api_v1.ErrorCodeDryRunFailed)
+		deploymentResponse.render(w)
+		logger.Warnf("Dry run failed: %s", deploymentResponse.Message)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	deploymentResponse.render(w)
+
+	logger.Info("Dry run succeeded")
+}
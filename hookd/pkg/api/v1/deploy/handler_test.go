@@ -10,10 +10,17 @@ import (
 	"io/ioutil"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi"
+	chi_middleware "github.com/go-chi/chi/middleware"
 	gh "github.com/google/go-github/v27/github"
 	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/dryrun"
+	"github.com/navikt/deployment/hookd/pkg/freeze"
 	"github.com/navikt/deployment/hookd/pkg/github"
 
 	types "github.com/navikt/deployment/common/pkg/deployment"
@@ -28,12 +35,25 @@ const (
 
 var secretKey = []byte("foobar")
 
+// slowDryRun is how long dry runs of the repository 'slow' take.
+const slowDryRun = 200 * time.Millisecond
+
 var validClusters = []string{
 	"local",
+	"protected",
+}
+
+var timeouts = api_v1.Timeouts{
+	Bounds: api_v1.TimeoutBounds{Min: time.Minute, Max: time.Hour},
+	Clusters: map[string]api_v1.TimeoutBounds{
+		"protected": {Min: 5 * time.Minute, Max: 2 * time.Hour},
+	},
+	MaxTTL: 15 * time.Minute,
 }
 
 type request struct {
 	Headers map[string]string
+	Query   string
 	Body    json.RawMessage
 }
 
@@ -95,10 +115,39 @@ func (a *apiKeyStorage) Write(team string, key []byte) error {
 	return nil
 }
 
+func (a *apiKeyStorage) Delete(team string) error {
+	return nil
+}
+
 func (a *apiKeyStorage) IsErrNotFound(err error) bool {
 	return err == persistence.ErrNotFound
 }
 
+// freezeStorage freezes team_not_repo_owner as well,
+// to check that teams without access to the repository are turned away before learning about the freeze.
+type freezeStorage struct{}
+
+func (f *freezeStorage) List() ([]freeze.Window, error) {
+	return []freeze.Window{
+		{
+			ID:         "frozen",
+			Start:      time.Now().Add(-time.Hour),
+			End:        time.Now().Add(time.Hour),
+			Teams:      []string{"frozen", "team_not_repo_owner"},
+			Recurrence: freeze.RecurrenceDaily,
+			Reason:     "incident in progress",
+		},
+	}, nil
+}
+
+func (f *freezeStorage) Create(window freeze.Window) (*freeze.Window, error) {
+	return &window, nil
+}
+
+func (f *freezeStorage) Delete(id string) error {
+	return nil
+}
+
 func fileReader(file string) io.Reader {
 	f, err := os.Open(file)
 	if err != nil {
@@ -113,9 +162,58 @@ func testResponse(t *testing.T, recorder *httptest.ResponseRecorder, response re
 	assert.NoError(t, err)
 	assert.Equal(t, response.StatusCode, recorder.Code)
 	assert.Equal(t, response.Body.Message, decodedBody.Message)
+	assert.Equal(t, response.Body.Error, decodedBody.Error)
 	assert.NotEmpty(t, decodedBody.CorrelationID)
 
 	assert.Equal(t, response.Body.GithubDeployment.GetID(), decodedBody.GithubDeployment.GetID())
+	assert.Equal(t, response.Body.Resources, decodedBody.Resources)
+}
+
+// fakeDeployd answers dry run requests. Resources of kind 'Invalid' are rejected,
+// other resources get a one line diff if asked for, requests from the repository 'silent' are never answered,
+// and requests from the repository 'slow' are answered after slowDryRun.
+func fakeDeployd(requests chan types.DeploymentRequest, dryRuns *dryrun.Results) {
+	for req := range requests {
+		if !req.GetDryRun() || req.GetDeployment().GetRepository().GetName() == "silent" {
+			continue
+		}
+		if req.GetDeployment().GetRepository().GetName() == "slow" {
+			time.Sleep(slowDryRun)
+		}
+		results := make([]*types.ResourceResult, 0)
+		for _, resource := range req.GetPayloadSpec().GetKubernetes().GetResources() {
+			result := &types.ResourceResult{
+				Kind: resource.Fields["kind"].GetStringValue(),
+				Name: resource.Fields["metadata"].GetStructValue().GetFields()["name"].GetStringValue(),
+			}
+			if result.Kind == "Invalid" {
+				result.Error = "admission webhook denied the request"
+			} else if req.GetDiff() {
+				result.Diff = fmt.Sprintf("+kind: %s\n", result.Kind)
+			}
+			results = append(results, result)
+		}
+		dryRuns.Deliver(*types.NewDryRunStatus(req, results))
+	}
+}
+
+// Inject timestamp in request payload
+func addTimestampToBody(in []byte, timeshift int64) []byte {
+	tmp := make(map[string]interface{})
+	err := json.Unmarshal(in, &tmp)
+	if err != nil {
+		return in
+	}
+	if _, ok := tmp["timestamp"]; ok {
+		// timestamp already provided in test fixture
+		return in
+	}
+	tmp["timestamp"] = time.Now().Unix() + timeshift
+	out, err := json.Marshal(tmp)
+	if err != nil {
+		return in
+	}
+	return out
 }
 
 func subTest(t *testing.T, name string) {
@@ -135,8 +233,9 @@ func subTest(t *testing.T, name string) {
 		t.Fail()
 	}
 
+	body := addTimestampToBody(test.Request.Body, 0)
 	recorder := httptest.NewRecorder()
-	request := httptest.NewRequest("GET", "/", bytes.NewReader(test.Request.Body))
+	request := httptest.NewRequest("GET", "/?"+test.Request.Query, bytes.NewReader(body))
 
 	for key, val := range test.Request.Headers {
 		request.Header.Set(key, val)
@@ -144,7 +243,7 @@ func subTest(t *testing.T, name string) {
 
 	// Generate HMAC header for cases where the header should be valid
 	if len(request.Header.Get(api_v1.SignatureHeader)) == 0 {
-		mac := api_v1.GenMAC(test.Request.Body, secretKey)
+		mac := api_v1.GenMAC(body, secretKey)
 		request.Header.Set(api_v1.SignatureHeader, hex.EncodeToString(mac))
 	}
 
@@ -153,12 +252,32 @@ func subTest(t *testing.T, name string) {
 	ghClient := githubClient{}
 	apiKeyStore := apiKeyStorage{}
 
+	dir, err := ioutil.TempDir("", "approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gate, err := approval.NewGate(filepath.Join(dir, "pending.json"), []string{"protected"}, time.Hour, requests, statuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dryRuns := dryrun.New()
+	go fakeDeployd(requests, dryRuns)
+	defer close(requests)
+
 	handler := api_v1_deploy.DeploymentHandler{
+		DryRuns:           dryRuns,
+		DryRunTimeout:     100 * time.Millisecond,
 		DeploymentRequest: requests,
 		DeploymentStatus:  statuses,
 		APIKeyStorage:     &apiKeyStore,
 		GithubClient:      &ghClient,
 		Clusters:          validClusters,
+		Timeouts:          timeouts,
+		FreezeStorage:     &freezeStorage{},
+		ApprovalGate:      gate,
 	}
 
 	handler.ServeHTTP(recorder, request)
@@ -182,3 +301,75 @@ func TestDeploymentHandler(t *testing.T) {
 		})
 	}
 }
+
+// TestDryRunLongerThanRequestTimeout checks that a dry run may wait longer than the timeout of other API requests,
+// and still gets a complete response, as the deploy route allows for the dry run timeout.
+func TestDryRunLongerThanRequestTimeout(t *testing.T) {
+	const (
+		requestTimeout = slowDryRun / 4
+		dryRunTimeout  = slowDryRun * 2
+		deployTimeout  = dryRunTimeout * 2
+	)
+
+	requests := make(chan types.DeploymentRequest, 1024)
+	statuses := make(chan types.DeploymentStatus, 1024)
+
+	dir, err := ioutil.TempDir("", "approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gate, err := approval.NewGate(filepath.Join(dir, "pending.json"), []string{"protected"}, time.Hour, requests, statuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dryRuns := dryrun.New()
+	go fakeDeployd(requests, dryRuns)
+	defer close(requests)
+
+	handler := api_v1_deploy.DeploymentHandler{
+		DryRuns:           dryRuns,
+		DryRunTimeout:     dryRunTimeout,
+		DeploymentRequest: requests,
+		DeploymentStatus:  statuses,
+		APIKeyStorage:     &apiKeyStorage{},
+		GithubClient:      &githubClient{},
+		Clusters:          validClusters,
+		Timeouts:          timeouts,
+		FreezeStorage:     &freezeStorage{},
+		ApprovalGate:      gate,
+	}
+
+	router := chi.NewRouter()
+	router.With(chi_middleware.Timeout(deployTimeout)).Post("/deploy", handler.ServeHTTP)
+	router.With(chi_middleware.Timeout(requestTimeout)).Post("/other", handler.ServeHTTP)
+
+	deploy := func(path, repository string) (*httptest.ResponseRecorder, api_v1_deploy.DeploymentResponse) {
+		body := []byte(fmt.Sprintf(`{"team": "nobody", "cluster": "local", "owner": "foo", "repository": "%s", "ref": "master", "environment": "baz", "resources": [{"kind": "Application", "metadata": {"name": "myapp"}}], "timestamp": %d}`, repository, time.Now().Unix()))
+		request := httptest.NewRequest("POST", path+"?dryRun=true", bytes.NewReader(body))
+		request.Header.Set(api_v1.SignatureHeader, hex.EncodeToString(api_v1.GenMAC(body, secretKey)))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		response := api_v1_deploy.DeploymentResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return recorder, response
+	}
+
+	recorder, response := deploy("/deploy", "slow")
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, []api_v1_deploy.ResourceResult{{Kind: "Application", Name: "myapp"}}, response.Resources)
+
+	recorder, response = deploy("/deploy", "silent")
+	assert.Equal(t, 504, recorder.Code)
+	assert.Equal(t, api_v1.NewError(api_v1.ErrorCodeDryRunTimeout), response.Error)
+	assert.Equal(t, fmt.Sprintf("no dry run results received from cluster 'local' within %s", dryRunTimeout), response.Message)
+
+	// Under the timeout of other API requests, the same dry run is cut short long before the dry run timeout.
+	start := time.Now()
+	recorder, _ = deploy("/other", "slow")
+	assert.Equal(t, 504, recorder.Code)
+	assert.True(t, time.Since(start) < dryRunTimeout)
+}
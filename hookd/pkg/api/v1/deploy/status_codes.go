@@ -5,9 +5,14 @@ import (
 )
 
 var StatusCodes = []int{
+	http.StatusOK,
 	http.StatusCreated,
+	http.StatusAccepted,
 	http.StatusBadRequest,
 	http.StatusForbidden,
+	http.StatusLocked,
+	http.StatusUnprocessableEntity,
 	http.StatusBadGateway,
+	http.StatusGatewayTimeout,
 	http.StatusInternalServerError,
 }
@@ -0,0 +1,184 @@
+package api_v1_dora
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/dora"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler reports deployment frequency, change failure rate and mean time to restore for a team's deployments.
+// Requests must be signed with the team's API key, and only cover deployments made by that team.
+type Handler struct {
+	APIKeyStorage persistence.ApiKeyStorage
+	History       history.Store
+	AuditLog      *audit.Log
+	// DefaultRange is the length of the time range reported on if the request does not specify where it starts.
+	DefaultRange time.Duration
+}
+
+type ReportRequest struct {
+	Team       string           `json:"team"`
+	Repository string           `json:"repository,omitempty"`
+	Cluster    string           `json:"cluster,omitempty"`
+	From       time.Time        `json:"from,omitempty"`
+	To         time.Time        `json:"to,omitempty"`
+	Timestamp  api_v1.Timestamp `json:"timestamp"`
+}
+
+type ReportResponse struct {
+	Message string        `json:"message,omitempty"`
+	Report  *dora.Report  `json:"report,omitempty"`
+	Error   *api_v1.Error `json:"error,omitempty"`
+}
+
+func (r *ReportResponse) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *ReportRequest) validate() error {
+	var errs api_v1.FieldErrors
+
+	if err := r.Timestamp.Validate(); err != nil {
+		errs.Add("timestamp", err.Error())
+	}
+
+	if !r.From.Before(r.To) {
+		errs.Add("from", "start of the time range must be before its end")
+	}
+
+	return errs.Err()
+}
+
+// timeRange fills in the end of the time range with the current time, and the start with the default range.
+func (r *ReportRequest) timeRange(defaultRange time.Duration) {
+	if r.To.IsZero() {
+		r.To = time.Now()
+	}
+	if r.From.IsZero() {
+		r.From = r.To.Add(-defaultRange)
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var response ReportResponse
+
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	team, data, err := api_v1.ReadTeamSignedBody(r, h.APIKeyStorage)
+	logger = logger.WithField(types.LogFieldTeam, team)
+
+	switch err {
+	case nil:
+	case api_v1.ErrMalformedSignature:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrMalformedBody:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrNoTeam:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, api_v1.FieldError{Field: "team", Message: err.Error()})
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrUnknownTeam, api_v1.ErrInvalidSignature:
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.TeamActor(team),
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": err.Error(),
+			},
+		})
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = api_v1.FailedAuthenticationMsg
+		if err == api_v1.ErrUnknownTeam {
+			response.Error = api_v1.NewError(api_v1.ErrorCodeTeamNoAPIKey)
+		} else {
+			response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureInvalid)
+		}
+		response.render(w)
+		logger.Error(err)
+		return
+	case api_v1.ErrAPIKeyUnavailable:
+		w.WriteHeader(http.StatusBadGateway)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeAPIKeyBackendUnavailable)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyUnreadable)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	request := &ReportRequest{}
+	if err := json.Unmarshal(data, request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	request.timeRange(h.DefaultRange)
+
+	if err := request.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid report request: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	filter := history.Query{
+		Team:       request.Team,
+		Repository: request.Repository,
+		Cluster:    request.Cluster,
+	}
+
+	response.Report, err = dora.Generate(h.History, filter, request.From, request.To)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to read deployment history"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = fmt.Sprintf("metrics for %d applications from %s to %s", len(response.Report.Groups), response.Report.From.Format(time.RFC3339), response.Report.To.Format(time.RFC3339))
+	if response.Report.From.After(request.From) {
+		response.Message += "; older deployments are no longer in the deployment history"
+	}
+	response.render(w)
+}
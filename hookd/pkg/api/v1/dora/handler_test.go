@@ -0,0 +1,97 @@
+package api_v1_dora_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/apitest"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/dora"
+	"github.com/stretchr/testify/assert"
+)
+
+func status(deliveryID, team, repository string, state types.GithubDeploymentState, created time.Time) types.DeploymentStatus {
+	return types.DeploymentStatus{
+		Deployment: &types.DeploymentSpec{
+			Repository: &types.GithubRepository{
+				Owner: "navikt",
+				Name:  repository,
+			},
+		},
+		DeliveryID: deliveryID,
+		State:      state,
+		Team:       team,
+		Cluster:    "dev-fss",
+		Timestamp:  created.Unix(),
+	}
+}
+
+func TestReportHandler(t *testing.T) {
+	dir, cleanup := apitest.TempDir(t)
+	defer cleanup()
+
+	now := time.Now()
+	store := apitest.History(t, dir)
+	assert.NoError(t, store.Add(status("1", "aura", "myapp", types.GithubDeploymentState_failure, now.Add(-3*time.Hour)), now.Add(-3*time.Hour)))
+	assert.NoError(t, store.Add(status("2", "aura", "myapp", types.GithubDeploymentState_success, now.Add(-2*time.Hour)), now.Add(-time.Hour)))
+	assert.NoError(t, store.Add(status("3", "aura", "other", types.GithubDeploymentState_success, now.Add(-2*time.Hour)), now.Add(-time.Hour)))
+	assert.NoError(t, store.Add(status("4", "other", "myapp", types.GithubDeploymentState_success, now.Add(-2*time.Hour)), now.Add(-time.Hour)))
+	assert.NoError(t, store.Add(status("5", "aura", "myapp", types.GithubDeploymentState_success, now.Add(-48*time.Hour)), now.Add(-48*time.Hour)))
+
+	for _, test := range []struct {
+		name      string
+		request   api_v1_dora.ReportRequest
+		signature string
+		code      int
+		errorCode api_v1.ErrorCode
+		groups    int
+	}{
+		{name: "team report", request: api_v1_dora.ReportRequest{Team: "aura"}, code: http.StatusOK, groups: 2},
+		{name: "single repository", request: api_v1_dora.ReportRequest{Team: "aura", Repository: "navikt/myapp"}, code: http.StatusOK, groups: 1},
+		{name: "time range", request: api_v1_dora.ReportRequest{Team: "aura", From: now.Add(-72 * time.Hour), To: now.Add(-24 * time.Hour)}, code: http.StatusOK, groups: 1},
+		{name: "empty time range", request: api_v1_dora.ReportRequest{Team: "aura", From: now, To: now.Add(-time.Hour)}, code: http.StatusBadRequest, errorCode: api_v1.ErrorCodeValidationFailed},
+		{name: "team without API key", request: api_v1_dora.ReportRequest{Team: "notfound"}, code: http.StatusForbidden, errorCode: api_v1.ErrorCodeTeamNoAPIKey},
+		{name: "wrong signature", request: api_v1_dora.ReportRequest{Team: "aura"}, signature: "abcd", code: http.StatusForbidden, errorCode: api_v1.ErrorCodeSignatureInvalid},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := &api_v1_dora.Handler{
+				APIKeyStorage: &apitest.APIKeyStorage{},
+				History:       store,
+				DefaultRange:  24 * time.Hour,
+			}
+
+			test.request.Timestamp = api_v1.Timestamp(time.Now().Unix())
+			body, _ := json.Marshal(test.request)
+			signature := test.signature
+			if len(signature) == 0 {
+				signature = apitest.Sign(body)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/dora/report", bytes.NewReader(body))
+			request.Header.Set(api_v1.SignatureHeader, signature)
+			handler.ServeHTTP(recorder, request)
+
+			response := api_v1_dora.ReportResponse{}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, test.code, recorder.Code)
+
+			if test.code != http.StatusOK {
+				assert.Equal(t, test.errorCode, response.Error.Code)
+				assert.Nil(t, response.Report)
+				return
+			}
+
+			assert.Nil(t, response.Error)
+			assert.Len(t, response.Report.Groups, test.groups)
+			for _, group := range response.Report.Groups {
+				assert.Equal(t, "aura", group.Team, "only the team's own deployments are reported")
+			}
+		})
+	}
+}
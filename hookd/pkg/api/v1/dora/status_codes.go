@@ -0,0 +1,13 @@
+package api_v1_dora
+
+import (
+	"net/http"
+)
+
+var StatusCodes = []int{
+	http.StatusOK,
+	http.StatusBadRequest,
+	http.StatusForbidden,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+}
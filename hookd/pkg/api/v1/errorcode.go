@@ -0,0 +1,99 @@
+package api_v1
+
+import (
+	"strings"
+)
+
+// ErrorCode identifies the cause of a failed request. Codes are stable; clients can rely on them.
+type ErrorCode string
+
+const (
+	ErrorCodeBodyUnreadable           ErrorCode = "BODY_UNREADABLE"
+	ErrorCodeBodyMalformed            ErrorCode = "BODY_MALFORMED"
+	ErrorCodeValidationFailed         ErrorCode = "VALIDATION_FAILED"
+	ErrorCodeClusterUnknown           ErrorCode = "CLUSTER_UNKNOWN"
+	ErrorCodeSignatureMalformed       ErrorCode = "SIGNATURE_MALFORMED"
+	ErrorCodeSignatureInvalid         ErrorCode = "SIGNATURE_INVALID"
+	ErrorCodeTeamNoAPIKey             ErrorCode = "TEAM_NO_API_KEY"
+	ErrorCodeAPIKeyBackendUnavailable ErrorCode = "APIKEY_BACKEND_UNAVAILABLE"
+	ErrorCodeTeamNotOnGithub          ErrorCode = "TEAM_NOT_ON_GITHUB"
+	ErrorCodeTeamNoRepoAccess         ErrorCode = "TEAM_NO_REPO_ACCESS"
+	ErrorCodeGithubUnavailable        ErrorCode = "GITHUB_UNAVAILABLE"
+	ErrorCodeDeploymentFrozen         ErrorCode = "DEPLOYMENT_FROZEN"
+	ErrorCodeDeploymentNotFound       ErrorCode = "DEPLOYMENT_NOT_FOUND"
+	ErrorCodeDeploymentFinished       ErrorCode = "DEPLOYMENT_FINISHED"
+	ErrorCodeDryRunFailed             ErrorCode = "DRY_RUN_FAILED"
+	ErrorCodeDryRunTimeout            ErrorCode = "DRY_RUN_TIMEOUT"
+	ErrorCodeInternalError            ErrorCode = "INTERNAL_ERROR"
+	// DEPLOYMENT_ABORTED is returned for errors after the GitHub deployment has been created,
+	// and is not retryable, because a retry would create another deployment.
+	ErrorCodeDeploymentAborted ErrorCode = "DEPLOYMENT_ABORTED"
+)
+
+// ErrorCodes lists all error codes, along with whether a request failing with that code may succeed if retried.
+var ErrorCodes = map[ErrorCode]bool{
+	ErrorCodeBodyUnreadable:           true,
+	ErrorCodeBodyMalformed:            false,
+	ErrorCodeValidationFailed:         false,
+	ErrorCodeClusterUnknown:           false,
+	ErrorCodeSignatureMalformed:       false,
+	ErrorCodeSignatureInvalid:         false,
+	ErrorCodeTeamNoAPIKey:             false,
+	ErrorCodeAPIKeyBackendUnavailable: true,
+	ErrorCodeTeamNotOnGithub:          false,
+	ErrorCodeTeamNoRepoAccess:         false,
+	ErrorCodeGithubUnavailable:        true,
+	ErrorCodeDeploymentFrozen:         false,
+	ErrorCodeDeploymentNotFound:       false,
+	ErrorCodeDeploymentFinished:       false,
+	ErrorCodeDryRunFailed:             false,
+	ErrorCodeDryRunTimeout:            true,
+	ErrorCodeInternalError:            true,
+	ErrorCodeDeploymentAborted:        false,
+}
+
+// Error is the machine-readable part of an error response.
+type Error struct {
+	Code      ErrorCode    `json:"code"`
+	Retryable bool         `json:"retryable"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes a problem with a single field in the request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors is returned by request validation, and lists every invalid field.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i := range e {
+		messages[i] = e[i].Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add appends a problem with a field.
+func (e *FieldErrors) Add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Err returns nil if there are no field errors, and the field errors otherwise.
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// NewError returns an error with the retryable flag set according to the error code.
+func NewError(code ErrorCode, fields ...FieldError) *Error {
+	return &Error{
+		Code:      code,
+		Retryable: ErrorCodes[code],
+		Fields:    fields,
+	}
+}
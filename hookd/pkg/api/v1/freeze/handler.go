@@ -0,0 +1,235 @@
+package api_v1_freeze
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/freeze"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler manages deployment freeze windows.
+// All requests must be signed with the pre-shared administrator key.
+type Handler struct {
+	Storage   freeze.Storage
+	SecretKey []byte
+	AuditLog  *audit.Log
+}
+
+type ListRequest struct {
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type CreateRequest struct {
+	Window    freeze.Window    `json:"window"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type DeleteRequest struct {
+	ID        string           `json:"id"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type Response struct {
+	Message string          `json:"message,omitempty"`
+	Window  *freeze.Window  `json:"window,omitempty"`
+	Windows []freeze.Window `json:"windows,omitempty"`
+}
+
+func (r *Response) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *DeleteRequest) validate() error {
+	if len(r.ID) == 0 {
+		return fmt.Errorf("no freeze window id specified")
+	}
+	return r.Timestamp.Validate()
+}
+
+// decode authenticates the request and unmarshals its body into target.
+// If anything goes wrong, an error response is written and false is returned.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, logger *log.Entry, target interface{}) bool {
+	var response Response
+
+	data, err := api_v1.ReadSignedBody(r, h.SecretKey)
+	switch err {
+	case nil:
+	case api_v1.ErrMalformedSignature:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return false
+	case api_v1.ErrInvalidSignature:
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.ActorAdmin,
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": "invalid HMAC signature",
+			},
+		})
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = api_v1.FailedAuthenticationMsg
+		response.render(w)
+		logger.Error(err)
+		return false
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return false
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &ListRequest{}
+	if !h.decode(w, r, logger, request) {
+		return
+	}
+
+	if err := request.Timestamp.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid freeze window request: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	windows, err := h.Storage.List()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to retrieve freeze windows"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Windows = windows
+	response.Message = fmt.Sprintf("%d freeze windows configured", len(windows))
+	response.render(w)
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &CreateRequest{}
+	if !h.decode(w, r, logger, request) {
+		return
+	}
+
+	err := request.Timestamp.Validate()
+	if err == nil {
+		err = request.Window.Validate()
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid freeze window request: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	window, err := h.Storage.Create(request.Window)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to persist freeze window"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.ActorAdmin,
+		Action:        audit.ActionFreezeCreate,
+		Target:        window.ID,
+		Outcome:       audit.OutcomeSuccess,
+		CorrelationID: middleware.CorrelationID(r),
+		Details: map[string]string{
+			"reason": window.Reason,
+		},
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	response.Window = window
+	response.Message = "freeze window created"
+	response.render(w)
+
+	logger.WithField(api_v1.LogFieldFreezeWindowID, window.ID).Infof("Freeze window created: %s", window.Reason)
+}
+
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &DeleteRequest{}
+	if !h.decode(w, r, logger, request) {
+		return
+	}
+
+	if err := request.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid freeze window request: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	logger = logger.WithField(api_v1.LogFieldFreezeWindowID, request.ID)
+
+	err := h.Storage.Delete(request.ID)
+	switch err {
+	case nil:
+	case freeze.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to delete freeze window"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.ActorAdmin,
+		Action:        audit.ActionFreezeDelete,
+		Target:        request.ID,
+		Outcome:       audit.OutcomeSuccess,
+		CorrelationID: middleware.CorrelationID(r),
+	})
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "freeze window deleted"
+	response.render(w)
+
+	logger.Info("Freeze window deleted")
+}
@@ -0,0 +1,175 @@
+package api_v1_freeze_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/freeze"
+	"github.com/navikt/deployment/hookd/pkg/freeze"
+	"github.com/stretchr/testify/assert"
+)
+
+var adminKey = []byte("cryptographically secure")
+
+type request struct {
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+	Body     json.RawMessage   `json:"body"`
+}
+
+type response struct {
+	StatusCode int                    `json:"statusCode"`
+	Body       api_v1_freeze.Response `json:"body"`
+}
+
+type testCase struct {
+	Request  request  `json:"request"`
+	Response response `json:"response"`
+}
+
+// freezeStorage holds a single freeze window with the id "existing".
+type freezeStorage struct{}
+
+func (f *freezeStorage) List() ([]freeze.Window, error) {
+	return []freeze.Window{
+		{
+			ID:     "existing",
+			Start:  time.Now().Add(-time.Hour),
+			End:    time.Now().Add(time.Hour),
+			Reason: "incident in progress",
+		},
+	}, nil
+}
+
+func (f *freezeStorage) Create(window freeze.Window) (*freeze.Window, error) {
+	if window.Reason == "unwritable" {
+		return nil, fmt.Errorf("service unavailable")
+	}
+	window.ID = "created"
+	return &window, nil
+}
+
+func (f *freezeStorage) Delete(id string) error {
+	switch id {
+	case "existing":
+		return nil
+	case "unavailable":
+		return fmt.Errorf("service unavailable")
+	default:
+		return freeze.ErrNotFound
+	}
+}
+
+func testStatusResponse(t *testing.T, recorder *httptest.ResponseRecorder, response response) {
+	assert.Equal(t, response.StatusCode, recorder.Code)
+
+	decodedBody := api_v1_freeze.Response{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &decodedBody)
+	assert.NoError(t, err)
+	assert.Equal(t, response.Body.Message, decodedBody.Message)
+	if response.Body.Window != nil && assert.NotNil(t, decodedBody.Window) {
+		assert.Equal(t, response.Body.Window.ID, decodedBody.Window.ID)
+	}
+	assert.Len(t, decodedBody.Windows, len(response.Body.Windows))
+}
+
+// Inject timestamp in request payload
+func addTimestampToBody(in []byte, timeshift int64) []byte {
+	tmp := make(map[string]interface{})
+	err := json.Unmarshal(in, &tmp)
+	if err != nil {
+		return in
+	}
+	if _, ok := tmp["timestamp"]; ok {
+		// timestamp already provided in test fixture
+		return in
+	}
+	tmp["timestamp"] = time.Now().Unix() + timeshift
+	out, err := json.Marshal(tmp)
+	if err != nil {
+		return in
+	}
+	return out
+}
+
+func fileReader(file string) io.Reader {
+	f, err := os.Open(file)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func statusSubTest(t *testing.T, name string) {
+	inFile := fmt.Sprintf("testdata/%s", name)
+
+	fixture := fileReader(inFile)
+	data, err := ioutil.ReadAll(fixture)
+	if err != nil {
+		t.Error(data)
+		t.Fail()
+	}
+
+	test := testCase{}
+	err = json.Unmarshal(data, &test)
+	if err != nil {
+		t.Error(string(data))
+		t.Fail()
+	}
+
+	body := addTimestampToBody(test.Request.Body, 0)
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/api/v1/freeze/"+test.Request.Endpoint, bytes.NewReader(body))
+
+	for key, val := range test.Request.Headers {
+		request.Header.Set(key, val)
+	}
+
+	// Generate HMAC header for cases where the header should be valid
+	if len(request.Header.Get(api_v1.SignatureHeader)) == 0 {
+		mac := api_v1.GenMAC(body, adminKey)
+		request.Header.Set(api_v1.SignatureHeader, hex.EncodeToString(mac))
+	}
+
+	handler := api_v1_freeze.Handler{
+		Storage:   &freezeStorage{},
+		SecretKey: adminKey,
+	}
+
+	switch test.Request.Endpoint {
+	case "create":
+		handler.Create(recorder, request)
+	case "delete":
+		handler.Delete(recorder, request)
+	default:
+		handler.List(recorder, request)
+	}
+
+	testStatusResponse(t, recorder, test.Response)
+}
+
+func TestFreezeHandler(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Error(err)
+		t.Fail()
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		name := file.Name()
+		t.Run(name, func(t *testing.T) {
+			statusSubTest(t, name)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package api_v1_logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler returns the log lines deployd has sent about a deployment, so that they can be tailed by the client.
+// Requests must be signed with the API key of the team owning the deployment.
+type Handler struct {
+	APIKeyStorage persistence.ApiKeyStorage
+	Logs          logstore.Store
+	History       history.Store
+	AuditLog      *audit.Log
+}
+
+type LogsRequest struct {
+	Team string `json:"team"`
+	// Offset is the number of log lines already seen by the client; only lines after them are returned.
+	Offset    int              `json:"offset,omitempty"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type LogsResponse struct {
+	Message string           `json:"message,omitempty"`
+	Events  []logstore.Event `json:"events,omitempty"`
+	// State is the last known state of the deployment, if it has been recorded in the deployment history.
+	State string `json:"state,omitempty"`
+	// Finished is true when no more log lines are expected.
+	Finished bool          `json:"finished,omitempty"`
+	Error    *api_v1.Error `json:"error,omitempty"`
+}
+
+func (r *LogsResponse) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *LogsRequest) validate() error {
+	var errs api_v1.FieldErrors
+
+	if err := r.Timestamp.Validate(); err != nil {
+		errs.Add("timestamp", err.Error())
+	}
+
+	if r.Offset < 0 {
+		errs.Add("offset", "offset cannot be negative")
+	}
+
+	return errs.Err()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var response LogsResponse
+
+	id := chi.URLParam(r, "id")
+	logger := log.WithFields(middleware.RequestLogFields(r)).WithField(types.LogFieldDeliveryID, id)
+
+	team, data, err := api_v1.ReadTeamSignedBody(r, h.APIKeyStorage)
+	logger = logger.WithField(types.LogFieldTeam, team)
+
+	switch err {
+	case nil:
+	case api_v1.ErrMalformedSignature:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrMalformedBody:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrNoTeam:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, api_v1.FieldError{Field: "team", Message: err.Error()})
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	case api_v1.ErrUnknownTeam, api_v1.ErrInvalidSignature:
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.TeamActor(team),
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": err.Error(),
+			},
+		})
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = api_v1.FailedAuthenticationMsg
+		if err == api_v1.ErrUnknownTeam {
+			response.Error = api_v1.NewError(api_v1.ErrorCodeTeamNoAPIKey)
+		} else {
+			response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureInvalid)
+		}
+		response.render(w)
+		logger.Error(err)
+		return
+	case api_v1.ErrAPIKeyUnavailable:
+		w.WriteHeader(http.StatusBadGateway)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeAPIKeyBackendUnavailable)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = err.Error()
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyUnreadable)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	request := &LogsRequest{}
+	if err := json.Unmarshal(data, request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	if err := request.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid logs request: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	// The owner of a deployment is taken from the deployment history if possible, and otherwise from the log lines,
+	// which carry the team of the deployment request. Logs of other teams' deployments are reported as missing,
+	// so that their existence is not revealed.
+	owner := ""
+	deployment, err := h.History.Get(id)
+	if err == nil {
+		owner = deployment.Team
+	} else if err != history.ErrNotFound {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to look up deployment"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	events, err := h.Logs.List(id, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to read deployment log"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	if len(owner) == 0 && len(events) > 0 {
+		owner = events[0].Team
+	}
+
+	if len(owner) > 0 && owner != request.Team {
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = fmt.Sprintf("deployment %s does not exist", id)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeDeploymentNotFound)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	if deployment != nil {
+		response.State = deployment.State
+		response.Finished = deployment.Finished()
+	}
+
+	if request.Offset < len(events) {
+		response.Events = events[request.Offset:]
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = fmt.Sprintf("%d log lines", len(response.Events))
+	response.render(w)
+}
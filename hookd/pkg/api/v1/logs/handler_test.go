@@ -0,0 +1,117 @@
+package api_v1_logs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/apitest"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/logs"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(team, message string) logstore.Event {
+	return logstore.Event{
+		Time:    time.Now(),
+		Cluster: "dev-fss",
+		Team:    team,
+		Level:   "info",
+		Message: message,
+	}
+}
+
+func TestLogsHandler(t *testing.T) {
+	dir, cleanup := apitest.TempDir(t)
+	defer cleanup()
+
+	historyStore := apitest.History(t, dir)
+	assert.NoError(t, historyStore.Add(types.DeploymentStatus{
+		DeliveryID: "finished",
+		State:      types.GithubDeploymentState_success,
+		Team:       "aura",
+		Cluster:    "dev-fss",
+	}, time.Now()))
+
+	logs, err := logstore.NewFileStore(filepath.Join(dir, "logs"))
+	assert.NoError(t, err)
+	assert.NoError(t, logs.Add("finished", event("aura", "first")))
+	assert.NoError(t, logs.Add("finished", event("aura", "second")))
+	assert.NoError(t, logs.Add("running", event("aura", "first")))
+
+	for _, test := range []struct {
+		name      string
+		id        string
+		team      string
+		offset    int
+		signature string
+		code      int
+		errorCode api_v1.ErrorCode
+		messages  []string
+		finished  bool
+	}{
+		{name: "finished deployment", id: "finished", team: "aura", code: http.StatusOK, messages: []string{"first", "second"}, finished: true},
+		{name: "with offset", id: "finished", team: "aura", offset: 1, code: http.StatusOK, messages: []string{"second"}, finished: true},
+		{name: "offset past the end", id: "finished", team: "aura", offset: 5, code: http.StatusOK, finished: true},
+		{name: "deployment not in history", id: "running", team: "aura", code: http.StatusOK, messages: []string{"first"}},
+		{name: "deployment without logs", id: "unknown", team: "aura", code: http.StatusOK},
+		{name: "negative offset", id: "finished", team: "aura", offset: -1, code: http.StatusBadRequest, errorCode: api_v1.ErrorCodeValidationFailed},
+		{name: "other team's deployment", id: "finished", team: "other", code: http.StatusNotFound, errorCode: api_v1.ErrorCodeDeploymentNotFound},
+		{name: "other team's logs", id: "running", team: "other", code: http.StatusNotFound, errorCode: api_v1.ErrorCodeDeploymentNotFound},
+		{name: "team without API key", id: "finished", team: "notfound", code: http.StatusForbidden, errorCode: api_v1.ErrorCodeTeamNoAPIKey},
+		{name: "wrong signature", id: "finished", team: "aura", signature: "abcd", code: http.StatusForbidden, errorCode: api_v1.ErrorCodeSignatureInvalid},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := &api_v1_logs.Handler{
+				APIKeyStorage: &apitest.APIKeyStorage{},
+				Logs:          logs,
+				History:       historyStore,
+			}
+			router := chi.NewRouter()
+			router.Post("/api/v1/deploy/{id}/logs", handler.ServeHTTP)
+
+			body, _ := json.Marshal(api_v1_logs.LogsRequest{
+				Team:      test.team,
+				Offset:    test.offset,
+				Timestamp: api_v1.Timestamp(time.Now().Unix()),
+			})
+			signature := test.signature
+			if len(signature) == 0 {
+				signature = apitest.Sign(body)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/deploy/"+test.id+"/logs", bytes.NewReader(body))
+			request.Header.Set(api_v1.SignatureHeader, signature)
+			router.ServeHTTP(recorder, request)
+
+			response := api_v1_logs.LogsResponse{}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, test.code, recorder.Code)
+
+			if test.code != http.StatusOK {
+				assert.Equal(t, test.errorCode, response.Error.Code)
+				assert.Empty(t, response.Events)
+				return
+			}
+
+			assert.Nil(t, response.Error)
+			assert.Equal(t, test.finished, response.Finished)
+			messages := make([]string, 0)
+			for _, event := range response.Events {
+				messages = append(messages, event.Message)
+			}
+			if test.messages == nil {
+				test.messages = []string{}
+			}
+			assert.Equal(t, test.messages, messages)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package api_v1_logs
+
+import (
+	"net/http"
+)
+
+var StatusCodes = []int{
+	http.StatusOK,
+	http.StatusBadRequest,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+}
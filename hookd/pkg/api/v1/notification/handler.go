@@ -0,0 +1,267 @@
+package api_v1_notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/notification"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler lets teams manage their notification subscriptions.
+// All requests must be signed with the team's API key, in the same way as deployment requests.
+type Handler struct {
+	APIKeyStorage persistence.ApiKeyStorage
+	Storage       notification.Storage
+	History       *notification.History
+	AuditLog      *audit.Log
+}
+
+type ListRequest struct {
+	Team      string           `json:"team"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type CreateRequest struct {
+	Team         string                    `json:"team"`
+	Subscription notification.Subscription `json:"subscription"`
+	Timestamp    api_v1.Timestamp          `json:"timestamp"`
+}
+
+type DeleteRequest struct {
+	Team      string           `json:"team"`
+	ID        string           `json:"id"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type HistoryRequest struct {
+	Team           string           `json:"team"`
+	SubscriptionID string           `json:"subscriptionID,omitempty"`
+	Timestamp      api_v1.Timestamp `json:"timestamp"`
+}
+
+type Response struct {
+	Message       string                      `json:"message,omitempty"`
+	Subscription  *notification.Subscription  `json:"subscription,omitempty"`
+	Subscriptions []notification.Subscription `json:"subscriptions,omitempty"`
+	Deliveries    []notification.Delivery     `json:"deliveries,omitempty"`
+}
+
+func (r *Response) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *DeleteRequest) validate() error {
+	if len(r.ID) == 0 {
+		return fmt.Errorf("no subscription id specified")
+	}
+	return r.Timestamp.Validate()
+}
+
+// decode authenticates the request and unmarshals its body into target.
+// If anything goes wrong, an error response is written and false is returned.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, logger *log.Entry, target interface{}) (*log.Entry, bool) {
+	var response Response
+
+	team, data, err := api_v1.ReadTeamSignedBody(r, h.APIKeyStorage)
+	logger = logger.WithField(types.LogFieldTeam, team)
+
+	switch err {
+	case nil:
+	case api_v1.ErrMalformedSignature, api_v1.ErrMalformedBody, api_v1.ErrNoTeam:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return logger, false
+	case api_v1.ErrUnknownTeam, api_v1.ErrInvalidSignature:
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.TeamActor(team),
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": err.Error(),
+			},
+		})
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = api_v1.FailedAuthenticationMsg
+		response.render(w)
+		logger.Error(err)
+		return logger, false
+	case api_v1.ErrAPIKeyUnavailable:
+		w.WriteHeader(http.StatusBadGateway)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return logger, false
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return logger, false
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return logger, false
+	}
+
+	return logger, true
+}
+
+func (h *Handler) invalid(w http.ResponseWriter, logger *log.Entry, err error) {
+	var response Response
+	w.WriteHeader(http.StatusBadRequest)
+	response.Message = fmt.Sprintf("invalid notification request: %s", err)
+	response.render(w)
+	logger.Error(response.Message)
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &ListRequest{}
+	logger, ok := h.decode(w, r, logger, request)
+	if !ok {
+		return
+	}
+
+	if err := request.Timestamp.Validate(); err != nil {
+		h.invalid(w, logger, err)
+		return
+	}
+
+	subscriptions, err := notification.ForTeam(h.Storage, request.Team)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to retrieve subscriptions"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	// Never reveal shared secrets after creation
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Subscriptions = subscriptions
+	response.Message = fmt.Sprintf("%d subscriptions configured", len(subscriptions))
+	response.render(w)
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &CreateRequest{}
+	logger, ok := h.decode(w, r, logger, request)
+	if !ok {
+		return
+	}
+
+	request.Subscription.Team = request.Team
+
+	err := request.Timestamp.Validate()
+	if err == nil {
+		err = request.Subscription.Validate()
+	}
+
+	if err != nil {
+		h.invalid(w, logger, err)
+		return
+	}
+
+	subscription, err := h.Storage.Create(request.Subscription)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to persist subscription"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	response.Subscription = subscription
+	response.Message = "subscription created"
+	response.render(w)
+
+	logger.WithField(notification.LogFieldSubscriptionID, subscription.ID).Infof("Notification subscription created for %s", subscription.URL)
+}
+
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &DeleteRequest{}
+	logger, ok := h.decode(w, r, logger, request)
+	if !ok {
+		return
+	}
+
+	if err := request.validate(); err != nil {
+		h.invalid(w, logger, err)
+		return
+	}
+
+	logger = logger.WithField(notification.LogFieldSubscriptionID, request.ID)
+
+	err := h.Storage.Delete(request.Team, request.ID)
+	switch err {
+	case nil:
+	case notification.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to delete subscription"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "subscription deleted"
+	response.render(w)
+
+	logger.Info("Notification subscription deleted")
+}
+
+func (h *Handler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &HistoryRequest{}
+	logger, ok := h.decode(w, r, logger, request)
+	if !ok {
+		return
+	}
+
+	if err := request.Timestamp.Validate(); err != nil {
+		h.invalid(w, logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Deliveries = h.History.List(request.Team, request.SubscriptionID)
+	response.Message = fmt.Sprintf("%d notification deliveries", len(response.Deliveries))
+	response.render(w)
+}
@@ -0,0 +1,15 @@
+package api_v1_notification
+
+import (
+	"net/http"
+)
+
+var StatusCodes = []int{
+	http.StatusOK,
+	http.StatusCreated,
+	http.StatusBadRequest,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+}
@@ -0,0 +1,21 @@
+package api_v1_openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Handler serves the OpenAPI document.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", ContentType)
+	w.WriteHeader(http.StatusOK)
+	Encode(w, Spec())
+}
+
+// Encode writes the document in the same format as the checked in openapi.json.
+func Encode(w io.Writer, doc *Document) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
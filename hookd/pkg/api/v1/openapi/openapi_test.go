@@ -0,0 +1,76 @@
+package api_v1_openapi_test
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const specFile = "openapi.json"
+
+var update = flag.Bool("update", false, "rewrite openapi.json from the Go types")
+
+// The checked in document must match the one generated from the Go types.
+// Run `go test ./hookd/pkg/api/v1/openapi -update` after changing the API.
+func TestSpecIsUpToDate(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := api_v1_openapi.Encode(buf, api_v1_openapi.Spec())
+	assert.NoError(t, err)
+
+	if *update {
+		err = ioutil.WriteFile(specFile, buf.Bytes(), 0644)
+		assert.NoError(t, err)
+	}
+
+	data, err := ioutil.ReadFile(specFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), buf.String(), "%s is out of date; regenerate it with -update", specFile)
+}
+
+// Property names referred to by the spec must exist in the Go types.
+func TestSchemaPropertiesExist(t *testing.T) {
+	for _, component := range api_v1_openapi.Schemas {
+		schema := api_v1_openapi.SchemaOf(component.Value)
+		names := make([]string, 0)
+		names = append(names, component.Required...)
+		for name := range component.Descriptions {
+			names = append(names, name)
+		}
+		for name := range component.Overrides {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			assert.Contains(t, schema.Properties, name, "%s has no property '%s'", component.Name, name)
+		}
+	}
+}
+
+func TestEndpointsReferToSchemas(t *testing.T) {
+	spec := api_v1_openapi.Spec()
+	for _, endpoint := range api_v1_openapi.Endpoints {
+		assert.Contains(t, spec.Components.Schemas, endpoint.Request)
+		assert.Contains(t, spec.Components.Schemas, endpoint.Response)
+		for _, code := range endpoint.StatusCodes {
+			assert.Contains(t, spec.Paths[endpoint.Path].Post.Responses, strconv.Itoa(code))
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+	api_v1_openapi.Handler(recorder, request)
+
+	data, err := ioutil.ReadFile(specFile)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("content-type"))
+	assert.Equal(t, string(data), recorder.Body.String())
+}
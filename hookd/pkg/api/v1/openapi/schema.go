@@ -0,0 +1,127 @@
+package api_v1_openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+)
+
+// Schemas for types that can not be derived from their Go definition.
+var knownTypes = map[reflect.Type]*Schema{
+	reflect.TypeOf(time.Time{}): {
+		Type:   "string",
+		Format: "date-time",
+	},
+	reflect.TypeOf(json.RawMessage{}): {
+		Description: "Any JSON value.",
+	},
+	reflect.TypeOf(gh.Deployment{}): {
+		Type:        "object",
+		Description: "GitHub deployment object; see https://developer.github.com/v3/repos/deployments/",
+	},
+	reflect.TypeOf(api_v1.ErrorCode("")): {
+		Type:        "string",
+		Description: "Stable identifier of the cause of the error.",
+		Enum:        errorCodes(),
+	},
+}
+
+// Types described by a named schema in the document's components, and referred to by name.
+var references = map[reflect.Type]string{
+	reflect.TypeOf(api_v1.Error{}): "Error",
+}
+
+func errorCodes() []string {
+	codes := make([]string, 0, len(api_v1.ErrorCodes))
+	for code := range api_v1.ErrorCodes {
+		codes = append(codes, string(code))
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// SchemaOf returns a schema describing the JSON encoding of the value's type.
+// Struct fields are named after their JSON tags, and fields tagged with "-" are omitted.
+// Nested types listed in references are replaced by a reference to their component.
+func SchemaOf(v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return describe(t)
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if name, ok := references[t]; ok {
+		return ref(name)
+	}
+
+	return describe(t)
+}
+
+func describe(t reflect.Type) *Schema {
+	if known, ok := knownTypes[t]; ok {
+		schema := *known
+		return &schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{
+			Type:       "object",
+			Properties: make(map[string]*Schema),
+		}
+		addProperties(schema, t)
+		return schema
+	default:
+		return &Schema{}
+	}
+}
+
+func addProperties(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+
+		if tag == "-" || len(field.PkgPath) > 0 {
+			continue
+		}
+
+		if field.Anonymous && len(name) == 0 && field.Type.Kind() == reflect.Struct {
+			addProperties(schema, field.Type)
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		schema.Properties[name] = schemaOf(field.Type)
+	}
+}
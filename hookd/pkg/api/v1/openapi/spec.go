@@ -0,0 +1,393 @@
+package api_v1_openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/navikt/deployment/common/pkg/tracing"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/cancel"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/dora"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/logs"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/provision"
+	"github.com/navikt/deployment/hookd/pkg/api/v1/status"
+)
+
+const (
+	Version     = "3.0.2"
+	APIVersion  = "1.0.0"
+	ContentType = "application/json"
+
+	securityTeam      = "teamSignature"
+	securityProvision = "provisionSignature"
+)
+
+// Component is a named schema derived from a Go type.
+type Component struct {
+	Name  string
+	Value interface{}
+	// Required lists the properties the server refuses to do without.
+	Required []string
+	// Descriptions documents individual properties.
+	Descriptions map[string]string
+	// Overrides replaces the derived schema of individual properties.
+	Overrides map[string]*Schema
+}
+
+// Endpoint is an API operation accepting a signed JSON request and returning a JSON response.
+type Endpoint struct {
+	Path        string
+	OperationID string
+	Summary     string
+	Security    string
+	// PathParameters documents the parameters in the path, such as {id}.
+	PathParameters map[string]string
+	// QueryParameters documents the optional parameters in the query string.
+	QueryParameters []*Parameter
+	Request         string
+	Response        string
+	StatusCodes     []int
+}
+
+// traceparentParameter is accepted by every endpoint, so that callers can include the deployment in their own traces.
+var traceparentParameter = &Parameter{
+	Name:        tracing.TraceparentHeader,
+	In:          "header",
+	Description: "W3C trace context of the caller. hookd and deployd record their work as part of this trace.",
+	Schema:      &Schema{Type: "string"},
+}
+
+var timestampDescription = "Current time as seconds since the Unix epoch. Requests more than 30 seconds off are rejected."
+
+var Schemas = []Component{
+	{
+		Name:     "DeploymentRequest",
+		Value:    api_v1_deploy.DeploymentRequest{},
+		Required: []string{"resources", "team", "cluster", "environment", "owner", "repository", "ref", "timestamp"},
+		Descriptions: map[string]string{
+			"team":           "Team owning the deployment. The request must be signed with this team's API key.",
+			"cluster":        "Cluster to deploy to.",
+			"environment":    "GitHub deployment environment, usually on the form 'cluster:namespace'.",
+			"owner":          "Owner of the GitHub repository the deployment is made from.",
+			"repository":     "Name of the GitHub repository the deployment is made from.",
+			"ref":            "Git commit reference being deployed.",
+			"freezeOverride": "Deploy even if the cluster is covered by a deployment freeze window.",
+			"timeout":        "Time to wait for the rollout to complete, e.g. '10m'. Must be within the bounds configured for the cluster. Defaults to the cluster's rollout timeout.",
+			"ttl":            "Discard the deployment request if it has not been processed within this time, e.g. '5m'. Defaults to one minute.",
+			"user":           "GitHub login of the person requesting the deployment. They are not allowed to approve it, if approval is required. Required for clusters that need approval.",
+			"timestamp":      timestampDescription,
+		},
+		Overrides: map[string]*Schema{
+			"resources": {
+				Type:        "array",
+				Description: "Kubernetes resources to apply.",
+				Items:       &Schema{Type: "object"},
+			},
+		},
+	},
+	{
+		Name:  "DeploymentResponse",
+		Value: api_v1_deploy.DeploymentResponse{},
+		Descriptions: map[string]string{
+			"correlationID": "Identifies the deployment request in logs and status messages.",
+			"logURL":        "Link to the deployment logs.",
+			"resources":     "Results of a dry run, one per resource in the request.",
+		},
+	},
+	{
+		Name:  "ResourceResult",
+		Value: api_v1_deploy.ResourceResult{},
+		Descriptions: map[string]string{
+			"diff":  "Unified diff between the version running in the cluster and the dry run result, with status and server managed fields left out. Only present when a diff was requested and the resource has changes.",
+			"error": "Reason Kubernetes rejected the resource. Empty if the resource was accepted.",
+		},
+	},
+	{
+		Name:     "CancelRequest",
+		Value:    api_v1_cancel.CancelRequest{},
+		Required: []string{"team", "timestamp"},
+		Descriptions: map[string]string{
+			"team":      "Team owning the deployment. The request must be signed with this team's API key.",
+			"user":      "Person cancelling the deployment, shown in the deployment status.",
+			"rollback":  "Re-apply the resources that were present in the cluster before the deployment.",
+			"timestamp": timestampDescription,
+		},
+	},
+	{
+		Name:  "CancelResponse",
+		Value: api_v1_cancel.CancelResponse{},
+		Descriptions: map[string]string{
+			"correlationID": "Identifies the cancellation request in logs.",
+		},
+	},
+	{
+		Name:     "LogsRequest",
+		Value:    api_v1_logs.LogsRequest{},
+		Required: []string{"team", "timestamp"},
+		Descriptions: map[string]string{
+			"team":      "Team owning the deployment. The request must be signed with this team's API key.",
+			"offset":    "Number of log lines already received; only lines after them are returned.",
+			"timestamp": timestampDescription,
+		},
+	},
+	{
+		Name:  "LogsResponse",
+		Value: api_v1_logs.LogsResponse{},
+		Descriptions: map[string]string{
+			"events":   "Log lines about the deployment sent by deployd, in the order they were received.",
+			"state":    "Last known state of the deployment, such as 'in_progress', 'success' or 'failure'.",
+			"finished": "The deployment has reached a final state, and no more log lines are expected.",
+		},
+	},
+	{
+		Name:     "StatusRequest",
+		Value:    api_v1_status.StatusRequest{},
+		Required: []string{"deploymentID", "owner", "repository", "team", "timestamp"},
+		Descriptions: map[string]string{
+			"deploymentID": "ID of the GitHub deployment, as returned by the deploy endpoint.",
+			"team":         "Team owning the deployment. The request must be signed with this team's API key.",
+			"timestamp":    timestampDescription,
+		},
+	},
+	{
+		Name:  "StatusResponse",
+		Value: api_v1_status.StatusResponse{},
+		Descriptions: map[string]string{
+			"status": "Latest GitHub deployment state, such as 'pending', 'in_progress', 'success', 'failure' or 'error'.",
+		},
+	},
+	{
+		Name:     "ReportRequest",
+		Value:    api_v1_dora.ReportRequest{},
+		Required: []string{"team", "timestamp"},
+		Descriptions: map[string]string{
+			"team":       "Team to report on. The request must be signed with this team's API key.",
+			"repository": "Only report on deployments from this repository, on the form 'owner/name'.",
+			"cluster":    "Only report on deployments to this cluster.",
+			"from":       "Start of the time range. Defaults to the length of the server's DORA window before 'to'.",
+			"to":         "End of the time range. Defaults to the current time.",
+			"timestamp":  timestampDescription,
+		},
+	},
+	{
+		Name:  "ReportResponse",
+		Value: api_v1_dora.ReportResponse{},
+		Descriptions: map[string]string{
+			"report": "Metrics per team, repository and cluster, computed from deployments created within the time range.",
+		},
+	},
+	{
+		Name:     "ProvisionRequest",
+		Value:    api_v1_provision.Request{},
+		Required: []string{"team", "timestamp"},
+		Descriptions: map[string]string{
+			"team":      "Team to provision an API key for.",
+			"rotate":    "Replace the team's API key if it already exists.",
+			"timestamp": timestampDescription,
+		},
+	},
+	{
+		Name:  "ProvisionResponse",
+		Value: api_v1_provision.Response{},
+	},
+	{
+		Name:     "Error",
+		Value:    api_v1.Error{},
+		Required: []string{"code", "retryable"},
+		Descriptions: map[string]string{
+			"retryable": "Whether the request may succeed if it is retried unchanged.",
+			"fields":    "Problems with individual fields in the request body.",
+		},
+	},
+}
+
+var Endpoints = []Endpoint{
+	{
+		Path:        "/api/v1/deploy",
+		OperationID: "deploy",
+		Summary:     "Deploy Kubernetes resources to a cluster.",
+		Security:    securityTeam,
+		QueryParameters: []*Parameter{
+			{
+				Name:        "dryRun",
+				In:          "query",
+				Description: "Validate the request and submit the resources to Kubernetes as a server-side dry run, without deploying them or creating a GitHub deployment. Results are returned per resource.",
+				Schema:      &Schema{Type: "boolean"},
+			},
+			{
+				Name:        "diff",
+				In:          "query",
+				Description: "Run a dry run, and include a diff against the version running in the cluster for each resource. Implies dryRun.",
+				Schema:      &Schema{Type: "boolean"},
+			},
+		},
+		Request:     "DeploymentRequest",
+		Response:    "DeploymentResponse",
+		StatusCodes: api_v1_deploy.StatusCodes,
+	},
+	{
+		Path:        "/api/v1/deploy/{id}/cancel",
+		OperationID: "cancel",
+		Summary:     "Cancel a deployment that has not yet reached a final state.",
+		Security:    securityTeam,
+		PathParameters: map[string]string{
+			"id": "Correlation ID of the deployment, as returned by the deploy endpoint.",
+		},
+		Request:     "CancelRequest",
+		Response:    "CancelResponse",
+		StatusCodes: api_v1_cancel.StatusCodes,
+	},
+	{
+		Path:        "/api/v1/deploy/{id}/logs",
+		OperationID: "logs",
+		Summary:     "Get the log lines of a deployment.",
+		Security:    securityTeam,
+		PathParameters: map[string]string{
+			"id": "Correlation ID of the deployment, as returned by the deploy endpoint.",
+		},
+		Request:     "LogsRequest",
+		Response:    "LogsResponse",
+		StatusCodes: api_v1_logs.StatusCodes,
+	},
+	{
+		Path:        "/api/v1/status",
+		OperationID: "status",
+		Summary:     "Get the status of a deployment.",
+		Security:    securityTeam,
+		Request:     "StatusRequest",
+		Response:    "StatusResponse",
+		StatusCodes: api_v1_status.StatusCodes,
+	},
+	{
+		Path:        "/api/v1/dora/report",
+		OperationID: "doraReport",
+		Summary:     "Get deployment frequency, change failure rate and mean time to restore for a team's deployments.",
+		Security:    securityTeam,
+		Request:     "ReportRequest",
+		Response:    "ReportResponse",
+		StatusCodes: api_v1_dora.StatusCodes,
+	},
+	{
+		Path:        "/api/v1/provision",
+		OperationID: "provision",
+		Summary:     "Provision an API key for a team.",
+		Security:    securityProvision,
+		Request:     "ProvisionRequest",
+		Response:    "ProvisionResponse",
+		StatusCodes: api_v1_provision.StatusCodes,
+	},
+}
+
+func (c Component) Schema() *Schema {
+	schema := SchemaOf(c.Value)
+	schema.Required = c.Required
+	for name, override := range c.Overrides {
+		property := *override
+		schema.Properties[name] = &property
+	}
+	for name, description := range c.Descriptions {
+		if property, ok := schema.Properties[name]; ok {
+			property.Description = description
+		}
+	}
+	return schema
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{
+		ContentType: {Schema: schema},
+	}
+}
+
+func (e Endpoint) parameters() []*Parameter {
+	parameters := make([]*Parameter, 0, len(e.PathParameters))
+	for name, description := range e.PathParameters {
+		parameters = append(parameters, &Parameter{
+			Name:        name,
+			In:          "path",
+			Description: description,
+			Required:    true,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		return parameters[i].Name < parameters[j].Name
+	})
+	parameters = append(parameters, e.QueryParameters...)
+	return append(parameters, traceparentParameter)
+}
+
+func (e Endpoint) Operation() *Operation {
+	responses := make(map[string]*Response)
+	for _, code := range e.StatusCodes {
+		response := &Response{
+			Description: http.StatusText(code),
+		}
+		if code != http.StatusNoContent {
+			response.Content = jsonContent(ref(e.Response))
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+
+	return &Operation{
+		OperationID: e.OperationID,
+		Summary:     e.Summary,
+		Security: []SecurityRequirement{
+			{e.Security: []string{}},
+		},
+		Parameters: e.parameters(),
+		RequestBody: &RequestBody{
+			Required: true,
+			Content:  jsonContent(ref(e.Request)),
+		},
+		Responses: responses,
+	}
+}
+
+// Spec returns the OpenAPI document describing the hookd API.
+func Spec() *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "hookd",
+			Description: "NAIS deployment API. Request bodies are signed with HMAC-SHA256, and the hex encoded digest is sent in the " + api_v1.SignatureHeader + " header.",
+			Version:     APIVersion,
+		},
+		Paths: make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				securityTeam: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        api_v1.SignatureHeader,
+					Description: "HMAC-SHA256 digest of the request body, keyed with the team's API key.",
+				},
+				securityProvision: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        api_v1.SignatureHeader,
+					Description: "HMAC-SHA256 digest of the request body, keyed with the pre-shared provisioning key.",
+				},
+			},
+		},
+	}
+
+	for _, component := range Schemas {
+		doc.Components.Schemas[component.Name] = component.Schema()
+	}
+
+	for _, endpoint := range Endpoints {
+		doc.Paths[endpoint.Path] = &PathItem{
+			Post: endpoint.Operation(),
+		}
+	}
+
+	return doc
+}
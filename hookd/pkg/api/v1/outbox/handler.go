@@ -0,0 +1,222 @@
+package api_v1_outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/outbox"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler inspects and purges hookd's outboxes.
+// All requests must be signed with the pre-shared administrator key.
+type Handler struct {
+	Outboxes  []*outbox.Outbox
+	SecretKey []byte
+	AuditLog  *audit.Log
+}
+
+type InspectRequest struct {
+	Name      string           `json:"name,omitempty"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+// PurgeRequest removes a single item if ID is set, or otherwise all items in the outbox.
+// If Dead is true, only items that have been given up are removed.
+type PurgeRequest struct {
+	Name      string           `json:"name"`
+	ID        string           `json:"id,omitempty"`
+	Dead      bool             `json:"dead,omitempty"`
+	Timestamp api_v1.Timestamp `json:"timestamp"`
+}
+
+type Response struct {
+	Message  string         `json:"message,omitempty"`
+	Outboxes []outbox.Stats `json:"outboxes,omitempty"`
+}
+
+func (r *Response) render(w io.Writer) {
+	json.NewEncoder(w).Encode(r)
+}
+
+func (r *PurgeRequest) validate() error {
+	if len(r.Name) == 0 {
+		return fmt.Errorf("no outbox name specified")
+	}
+	return r.Timestamp.Validate()
+}
+
+func (h *Handler) find(name string) *outbox.Outbox {
+	for _, box := range h.Outboxes {
+		if box.Name == name {
+			return box
+		}
+	}
+	return nil
+}
+
+// decode authenticates the request and unmarshals its body into target.
+// If anything goes wrong, an error response is written and false is returned.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, logger *log.Entry, target interface{}) bool {
+	var response Response
+
+	data, err := api_v1.ReadSignedBody(r, h.SecretKey)
+	switch err {
+	case nil:
+	case api_v1.ErrMalformedSignature:
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return false
+	case api_v1.ErrInvalidSignature:
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.ActorAdmin,
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": "invalid HMAC signature",
+			},
+		})
+		w.WriteHeader(http.StatusForbidden)
+		response.Message = api_v1.FailedAuthenticationMsg
+		response.render(w)
+		logger.Error(err)
+		return false
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return false
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) Inspect(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &InspectRequest{}
+	if !h.decode(w, r, logger, request) {
+		return
+	}
+
+	if err := request.Timestamp.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid outbox request: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	for _, box := range h.Outboxes {
+		if len(request.Name) == 0 || request.Name == box.Name {
+			response.Outboxes = append(response.Outboxes, box.Inspect())
+		}
+	}
+
+	if len(response.Outboxes) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = fmt.Sprintf("no outbox named '%s'", request.Name)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = fmt.Sprintf("%d outboxes", len(response.Outboxes))
+	response.render(w)
+}
+
+func (h *Handler) Purge(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	logger := log.WithFields(middleware.RequestLogFields(r))
+
+	request := &PurgeRequest{}
+	if !h.decode(w, r, logger, request) {
+		return
+	}
+
+	if err := request.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Message = fmt.Sprintf("invalid outbox request: %s", err)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	logger = logger.WithField(outbox.LogFieldOutbox, request.Name)
+
+	box := h.find(request.Name)
+	if box == nil {
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = fmt.Sprintf("no outbox named '%s'", request.Name)
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	}
+
+	var err error
+	var count int
+
+	if len(request.ID) > 0 {
+		logger = logger.WithField(outbox.LogFieldItemID, request.ID)
+		err = box.Purge(request.ID)
+		count = 1
+	} else {
+		count, err = box.PurgeAll(request.Dead)
+	}
+
+	switch err {
+	case nil:
+	case outbox.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		response.Message = err.Error()
+		response.render(w)
+		logger.Error(response.Message)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Message = "unable to purge outbox"
+		response.render(w)
+		logger.Errorf("%s: %s", response.Message, err)
+		return
+	}
+
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.ActorAdmin,
+		Action:        audit.ActionOutboxPurge,
+		Target:        request.Name,
+		Outcome:       audit.OutcomeSuccess,
+		CorrelationID: middleware.CorrelationID(r),
+		Details: map[string]string{
+			"id":     request.ID,
+			"dead":   strconv.FormatBool(request.Dead),
+			"purged": strconv.Itoa(count),
+		},
+	})
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = fmt.Sprintf("%d items purged", count)
+	response.render(w)
+
+	logger.WithField(api_v1.LogFieldAudit, true).Warnf("Outbox purged: %s", response.Message)
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 
 	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
 	"github.com/navikt/deployment/hookd/pkg/middleware"
 
 	types "github.com/navikt/deployment/common/pkg/deployment"
@@ -19,6 +20,7 @@ import (
 type Handler struct {
 	APIKeyStorage persistence.ApiKeyStorage
 	SecretKey     []byte
+	AuditLog      *audit.Log
 }
 
 type Request struct {
@@ -28,7 +30,8 @@ type Request struct {
 }
 
 type Response struct {
-	Message string `json:"message,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Error   *api_v1.Error `json:"error,omitempty"`
 }
 
 func (r *Response) render(w io.Writer) {
@@ -36,16 +39,17 @@ func (r *Response) render(w io.Writer) {
 }
 
 func (r *Request) validate() error {
+	var errs api_v1.FieldErrors
 
 	if len(r.Team) == 0 {
-		return fmt.Errorf("no team specified")
+		errs.Add("team", "no team specified")
 	}
 
 	if err := r.Timestamp.Validate(); err != nil {
-		return err
+		errs.Add("timestamp", err.Error())
 	}
 
-	return nil
+	return errs.Err()
 }
 
 func (r *Request) LogFields() log.Fields {
@@ -67,6 +71,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		response.Message = fmt.Sprintf("unable to read request body: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyUnreadable)
 		response.render(w)
 
 		logger.Error(response.Message)
@@ -78,6 +83,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		response.Message = "HMAC digest must be hex encoded"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureMalformed)
 		response.render(w)
 		logger.Errorf("unable to validate team: %s: %s", response.Message, err)
 		return
@@ -89,6 +95,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := json.Unmarshal(data, request); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		response.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
 		response.render(w)
 		logger.Error(response.Message)
 		return
@@ -102,6 +109,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		response.Message = fmt.Sprintf("invalid provision request: %s", err)
+		response.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
 		response.render(w)
 		logger.Error(response.Message)
 		return
@@ -110,8 +118,19 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger.Tracef("Request body validated successfully")
 
 	if !api_v1.ValidateMAC(data, []byte(signature), h.SecretKey) {
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.ActorProvisioner,
+			Action:        audit.ActionAuthenticate,
+			Target:        request.Team,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: middleware.CorrelationID(r),
+			Details: map[string]string{
+				"reason": "invalid HMAC signature",
+			},
+		})
 		w.WriteHeader(http.StatusForbidden)
 		response.Message = api_v1.FailedAuthenticationMsg
+		response.Error = api_v1.NewError(api_v1.ErrorCodeSignatureInvalid)
 		response.render(w)
 		logger.Errorf("%s: HMAC signature error", api_v1.FailedAuthenticationMsg)
 		return
@@ -126,6 +145,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			w.WriteHeader(http.StatusBadGateway)
 			response.Message = "unable to communicate with team API key backend"
+			response.Error = api_v1.NewError(api_v1.ErrorCodeAPIKeyBackendUnavailable)
 			response.render(w)
 			logger.Error(fmt.Sprintf("%s: %s", response.Message, err))
 			return
@@ -142,6 +162,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		response.Message = "unable to generate API key"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeInternalError)
 		response.render(w)
 		logger.Error(fmt.Sprintf("%s: %s", response.Message, err))
 		return
@@ -151,11 +172,20 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadGateway)
 		response.Message = "unable to persist API key"
+		response.Error = api_v1.NewError(api_v1.ErrorCodeAPIKeyBackendUnavailable)
 		response.render(w)
 		logger.Error(fmt.Sprintf("%s: %s", response.Message, err))
 		return
 	}
 
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.ActorProvisioner,
+		Action:        audit.ActionApiKeyProvision,
+		Target:        request.Team,
+		Outcome:       audit.OutcomeSuccess,
+		CorrelationID: middleware.CorrelationID(r),
+	})
+
 	w.WriteHeader(http.StatusCreated)
 	response.Message = "API key provisioned successfully"
 	response.render(w)
@@ -61,6 +61,10 @@ func (a *apiKeyStorage) Write(team string, key []byte) error {
 	}
 }
 
+func (a *apiKeyStorage) Delete(team string) error {
+	return nil
+}
+
 func (a *apiKeyStorage) IsErrNotFound(err error) bool {
 	return err == persistence.ErrNotFound
 }
@@ -75,6 +79,7 @@ func testStatusResponse(t *testing.T, recorder *httptest.ResponseRecorder, respo
 	err := json.Unmarshal(recorder.Body.Bytes(), &decodedBody)
 	assert.NoError(t, err)
 	assert.Equal(t, response.Body.Message, decodedBody.Message)
+	assert.Equal(t, response.Body.Error, decodedBody.Error)
 }
 
 // Inject timestamp in request payload
@@ -0,0 +1,78 @@
+package api_v1
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+)
+
+var (
+	ErrMalformedSignature = fmt.Errorf("HMAC digest must be hex encoded")
+	ErrInvalidSignature   = fmt.Errorf("%s: HMAC signature error", FailedAuthenticationMsg)
+	ErrMalformedBody      = fmt.Errorf("unable to unmarshal request body")
+	ErrNoTeam             = fmt.Errorf("no team specified")
+	ErrUnknownTeam        = fmt.Errorf("%s: team has no API key", FailedAuthenticationMsg)
+	ErrAPIKeyUnavailable  = fmt.Errorf("something wrong happened when communicating with api key service")
+)
+
+// ReadSignedBody reads the request body and verifies that the signature header
+// contains a valid HMAC digest of the body, signed with the pre-shared key.
+func ReadSignedBody(r *http.Request, key []byte) ([]byte, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request body: %s", err)
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil {
+		return nil, ErrMalformedSignature
+	}
+
+	if !ValidateMAC(data, signature, key) {
+		return nil, ErrInvalidSignature
+	}
+
+	return data, nil
+}
+
+// ReadTeamSignedBody reads the request body, and verifies that the signature header contains a
+// valid HMAC digest of the body, signed with the API key of the team named in the body's "team" field.
+func ReadTeamSignedBody(r *http.Request, storage persistence.ApiKeyStorage) (string, []byte, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to read request body: %s", err)
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil {
+		return "", nil, ErrMalformedSignature
+	}
+
+	request := struct {
+		Team string `json:"team"`
+	}{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return "", nil, ErrMalformedBody
+	}
+	if len(request.Team) == 0 {
+		return "", nil, ErrNoTeam
+	}
+
+	key, err := storage.Read(request.Team)
+	if err != nil {
+		if storage.IsErrNotFound(err) {
+			return request.Team, nil, ErrUnknownTeam
+		}
+		return request.Team, nil, ErrAPIKeyUnavailable
+	}
+
+	if !ValidateMAC(data, signature, key) {
+		return request.Team, nil, ErrInvalidSignature
+	}
+
+	return request.Team, data, nil
+}
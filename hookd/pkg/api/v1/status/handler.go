@@ -9,6 +9,7 @@ import (
 	"net/http"
 
 	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
 	"github.com/navikt/deployment/hookd/pkg/github"
 	"github.com/navikt/deployment/hookd/pkg/middleware"
 
@@ -20,6 +21,7 @@ import (
 type StatusHandler struct {
 	APIKeyStorage persistence.ApiKeyStorage
 	GithubClient  github.Client
+	AuditLog      *audit.Log
 }
 
 type StatusRequest struct {
@@ -31,8 +33,9 @@ type StatusRequest struct {
 }
 
 type StatusResponse struct {
-	Message string  `json:"message,omitempty"`
-	Status  *string `json:"status,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Status  *string       `json:"status,omitempty"`
+	Error   *api_v1.Error `json:"error,omitempty"`
 }
 
 func (r *StatusResponse) render(w io.Writer) {
@@ -40,28 +43,29 @@ func (r *StatusResponse) render(w io.Writer) {
 }
 
 func (r *StatusRequest) validate() error {
+	var errs api_v1.FieldErrors
 
 	if r.DeploymentID == 0 {
-		return fmt.Errorf("no deployment ID specified")
+		errs.Add("deploymentID", "no deployment ID specified")
 	}
 
 	if len(r.Owner) == 0 {
-		return fmt.Errorf("no repository owner specified")
+		errs.Add("owner", "no repository owner specified")
 	}
 
 	if len(r.Repository) == 0 {
-		return fmt.Errorf("no repository specified")
+		errs.Add("repository", "no repository specified")
 	}
 
 	if len(r.Team) == 0 {
-		return fmt.Errorf("no team specified")
+		errs.Add("team", "no team specified")
 	}
 
 	if err := r.Timestamp.Validate(); err != nil {
-		return err
+		errs.Add("timestamp", err.Error())
 	}
 
-	return nil
+	return errs.Err()
 }
 
 func (r *StatusRequest) LogFields() log.Fields {
@@ -85,6 +89,7 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		statusResponse.Message = fmt.Sprintf("unable to read request body: %s", err)
+		statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeBodyUnreadable)
 		statusResponse.render(w)
 
 		logger.Error(statusResponse.Message)
@@ -96,6 +101,7 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		statusResponse.Message = "HMAC digest must be hex encoded"
+		statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeSignatureMalformed)
 		statusResponse.render(w)
 		logger.Errorf("unable to validate team: %s: %s", statusResponse.Message, err)
 		return
@@ -107,6 +113,7 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := json.Unmarshal(data, statusRequest); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		statusResponse.Message = fmt.Sprintf("unable to unmarshal request body: %s", err)
+		statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeBodyMalformed)
 		statusResponse.render(w)
 		logger.Error(statusResponse.Message)
 		return
@@ -120,6 +127,7 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		statusResponse.Message = fmt.Sprintf("invalid status request: %s", err)
+		statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeValidationFailed, err.(api_v1.FieldErrors)...)
 		statusResponse.render(w)
 		logger.Error(statusResponse.Message)
 		return
@@ -131,8 +139,10 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if h.APIKeyStorage.IsErrNotFound(err) {
+			h.denied(r, statusRequest, "team has no API key")
 			w.WriteHeader(http.StatusForbidden)
 			statusResponse.Message = api_v1.FailedAuthenticationMsg
+			statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeTeamNoAPIKey)
 			statusResponse.render(w)
 			logger.Errorf("%s: %s", api_v1.FailedAuthenticationMsg, err)
 			return
@@ -140,6 +150,7 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		w.WriteHeader(http.StatusBadGateway)
 		statusResponse.Message = "something wrong happened when communicating with api key service"
+		statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeAPIKeyBackendUnavailable)
 		statusResponse.render(w)
 		logger.Errorf("unable to fetch team apikey from storage: %s", err)
 		return
@@ -148,8 +159,10 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger.Tracef("Team API key retrieved from storage")
 
 	if !api_v1.ValidateMAC(data, []byte(signature), token) {
+		h.denied(r, statusRequest, "invalid HMAC signature")
 		w.WriteHeader(http.StatusForbidden)
 		statusResponse.Message = api_v1.FailedAuthenticationMsg
+		statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeSignatureInvalid)
 		statusResponse.render(w)
 		logger.Errorf("%s: HMAC signature error", api_v1.FailedAuthenticationMsg)
 		return
@@ -169,6 +182,9 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if err == github.ErrDeploymentNotFound {
 			w.WriteHeader(http.StatusBadRequest)
+			statusResponse.Message = fmt.Sprintf("deployment %d does not exist", statusRequest.DeploymentID)
+			statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeDeploymentNotFound, api_v1.FieldError{Field: "deploymentID", Message: statusResponse.Message})
+			statusResponse.render(w)
 			logger.Infof("Deployment %d does not exist", statusRequest.DeploymentID)
 			return
 		} else if err == github.ErrNoDeploymentStatuses {
@@ -178,6 +194,7 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		w.WriteHeader(http.StatusBadGateway)
 		statusResponse.Message = "unable to return deployment status: GitHub is unavailable"
+		statusResponse.Error = api_v1.NewError(api_v1.ErrorCodeGithubUnavailable)
 		statusResponse.render(w)
 		logger.Errorf("Unable to return deployment status: GitHub is unavailable: %s", err)
 		return
@@ -191,3 +208,17 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info("Status request processed successfully")
 }
+
+// denied records a failed authentication in the audit log.
+func (h *StatusHandler) denied(r *http.Request, request *StatusRequest, reason string) {
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.TeamActor(request.Team),
+		Action:        audit.ActionAuthenticate,
+		Target:        fmt.Sprintf("%s/%s", request.Owner, request.Repository),
+		Outcome:       audit.OutcomeDenied,
+		CorrelationID: middleware.CorrelationID(r),
+		Details: map[string]string{
+			"reason": reason,
+		},
+	})
+}
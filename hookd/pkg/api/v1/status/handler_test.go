@@ -90,6 +90,10 @@ func (a *apiKeyStorage) Write(team string, key []byte) error {
 	return nil
 }
 
+func (a *apiKeyStorage) Delete(team string) error {
+	return nil
+}
+
 func (a *apiKeyStorage) IsErrNotFound(err error) bool {
 	return err == persistence.ErrNotFound
 }
@@ -100,6 +104,7 @@ func testStatusResponse(t *testing.T, recorder *httptest.ResponseRecorder, respo
 	assert.NoError(t, err)
 	assert.Equal(t, response.StatusCode, recorder.Code)
 	assert.Equal(t, response.Body.Message, decodedBody.Message)
+	assert.Equal(t, response.Body.Error, decodedBody.Error)
 	assert.Equal(t, response.Body.Status, decodedBody.Status)
 }
 
@@ -0,0 +1,77 @@
+package api_v1
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeoutBounds is the range of rollout timeouts deployment requests may specify.
+// A zero Max means there is no upper bound.
+type TimeoutBounds struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Timeouts limits the rollout timeout and time to live that deployment requests may specify.
+type Timeouts struct {
+	// Bounds apply to clusters that are not listed in Clusters.
+	Bounds   TimeoutBounds
+	Clusters map[string]TimeoutBounds
+	// MaxTTL is the longest time a deployment request may wait before it is discarded. Zero means no limit.
+	MaxTTL time.Duration
+}
+
+// ParseTimeoutBounds parses bounds on the form 'min-max', e.g. '1m-2h'.
+func ParseTimeoutBounds(str string) (TimeoutBounds, error) {
+	parts := strings.Split(str, "-")
+	if len(parts) != 2 {
+		return TimeoutBounds{}, fmt.Errorf("timeout bounds '%s' must be on the form 'min-max'", str)
+	}
+	min, err := time.ParseDuration(parts[0])
+	if err != nil {
+		return TimeoutBounds{}, fmt.Errorf("minimum timeout: %s", err)
+	}
+	max, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return TimeoutBounds{}, fmt.Errorf("maximum timeout: %s", err)
+	}
+	if max < min {
+		return TimeoutBounds{}, fmt.Errorf("maximum timeout %s is less than minimum timeout %s", max, min)
+	}
+	return TimeoutBounds{Min: min, Max: max}, nil
+}
+
+// Check returns an error if the timeout is outside the bounds.
+func (b TimeoutBounds) Check(timeout time.Duration) error {
+	if timeout < b.Min || (b.Max > 0 && timeout > b.Max) {
+		return fmt.Errorf("must be between %s and %s", b.Min, b.Max)
+	}
+	return nil
+}
+
+// ClusterBounds returns the rollout timeout bounds for a cluster.
+func (t Timeouts) ClusterBounds(cluster string) TimeoutBounds {
+	if bounds, ok := t.Clusters[cluster]; ok {
+		return bounds
+	}
+	return t.Bounds
+}
+
+// Check validates the rollout timeout and time to live of a deployment request to a cluster.
+// Zero values mean that the request did not specify them, and are always accepted.
+func (t Timeouts) Check(cluster string, timeout, ttl time.Duration) error {
+	var errs FieldErrors
+
+	if timeout != 0 {
+		if err := t.ClusterBounds(cluster).Check(timeout); err != nil {
+			errs.Add("timeout", fmt.Sprintf("rollout timeout in cluster '%s' %s", cluster, err))
+		}
+	}
+
+	if ttl != 0 && t.MaxTTL > 0 && ttl > t.MaxTTL {
+		errs.Add("ttl", fmt.Sprintf("time to live must not exceed %s", t.MaxTTL))
+	}
+
+	return errs.Err()
+}
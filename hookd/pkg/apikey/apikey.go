@@ -0,0 +1,138 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+)
+
+var (
+	ErrNotMaintainer = fmt.Errorf("only maintainers of a team can manage its API keys")
+	ErrUnknownAction = fmt.Errorf("action must be either 'rotate' or 'revoke'")
+)
+
+// Team describes whether a team has an API key. The key itself is never included.
+type Team struct {
+	Team   string `json:"team"`
+	HasKey bool   `json:"hasKey"`
+}
+
+// MaintainedTeams returns the slugs of all teams in the organization where the GitHub user has the maintainer role.
+//
+// The client must act on behalf of the user, with an OAuth token that has the read:org scope.
+// Teams in other organizations are ignored, so that a team with the same name elsewhere cannot be used to gain access.
+func MaintainedTeams(ctx context.Context, client *gh.Client, organization, login string) ([]string, error) {
+	opt := &gh.ListOptions{
+		PerPage: 100,
+	}
+
+	maintained := make([]string, 0)
+
+	for {
+		teams, resp, err := client.Teams.ListUserTeams(ctx, opt)
+		if err != nil {
+			return nil, fmt.Errorf("list user teams: %s", err)
+		}
+
+		for _, team := range teams {
+			if team.GetOrganization().GetLogin() != organization {
+				continue
+			}
+
+			membership, resp, err := client.Teams.GetTeamMembership(ctx, team.GetID(), login)
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("look up membership in team '%s': %s", team.GetSlug(), err)
+			}
+
+			if membership.GetRole() == "maintainer" {
+				maintained = append(maintained, team.GetSlug())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	sort.Strings(maintained)
+
+	return maintained, nil
+}
+
+// Authorize checks that a team is one of the teams maintained by the user.
+func Authorize(maintained []string, team string) error {
+	for _, slug := range maintained {
+		if slug == team {
+			return nil
+		}
+	}
+	return ErrNotMaintainer
+}
+
+// List returns the API key status of each team.
+func List(storage persistence.ApiKeyStorage, teams []string) ([]Team, error) {
+	result := make([]Team, 0, len(teams))
+
+	for _, team := range teams {
+		_, err := storage.Read(team)
+		if err != nil && !storage.IsErrNotFound(err) {
+			return nil, fmt.Errorf("read API key for team '%s': %s", team, err)
+		}
+		result = append(result, Team{
+			Team:   team,
+			HasKey: err == nil,
+		})
+	}
+
+	return result, nil
+}
+
+// Rotate generates a new API key for a team, replacing the existing key if there is one.
+// The new key is returned to the caller, and cannot be retrieved again later.
+func Rotate(storage persistence.ApiKeyStorage, team string) ([]byte, error) {
+	key, err := api_v1.Keygen(api_v1.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("generate API key: %s", err)
+	}
+
+	err = storage.Write(team, key)
+	if err != nil {
+		return nil, fmt.Errorf("persist API key: %s", err)
+	}
+
+	return key, nil
+}
+
+// Revoke deletes a team's API key. The team will be unable to deploy until a new key is created.
+func Revoke(storage persistence.ApiKeyStorage, team string) error {
+	err := storage.Delete(team)
+	if err != nil {
+		return fmt.Errorf("delete API key: %s", err)
+	}
+	return nil
+}
+
+// AuditRecord describes a change to a team's API key made by a GitHub user.
+func AuditRecord(login, team string, rotate bool, outcome, correlationID string) audit.Record {
+	action := audit.ActionApiKeyRevoke
+	if rotate {
+		action = audit.ActionApiKeyRotate
+	}
+	return audit.Record{
+		Actor:         audit.UserActor(login),
+		Action:        action,
+		Target:        team,
+		Outcome:       outcome,
+		CorrelationID: correlationID,
+	}
+}
@@ -0,0 +1,93 @@
+package apikey_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/hookd/pkg/apikey"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGitHub serves the user's teams, and the user's role in each team.
+func fakeGitHub() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user/teams", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"id": 1, "slug": "aura", "organization": {"login": "navikt"}},
+			{"id": 2, "slug": "nais", "organization": {"login": "navikt"}},
+			{"id": 3, "slug": "bris", "organization": {"login": "navikt"}},
+			{"id": 4, "slug": "aura", "organization": {"login": "evilcorp"}}
+		]`))
+	})
+	mux.HandleFunc("/teams/1/memberships/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"role": "maintainer", "state": "active"}`))
+	})
+	mux.HandleFunc("/teams/2/memberships/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"role": "member", "state": "active"}`))
+	})
+	mux.HandleFunc("/teams/3/memberships/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/teams/4/memberships/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"role": "maintainer", "state": "active"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestMaintainedTeams(t *testing.T) {
+	server := fakeGitHub()
+	defer server.Close()
+
+	client := gh.NewClient(server.Client())
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	teams, err := apikey.MaintainedTeams(context.Background(), client, "navikt", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aura"}, teams)
+
+	assert.NoError(t, apikey.Authorize(teams, "aura"))
+	assert.Equal(t, apikey.ErrNotMaintainer, apikey.Authorize(teams, "nais"))
+}
+
+func TestRotateAndRevoke(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	storage, err := persistence.NewFileApiKeyStorage(filepath.Join(dir, "apikeys.json"))
+	assert.NoError(t, err)
+
+	teams, err := apikey.List(storage, []string{"aura"})
+	assert.NoError(t, err)
+	assert.Equal(t, []apikey.Team{{Team: "aura", HasKey: false}}, teams)
+
+	key, err := apikey.Rotate(storage, "aura")
+	assert.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	stored, err := storage.Read("aura")
+	assert.NoError(t, err)
+	assert.Equal(t, key, stored)
+
+	teams, err = apikey.List(storage, []string{"aura"})
+	assert.NoError(t, err)
+	assert.Equal(t, []apikey.Team{{Team: "aura", HasKey: true}}, teams)
+
+	rotated, err := apikey.Rotate(storage, "aura")
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, rotated)
+
+	assert.NoError(t, apikey.Revoke(storage, "aura"))
+
+	teams, err = apikey.List(storage, []string{"aura"})
+	assert.NoError(t, err)
+	assert.Equal(t, []apikey.Team{{Team: "aura", HasKey: false}}, teams)
+}
@@ -0,0 +1,246 @@
+package approval
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNotFound      = fmt.Errorf("no pending deployment request with that id")
+	ErrExpired       = fmt.Errorf("deployment request was not approved in time")
+	ErrUnknownAction = fmt.Errorf("action must be either 'approve' or 'reject'")
+)
+
+// Pending is a validated deployment request that is held back until someone approves it.
+type Pending struct {
+	ID         string    `json:"id"`
+	Team       string    `json:"team"`
+	Cluster    string    `json:"cluster"`
+	Repository string    `json:"repository"`
+	Requester  string    `json:"requester,omitempty"` // GitHub login of the person requesting the deployment, if known
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+
+	// Protobuf encoded deployment.DeploymentRequest
+	Request []byte `json:"request"`
+}
+
+func (p *Pending) DeploymentRequest() (*deployment.DeploymentRequest, error) {
+	req := &deployment.DeploymentRequest{}
+	err := proto.Unmarshal(p.Request, req)
+	if err != nil {
+		return nil, fmt.Errorf("decode pending deployment request: %s", err)
+	}
+	return req, nil
+}
+
+// Gate holds deployment requests to protected clusters until they are approved.
+//
+// Released requests are sent on the request channel, and the resulting
+// deployment statuses on the status channel, just like any other deployment.
+type Gate struct {
+	Clusters          []string
+	Timeout           time.Duration
+	DeploymentRequest chan<- deployment.DeploymentRequest
+	DeploymentStatus  chan<- deployment.DeploymentStatus
+
+	lock    sync.Mutex
+	path    string
+	pending map[string]Pending
+}
+
+// NewGate returns an approval gate for the specified clusters.
+// Pending requests are persisted to a JSON file, and loaded from it on startup.
+func NewGate(path string, clusters []string, timeout time.Duration, requests chan<- deployment.DeploymentRequest, statuses chan<- deployment.DeploymentStatus) (*Gate, error) {
+	g := &Gate{
+		Clusters:          clusters,
+		Timeout:           timeout,
+		DeploymentRequest: requests,
+		DeploymentStatus:  statuses,
+		path:              path,
+		pending:           make(map[string]Pending),
+	}
+	err := persistence.LoadJSONFile(path, &g.pending)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Required reports whether deployments to a cluster must be approved. A nil gate never requires approval.
+func (g *Gate) Required(cluster string) bool {
+	if g == nil {
+		return false
+	}
+	for _, c := range g.Clusters {
+		if c == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// Hold stores a deployment request until it is approved, rejected, or expires.
+// A pending status is posted for the deployment. The requester, if known, is not allowed to approve it.
+func (g *Gate) Hold(req deployment.DeploymentRequest, requester string) (*Pending, error) {
+	payload, err := proto.Marshal(&req)
+	if err != nil {
+		return nil, fmt.Errorf("encode deployment request: %s", err)
+	}
+
+	now := time.Now()
+	pending := Pending{
+		ID:         req.GetDeliveryID(),
+		Team:       req.GetPayloadSpec().GetTeam(),
+		Cluster:    req.GetCluster(),
+		Repository: req.GetDeployment().GetRepository().FullName(),
+		Requester:  requester,
+		Created:    now,
+		Expires:    now.Add(g.Timeout),
+		Request:    payload,
+	}
+
+	g.lock.Lock()
+	g.pending[pending.ID] = pending
+	err = g.save()
+	if err != nil {
+		delete(g.pending, pending.ID)
+	}
+	g.lock.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	g.DeploymentStatus <- *deployment.NewPendingStatus(req)
+
+	return &pending, nil
+}
+
+// List returns all pending requests, oldest first.
+func (g *Gate) List() []Pending {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	list := make([]Pending, 0, len(g.pending))
+	for _, pending := range g.pending {
+		list = append(list, pending)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+
+	return list
+}
+
+// Get returns a pending request.
+func (g *Gate) Get(id string) (*Pending, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	pending, ok := g.pending[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &pending, nil
+}
+
+// Approve releases a pending deployment request for deployment.
+func (g *Gate) Approve(id, approver string) error {
+	req, err := g.remove(id)
+	if err != nil {
+		return err
+	}
+
+	// The request has been waiting for some time, so move its deadline accordingly.
+	ttl := req.GetDeadline() - req.GetTimestamp()
+	req.Deadline = time.Now().Unix() + ttl
+
+	g.DeploymentRequest <- *req
+
+	return nil
+}
+
+// Reject discards a pending deployment request.
+func (g *Gate) Reject(id, approver string) error {
+	req, err := g.remove(id)
+	if err != nil {
+		return err
+	}
+
+	g.DeploymentStatus <- *deployment.NewErrorStatus(*req, fmt.Errorf("deployment was rejected by %s", approver))
+
+	return nil
+}
+
+// Cancel discards a pending deployment request on behalf of the team that requested it.
+// The deployment gets an inactive status, as it was withdrawn rather than rejected.
+func (g *Gate) Cancel(id, user string) error {
+	req, err := g.remove(id)
+	if err != nil {
+		return err
+	}
+
+	if len(user) == 0 {
+		user = "a user"
+	}
+	g.DeploymentStatus <- *deployment.NewInactiveStatus(*req, fmt.Sprintf("Deployment was cancelled by %s before it was approved.", user))
+
+	return nil
+}
+
+// Expire discards all pending requests that have not been approved within the timeout.
+func (g *Gate) Expire(now time.Time) {
+	for _, pending := range g.List() {
+		if now.Before(pending.Expires) {
+			continue
+		}
+		req, err := g.remove(pending.ID)
+		if err != nil {
+			continue
+		}
+		log.WithFields(req.LogFields()).Warnf("Discarding deployment request: %s", ErrExpired)
+		g.DeploymentStatus <- *deployment.NewErrorStatus(*req, ErrExpired)
+	}
+}
+
+// ExpireLoop periodically discards expired requests.
+func (g *Gate) ExpireLoop(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		g.Expire(now)
+	}
+}
+
+func (g *Gate) remove(id string) (*deployment.DeploymentRequest, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	pending, ok := g.pending[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	req, err := pending.DeploymentRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	delete(g.pending, id)
+	if err := g.save(); err != nil {
+		g.pending[id] = pending
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (g *Gate) save() error {
+	return persistence.SaveJSONFile(g.path, g.pending)
+}
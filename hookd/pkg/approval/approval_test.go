@@ -0,0 +1,171 @@
+package approval_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/stretchr/testify/assert"
+)
+
+func request() deployment.DeploymentRequest {
+	now := time.Now()
+	return deployment.DeploymentRequest{
+		Deployment: &deployment.DeploymentSpec{
+			Repository: &deployment.GithubRepository{
+				Owner: "navikt",
+				Name:  "deployment",
+			},
+			DeploymentID: 1,
+		},
+		PayloadSpec: &deployment.Payload{
+			Team: "aura",
+		},
+		Cluster:    "prod-fss",
+		DeliveryID: "delivery",
+		Timestamp:  now.Add(-time.Minute * 5).Unix(),
+		Deadline:   now.Add(-time.Minute * 4).Unix(),
+	}
+}
+
+func gate(t *testing.T, path string) (*approval.Gate, chan deployment.DeploymentRequest, chan deployment.DeploymentStatus) {
+	requests := make(chan deployment.DeploymentRequest, 16)
+	statuses := make(chan deployment.DeploymentStatus, 16)
+	g, err := approval.NewGate(path, []string{"prod-fss"}, time.Hour, requests, statuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, requests, statuses
+}
+
+func tempfile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "pending.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestGateRequired(t *testing.T) {
+	var g *approval.Gate
+	assert.False(t, g.Required("prod-fss"))
+
+	g = &approval.Gate{Clusters: []string{"prod-fss"}}
+	assert.True(t, g.Required("prod-fss"))
+	assert.False(t, g.Required("dev-fss"))
+}
+
+func TestGateApprove(t *testing.T) {
+	path, cleanup := tempfile(t)
+	defer cleanup()
+
+	g, requests, statuses := gate(t, path)
+
+	pending, err := g.Hold(request(), "jane")
+	assert.NoError(t, err)
+	assert.Equal(t, "delivery", pending.ID)
+	assert.Equal(t, "navikt/deployment", pending.Repository)
+	assert.Equal(t, "jane", pending.Requester)
+
+	status := <-statuses
+	assert.Equal(t, deployment.GithubDeploymentState_pending, status.GetState())
+	assert.Len(t, requests, 0)
+
+	// pending requests survive a restart
+	g, requests, _ = gate(t, path)
+	assert.Len(t, g.List(), 1)
+
+	assert.NoError(t, g.Approve("delivery", "approver"))
+	assert.Len(t, g.List(), 0)
+	assert.Equal(t, approval.ErrNotFound, g.Approve("delivery", "approver"))
+
+	req := <-requests
+	assert.Equal(t, "delivery", req.GetDeliveryID())
+	assert.True(t, req.GetDeadline() > time.Now().Unix(), "deadline is moved forward on approval")
+}
+
+func TestGateReject(t *testing.T) {
+	path, cleanup := tempfile(t)
+	defer cleanup()
+
+	g, requests, statuses := gate(t, path)
+
+	_, err := g.Hold(request(), "jane")
+	assert.NoError(t, err)
+	<-statuses
+
+	assert.NoError(t, g.Reject("delivery", "approver"))
+	status := <-statuses
+	assert.Equal(t, deployment.GithubDeploymentState_error, status.GetState())
+	assert.Len(t, requests, 0)
+}
+
+func TestGateExpire(t *testing.T) {
+	path, cleanup := tempfile(t)
+	defer cleanup()
+
+	g, _, statuses := gate(t, path)
+
+	_, err := g.Hold(request(), "jane")
+	assert.NoError(t, err)
+	<-statuses
+
+	g.Expire(time.Now())
+	assert.Len(t, g.List(), 1)
+
+	g.Expire(time.Now().Add(time.Hour))
+	assert.Len(t, g.List(), 0)
+
+	status := <-statuses
+	assert.Equal(t, deployment.GithubDeploymentState_error, status.GetState())
+}
+
+func TestAuthorize(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/navikt/teams/aura", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "slug": "aura"}`)
+	})
+	mux.HandleFunc("/teams/1/memberships/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/teams/1/memberships/jane", "/teams/1/memberships/john":
+			fmt.Fprint(w, `{"role": "maintainer", "state": "active"}`)
+		case "/teams/1/memberships/jack":
+			fmt.Fprint(w, `{"role": "member", "state": "active"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := gh.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	pending := &approval.Pending{
+		ID:         "delivery",
+		Team:       "aura",
+		Repository: "navikt/deployment",
+		Requester:  "jane",
+	}
+
+	assert.NoError(t, approval.Authorize(client, pending, "john", true), "another maintainer can approve")
+	assert.Equal(t, approval.ErrSelfApproval, approval.Authorize(client, pending, "jane", true), "requester can not approve their own deployment")
+	assert.Equal(t, approval.ErrSelfApproval, approval.Authorize(client, pending, "JANE", true), "logins are case insensitive")
+	assert.NoError(t, approval.Authorize(client, pending, "jane", false), "requester can reject their own deployment")
+	assert.Equal(t, approval.ErrNotMaintainer, approval.Authorize(client, pending, "jack", true))
+	assert.Equal(t, approval.ErrNotMaintainer, approval.Authorize(client, pending, "nobody", true))
+
+	pending.Requester = ""
+	assert.Equal(t, approval.ErrNoRequester, approval.Authorize(client, pending, "jane", true), "nobody can approve when the requester is unknown")
+	assert.NoError(t, approval.Authorize(client, pending, "jane", false), "any maintainer can reject when the requester is unknown")
+}
@@ -0,0 +1,24 @@
+package approval
+
+import (
+	"github.com/navikt/deployment/hookd/pkg/audit"
+)
+
+// AuditRecord describes an approval decision. The correlation ID is the delivery ID of the deployment request.
+func AuditRecord(login string, pending *Pending, approve bool, outcome string) audit.Record {
+	action := audit.ActionReject
+	if approve {
+		action = audit.ActionApprove
+	}
+	return audit.Record{
+		Actor:         audit.UserActor(login),
+		Action:        action,
+		Target:        pending.Repository,
+		Outcome:       outcome,
+		CorrelationID: pending.ID,
+		Details: map[string]string{
+			"team":    pending.Team,
+			"cluster": pending.Cluster,
+		},
+	}
+}
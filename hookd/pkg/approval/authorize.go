@@ -0,0 +1,58 @@
+package approval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/hookd/pkg/github"
+)
+
+var (
+	ErrNotMaintainer = fmt.Errorf("only maintainers of the deploying team can approve this deployment")
+	ErrSelfApproval  = fmt.Errorf("deployments must be approved by someone else than the person requesting them")
+	ErrNoRequester   = fmt.Errorf("deployments without a known requester can not be approved, only rejected")
+)
+
+// Authorize checks that a GitHub user is allowed to approve or reject a pending request.
+// Approvers must have the maintainer role in the GitHub team that is deploying,
+// and can not approve their own requests. Requesters may reject their own requests.
+// Requests without a requester can not be approved, as anyone could have made them.
+func Authorize(client *gh.Client, pending *Pending, login string, approve bool) error {
+	if approve && len(pending.Requester) == 0 {
+		return ErrNoRequester
+	}
+
+	if approve && strings.EqualFold(pending.Requester, login) {
+		return ErrSelfApproval
+	}
+
+	if client == nil {
+		return github.ErrGitHubNotEnabled
+	}
+
+	owner, _, err := github.SplitFullname(pending.Repository)
+	if err != nil {
+		return err
+	}
+
+	team, _, err := client.Teams.GetTeamBySlug(context.Background(), owner, pending.Team)
+	if err != nil {
+		return fmt.Errorf("look up team '%s': %s", pending.Team, err)
+	}
+
+	membership, resp, err := client.Teams.GetTeamMembership(context.Background(), team.GetID(), login)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return ErrNotMaintainer
+	} else if err != nil {
+		return fmt.Errorf("look up team membership: %s", err)
+	}
+
+	if membership.GetRole() != "maintainer" {
+		return ErrNotMaintainer
+	}
+
+	return nil
+}
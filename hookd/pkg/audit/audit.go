@@ -0,0 +1,259 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SinkFile  = "file"
+	SinkKafka = "kafka"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+const (
+	ActionAuthenticate    = "authenticate"
+	ActionDeploy          = "deploy"
+	ActionCancel          = "deploy.cancel"
+	ActionFreezeOverride  = "freeze.override"
+	ActionFreezeCreate    = "freeze.create"
+	ActionFreezeDelete    = "freeze.delete"
+	ActionApprove         = "approval.approve"
+	ActionReject          = "approval.reject"
+	ActionApiKeyProvision = "apikey.provision"
+	ActionApiKeyRotate    = "apikey.rotate"
+	ActionApiKeyRevoke    = "apikey.revoke"
+	ActionRepositoryGrant = "repository.grant"
+	ActionOutboxPurge     = "outbox.purge"
+)
+
+const (
+	ActorAdmin       = "admin"
+	ActorProvisioner = "provisioner"
+	ActorAnonymous   = "anonymous"
+	ActorWebhook     = "github-webhook"
+)
+
+// TeamActor identifies a team authenticated with its API key.
+func TeamActor(team string) string {
+	return "team:" + team
+}
+
+// UserActor identifies a GitHub user.
+func UserActor(login string) string {
+	return "github:" + login
+}
+
+// Record is a single entry in the audit log.
+//
+// Records are hash chained: Hash covers every other field, including PreviousHash,
+// which is the hash of the record written before it. Changing, removing or
+// reordering records breaks the chain, which is detected by Verify.
+//
+// Hashes are keyed with a secret held outside the log, so that whoever can write
+// the log can not recompute the chain after changing it.
+type Record struct {
+	Sequence      uint64            `json:"sequence"`
+	Time          time.Time         `json:"time"`
+	Actor         string            `json:"actor"`
+	Action        string            `json:"action"`
+	Target        string            `json:"target"`
+	Outcome       string            `json:"outcome"`
+	CorrelationID string            `json:"correlationID,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+	PreviousHash  string            `json:"previousHash"`
+	Hash          string            `json:"hash"`
+}
+
+// ComputeHash returns the hex encoded HMAC-SHA256 of the record, excluding the Hash field itself.
+// Without a key, the plain SHA-256 digest is returned, which only protects against accidental changes.
+func (r Record) ComputeHash(key []byte) string {
+	r.Hash = ""
+	payload, _ := json.Marshal(r)
+	if len(key) == 0 {
+		digest := sha256.Sum256(payload)
+		return hex.EncodeToString(digest[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sink stores audit records.
+type Sink interface {
+	Write(record Record) error
+}
+
+// Reader is implemented by sinks that can read back the records they have written.
+type Reader interface {
+	Records() ([]Record, error)
+}
+
+// Tailer is implemented by sinks that can return the last record written,
+// so that the hash chain can be continued after a restart.
+type Tailer interface {
+	Last() (*Record, error)
+}
+
+var ErrNoTailer = fmt.Errorf("audit records can not be read back from any of the sinks, so the hash chain can not be continued after a restart; add the 'file' sink")
+
+// Log appends records to one or more sinks.
+// A nil Log discards all records, which is convenient in tests.
+type Log struct {
+	lock     sync.Mutex
+	key      []byte
+	tailer   Sink
+	sinks    []Sink
+	sequence uint64
+	lastHash string
+}
+
+// New creates an audit log writing to the given sinks, keying the hash chain with key.
+// The hash chain is continued from the last record of the first sink implementing Tailer,
+// which must exist unless there are no sinks at all. The last record must have been written with the same key.
+func New(key []byte, sinks ...Sink) (*Log, error) {
+	l := &Log{
+		key: key,
+	}
+
+	for _, sink := range sinks {
+		tailer, ok := sink.(Tailer)
+		if !ok || l.tailer != nil {
+			l.sinks = append(l.sinks, sink)
+			continue
+		}
+		last, err := tailer.Last()
+		if err != nil {
+			return nil, fmt.Errorf("read last audit record: %s", err)
+		}
+		if last != nil {
+			if last.ComputeHash(key) != last.Hash {
+				return nil, fmt.Errorf("last audit record %d does not match the audit key; the record has been modified, or the log was written with another key", last.Sequence)
+			}
+			l.sequence = last.Sequence
+			l.lastHash = last.Hash
+		}
+		l.tailer = sink
+	}
+
+	if l.tailer == nil && len(l.sinks) > 0 {
+		return nil, ErrNoTailer
+	}
+
+	return l, nil
+}
+
+// Head returns the sequence number and hash of the last record in the chain.
+func (l *Log) Head() (uint64, string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.sequence, l.lastHash
+}
+
+// Verify checks records read back from the log with the log's key.
+func (l *Log) Verify(records []Record) error {
+	return Verify(records, l.key)
+}
+
+// Reader returns the first sink that can read back records, or nil if there is none.
+func (l *Log) Reader() Reader {
+	if l == nil {
+		return nil
+	}
+	if reader, ok := l.tailer.(Reader); ok {
+		return reader
+	}
+	for _, sink := range l.sinks {
+		if reader, ok := sink.(Reader); ok {
+			return reader
+		}
+	}
+	return nil
+}
+
+// Record chains a record to the previous one and writes it to all sinks.
+//
+// The chain only advances once the sink it is continued from has stored the record.
+// If that fails, the record is not written to the other sinks either, so that they hold the same chain.
+//
+// Failing to write an audit record does not fail the action being audited;
+// errors are logged instead.
+func (l *Log) Record(record Record) {
+	if l == nil || l.tailer == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	record.Sequence = l.sequence + 1
+	record.Time = time.Now().UTC()
+	record.PreviousHash = l.lastHash
+	record.Hash = record.ComputeHash(l.key)
+
+	logger := log.WithFields(log.Fields{
+		"audit_action":   record.Action,
+		"audit_sequence": record.Sequence,
+	})
+
+	err := l.tailer.Write(record)
+	if err != nil {
+		logger.Errorf("Unable to write audit record: %s", err)
+		return
+	}
+
+	l.sequence = record.Sequence
+	l.lastHash = record.Hash
+
+	for _, sink := range l.sinks {
+		err := sink.Write(record)
+		if err != nil {
+			logger.Errorf("Unable to write audit record: %s", err)
+		}
+	}
+}
+
+// Verify checks that each record's hash is correct for the key, and that the records form an unbroken chain.
+// The first record must be the start of a chain, with sequence number 1 and no previous hash.
+//
+// Records removed from the end of the log can not be detected this way; use VerifyAnchor for that.
+func Verify(records []Record, key []byte) error {
+	previous := ""
+	for i, record := range records {
+		if record.Sequence != uint64(i+1) {
+			return fmt.Errorf("record %d: expected sequence number %d, got %d", i+1, i+1, record.Sequence)
+		}
+		if record.PreviousHash != previous {
+			return fmt.Errorf("record %d: previous hash does not match; records have been removed or reordered", record.Sequence)
+		}
+		if record.ComputeHash(key) != record.Hash {
+			return fmt.Errorf("record %d: hash does not match contents; record has been modified, or was written with another key", record.Sequence)
+		}
+		previous = record.Hash
+	}
+	return nil
+}
+
+// VerifyAnchor checks that the records contain a record with the given hash, such as the head of the chain
+// noted down at some earlier point. This detects records removed from the end of the log, up to that point.
+// The records should be verified with Verify first.
+func VerifyAnchor(records []Record, anchor string) error {
+	for _, record := range records {
+		if record.Hash == anchor {
+			return nil
+		}
+	}
+	return fmt.Errorf("no record with hash %s; records have been removed from the end of the log", anchor)
+}
@@ -0,0 +1,169 @@
+package audit_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+var key = []byte("audit key")
+
+func writeRecords(t *testing.T, path string) {
+	log, err := audit.New(key, audit.NewFileSink(path))
+	assert.NoError(t, err)
+
+	log.Record(audit.Record{Actor: "team:aura", Action: audit.ActionDeploy, Target: "navikt/deployment", Outcome: audit.OutcomeSuccess})
+	log.Record(audit.Record{Actor: "admin", Action: audit.ActionFreezeCreate, Target: "prod-fss", Outcome: audit.OutcomeSuccess})
+	log.Record(audit.Record{Actor: "team:aura", Action: audit.ActionAuthenticate, Target: "aura", Outcome: audit.OutcomeDenied})
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	sink := audit.NewFileSink(path)
+
+	writeRecords(t, path)
+
+	t.Run("records form a valid chain", func(t *testing.T) {
+		records, err := sink.Records()
+		assert.NoError(t, err)
+		assert.Len(t, records, 3)
+		assert.NoError(t, audit.Verify(records, key))
+		assert.Equal(t, records[0].Hash, records[1].PreviousHash)
+	})
+
+	t.Run("chain is continued after restart", func(t *testing.T) {
+		writeRecords(t, path)
+		records, err := sink.Records()
+		assert.NoError(t, err)
+		assert.Len(t, records, 6)
+		assert.NoError(t, audit.Verify(records, key))
+	})
+
+	t.Run("modified records are detected", func(t *testing.T) {
+		records, err := sink.Records()
+		assert.NoError(t, err)
+		records[1].Outcome = audit.OutcomeFailure
+		assert.Error(t, audit.Verify(records, key))
+	})
+
+	t.Run("removed records are detected", func(t *testing.T) {
+		records, err := sink.Records()
+		assert.NoError(t, err)
+		records = append(records[:2], records[3:]...)
+		assert.Error(t, audit.Verify(records, key))
+	})
+
+	t.Run("rewritten chains are detected", func(t *testing.T) {
+		records, err := sink.Records()
+		assert.NoError(t, err)
+		records[2].Outcome = audit.OutcomeSuccess
+		records[2].Hash = records[2].ComputeHash(key)
+		assert.Error(t, audit.Verify(records, key))
+	})
+
+	t.Run("rewritten tails are detected without the key", func(t *testing.T) {
+		records, err := sink.Records()
+		assert.NoError(t, err)
+		records[2].Outcome = audit.OutcomeSuccess
+		for i := 2; i < len(records); i++ {
+			records[i].PreviousHash = records[i-1].Hash
+			records[i].Hash = records[i].ComputeHash(nil)
+		}
+		assert.Error(t, audit.Verify(records, key))
+
+		for i := 2; i < len(records); i++ {
+			records[i].PreviousHash = records[i-1].Hash
+			records[i].Hash = records[i].ComputeHash([]byte("guessed key"))
+		}
+		assert.Error(t, audit.Verify(records, key))
+	})
+
+	t.Run("truncated tails are detected with an anchor", func(t *testing.T) {
+		records, err := sink.Records()
+		assert.NoError(t, err)
+		anchor := records[4].Hash
+		assert.NoError(t, audit.VerifyAnchor(records, anchor))
+
+		records = records[:4]
+		assert.NoError(t, audit.Verify(records, key), "a truncated chain is still a valid chain")
+		assert.Error(t, audit.VerifyAnchor(records, anchor))
+	})
+
+	t.Run("chain is not continued with another key", func(t *testing.T) {
+		_, err := audit.New([]byte("another key"), sink)
+		assert.Error(t, err)
+	})
+
+	t.Run("records can be queried", func(t *testing.T) {
+		records, err := audit.Query(sink, audit.Filter{Actor: "team:aura"})
+		assert.NoError(t, err)
+		assert.Len(t, records, 4)
+
+		records, err = audit.Query(sink, audit.Filter{Actor: "team:aura", Outcome: audit.OutcomeDenied, Limit: 1})
+		assert.NoError(t, err)
+		assert.Len(t, records, 1)
+		assert.Equal(t, uint64(6), records[0].Sequence)
+	})
+}
+
+func TestNilLog(t *testing.T) {
+	var log *audit.Log
+	log.Record(audit.Record{Action: audit.ActionDeploy})
+	assert.Nil(t, log.Reader())
+}
+
+// flakySink is a tailer that fails to write while failing is set.
+type flakySink struct {
+	failing bool
+	records []audit.Record
+}
+
+func (s *flakySink) Write(record audit.Record) error {
+	if s.failing {
+		return fmt.Errorf("disk full")
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *flakySink) Last() (*audit.Record, error) {
+	if len(s.records) == 0 {
+		return nil, nil
+	}
+	return &s.records[len(s.records)-1], nil
+}
+
+func TestFailedWrite(t *testing.T) {
+	tailer := &flakySink{}
+	other := &flakySink{}
+	log, err := audit.New(key, tailer, other)
+	assert.NoError(t, err)
+
+	log.Record(audit.Record{Action: audit.ActionDeploy})
+	tailer.failing = true
+	log.Record(audit.Record{Action: audit.ActionCancel})
+	tailer.failing = false
+	log.Record(audit.Record{Action: audit.ActionDeploy})
+
+	assert.Len(t, tailer.records, 2)
+	assert.NoError(t, audit.Verify(tailer.records, key), "chain does not advance past records that were not stored")
+	assert.Equal(t, tailer.records, other.records, "other sinks get the same chain")
+}
+
+func TestKafkaOnly(t *testing.T) {
+	_, err := audit.New(key, &audit.KafkaSink{})
+	assert.Equal(t, audit.ErrNoTailer, err)
+
+	log, err := audit.New(key)
+	assert.NoError(t, err, "audit log can be disabled")
+	log.Record(audit.Record{Action: audit.ActionDeploy})
+}
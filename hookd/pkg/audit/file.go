@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSink appends audit records to a file, one JSON object per line.
+type FileSink struct {
+	lock sync.Mutex
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{
+		path: path,
+	}
+}
+
+func (s *FileSink) Write(record Record) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal audit record: %s", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %s", err)
+	}
+
+	_, err = file.Write(append(payload, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("write audit log: %s", err)
+	}
+
+	return file.Close()
+}
+
+// Records reads all records in the file. A missing file contains no records.
+func (s *FileSink) Records() ([]Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records := make([]Record, 0)
+
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return records, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open audit log: %s", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		record := Record{}
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf("audit log line %d: %s", line, err)
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %s", err)
+	}
+
+	return records, nil
+}
+
+// Last returns the last record in the file, or nil if the file is empty.
+func (s *FileSink) Last() (*Record, error) {
+	records, err := s.Records()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[len(records)-1], nil
+}
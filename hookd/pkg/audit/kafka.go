@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// All records are produced with the same key, so that they end up
+// on the same partition and keep their order.
+const kafkaMessageKey = "audit"
+
+// KafkaSink produces audit records to a Kafka topic, as JSON.
+//
+// Records cannot be read back from Kafka by hookd, so this sink must be combined with a file sink,
+// which the hash chain is continued from when hookd restarts.
+type KafkaSink struct {
+	Producer sarama.SyncProducer
+	Topic    string
+}
+
+func (s *KafkaSink) Write(record Record) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal audit record: %s", err)
+	}
+
+	_, _, err = s.Producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.Topic,
+		Key:   sarama.StringEncoder(kafkaMessageKey),
+		Value: sarama.ByteEncoder(payload),
+	})
+	if err != nil {
+		return fmt.Errorf("produce audit record: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"time"
+)
+
+// Filter selects audit records. Empty fields match all records.
+type Filter struct {
+	Actor         string    `json:"actor,omitempty"`
+	Action        string    `json:"action,omitempty"`
+	Target        string    `json:"target,omitempty"`
+	Outcome       string    `json:"outcome,omitempty"`
+	CorrelationID string    `json:"correlationID,omitempty"`
+	Since         time.Time `json:"since,omitempty"`
+	Until         time.Time `json:"until,omitempty"`
+	// Limit returns only the most recent matching records. Zero means no limit.
+	Limit int `json:"limit,omitempty"`
+}
+
+func (f Filter) Match(r Record) bool {
+	switch {
+	case len(f.Actor) > 0 && f.Actor != r.Actor:
+	case len(f.Action) > 0 && f.Action != r.Action:
+	case len(f.Target) > 0 && f.Target != r.Target:
+	case len(f.Outcome) > 0 && f.Outcome != r.Outcome:
+	case len(f.CorrelationID) > 0 && f.CorrelationID != r.CorrelationID:
+	case !f.Since.IsZero() && r.Time.Before(f.Since):
+	case !f.Until.IsZero() && r.Time.After(f.Until):
+	default:
+		return true
+	}
+	return false
+}
+
+// Apply returns the records matching the filter, in their original order.
+func (f Filter) Apply(records []Record) []Record {
+	matches := make([]Record, 0)
+	for _, record := range records {
+		if f.Match(record) {
+			matches = append(matches, record)
+		}
+	}
+
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[len(matches)-f.Limit:]
+	}
+
+	return matches
+}
+
+// Query returns all records matching the filter, oldest first.
+func Query(reader Reader, filter Filter) ([]Record, error) {
+	records, err := reader.Records()
+	if err != nil {
+		return nil, err
+	}
+	return filter.Apply(records), nil
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	gh "github.com/google/go-github/v27/github"
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/apikey"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+type ApiKeysHandler struct {
+	APIKeyStorage persistence.ApiKeyStorage
+	Organization  string
+	AuditLog      *audit.Log
+}
+
+type ApiKeysData struct {
+	User      *gh.User
+	Teams     []apikey.Team
+	CSRFToken string
+	// Team and Key are only set right after a new key has been created.
+	Team  string
+	Key   string
+	Error string
+}
+
+// ServeHTTP lists the teams maintained by the user and their API key status,
+// and rotates or revokes a team's API key on POST.
+func (h *ApiKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := r.Cookie("accessToken")
+
+	if err != nil || len(accessToken.Value) == 0 {
+		http.Redirect(w, r, "/auth/logout", http.StatusFound)
+		return
+	}
+
+	client := userClient(accessToken.Value)
+	user, err := getAuthenticatedUser(client)
+
+	if err != nil {
+		log.Error(err)
+		http.Redirect(w, r, "/auth/logout", http.StatusFound)
+		return
+	}
+
+	data := ApiKeysData{
+		User:      user,
+		CSRFToken: csrfToken(r),
+	}
+
+	maintained, err := apikey.MaintainedTeams(r.Context(), client, h.Organization, user.GetLogin())
+	if err != nil {
+		log.Error(err)
+		data.Error = err.Error()
+	}
+
+	if err == nil && r.Method == http.MethodPost {
+		err = h.manage(r, &data, maintained)
+		if err != nil {
+			log.Error(err)
+			data.Error = err.Error()
+		}
+	}
+
+	data.Teams, err = apikey.List(h.APIKeyStorage, maintained)
+	if err != nil {
+		log.Error(err)
+		data.Error = err.Error()
+	}
+
+	page, err := templateWithBase("apikeys.html")
+	if err != nil {
+		log.Errorf("error while parsing page templates: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// The page may contain a newly created API key.
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err = page.Execute(w, data); err != nil {
+		log.Errorf("error while serving page: %s", err)
+	}
+}
+
+func (h *ApiKeysHandler) manage(r *http.Request, data *ApiKeysData, maintained []string) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+
+	err = checkCSRF(r)
+	if err != nil {
+		return err
+	}
+
+	team := r.Form.Get("team")
+	action := r.Form.Get("action")
+	login := data.User.GetLogin()
+
+	var rotate bool
+	switch action {
+	case "rotate":
+		rotate = true
+	case "revoke":
+		rotate = false
+	default:
+		return apikey.ErrUnknownAction
+	}
+
+	correlationID := middleware.CorrelationID(r)
+
+	err = apikey.Authorize(maintained, team)
+	if err != nil {
+		h.AuditLog.Record(apikey.AuditRecord(login, team, rotate, audit.OutcomeDenied, correlationID))
+		return err
+	}
+
+	if rotate {
+		var key []byte
+		key, err = apikey.Rotate(h.APIKeyStorage, team)
+		if err == nil {
+			data.Team = team
+			data.Key = hex.EncodeToString(key)
+		}
+		action = "rotated"
+	} else {
+		err = apikey.Revoke(h.APIKeyStorage, team)
+		action = "revoked"
+	}
+
+	if err != nil {
+		h.AuditLog.Record(apikey.AuditRecord(login, team, rotate, audit.OutcomeFailure, correlationID))
+		return err
+	}
+
+	h.AuditLog.Record(apikey.AuditRecord(login, team, rotate, audit.OutcomeSuccess, correlationID))
+
+	log.WithFields(log.Fields{
+		types.LogFieldTeam:   team,
+		api_v1.LogFieldUser:  login,
+		api_v1.LogFieldAudit: true,
+	}).Infof("Team API key %s by '%s' through web portal", action, login)
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"net/http"
+
+	gh "github.com/google/go-github/v27/github"
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	log "github.com/sirupsen/logrus"
+)
+
+type ApprovalsHandler struct {
+	Gate              *approval.Gate
+	ApplicationClient *gh.Client
+	AuditLog          *audit.Log
+}
+
+type ApprovalsData struct {
+	User      *gh.User
+	Pending   []approval.Pending
+	CSRFToken string
+	Error     string
+}
+
+// ServeHTTP lists deployment requests waiting for approval, and approves or rejects them on POST.
+func (h *ApprovalsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := r.Cookie("accessToken")
+
+	if err != nil || len(accessToken.Value) == 0 {
+		http.Redirect(w, r, "/auth/logout", http.StatusFound)
+		return
+	}
+
+	user, err := getAuthenticatedUser(userClient(accessToken.Value))
+
+	if err != nil {
+		log.Error(err)
+		http.Redirect(w, r, "/auth/logout", http.StatusFound)
+		return
+	}
+
+	data := ApprovalsData{
+		User:      user,
+		CSRFToken: csrfToken(r),
+	}
+
+	if r.Method == http.MethodPost {
+		err = h.decide(r, user.GetLogin())
+		if err != nil {
+			log.Error(err)
+			data.Error = err.Error()
+		}
+	}
+
+	data.Pending = h.Gate.List()
+
+	page, err := templateWithBase("approvals.html")
+	if err != nil {
+		log.Errorf("error while parsing page templates: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err = page.Execute(w, data); err != nil {
+		log.Errorf("error while serving page: %s", err)
+	}
+}
+
+func (h *ApprovalsHandler) decide(r *http.Request, login string) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+
+	err = checkCSRF(r)
+	if err != nil {
+		return err
+	}
+
+	id := r.Form.Get("id")
+	action := r.Form.Get("action")
+
+	pending, err := h.Gate.Get(id)
+	if err != nil {
+		return err
+	}
+
+	var approve bool
+	switch action {
+	case "approve":
+		approve = true
+	case "reject":
+		approve = false
+	default:
+		return approval.ErrUnknownAction
+	}
+
+	err = approval.Authorize(h.ApplicationClient, pending, login, approve)
+	if err == approval.ErrNotMaintainer || err == approval.ErrSelfApproval {
+		h.AuditLog.Record(approval.AuditRecord(login, pending, approve, audit.OutcomeDenied))
+	}
+	if err != nil {
+		return err
+	}
+
+	if approve {
+		err = h.Gate.Approve(id, login)
+	} else {
+		err = h.Gate.Reject(id, login)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	h.AuditLog.Record(approval.AuditRecord(login, pending, approve, audit.OutcomeSuccess))
+
+	log.WithFields(log.Fields{
+		types.LogFieldDeliveryID: id,
+		types.LogFieldTeam:       pending.Team,
+		types.LogFieldCluster:    pending.Cluster,
+		types.LogFieldRepository: pending.Repository,
+		api_v1.LogFieldApprover:  login,
+		api_v1.LogFieldAudit:     true,
+	}).Infof("Deployment request %sd by '%s' through web portal", action, login)
+
+	return nil
+}
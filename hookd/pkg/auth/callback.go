@@ -1,11 +1,13 @@
 package auth
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 type CallbackHandler struct {
@@ -69,12 +71,20 @@ func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	accessToken := parsedBody.Get("access_token")
 
-	cookie := http.Cookie{
-		Name:    "accessToken",
-		Value:   accessToken,
-		Expires: time.Now().Add(1 * time.Hour),
-		Path:    "/",
+	err = setSessionCookies(w, accessToken)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
-	http.SetCookie(w, &cookie)
-	http.Redirect(w, r, "/auth/form", http.StatusFound)
+
+	// Browsers do not send SameSite=Strict cookies when following a redirect from GitHub,
+	// so continue with a navigation that starts on this site.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, callbackPage)
 }
+
+const callbackPage = `<!DOCTYPE html>
+<html><head><meta http-equiv="refresh" content="0;url=/auth/form"></head>
+<body><a href="/auth/form">Continue</a></body></html>
+`
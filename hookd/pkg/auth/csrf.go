@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	csrfCookieName = "csrfToken"
+	csrfFormField  = "csrf"
+)
+
+var ErrCSRFToken = fmt.Errorf("the form has expired or was not submitted from this site; reload the page and try again")
+
+// setSessionCookies stores the access token of a new session, together with a random token that
+// must be submitted with every form, so that other sites can not post forms on the user's behalf.
+func setSessionCookies(w http.ResponseWriter, accessToken string) error {
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("generate CSRF token: %s", err)
+	}
+
+	expires := time.Now().Add(1 * time.Hour)
+
+	for name, value := range map[string]string{"accessToken": accessToken, csrfCookieName: token.String()} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Expires:  expires,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+
+	return nil
+}
+
+// csrfToken returns the CSRF token of the session, to be rendered into forms.
+func csrfToken(r *http.Request) string {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// checkCSRF verifies that a submitted form carries the CSRF token of the session.
+// The form must already be parsed.
+func checkCSRF(r *http.Request) error {
+	expected := csrfToken(r)
+	submitted := r.PostForm.Get(csrfFormField)
+	if len(expected) == 0 || subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) != 1 {
+		return ErrCSRFToken
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi"
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/hookd/pkg/cache"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/logproxy"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ logproxy.Authenticator = &DeploymentsHandler{}
+
+// deploymentsLimit is the number of deployments shown on the dashboard.
+const deploymentsLimit = 50
+
+// deploymentsRefreshInterval is how often the dashboard reloads while a deployment is running, in seconds.
+const deploymentsRefreshInterval = 5
+
+// DeploymentsHandler shows a read-only dashboard of the deployments made by the user's teams.
+//
+// The signed in user and their teams are looked up on GitHub, at GithubURL if set.
+// If Cache is set, the lookups are cached for each access token, as the dashboard
+// and the log viewer reload themselves while a deployment is running.
+type DeploymentsHandler struct {
+	History      history.Store
+	Organization string
+	BaseURL      string
+	GithubURL    string
+	Cache        *cache.Cache
+}
+
+// session is the GitHub user signed in with an access token, and the teams they are a member of.
+type session struct {
+	user  *gh.User
+	teams []string
+}
+
+type DeploymentView struct {
+	history.Deployment
+	LogURL string
+}
+
+// Duration is the time from the deployment was created until its last status, rounded to seconds.
+func (d DeploymentView) Duration() time.Duration {
+	return d.Updated.Sub(d.Created).Round(time.Second)
+}
+
+type DeploymentsData struct {
+	User        *gh.User
+	Teams       []string
+	Team        string
+	Deployments []DeploymentView
+	Deployment  *DeploymentView
+	Refresh     int
+	Error       string
+}
+
+// userTeams returns the slugs of the teams in the organization that the authenticated user is a member of.
+func userTeams(ctx context.Context, client *gh.Client, organization string) ([]string, error) {
+	opt := &gh.ListOptions{
+		PerPage: 100,
+	}
+
+	teams := make([]string, 0)
+
+	for {
+		page, resp, err := client.Teams.ListUserTeams(ctx, opt)
+		if err != nil {
+			return nil, fmt.Errorf("list user teams: %s", err)
+		}
+
+		for _, team := range page {
+			if team.GetOrganization().GetLogin() == organization {
+				teams = append(teams, team.GetSlug())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	sort.Strings(teams)
+
+	return teams, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *DeploymentsHandler) view(d history.Deployment) DeploymentView {
+	return DeploymentView{
+		Deployment: d,
+		LogURL:     logproxy.MakeURL(h.BaseURL, d.DeliveryID, d.Created),
+	}
+}
+
+func (h *DeploymentsHandler) client(accessToken string) (*gh.Client, error) {
+	client := userClient(accessToken)
+	if len(h.GithubURL) == 0 {
+		return client, nil
+	}
+
+	baseURL, err := url.Parse(h.GithubURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse GitHub URL: %s", err)
+	}
+	client.BaseURL = baseURL
+
+	return client, nil
+}
+
+// lookup returns the user signed in with an access token, and their teams.
+// If the teams could not be listed, the session holds the user only, and an error is returned.
+func (h *DeploymentsHandler) lookup(ctx context.Context, accessToken string) (*session, error) {
+	client, err := h.client(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getAuthenticatedUser(client)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := userTeams(ctx, client, h.Organization)
+
+	return &session{user: user, teams: teams}, err
+}
+
+// cachedLookup is lookup, cached by a hash of the access token.
+// Failed lookups are not cached, so that a user whose teams could not be listed gets a new attempt on the next request.
+func (h *DeploymentsHandler) cachedLookup(ctx context.Context, accessToken string) (*session, error) {
+	if h.Cache == nil {
+		return h.lookup(ctx, accessToken)
+	}
+
+	hash := sha256.Sum256([]byte(accessToken))
+	value, err := h.Cache.Get(hex.EncodeToString(hash[:]), func() (interface{}, error) {
+		return h.lookup(ctx, accessToken)
+	})
+
+	s, _ := value.(*session)
+	return s, err
+}
+
+// authenticate returns the authenticated user and the teams they are a member of,
+// or redirects to the logout page if the user is not signed in.
+func (h *DeploymentsHandler) authenticate(w http.ResponseWriter, r *http.Request) (*DeploymentsData, bool) {
+	accessToken, err := r.Cookie("accessToken")
+
+	if err != nil || len(accessToken.Value) == 0 {
+		http.Redirect(w, r, "/auth/logout", http.StatusFound)
+		return nil, false
+	}
+
+	s, err := h.cachedLookup(r.Context(), accessToken.Value)
+
+	if s == nil {
+		log.Error(err)
+		http.Redirect(w, r, "/auth/logout", http.StatusFound)
+		return nil, false
+	}
+
+	data := &DeploymentsData{
+		User:  s.user,
+		Teams: s.teams,
+	}
+
+	if err != nil {
+		log.Error(err)
+		data.Error = err.Error()
+	}
+
+	return data, true
+}
+
+// Teams returns the teams of the signed in user, so that the log viewer only shows logs to members of the deployment's team.
+func (h *DeploymentsHandler) Teams(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	data, ok := h.authenticate(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if len(data.Error) > 0 {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "unable to look up your teams\n")
+		return nil, false
+	}
+
+	return data.Teams, true
+}
+
+func (h *DeploymentsHandler) render(w http.ResponseWriter, name string, data *DeploymentsData) {
+	page, err := templateWithBase(name)
+	if err != nil {
+		log.Errorf("error while parsing page templates: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err = page.Execute(w, data); err != nil {
+		log.Errorf("error while serving page: %s", err)
+	}
+}
+
+// List shows the latest deployments of the user's teams, or of one of them if the team query parameter is set.
+// The page reloads itself while any of the deployments is running.
+func (h *DeploymentsHandler) List(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	teams := data.Teams
+	if team := r.URL.Query().Get("team"); len(team) > 0 {
+		if !contains(data.Teams, team) {
+			w.WriteHeader(http.StatusForbidden)
+			data.Error = fmt.Sprintf("you are not a member of team '%s'", team)
+			h.render(w, "deployments.html", data)
+			return
+		}
+		data.Team = team
+		teams = []string{team}
+	}
+
+	deployments := make([]history.Deployment, 0)
+	for _, team := range teams {
+		list, err := h.History.List(history.Query{Team: team, Limit: deploymentsLimit})
+		if err != nil {
+			log.Errorf("read deployment history: %s", err)
+			data.Error = "unable to read deployment history"
+			break
+		}
+		deployments = append(deployments, list...)
+	}
+
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Created.After(deployments[j].Created)
+	})
+	if len(deployments) > deploymentsLimit {
+		deployments = deployments[:deploymentsLimit]
+	}
+
+	for _, d := range deployments {
+		data.Deployments = append(data.Deployments, h.view(d))
+		if !d.Finished() {
+			data.Refresh = deploymentsRefreshInterval
+		}
+	}
+
+	h.render(w, "deployments.html", data)
+}
+
+// Get shows the status timeline, resources and log link of a deployment made by one of the user's teams.
+// The page reloads itself until the deployment has finished.
+func (h *DeploymentsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	data, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	// Deployments belonging to other teams are reported as missing, so that their existence is not revealed.
+	deployment, err := h.History.Get(id)
+	if err == history.ErrNotFound || (err == nil && !contains(data.Teams, deployment.Team)) {
+		w.WriteHeader(http.StatusNotFound)
+		data.Error = fmt.Sprintf("deployment %s does not exist", id)
+		h.render(w, "deployment.html", data)
+		return
+	} else if err != nil {
+		log.Errorf("read deployment history: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.Error = "unable to read deployment history"
+		h.render(w, "deployment.html", data)
+		return
+	}
+
+	view := h.view(*deployment)
+	data.Deployment = &view
+	if !deployment.Finished() {
+		data.Refresh = deploymentsRefreshInterval
+	}
+
+	h.render(w, "deployment.html", data)
+}
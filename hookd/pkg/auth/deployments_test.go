@@ -0,0 +1,190 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/auth"
+	"github.com/navikt/deployment/hookd/pkg/cache"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGithub serves the authenticated user and their teams.
+// The user signed in with the access token "teamless" can not list their teams.
+type fakeGithub struct {
+	lock     sync.Mutex
+	requests map[string]int
+}
+
+func (g *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.lock.Lock()
+	g.requests[r.URL.Path]++
+	g.lock.Unlock()
+
+	switch r.URL.Path {
+	case "/user":
+		json.NewEncoder(w).Encode(map[string]string{"login": "alice", "name": "Alice"})
+	case "/user/teams":
+		if r.Header.Get("authorization") == "Bearer teamless" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"slug": "aura", "organization": map[string]string{"login": "navikt"}},
+			{"slug": "elsewhere", "organization": map[string]string{"login": "other"}},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (g *fakeGithub) count(path string) int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.requests[path]
+}
+
+type harness struct {
+	server  *httptest.Server
+	github  *fakeGithub
+	handler *auth.DeploymentsHandler
+	router  chi.Router
+}
+
+func newHarness(t *testing.T, dir string, c *cache.Cache) *harness {
+	auth.TemplateLocation = "../../templates/"
+
+	historyStore, err := history.NewFileStore(filepath.Join(dir, "history.json"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []deployment.DeploymentStatus{
+		{DeliveryID: "aura-deployment", Team: "aura", Cluster: "dev-fss", State: deployment.GithubDeploymentState_success},
+		{DeliveryID: "other-deployment", Team: "other", Cluster: "dev-fss", State: deployment.GithubDeploymentState_success},
+	} {
+		if err := historyStore.Add(status, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	github := &fakeGithub{requests: make(map[string]int)}
+	server := httptest.NewServer(github)
+
+	handler := &auth.DeploymentsHandler{
+		History:      historyStore,
+		Organization: "navikt",
+		BaseURL:      "https://deployment.nais.io",
+		GithubURL:    server.URL + "/",
+		Cache:        c,
+	}
+
+	router := chi.NewRouter()
+	router.Get("/deployments", handler.List)
+	router.Get("/deployments/{id}", handler.Get)
+
+	return &harness{server: server, github: github, handler: handler, router: router}
+}
+
+func (h *harness) get(path, accessToken string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if len(accessToken) > 0 {
+		request.AddCookie(&http.Cookie{Name: "accessToken", Value: accessToken})
+	}
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "deployments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestDeploymentsList(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	h := newHarness(t, dir, nil)
+	defer h.server.Close()
+
+	recorder := h.get("/deployments", "")
+	assert.Equal(t, http.StatusFound, recorder.Code)
+
+	recorder = h.get("/deployments", "token")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "aura-deployment")
+	assert.NotContains(t, recorder.Body.String(), "other-deployment")
+
+	// Teams in other organizations do not count.
+	recorder = h.get("/deployments?team=elsewhere", "token")
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "you are not a member of team &#39;elsewhere&#39;")
+
+	recorder = h.get("/deployments?team=other", "token")
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "other-deployment")
+}
+
+func TestDeploymentsGet(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	h := newHarness(t, dir, nil)
+	defer h.server.Close()
+
+	recorder := h.get("/deployments/aura-deployment", "token")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "aura-deployment")
+
+	// Deployments of other teams are indistinguishable from deployments that do not exist.
+	recorder = h.get("/deployments/other-deployment", "token")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "deployment other-deployment does not exist")
+
+	recorder = h.get("/deployments/unknown", "token")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "deployment unknown does not exist")
+}
+
+func TestDeploymentsTeams(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	h := newHarness(t, dir, cache.New("github_users", time.Minute, 0, nil))
+	defer h.server.Close()
+
+	teams := func(accessToken string) (*httptest.ResponseRecorder, []string, bool) {
+		request := httptest.NewRequest(http.MethodGet, "/logs", nil)
+		request.AddCookie(&http.Cookie{Name: "accessToken", Value: accessToken})
+		recorder := httptest.NewRecorder()
+		teams, ok := h.handler.Teams(recorder, request)
+		return recorder, teams, ok
+	}
+
+	for i := 0; i < 2; i++ {
+		_, list, ok := teams("token")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"aura"}, list)
+	}
+	assert.Equal(t, 1, h.github.count("/user"))
+	assert.Equal(t, 1, h.github.count("/user/teams"))
+
+	// Failed team lookups are not cached.
+	for i := 0; i < 2; i++ {
+		recorder, _, ok := teams("teamless")
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadGateway, recorder.Code)
+	}
+	assert.Equal(t, 3, h.github.count("/user"))
+}
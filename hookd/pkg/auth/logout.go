@@ -7,7 +7,7 @@ import (
 type LogoutHandler struct{}
 
 func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	for _, cookieName := range []string{"accessToken", "authState"} {
+	for _, cookieName := range []string{"accessToken", csrfCookieName, "authState"} {
 		http.SetCookie(w, &http.Cookie{
 			Name:   cookieName,
 			Value:  "",
@@ -2,8 +2,11 @@ package auth
 
 import (
 	"net/http"
+	"strings"
 
 	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/middleware"
 	"github.com/navikt/deployment/hookd/pkg/persistence"
 	log "github.com/sirupsen/logrus"
 )
@@ -13,6 +16,7 @@ type SubmittedFormHandler struct {
 	userClient            *gh.Client
 	ApplicationClient     *gh.Client
 	TeamRepositoryStorage persistence.TeamRepositoryStorage
+	AuditLog              *audit.Log
 }
 
 type SubmittedFormData struct {
@@ -62,6 +66,7 @@ func (h *SubmittedFormHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	// check that the user submitted only teams that they can administer
 	err = teamListsMatch(teamNames, teams)
 	if err != nil {
+		h.audit(r, user.GetLogin(), fullName, teamNames, audit.OutcomeDenied)
 		log.Error(err)
 		w.WriteHeader(http.StatusForbidden)
 		return
@@ -69,11 +74,14 @@ func (h *SubmittedFormHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 	err = h.TeamRepositoryStorage.Write(fullName, teamNames)
 	if err != nil {
+		h.audit(r, user.GetLogin(), fullName, teamNames, audit.OutcomeFailure)
 		log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	h.audit(r, user.GetLogin(), fullName, teamNames, audit.OutcomeSuccess)
+
 	log.Infof("The repository '%s' has been granted deployment access by Github user '%s' for the following teams: %+v", fullName, user.GetLogin(), teamNames)
 
 	data := SubmittedFormData{
@@ -99,3 +107,16 @@ func (h *SubmittedFormHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		log.Errorf("error while serving page: %s", err)
 	}
 }
+
+func (h *SubmittedFormHandler) audit(r *http.Request, login, repository string, teams []string, outcome string) {
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.UserActor(login),
+		Action:        audit.ActionRepositoryGrant,
+		Target:        repository,
+		Outcome:       outcome,
+		CorrelationID: middleware.CorrelationID(r),
+		Details: map[string]string{
+			"teams": strings.Join(teams, ","),
+		},
+	})
+}
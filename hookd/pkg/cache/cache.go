@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/metrics"
+)
+
+const (
+	ResultHit         = "hit"
+	ResultNegativeHit = "negative_hit"
+	ResultMiss        = "miss"
+)
+
+// Loader fetches a value that is not present in the cache.
+type Loader func() (interface{}, error)
+
+type entry struct {
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// Cache keeps the results of slow lookups in memory for a limited time.
+//
+// Successful lookups are kept for TTL. Failed lookups are kept for NegativeTTL
+// if the error is considered permanent by IsNegative, e.g. "not found" errors.
+// Other errors are never cached. A TTL of zero disables caching of that kind.
+//
+// A lookup that is in progress while its key is invalidated, or the cache purged,
+// returns its result without caching it, as the result may be outdated.
+type Cache struct {
+	Name        string
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	IsNegative  func(err error) bool
+
+	lock        sync.Mutex
+	entries     map[string]entry
+	generations map[string]uint64
+	epoch       uint64
+}
+
+func New(name string, ttl, negativeTTL time.Duration, isNegative func(err error) bool) *Cache {
+	return &Cache{
+		Name:        name,
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		IsNegative:  isNegative,
+		entries:     make(map[string]entry),
+		generations: make(map[string]uint64),
+	}
+}
+
+// Get returns the cached result for key, or calls load and caches its result.
+func (c *Cache) Get(key string, load Loader) (interface{}, error) {
+	now := time.Now()
+
+	c.lock.Lock()
+	cached, ok := c.entries[key]
+	if ok && now.After(cached.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	generation, epoch := c.generations[key], c.epoch
+	c.lock.Unlock()
+
+	if ok {
+		if cached.err != nil {
+			metrics.CacheLookup(c.Name, ResultNegativeHit)
+		} else {
+			metrics.CacheLookup(c.Name, ResultHit)
+		}
+		return cached.value, cached.err
+	}
+
+	metrics.CacheLookup(c.Name, ResultMiss)
+
+	value, err := load()
+
+	var ttl time.Duration
+	switch {
+	case err == nil:
+		ttl = c.TTL
+	case c.IsNegative != nil && c.IsNegative(err):
+		ttl = c.NegativeTTL
+	}
+
+	if ttl > 0 {
+		c.lock.Lock()
+		if c.generations[key] == generation && c.epoch == epoch {
+			c.entries[key] = entry{
+				value:   value,
+				err:     err,
+				expires: now.Add(ttl),
+			}
+		}
+		c.lock.Unlock()
+	}
+
+	return value, err
+}
+
+// Invalidate removes a single entry from the cache, and discards the result of any lookup of it in progress.
+func (c *Cache) Invalidate(key string) {
+	c.lock.Lock()
+	delete(c.entries, key)
+	c.generations[key]++
+	c.lock.Unlock()
+}
+
+// Purge removes all entries from the cache, and discards the results of all lookups in progress.
+func (c *Cache) Purge() {
+	c.lock.Lock()
+	c.entries = make(map[string]entry)
+	c.generations = make(map[string]uint64)
+	c.epoch++
+	c.lock.Unlock()
+}
+
+// Len returns the number of cached entries, including expired entries not yet removed.
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries)
+}
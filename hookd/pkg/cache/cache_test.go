@@ -0,0 +1,152 @@
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errNotFound    = fmt.Errorf("not found")
+	errUnavailable = fmt.Errorf("unavailable")
+)
+
+func isNotFound(err error) bool {
+	return err == errNotFound
+}
+
+type loader struct {
+	calls int
+	value interface{}
+	err   error
+}
+
+func (l *loader) load() (interface{}, error) {
+	l.calls++
+	return l.value, l.err
+}
+
+func TestCache(t *testing.T) {
+	t.Run("successful lookups are cached", func(t *testing.T) {
+		c := cache.New("test", time.Minute, time.Minute, isNotFound)
+		l := &loader{value: "foo"}
+
+		for i := 0; i < 3; i++ {
+			value, err := c.Get("key", l.load)
+			assert.NoError(t, err)
+			assert.Equal(t, "foo", value)
+		}
+		assert.Equal(t, 1, l.calls)
+	})
+
+	t.Run("negative lookups are cached", func(t *testing.T) {
+		c := cache.New("test", time.Minute, time.Minute, isNotFound)
+		l := &loader{err: errNotFound}
+
+		for i := 0; i < 3; i++ {
+			_, err := c.Get("key", l.load)
+			assert.Equal(t, errNotFound, err)
+		}
+		assert.Equal(t, 1, l.calls)
+	})
+
+	t.Run("other errors are not cached", func(t *testing.T) {
+		c := cache.New("test", time.Minute, time.Minute, isNotFound)
+		l := &loader{err: errUnavailable}
+
+		for i := 0; i < 3; i++ {
+			_, err := c.Get("key", l.load)
+			assert.Equal(t, errUnavailable, err)
+		}
+		assert.Equal(t, 3, l.calls)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		c := cache.New("test", time.Millisecond, time.Millisecond, isNotFound)
+		l := &loader{value: "foo"}
+
+		c.Get("key", l.load)
+		time.Sleep(time.Millisecond * 5)
+		c.Get("key", l.load)
+		assert.Equal(t, 2, l.calls)
+	})
+
+	t.Run("zero TTL disables caching", func(t *testing.T) {
+		c := cache.New("test", 0, 0, isNotFound)
+		l := &loader{value: "foo"}
+
+		c.Get("key", l.load)
+		c.Get("key", l.load)
+		assert.Equal(t, 2, l.calls)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("invalidated entries are loaded again", func(t *testing.T) {
+		c := cache.New("test", time.Minute, time.Minute, isNotFound)
+		l := &loader{value: "foo"}
+
+		c.Get("key", l.load)
+		c.Invalidate("key")
+		l.value = "bar"
+
+		value, err := c.Get("key", l.load)
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", value)
+		assert.Equal(t, 2, l.calls)
+	})
+
+	t.Run("lookups in progress are not cached when invalidated", func(t *testing.T) {
+		c := cache.New("test", time.Minute, time.Minute, isNotFound)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			value, err := c.Get("key", func() (interface{}, error) {
+				close(started)
+				<-release
+				return "revoked", nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "revoked", value)
+		}()
+
+		<-started
+		c.Invalidate("key")
+		close(release)
+		<-done
+
+		l := &loader{value: "rotated"}
+		value, err := c.Get("key", l.load)
+		assert.NoError(t, err)
+		assert.Equal(t, "rotated", value)
+		assert.Equal(t, 1, l.calls)
+	})
+
+	t.Run("lookups in progress are not cached when purged", func(t *testing.T) {
+		c := cache.New("test", time.Minute, time.Minute, isNotFound)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			c.Get("key", func() (interface{}, error) {
+				close(started)
+				<-release
+				return "stale", nil
+			})
+		}()
+
+		<-started
+		c.Purge()
+		close(release)
+		<-done
+
+		assert.Equal(t, 0, c.Len())
+	})
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/navikt/deployment/common/pkg/kafka"
+	"github.com/navikt/deployment/common/pkg/tracing"
 )
 
 type S3 struct {
@@ -16,6 +19,29 @@ type S3 struct {
 	UseTLS         bool   `json:"secure"`
 }
 
+type TeamRepositories struct {
+	Backend string
+	Path    string
+}
+
+type ApiKeys struct {
+	Backend   string
+	Namespace string
+	File      string
+}
+
+type Audit struct {
+	Sinks      []string
+	File       string
+	KafkaTopic string
+	Key        string
+}
+
+type Cache struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
 type Vault struct {
 	CredentialsFile string
 	Token           string
@@ -34,21 +60,89 @@ type Github struct {
 	ApplicationID int
 	InstallID     int
 	KeyFile       string
+	Organization  string
+}
+
+type Approval struct {
+	Clusters []string
+	Timeout  time.Duration
+}
+
+type Timeouts struct {
+	RolloutMin    time.Duration
+	RolloutMax    time.Duration
+	RolloutBounds map[string]string
+	MaxTTL        time.Duration
+	DryRun        time.Duration
+	Deploy        time.Duration
+}
+
+type Notification struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	Workers     int
+	QueueSize   int
+}
+
+type Reporters struct {
+	Enabled       []string
+	FilePath      string
+	HTTPURL       string
+	MaxAttempts   map[string]int
+	RetryInterval map[string]string
+	HistorySize   int
+}
+
+type DORA struct {
+	Window time.Duration
+}
+
+type Logs struct {
+	Retention      time.Duration
+	Backend        string
+	ClusterBackend map[string]string
+	KibanaURL      string
+	KibanaIndex    string
+	LokiURL        string
+	LokiDatasource string
+	LokiSelector   string
+	URLTemplate    string
+}
+
+type Outbox struct {
+	Capacity           int
+	MaxBackoff         time.Duration
+	RequestMaxAttempts int
 }
 
 type Config struct {
-	ListenAddress string
-	LogFormat     string
-	LogLevel      string
-	BaseURL       string
-	Kafka         kafka.Config
-	S3            S3
-	Github        Github
-	Vault         Vault
-	MetricsPath   string
-	Clusters      []string
-	ProvisionKey  string
-	EncryptionKey string
+	ListenAddress    string
+	LogFormat        string
+	LogLevel         string
+	BaseURL          string
+	Kafka            kafka.Config
+	S3               S3
+	TeamRepositories TeamRepositories
+	Github           Github
+	Vault            Vault
+	ApiKeys          ApiKeys
+	Cache            Cache
+	Audit            Audit
+	Approval         Approval
+	Timeouts         Timeouts
+	Notification     Notification
+	Reporters        Reporters
+	Outbox           Outbox
+	DORA             DORA
+	Logs             Logs
+	Tracing          tracing.Config
+	MetricsPath      string
+	Clusters         []string
+	ProvisionKey     string
+	AdminKey         string
+	EncryptionKey    string
+	DataDir          string
+	ShutdownTimeout  time.Duration
 }
 
 func getEnv(key, fallback string) string {
@@ -68,6 +162,18 @@ func parseInt(str string) int {
 	return i
 }
 
+func parseDuration(str string) time.Duration {
+	d, _ := time.ParseDuration(str)
+	return d
+}
+
+func parseList(str string) []string {
+	if len(str) == 0 {
+		return nil
+	}
+	return strings.Split(str, ",")
+}
+
 func DefaultConfig() *Config {
 	return &Config{
 		BaseURL:       getEnv("BASE_URL", "http://localhost:8080"),
@@ -82,6 +188,7 @@ func DefaultConfig() *Config {
 			Enabled:       parseBool(getEnv("GITHUB_ENABLED", "false")),
 			InstallID:     parseInt(getEnv("GITHUB_INSTALL_ID", "0")),
 			KeyFile:       getEnv("GITHUB_KEY_FILE", "private-key.pem"),
+			Organization:  getEnv("GITHUB_ORGANIZATION", "navikt"),
 			WebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
 		},
 		S3: S3{
@@ -92,6 +199,10 @@ func DefaultConfig() *Config {
 			BucketLocation: getEnv("S3_BUCKET_LOCATION", ""),
 			UseTLS:         parseBool(getEnv("S3_SECURE", "false")),
 		},
+		TeamRepositories: TeamRepositories{
+			Backend: getEnv("TEAM_REPOSITORY_BACKEND", "s3"),
+			Path:    getEnv("TEAM_REPOSITORY_PATH", ""),
+		},
 		Vault: Vault{
 			CredentialsFile: getEnv("VAULT_CREDENTIALS_FILE", ""),
 			Address:         getEnv("VAULT_ADDRESS", "http://localhost:8200"),
@@ -101,8 +212,73 @@ func DefaultConfig() *Config {
 			AuthRole:        getEnv("VAULT_AUTH_ROLE", ""),
 			Token:           getEnv("VAULT_TOKEN", "123456789"),
 		},
+		ApiKeys: ApiKeys{
+			Backend:   getEnv("API_KEY_BACKEND", "vault"),
+			Namespace: getEnv("API_KEY_NAMESPACE", "default"),
+			File:      getEnv("API_KEY_FILE", ""),
+		},
+		Cache: Cache{
+			TTL:         parseDuration(getEnv("CACHE_TTL", "1m")),
+			NegativeTTL: parseDuration(getEnv("CACHE_NEGATIVE_TTL", "10s")),
+		},
+		Audit: Audit{
+			Sinks:      parseList(getEnv("AUDIT_SINKS", "file")),
+			File:       getEnv("AUDIT_FILE", ""),
+			KafkaTopic: getEnv("AUDIT_KAFKA_TOPIC", "deploymentAudit"),
+			Key:        getEnv("AUDIT_KEY", ""),
+		},
+		Approval: Approval{
+			Clusters: parseList(getEnv("APPROVAL_CLUSTERS", "")),
+			Timeout:  parseDuration(getEnv("APPROVAL_TIMEOUT", "1h")),
+		},
+		Timeouts: Timeouts{
+			RolloutMin:    parseDuration(getEnv("ROLLOUT_TIMEOUT_MIN", "1m")),
+			RolloutMax:    parseDuration(getEnv("ROLLOUT_TIMEOUT_MAX", "1h")),
+			RolloutBounds: make(map[string]string),
+			MaxTTL:        parseDuration(getEnv("REQUEST_TTL_MAX", "15m")),
+			DryRun:        parseDuration(getEnv("DRY_RUN_TIMEOUT", "30s")),
+			Deploy:        parseDuration(getEnv("DEPLOY_TIMEOUT", "1m")),
+		},
+		Notification: Notification{
+			MaxAttempts: parseInt(getEnv("NOTIFICATION_MAX_ATTEMPTS", "5")),
+			Backoff:     parseDuration(getEnv("NOTIFICATION_BACKOFF", "5s")),
+			Workers:     parseInt(getEnv("NOTIFICATION_WORKERS", "4")),
+			QueueSize:   parseInt(getEnv("NOTIFICATION_QUEUE_SIZE", "1000")),
+		},
+		Reporters: Reporters{
+			Enabled:       parseList(getEnv("STATUS_REPORTERS", "github,history,notification")),
+			FilePath:      getEnv("STATUS_FILE", "statuses.jsonl"),
+			HTTPURL:       getEnv("STATUS_HTTP_URL", ""),
+			MaxAttempts:   make(map[string]int),
+			RetryInterval: make(map[string]string),
+			HistorySize:   parseInt(getEnv("HISTORY_SIZE", "1000")),
+		},
+		Outbox: Outbox{
+			Capacity:           parseInt(getEnv("OUTBOX_CAPACITY", "1000")),
+			MaxBackoff:         parseDuration(getEnv("OUTBOX_MAX_BACKOFF", "5m")),
+			RequestMaxAttempts: parseInt(getEnv("OUTBOX_REQUEST_MAX_ATTEMPTS", "20")),
+		},
+		DORA: DORA{
+			Window: parseDuration(getEnv("DORA_WINDOW", "720h")),
+		},
+		Logs: Logs{
+			Retention:      parseDuration(getEnv("LOG_RETENTION", "168h")),
+			Backend:        getEnv("LOG_BACKEND", "builtin"),
+			ClusterBackend: make(map[string]string),
+			KibanaURL:      getEnv("LOG_KIBANA_URL", "https://logs.adeo.no"),
+			KibanaIndex:    getEnv("LOG_KIBANA_INDEX", "logstash-apps-*"),
+			LokiURL:        getEnv("LOG_LOKI_URL", ""),
+			LokiDatasource: getEnv("LOG_LOKI_DATASOURCE", "Loki"),
+			LokiSelector:   getEnv("LOG_LOKI_SELECTOR", `{app="deployd"}`),
+			URLTemplate:    getEnv("LOG_URL_TEMPLATE", ""),
+		},
+		Tracing:       tracing.DefaultConfig(),
 		MetricsPath:   getEnv("METRICS_PATH", "/metrics"),
 		ProvisionKey:  getEnv("PROVISION_KEY", ""),
+		AdminKey:      getEnv("ADMIN_KEY", ""),
+		DataDir:       getEnv("DATA_DIR", "data"),
 		EncryptionKey: getEnv("ENCRYPTION_KEY", "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"),
+
+		ShutdownTimeout: parseDuration(getEnv("SHUTDOWN_TIMEOUT", "20s")),
 	}
 }
@@ -0,0 +1,55 @@
+package dora
+
+import (
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	labels = []string{metrics.Team, metrics.Repository, metrics.Cluster}
+
+	deploymentFrequency = metrics.Desc("dora_deployment_frequency", "successful deployments per day within the DORA window", labels...)
+	changeFailureRate   = metrics.Desc("dora_change_failure_rate", "fraction of finished deployments within the DORA window that failed", labels...)
+	meanTimeToRestore   = metrics.Desc("dora_mean_time_to_restore_seconds", "average time from a failed deployment until the next successful one within the DORA window", labels...)
+	finishedDeployments = metrics.Desc("dora_deployments", "finished deployments within the DORA window", append(labels, metrics.Result)...)
+)
+
+// Collector exposes the key delivery metrics for the last window of time as Prometheus metrics.
+// The metrics are computed from the deployment history every time they are scraped.
+type Collector struct {
+	Store  history.Store
+	Window time.Duration
+}
+
+var _ prometheus.Collector = &Collector{}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- deploymentFrequency
+	ch <- changeFailureRate
+	ch <- meanTimeToRestore
+	ch <- finishedDeployments
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	report, err := Generate(c.Store, history.Query{}, now.Add(-c.Window), now)
+	if err != nil {
+		log.Errorf("Computing DORA metrics: %s", err)
+		return
+	}
+
+	for _, m := range report.Groups {
+		values := []string{m.Team, m.Repository, m.Cluster}
+		ch <- prometheus.MustNewConstMetric(deploymentFrequency, prometheus.GaugeValue, m.DeploymentFrequency, values...)
+		ch <- prometheus.MustNewConstMetric(changeFailureRate, prometheus.GaugeValue, m.ChangeFailureRate, values...)
+		ch <- prometheus.MustNewConstMetric(finishedDeployments, prometheus.GaugeValue, float64(m.Successful), append(values, "success")...)
+		ch <- prometheus.MustNewConstMetric(finishedDeployments, prometheus.GaugeValue, float64(m.Failed), append(values, "failure")...)
+		if m.Restores > 0 {
+			ch <- prometheus.MustNewConstMetric(meanTimeToRestore, prometheus.GaugeValue, m.MeanTimeToRestore, values...)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package dora_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/dora"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dora")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := history.NewFileStore(filepath.Join(dir, "history.json"), 10)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	for i, state := range []deployment.GithubDeploymentState{
+		deployment.GithubDeploymentState_failure,
+		deployment.GithubDeploymentState_success,
+	} {
+		assert.NoError(t, store.Add(deployment.DeploymentStatus{
+			Deployment: &deployment.DeploymentSpec{
+				Repository: &deployment.GithubRepository{Owner: "navikt", Name: "myapp"},
+			},
+			DeliveryID: strconv.Itoa(i),
+			Team:       "aura",
+			Cluster:    "prod-fss",
+			State:      state,
+		}, now.Add(time.Duration(i-2)*time.Hour)))
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&dora.Collector{Store: store, Window: 24 * time.Hour})
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			values[family.GetName()] += metric.GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, 1.0, values["deployment_hookd_dora_deployment_frequency"])
+	assert.Equal(t, 0.5, values["deployment_hookd_dora_change_failure_rate"])
+	assert.Equal(t, 2.0, values["deployment_hookd_dora_deployments"])
+	assert.Equal(t, time.Hour.Seconds(), values["deployment_hookd_dora_mean_time_to_restore_seconds"])
+}
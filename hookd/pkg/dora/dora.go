@@ -0,0 +1,161 @@
+package dora
+
+import (
+	"sort"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/history"
+)
+
+// Group identifies the deployments that metrics are computed for.
+type Group struct {
+	Team       string `json:"team"`
+	Repository string `json:"repository"`
+	Cluster    string `json:"cluster"`
+}
+
+// Metrics are the key delivery metrics for a group of deployments.
+//
+// Only deployments that ended in success, failure or error are counted.
+// Cancelled and superseded deployments, and deployments that have not finished, are left out.
+type Metrics struct {
+	Group
+	Successful int `json:"successful"`
+	Failed     int `json:"failed"`
+	// DeploymentFrequency is the number of successful deployments per day.
+	DeploymentFrequency float64 `json:"deploymentFrequency"`
+	// ChangeFailureRate is the fraction of finished deployments that failed.
+	ChangeFailureRate float64 `json:"changeFailureRate"`
+	// Restores is the number of times a failed deployment was followed by a successful one.
+	Restores int `json:"restores"`
+	// MeanTimeToRestore is the average time from the first of a series of failed deployments
+	// until the next successful deployment, in seconds. Zero if nothing was restored.
+	MeanTimeToRestore float64 `json:"meanTimeToRestoreSeconds"`
+}
+
+// Report holds the metrics of every group with deployments created within a time range.
+type Report struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Groups []Metrics `json:"groups"`
+	// Horizon is set if the history has forgotten deployments created before it.
+	// The report then starts at the horizon rather than the requested time, so that the metrics are not under-reported.
+	Horizon *time.Time `json:"horizon,omitempty"`
+}
+
+// Generate computes a report from the deployments in the history that match the filter, and were created within [from, to).
+// If the history has forgotten deployments created after from, the report starts at the history's horizon instead.
+func Generate(store history.Store, filter history.Query, from, to time.Time) (*Report, error) {
+	horizon := store.Horizon()
+	if from.Before(horizon) {
+		from = horizon
+	}
+	if to.Before(from) {
+		to = from
+	}
+
+	filter.Since = from
+	filter.Limit = 0
+
+	deployments, err := store.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := Compute(deployments, from, to)
+	if !horizon.IsZero() {
+		report.Horizon = &horizon
+	}
+
+	return report, nil
+}
+
+// Compute computes a report from a list of deployments, ignoring deployments created outside [from, to).
+func Compute(deployments []history.Deployment, from, to time.Time) *Report {
+	groups := make(map[Group][]history.Deployment)
+
+	for _, d := range deployments {
+		if d.Created.Before(from) || !d.Created.Before(to) || !counted(d) {
+			continue
+		}
+		group := Group{Team: d.Team, Repository: d.Repository, Cluster: d.Cluster}
+		groups[group] = append(groups[group], d)
+	}
+
+	report := &Report{
+		From:   from,
+		To:     to,
+		Groups: make([]Metrics, 0, len(groups)),
+	}
+
+	days := to.Sub(from).Hours() / 24
+
+	for group, deployments := range groups {
+		report.Groups = append(report.Groups, compute(group, deployments, days))
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i].Group, report.Groups[j].Group
+		if a.Team != b.Team {
+			return a.Team < b.Team
+		}
+		if a.Repository != b.Repository {
+			return a.Repository < b.Repository
+		}
+		return a.Cluster < b.Cluster
+	})
+
+	return report
+}
+
+func compute(group Group, deployments []history.Deployment, days float64) Metrics {
+	metrics := Metrics{Group: group}
+
+	// Deployments are ordered by the time they finished, so that failures are restored by the next success.
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Updated.Before(deployments[j].Updated)
+	})
+
+	var failedSince *time.Time
+	var restoreTime time.Duration
+
+	for i := range deployments {
+		d := &deployments[i]
+		if failed(*d) {
+			metrics.Failed++
+			if failedSince == nil {
+				failedSince = &d.Updated
+			}
+			continue
+		}
+
+		metrics.Successful++
+		if failedSince != nil {
+			metrics.Restores++
+			restoreTime += d.Updated.Sub(*failedSince)
+			failedSince = nil
+		}
+	}
+
+	if days > 0 {
+		metrics.DeploymentFrequency = float64(metrics.Successful) / days
+	}
+	if total := metrics.Successful + metrics.Failed; total > 0 {
+		metrics.ChangeFailureRate = float64(metrics.Failed) / float64(total)
+	}
+	if metrics.Restores > 0 {
+		metrics.MeanTimeToRestore = restoreTime.Seconds() / float64(metrics.Restores)
+	}
+
+	return metrics
+}
+
+func counted(d history.Deployment) bool {
+	return d.State == deployment.GithubDeploymentState_success.String() || failed(d)
+}
+
+func failed(d history.Deployment) bool {
+	return d.State == deployment.GithubDeploymentState_failure.String() ||
+		d.State == deployment.GithubDeploymentState_error.String()
+}
@@ -0,0 +1,110 @@
+package dora_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/dora"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/stretchr/testify/assert"
+)
+
+var from = time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+
+func record(repository, state string, created, finished time.Duration) history.Deployment {
+	return history.Deployment{
+		Team:       "aura",
+		Repository: repository,
+		Cluster:    "prod-fss",
+		State:      state,
+		Created:    from.Add(created),
+		Updated:    from.Add(finished),
+	}
+}
+
+func TestCompute(t *testing.T) {
+	deployments := []history.Deployment{
+		record("navikt/a", "success", time.Hour, 2*time.Hour),
+		record("navikt/a", "failure", 3*time.Hour, 4*time.Hour),
+		record("navikt/a", "error", 5*time.Hour, 6*time.Hour),
+		record("navikt/a", "success", 7*time.Hour, 8*time.Hour),
+		record("navikt/a", "failure", 9*time.Hour, 10*time.Hour),
+		record("navikt/a", "inactive", 11*time.Hour, 12*time.Hour),
+		record("navikt/a", "in_progress", 13*time.Hour, 14*time.Hour),
+		record("navikt/b", "success", time.Hour, 2*time.Hour),
+		record("navikt/b", "success", -time.Hour, 2*time.Hour),
+		record("navikt/b", "success", 49*time.Hour, 50*time.Hour),
+	}
+
+	report := dora.Compute(deployments, from, from.Add(48*time.Hour))
+
+	assert.Len(t, report.Groups, 2)
+
+	a := report.Groups[0]
+	assert.Equal(t, "navikt/a", a.Repository)
+	assert.Equal(t, 2, a.Successful)
+	assert.Equal(t, 3, a.Failed)
+	assert.Equal(t, 1.0, a.DeploymentFrequency, "successful deployments per day")
+	assert.Equal(t, 0.6, a.ChangeFailureRate)
+	assert.Equal(t, 1, a.Restores, "the last failure is not restored yet")
+	assert.Equal(t, (4 * time.Hour).Seconds(), a.MeanTimeToRestore, "restore time is counted from the first failure")
+
+	b := report.Groups[1]
+	assert.Equal(t, "navikt/b", b.Repository)
+	assert.Equal(t, 1, b.Successful, "deployments created outside the time range are left out")
+	assert.Equal(t, 0.0, b.ChangeFailureRate)
+	assert.Equal(t, 0.0, b.MeanTimeToRestore)
+}
+
+func TestComputeEmpty(t *testing.T) {
+	report := dora.Compute(nil, from, from)
+	assert.NotNil(t, report.Groups)
+	assert.Empty(t, report.Groups)
+}
+
+func TestGenerateFromHorizon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dora")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := history.NewFileStore(filepath.Join(dir, "history.json"), 2)
+	assert.NoError(t, err)
+
+	for i, id := range []string{"1", "2", "3"} {
+		created := from.Add(time.Duration(i+1) * 24 * time.Hour)
+		assert.NoError(t, store.Add(deployment.DeploymentStatus{
+			Deployment: &deployment.DeploymentSpec{
+				Repository: &deployment.GithubRepository{Owner: "navikt", Name: "myapp"},
+			},
+			DeliveryID: id,
+			Team:       "aura",
+			Cluster:    "prod-fss",
+			State:      deployment.GithubDeploymentState_success,
+			Timestamp:  created.Unix(),
+		}, created))
+	}
+
+	// The first deployment has been forgotten, so the report starts when the second was created.
+	horizon := from.Add(48 * time.Hour)
+	report, err := dora.Generate(store, history.Query{}, from, from.Add(96*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, horizon, report.From.UTC())
+	if assert.NotNil(t, report.Horizon) {
+		assert.Equal(t, horizon, report.Horizon.UTC())
+	}
+	if assert.Len(t, report.Groups, 1) {
+		assert.Equal(t, 2, report.Groups[0].Successful)
+		assert.Equal(t, 1.0, report.Groups[0].DeploymentFrequency, "frequency is computed over the retained days only")
+	}
+
+	// Reports within the horizon are unaffected.
+	report, err = dora.Generate(store, history.Query{}, horizon, from.Add(96*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, horizon, report.From.UTC())
+}
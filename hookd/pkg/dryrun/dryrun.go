@@ -0,0 +1,80 @@
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+)
+
+var (
+	ErrTimeout = fmt.Errorf("no dry run result received from the cluster in time")
+)
+
+// Results connects dry run requests with the results sent back by deployd.
+//
+// A request is registered with Register before it is sent, so that its result can not arrive before anyone is waiting for it.
+// Results arriving after the waiter has given up are discarded.
+type Results struct {
+	lock    sync.Mutex
+	waiting map[string]chan deployment.DeploymentStatus
+}
+
+func New() *Results {
+	return &Results{
+		waiting: make(map[string]chan deployment.DeploymentStatus),
+	}
+}
+
+// Register starts waiting for the result of the dry run with the given delivery ID.
+// Wait must be called afterwards to collect the result and release the registration.
+func (r *Results) Register(deliveryID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.waiting[deliveryID] = make(chan deployment.DeploymentStatus, 1)
+}
+
+// Wait blocks until the result of a registered dry run arrives, or the context is done.
+func (r *Results) Wait(ctx context.Context, deliveryID string) (*deployment.DeploymentStatus, error) {
+	r.lock.Lock()
+	result, ok := r.waiting[deliveryID]
+	r.lock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("dry run %s is not registered", deliveryID)
+	}
+
+	defer func() {
+		r.lock.Lock()
+		delete(r.waiting, deliveryID)
+		r.lock.Unlock()
+	}()
+
+	select {
+	case status := <-result:
+		return &status, nil
+	case <-ctx.Done():
+		return nil, ErrTimeout
+	}
+}
+
+// Deliver hands a dry run result to its waiter.
+// Returns false if nobody is waiting for the result.
+func (r *Results) Deliver(status deployment.DeploymentStatus) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result, ok := r.waiting[status.GetDeliveryID()]
+	if !ok {
+		return false
+	}
+
+	select {
+	case result <- status:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,41 @@
+package dryrun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/dryrun"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResults(t *testing.T) {
+	results := dryrun.New()
+
+	assert.False(t, results.Deliver(deployment.DeploymentStatus{DeliveryID: "unknown"}), "results nobody is waiting for are discarded")
+
+	results.Register("dryrun")
+	assert.True(t, results.Deliver(deployment.DeploymentStatus{DeliveryID: "dryrun", DryRun: true}))
+
+	status, err := results.Wait(context.Background(), "dryrun")
+	assert.NoError(t, err)
+	assert.Equal(t, "dryrun", status.GetDeliveryID())
+
+	assert.False(t, results.Deliver(deployment.DeploymentStatus{DeliveryID: "dryrun"}), "registration is released after waiting")
+}
+
+func TestResultsTimeout(t *testing.T) {
+	results := dryrun.New()
+	results.Register("dryrun")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	status, err := results.Wait(ctx, "dryrun")
+	assert.Equal(t, dryrun.ErrTimeout, err)
+	assert.Nil(t, status)
+
+	_, err = results.Wait(context.Background(), "dryrun")
+	assert.Error(t, err, "registration is released after timing out")
+}
@@ -0,0 +1,140 @@
+package freeze
+
+import (
+	"fmt"
+	"time"
+)
+
+type Recurrence string
+
+const (
+	RecurrenceNone   Recurrence = ""
+	RecurrenceDaily  Recurrence = "daily"
+	RecurrenceWeekly Recurrence = "weekly"
+	RecurrenceYearly Recurrence = "yearly"
+)
+
+// Window is a time range during which deployments are blocked.
+//
+// Empty cluster and team lists match every cluster and team, respectively.
+// Recurring windows repeat their Start-End range every day, week or year.
+type Window struct {
+	ID         string     `json:"id"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	Recurrence Recurrence `json:"recurrence,omitempty"`
+	Clusters   []string   `json:"clusters,omitempty"`
+	Teams      []string   `json:"teams,omitempty"`
+	Reason     string     `json:"reason"`
+}
+
+func (w Window) Validate() error {
+	if len(w.Reason) == 0 {
+		return fmt.Errorf("no reason specified")
+	}
+
+	if w.Start.IsZero() || w.End.IsZero() {
+		return fmt.Errorf("both start and end time must be specified")
+	}
+
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("end time must be after start time")
+	}
+
+	var period time.Duration
+
+	switch w.Recurrence {
+	case RecurrenceNone:
+		return nil
+	case RecurrenceDaily:
+		period = time.Hour * 24
+	case RecurrenceWeekly:
+		period = time.Hour * 24 * 7
+	case RecurrenceYearly:
+		period = time.Hour * 24 * 365
+	default:
+		return fmt.Errorf("recurrence must be one of '%s', '%s' or '%s'", RecurrenceDaily, RecurrenceWeekly, RecurrenceYearly)
+	}
+
+	if w.End.Sub(w.Start) >= period {
+		return fmt.Errorf("a %s window must be shorter than its recurrence interval", w.Recurrence)
+	}
+
+	return nil
+}
+
+// Matches reports whether the window applies to deployments of a team into a cluster.
+func (w Window) Matches(cluster, team string) bool {
+	return contains(w.Clusters, cluster) && contains(w.Teams, team)
+}
+
+// ActiveAt reports whether the window, or one of its recurrences, covers the point in time t.
+func (w Window) ActiveAt(t time.Time) bool {
+	if t.Before(w.Start) {
+		return false
+	}
+
+	duration := w.End.Sub(w.Start)
+	occurrence := func(n int) time.Time {
+		switch w.Recurrence {
+		case RecurrenceDaily:
+			return w.Start.AddDate(0, 0, n)
+		case RecurrenceWeekly:
+			return w.Start.AddDate(0, 0, n*7)
+		case RecurrenceYearly:
+			return w.Start.AddDate(n, 0, 0)
+		default:
+			return w.Start
+		}
+	}
+
+	var n int
+	switch w.Recurrence {
+	case RecurrenceDaily:
+		n = int(t.Sub(w.Start) / (time.Hour * 24))
+	case RecurrenceWeekly:
+		n = int(t.Sub(w.Start) / (time.Hour * 24 * 7))
+	case RecurrenceYearly:
+		n = t.Year() - w.Start.Year()
+	}
+
+	// Daylight saving time and leap years can shift the computed occurrence
+	// by one, so check the neighbouring occurrences as well.
+	for i := n + 1; i >= n-1 && i >= 0; i-- {
+		start := occurrence(i)
+		if !t.Before(start) && t.Before(start.Add(duration)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Active returns the first window that blocks deployments of a team into a cluster at time t, or nil if there is none.
+func Active(windows []Window, cluster, team string, t time.Time) *Window {
+	for i := range windows {
+		if windows[i].Matches(cluster, team) && windows[i].ActiveAt(t) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+func (w Window) String() string {
+	if w.Recurrence == RecurrenceNone {
+		return fmt.Sprintf("deployments are frozen until %s: %s", w.End.Format(time.RFC3339), w.Reason)
+	}
+	return fmt.Sprintf("deployments are frozen by a %s freeze window: %s", w.Recurrence, w.Reason)
+}
+
+func contains(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
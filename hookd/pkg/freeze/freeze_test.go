@@ -0,0 +1,153 @@
+package freeze_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/freeze"
+	"github.com/stretchr/testify/assert"
+)
+
+func parse(t string) time.Time {
+	ts, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+func TestWindowActiveAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   freeze.Window
+		time     string
+		expected bool
+	}{
+		{
+			name:     "single window covers time",
+			window:   freeze.Window{Start: parse("2019-12-20T00:00:00Z"), End: parse("2020-01-02T00:00:00Z")},
+			time:     "2019-12-24T18:00:00Z",
+			expected: true,
+		},
+		{
+			name:     "single window has expired",
+			window:   freeze.Window{Start: parse("2019-12-20T00:00:00Z"), End: parse("2020-01-02T00:00:00Z")},
+			time:     "2020-01-02T00:00:00Z",
+			expected: false,
+		},
+		{
+			name:     "window has not started yet",
+			window:   freeze.Window{Start: parse("2019-12-20T00:00:00Z"), End: parse("2020-01-02T00:00:00Z")},
+			time:     "2019-12-19T23:59:59Z",
+			expected: false,
+		},
+		{
+			name:     "daily window recurs in the evening",
+			window:   freeze.Window{Start: parse("2019-01-01T16:00:00Z"), End: parse("2019-01-02T08:00:00Z"), Recurrence: freeze.RecurrenceDaily},
+			time:     "2019-06-13T23:00:00Z",
+			expected: true,
+		},
+		{
+			name:     "daily window is inactive during working hours",
+			window:   freeze.Window{Start: parse("2019-01-01T16:00:00Z"), End: parse("2019-01-02T08:00:00Z"), Recurrence: freeze.RecurrenceDaily},
+			time:     "2019-06-13T12:00:00Z",
+			expected: false,
+		},
+		{
+			name:     "weekly window recurs on fridays",
+			window:   freeze.Window{Start: parse("2019-11-01T12:00:00Z"), End: parse("2019-11-04T06:00:00Z"), Recurrence: freeze.RecurrenceWeekly},
+			time:     "2019-12-07T10:00:00Z",
+			expected: true,
+		},
+		{
+			name:     "weekly window is inactive on wednesdays",
+			window:   freeze.Window{Start: parse("2019-11-01T12:00:00Z"), End: parse("2019-11-04T06:00:00Z"), Recurrence: freeze.RecurrenceWeekly},
+			time:     "2019-12-04T10:00:00Z",
+			expected: false,
+		},
+		{
+			name:     "yearly window spans new year",
+			window:   freeze.Window{Start: parse("2018-12-22T00:00:00Z"), End: parse("2019-01-02T00:00:00Z"), Recurrence: freeze.RecurrenceYearly},
+			time:     "2021-01-01T12:00:00Z",
+			expected: true,
+		},
+		{
+			name:     "yearly window is inactive in summer",
+			window:   freeze.Window{Start: parse("2018-12-22T00:00:00Z"), End: parse("2019-01-02T00:00:00Z"), Recurrence: freeze.RecurrenceYearly},
+			time:     "2021-07-01T12:00:00Z",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.window.ActiveAt(parse(test.time)))
+		})
+	}
+}
+
+func TestWindowMatches(t *testing.T) {
+	window := freeze.Window{Clusters: []string{"prod-fss", "prod-sbs"}}
+	assert.True(t, window.Matches("prod-fss", "aura"))
+	assert.False(t, window.Matches("dev-fss", "aura"))
+
+	window = freeze.Window{Clusters: []string{"prod-fss"}, Teams: []string{"aura"}}
+	assert.True(t, window.Matches("prod-fss", "aura"))
+	assert.False(t, window.Matches("prod-fss", "other"))
+}
+
+func TestWindowValidate(t *testing.T) {
+	window := freeze.Window{Start: parse("2019-01-01T16:00:00Z"), End: parse("2019-01-03T08:00:00Z"), Reason: "christmas"}
+	assert.NoError(t, window.Validate())
+
+	window.Recurrence = freeze.RecurrenceDaily
+	assert.Error(t, window.Validate())
+
+	window.Recurrence = "monthly"
+	assert.Error(t, window.Validate())
+
+	window = freeze.Window{Start: parse("2019-01-01T16:00:00Z"), End: parse("2019-01-01T08:00:00Z"), Reason: "backwards"}
+	assert.Error(t, window.Validate())
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freeze")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "windows.json")
+	storage, err := freeze.NewFileStorage(path)
+	assert.NoError(t, err)
+
+	window, err := storage.Create(freeze.Window{
+		Start:    time.Now().Add(-time.Hour),
+		End:      time.Now().Add(time.Hour),
+		Clusters: []string{"prod-fss"},
+		Reason:   "incident in progress",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, window.ID)
+
+	active, err := freeze.Check(storage, "prod-fss", "aura")
+	assert.NoError(t, err)
+	assert.Equal(t, window.ID, active.ID)
+
+	active, err = freeze.Check(storage, "dev-fss", "aura")
+	assert.NoError(t, err)
+	assert.Nil(t, active)
+
+	// windows survive a restart
+	storage, err = freeze.NewFileStorage(path)
+	assert.NoError(t, err)
+	windows, err := storage.List()
+	assert.NoError(t, err)
+	assert.Len(t, windows, 1)
+
+	assert.NoError(t, storage.Delete(window.ID))
+	assert.Equal(t, freeze.ErrNotFound, storage.Delete(window.ID))
+}
@@ -0,0 +1,109 @@
+package freeze
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+)
+
+var (
+	ErrNotFound = fmt.Errorf("freeze window not found")
+)
+
+type Storage interface {
+	List() ([]Window, error)
+	Create(window Window) (*Window, error)
+	Delete(id string) error
+}
+
+type fileStorage struct {
+	lock    sync.Mutex
+	path    string
+	windows []Window
+}
+
+// NewFileStorage returns a freeze window storage backed by a JSON file.
+// Existing windows are loaded from the file on startup.
+func NewFileStorage(path string) (Storage, error) {
+	s := &fileStorage{
+		path:    path,
+		windows: make([]Window, 0),
+	}
+	err := persistence.LoadJSONFile(path, &s.windows)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStorage) List() ([]Window, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	windows := make([]Window, len(s.windows))
+	copy(windows, s.windows)
+
+	return windows, nil
+}
+
+func (s *fileStorage) Create(window Window) (*Window, error) {
+	if err := window.Validate(); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("generate window id: %s", err)
+	}
+	window.ID = id.String()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	windows := append(s.windows, window)
+	if err := persistence.SaveJSONFile(s.path, windows); err != nil {
+		return nil, err
+	}
+	s.windows = windows
+
+	return &window, nil
+}
+
+func (s *fileStorage) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	windows := make([]Window, 0, len(s.windows))
+	for _, window := range s.windows {
+		if window.ID != id {
+			windows = append(windows, window)
+		}
+	}
+
+	if len(windows) == len(s.windows) {
+		return ErrNotFound
+	}
+
+	if err := persistence.SaveJSONFile(s.path, windows); err != nil {
+		return err
+	}
+	s.windows = windows
+
+	return nil
+}
+
+// Check returns the window blocking deployments of a team into a cluster right now, or nil if deployments are allowed.
+// A nil storage never blocks deployments.
+func Check(storage Storage, cluster, team string) (*Window, error) {
+	if storage == nil {
+		return nil, nil
+	}
+	windows, err := storage.List()
+	if err != nil {
+		return nil, fmt.Errorf("retrieve freeze windows: %s", err)
+	}
+	return Active(windows, cluster, team, time.Now()), nil
+}
@@ -0,0 +1,44 @@
+package github
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/cache"
+)
+
+// CachedClient keeps the results of team access checks in memory.
+// Teams that are missing or lack access are cached for a shorter time.
+// Other requests are passed through to the underlying client.
+type CachedClient struct {
+	Client
+	cache *cache.Cache
+}
+
+func NewCachedClient(client Client, ttl, negativeTTL time.Duration) *CachedClient {
+	return &CachedClient{
+		Client: client,
+		cache:  cache.New("github_team_access", ttl, negativeTTL, isTeamAccessDenied),
+	}
+}
+
+func isTeamAccessDenied(err error) bool {
+	return err == ErrTeamNotExist || err == ErrTeamNoAccess
+}
+
+func teamAccessKey(owner, repository, team string) string {
+	return strings.Join([]string{owner, repository, team}, "/")
+}
+
+func (c *CachedClient) TeamAllowed(ctx context.Context, owner, repository, team string) error {
+	_, err := c.cache.Get(teamAccessKey(owner, repository, team), func() (interface{}, error) {
+		return nil, c.Client.TeamAllowed(ctx, owner, repository, team)
+	})
+	return err
+}
+
+// Invalidate removes a cached team access check.
+func (c *CachedClient) Invalidate(owner, repository, team string) {
+	c.cache.Invalidate(teamAccessKey(owner, repository, team))
+}
@@ -11,6 +11,7 @@ import (
 	gh "github.com/google/go-github/v27/github"
 	types "github.com/navikt/deployment/common/pkg/deployment"
 	"github.com/navikt/deployment/hookd/pkg/logproxy"
+	"golang.org/x/oauth2"
 )
 
 const maxDescriptionLength = 140
@@ -36,6 +37,20 @@ func InstallationClient(appId, installId int, keyFile string) (*gh.Client, error
 	return gh.NewClient(&http.Client{Transport: itr}), nil
 }
 
+// UserClient returns a GitHub client acting on behalf of the user owning the OAuth token.
+func UserClient(token string) *gh.Client {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{
+		AccessToken: token,
+	})
+	return gh.NewClient(oauth2.NewClient(context.Background(), ts))
+}
+
+// CheckInstallation verifies that the GitHub App is able to authenticate as its installation.
+func CheckInstallation(ctx context.Context, client *gh.Client) error {
+	_, _, err := client.Apps.ListRepos(ctx, &gh.ListOptions{PerPage: 1})
+	return err
+}
+
 func CreateDeploymentStatus(client *gh.Client, m *types.DeploymentStatus, baseurl string) (*gh.DeploymentStatus, *gh.Response, error) {
 	if client == nil {
 		return nil, nil, fmt.Errorf("no Github client supplied")
@@ -71,3 +86,75 @@ func CreateDeploymentStatus(client *gh.Client, m *types.DeploymentStatus, baseur
 		},
 	)
 }
+
+// previousDeploymentsLimit is how many earlier deployments to the same environment are looked at
+// when marking them inactive. Older deployments were marked inactive by earlier successful deployments.
+const previousDeploymentsLimit = 30
+
+// InactivatePreviousDeployments posts an inactive status on earlier deployments to the same repository and
+// environment as the deployment of m, if their latest status is success. GitHub only does this by itself for
+// non-production environments. Returns the IDs of the deployments that were marked inactive.
+func InactivatePreviousDeployments(client *gh.Client, m *types.DeploymentStatus) ([]int64, error) {
+	if client == nil {
+		return nil, fmt.Errorf("no Github client supplied")
+	}
+
+	deployment := m.GetDeployment()
+	if deployment == nil {
+		return nil, ErrEmptyDeployment
+	}
+
+	repo := deployment.GetRepository()
+	if repo == nil {
+		return nil, ErrEmptyRepository
+	}
+
+	ctx := context.Background()
+	owner, name, id := repo.GetOwner(), repo.GetName(), deployment.GetDeploymentID()
+
+	current, _, err := client.Repositories.GetDeployment(ctx, owner, name, id)
+	if err != nil {
+		return nil, fmt.Errorf("get deployment %d: %s", id, err)
+	}
+
+	previous, _, err := client.Repositories.ListDeployments(ctx, owner, name, &gh.DeploymentsListOptions{
+		Environment: current.GetEnvironment(),
+		ListOptions: gh.ListOptions{
+			PerPage: previousDeploymentsLimit,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list deployments to %s: %s", current.GetEnvironment(), err)
+	}
+
+	state := types.GithubDeploymentState_inactive.String()
+	description := fmt.Sprintf("Superseded by deployment %d", id)
+	inactivated := make([]int64, 0)
+
+	for _, d := range previous {
+		// Deployments made after this one are left alone, even if they finished first.
+		if d.GetID() >= id {
+			continue
+		}
+
+		statuses, _, err := client.Repositories.ListDeploymentStatuses(ctx, owner, name, d.GetID(), &gh.ListOptions{PerPage: 1})
+		if err != nil {
+			return inactivated, fmt.Errorf("list statuses of deployment %d: %s", d.GetID(), err)
+		}
+		if len(statuses) == 0 || statuses[0].GetState() != types.GithubDeploymentState_success.String() {
+			continue
+		}
+
+		_, _, err = client.Repositories.CreateDeploymentStatus(ctx, owner, name, d.GetID(), &gh.DeploymentStatusRequest{
+			State:       &state,
+			Description: &description,
+		})
+		if err != nil {
+			return inactivated, fmt.Errorf("mark deployment %d inactive: %s", d.GetID(), err)
+		}
+
+		inactivated = append(inactivated, d.GetID())
+	}
+
+	return inactivated, nil
+}
@@ -0,0 +1,227 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+)
+
+var (
+	ErrNotFound = fmt.Errorf("deployment not found in history")
+)
+
+// Status is a single state transition in a deployment's lifetime.
+type Status struct {
+	State       string    `json:"state"`
+	Description string    `json:"description"`
+	Time        time.Time `json:"time"`
+}
+
+// Resource identifies a Kubernetes resource in a deployment.
+type Resource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Deployment is the recorded history of a single deployment request.
+type Deployment struct {
+	DeliveryID   string     `json:"deliveryID"`
+	DeploymentID int64      `json:"deploymentID,omitempty"`
+	Team         string     `json:"team"`
+	Cluster      string     `json:"cluster"`
+	Repository   string     `json:"repository"`
+	State        string     `json:"state"`
+	Created      time.Time  `json:"created"`
+	Updated      time.Time  `json:"updated"`
+	Statuses     []Status   `json:"statuses"`
+	Resources    []Resource `json:"resources,omitempty"`
+}
+
+// Finished reports whether the deployment has reached a final state.
+func (d *Deployment) Finished() bool {
+	switch d.State {
+	case deployment.GithubDeploymentState_success.String(),
+		deployment.GithubDeploymentState_error.String(),
+		deployment.GithubDeploymentState_failure.String(),
+		deployment.GithubDeploymentState_inactive.String():
+		return true
+	}
+	return false
+}
+
+// Query filters deployments in the history. Empty fields match everything.
+type Query struct {
+	Team       string
+	Cluster    string
+	Repository string
+	Since      time.Time
+	Limit      int
+}
+
+func (q Query) matches(d *Deployment) bool {
+	return (len(q.Team) == 0 || q.Team == d.Team) &&
+		(len(q.Cluster) == 0 || q.Cluster == d.Cluster) &&
+		(len(q.Repository) == 0 || q.Repository == d.Repository) &&
+		!d.Created.Before(q.Since)
+}
+
+type Store interface {
+	// Add records a deployment status, creating the deployment if it is not known.
+	Add(status deployment.DeploymentStatus, t time.Time) error
+	Get(deliveryID string) (*Deployment, error)
+	// List returns deployments matching the query, newest first.
+	List(query Query) ([]Deployment, error)
+	// Horizon returns the creation time of the oldest deployment in a full history.
+	// Older deployments may have been forgotten, so the history is only complete after the horizon.
+	// Returns the zero time if the history is not full, and has not forgotten anything.
+	Horizon() time.Time
+}
+
+type fileStore struct {
+	lock        sync.Mutex
+	path        string
+	maxSize     int
+	deployments map[string]*Deployment
+}
+
+// NewFileStore returns a deployment history backed by a JSON file.
+// When more than maxSize deployments are recorded, the oldest ones are forgotten.
+func NewFileStore(path string, maxSize int) (Store, error) {
+	s := &fileStore{
+		path:        path,
+		maxSize:     maxSize,
+		deployments: make(map[string]*Deployment),
+	}
+	err := persistence.LoadJSONFile(path, &s.deployments)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStore) Add(status deployment.DeploymentStatus, t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d, ok := s.deployments[status.GetDeliveryID()]
+	if !ok {
+		d = &Deployment{
+			DeliveryID: status.GetDeliveryID(),
+			Team:       status.GetTeam(),
+			Cluster:    status.GetCluster(),
+			Repository: status.GetDeployment().GetRepository().FullName(),
+			Created:    t,
+		}
+		if status.GetTimestamp() > 0 {
+			d.Created = time.Unix(status.GetTimestamp(), 0)
+		}
+		s.deployments[d.DeliveryID] = d
+	}
+
+	if id := status.GetDeployment().GetDeploymentID(); id != 0 {
+		d.DeploymentID = id
+	}
+	if len(d.Resources) == 0 {
+		for _, r := range status.GetResources() {
+			d.Resources = append(d.Resources, Resource{
+				Kind:      r.GetKind(),
+				Namespace: r.GetNamespace(),
+				Name:      r.GetName(),
+			})
+		}
+	}
+	d.State = status.GetState().String()
+	d.Updated = t
+	d.Statuses = append(d.Statuses, Status{
+		State:       d.State,
+		Description: status.GetDescription(),
+		Time:        t,
+	})
+
+	s.truncate()
+
+	return persistence.SaveJSONFile(s.path, s.deployments)
+}
+
+func (s *fileStore) truncate() {
+	if len(s.deployments) <= s.maxSize {
+		return
+	}
+	list := s.sorted()
+	for _, d := range list[s.maxSize:] {
+		delete(s.deployments, d.DeliveryID)
+	}
+}
+
+// sorted returns all deployments, newest first.
+func (s *fileStore) sorted() []*Deployment {
+	list := make([]*Deployment, 0, len(s.deployments))
+	for _, d := range s.deployments {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.After(list[j].Created)
+	})
+	return list
+}
+
+func (s *fileStore) Get(deliveryID string) (*Deployment, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d, ok := s.deployments[deliveryID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return d.copy(), nil
+}
+
+func (s *fileStore) List(query Query) ([]Deployment, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	deployments := make([]Deployment, 0)
+	for _, d := range s.sorted() {
+		if query.Limit > 0 && len(deployments) >= query.Limit {
+			break
+		}
+		if query.matches(d) {
+			deployments = append(deployments, *d.copy())
+		}
+	}
+
+	return deployments, nil
+}
+
+func (s *fileStore) Horizon() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.deployments) < s.maxSize {
+		return time.Time{}
+	}
+
+	var horizon time.Time
+	for _, d := range s.deployments {
+		if horizon.IsZero() || d.Created.Before(horizon) {
+			horizon = d.Created
+		}
+	}
+	return horizon
+}
+
+func (d *Deployment) copy() *Deployment {
+	c := *d
+	c.Statuses = make([]Status, len(d.Statuses))
+	copy(c.Statuses, d.Statuses)
+	if d.Resources != nil {
+		c.Resources = make([]Resource, len(d.Resources))
+		copy(c.Resources, d.Resources)
+	}
+	return &c
+}
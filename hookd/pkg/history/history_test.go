@@ -0,0 +1,97 @@
+package history_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/stretchr/testify/assert"
+)
+
+func status(id, team string, state deployment.GithubDeploymentState, timestamp int64) deployment.DeploymentStatus {
+	return deployment.DeploymentStatus{
+		Deployment: &deployment.DeploymentSpec{
+			Repository: &deployment.GithubRepository{
+				Owner: "navikt",
+				Name:  "deployment",
+			},
+		},
+		DeliveryID: id,
+		Team:       team,
+		Cluster:    "prod-fss",
+		State:      state,
+		Timestamp:  timestamp,
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.json")
+	store, err := history.NewFileStore(path, 2)
+	assert.NoError(t, err)
+
+	now := time.Now()
+
+	assert.NoError(t, store.Add(status("1", "aura", deployment.GithubDeploymentState_queued, 100), now))
+	assert.NoError(t, store.Add(status("1", "aura", deployment.GithubDeploymentState_success, 100), now))
+	assert.True(t, store.Horizon().IsZero(), "history is complete until it is full")
+	assert.NoError(t, store.Add(status("2", "other", deployment.GithubDeploymentState_failure, 200), now))
+	assert.Equal(t, time.Unix(100, 0), store.Horizon())
+
+	d, err := store.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "success", d.State)
+	assert.True(t, d.Finished())
+	assert.Len(t, d.Statuses, 2)
+	assert.Equal(t, "navikt/deployment", d.Repository)
+
+	list, err := store.List(history.Query{Team: "aura"})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// oldest deployment is forgotten when the history is full
+	assert.NoError(t, store.Add(status("3", "aura", deployment.GithubDeploymentState_queued, 300), now))
+	_, err = store.Get("1")
+	assert.Equal(t, history.ErrNotFound, err)
+	assert.Equal(t, time.Unix(200, 0), store.Horizon())
+
+	// history survives a restart, newest first
+	store, err = history.NewFileStore(path, 2)
+	assert.NoError(t, err)
+	list, err = store.List(history.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "3", list[0].DeliveryID)
+	assert.False(t, list[0].Finished())
+}
+
+func TestResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := history.NewFileStore(filepath.Join(dir, "history.json"), 10)
+	assert.NoError(t, err)
+
+	queued := status("1", "aura", deployment.GithubDeploymentState_queued, 100)
+	queued.Resources = []*deployment.ResourceResult{
+		{Kind: "Application", Namespace: "aura", Name: "myapp"},
+	}
+	assert.NoError(t, store.Add(queued, time.Now()))
+	assert.NoError(t, store.Add(status("1", "aura", deployment.GithubDeploymentState_success, 100), time.Now()))
+
+	d, err := store.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []history.Resource{{Kind: "Application", Namespace: "aura", Name: "myapp"}}, d.Resources, "resources are kept from the first status listing them")
+}
@@ -0,0 +1,174 @@
+package logproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/navikt/deployment/common/pkg/deployment"
+	"gopkg.in/sakura-internet/go-rison.v3"
+)
+
+// Deployment holds what a log backend may use to find the logs of a deployment.
+// Team and cluster are empty if hookd does not know the deployment.
+type Deployment struct {
+	DeliveryID string
+	Timestamp  time.Time
+	Team       string
+	Cluster    string
+}
+
+// Backend links deployment logs to an external log system.
+type Backend interface {
+	URL(d Deployment) string
+}
+
+// oneDay returns the start and end of the UTC day of ts.
+func oneDay(ts time.Time) (time.Time, time.Time) {
+	od := time.Hour * 24
+	start := ts.UTC().Truncate(od)
+	return start, start.Add(od)
+}
+
+// Kibana links to a Discover search for the delivery ID in an index of log lines shipped by logstash.
+type Kibana struct {
+	BaseURL string
+	Index   string
+}
+
+const kibanaFormat = "%s/app/kibana#/discover?_a=%s&_g=%s"
+const kibanaQuery = "+x_delivery_id:\"%s\" -level:\"Trace\""
+
+type kibanaQueryState struct {
+	Language string `json:"language"`
+	Query    string `json:"query"`
+}
+
+type kibanaAppState struct {
+	Index string           `json:"index"`
+	Query kibanaQueryState `json:"query"`
+}
+
+type kibanaTimeRange struct {
+	From string `json:"from"`
+	Mode string `json:"mode"`
+	To   string `json:"to"`
+}
+
+type kibanaGlobalState struct {
+	Time kibanaTimeRange `json:"time"`
+}
+
+func (k *Kibana) URL(d Deployment) string {
+	start, end := oneDay(d.Timestamp)
+
+	as := kibanaAppState{
+		Index: k.Index,
+		Query: kibanaQueryState{
+			Language: "lucene",
+			Query:    fmt.Sprintf(kibanaQuery, d.DeliveryID),
+		},
+	}
+
+	gs := kibanaGlobalState{
+		Time: kibanaTimeRange{
+			From: start.Format(time.RFC3339),
+			Mode: "absolute",
+			To:   end.Format(time.RFC3339),
+		},
+	}
+
+	b, _ := rison.Encode(as, rison.Rison)
+	c, _ := rison.Encode(gs, rison.Rison)
+
+	return fmt.Sprintf(kibanaFormat, strings.TrimSuffix(k.BaseURL, "/"), string(b), string(c))
+}
+
+// Loki links to Grafana Explore, with a LogQL query for the log lines with the delivery ID.
+// Log lines are expected to be JSON, as written by deployd with --log-format=json.
+type Loki struct {
+	// BaseURL is the address of Grafana.
+	BaseURL string
+	// Datasource is the name of the Loki data source in Grafana.
+	Datasource string
+	// Selector is the LogQL stream selector matching deployd's log streams, such as {app="deployd"}.
+	Selector string
+}
+
+type lokiQuery struct {
+	RefID string `json:"refId"`
+	Expr  string `json:"expr"`
+}
+
+type lokiTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type lokiExploreState struct {
+	Datasource string        `json:"datasource"`
+	Queries    []lokiQuery   `json:"queries"`
+	Range      lokiTimeRange `json:"range"`
+}
+
+// Query returns the LogQL query for the log lines of a deployment.
+func (l *Loki) Query(deliveryID string) string {
+	return fmt.Sprintf("%s | json | %s=%s", l.Selector, types.LogFieldDeliveryID, strconv.Quote(deliveryID))
+}
+
+func (l *Loki) URL(d Deployment) string {
+	start, end := oneDay(d.Timestamp)
+
+	state := lokiExploreState{
+		Datasource: l.Datasource,
+		Queries: []lokiQuery{
+			{
+				RefID: "A",
+				Expr:  l.Query(d.DeliveryID),
+			},
+		},
+		Range: lokiTimeRange{
+			From: strconv.FormatInt(start.UnixNano()/int64(time.Millisecond), 10),
+			To:   strconv.FormatInt(end.UnixNano()/int64(time.Millisecond), 10),
+		},
+	}
+
+	left, _ := json.Marshal(state)
+
+	return fmt.Sprintf("%s/explore?left=%s", strings.TrimSuffix(l.BaseURL, "/"), url.QueryEscape(string(left)))
+}
+
+// Template links to a URL built from a template with the placeholders
+// {delivery_id}, {timestamp} (in seconds since the epoch), {team} and {cluster}.
+// Values are query escaped.
+type Template struct {
+	Template string
+}
+
+const (
+	PlaceholderDeliveryID = "{delivery_id}"
+	PlaceholderTimestamp  = "{timestamp}"
+	PlaceholderTeam       = "{team}"
+	PlaceholderCluster    = "{cluster}"
+)
+
+// NewTemplate returns a template backend, if the template refers to the delivery ID.
+func NewTemplate(template string) (*Template, error) {
+	if !strings.Contains(template, PlaceholderDeliveryID) {
+		return nil, fmt.Errorf("log URL template must contain %s", PlaceholderDeliveryID)
+	}
+	return &Template{Template: template}, nil
+}
+
+func (t *Template) URL(d Deployment) string {
+	replacer := strings.NewReplacer(
+		PlaceholderDeliveryID, url.QueryEscape(d.DeliveryID),
+		PlaceholderTimestamp, strconv.FormatInt(d.Timestamp.Unix(), 10),
+		PlaceholderTeam, url.QueryEscape(d.Team),
+		PlaceholderCluster, url.QueryEscape(d.Cluster),
+	)
+	return replacer.Replace(t.Template)
+}
@@ -0,0 +1,81 @@
+package logproxy_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/logproxy"
+	"github.com/stretchr/testify/assert"
+)
+
+var target = logproxy.Deployment{
+	DeliveryID: "9a0d1702-e7c5-448f-8a90-1e5ee29a043b",
+	Timestamp:  time.Date(2019, 11, 5, 8, 33, 9, 0, time.UTC),
+	Team:       "aura",
+	Cluster:    "dev-fss",
+}
+
+func TestKibana(t *testing.T) {
+	kibana := &logproxy.Kibana{
+		BaseURL: "https://logs.example.com/",
+		Index:   "logstash-apps-*",
+	}
+	assert.Equal(t,
+		"https://logs.example.com/app/kibana#/discover"+
+			"?_a=(index:'logstash-apps-*',query:(language:lucene,query:'+x_delivery_id:\"9a0d1702-e7c5-448f-8a90-1e5ee29a043b\" -level:\"Trace\"'))"+
+			"&_g=(time:(from:'2019-11-05T00:00:00Z',mode:absolute,to:'2019-11-06T00:00:00Z'))",
+		kibana.URL(target),
+	)
+}
+
+func TestLoki(t *testing.T) {
+	loki := &logproxy.Loki{
+		BaseURL:    "https://grafana.example.com",
+		Datasource: "Loki",
+		Selector:   `{app="deployd"}`,
+	}
+
+	assert.Equal(t, `{app="deployd"} | json | delivery_id="a\"b"`, loki.Query(`a"b`))
+
+	u, err := url.Parse(loki.URL(target))
+	assert.NoError(t, err)
+	assert.Equal(t, "grafana.example.com", u.Host)
+	assert.Equal(t, "/explore", u.Path)
+
+	state := struct {
+		Datasource string `json:"datasource"`
+		Queries    []struct {
+			Expr string `json:"expr"`
+		} `json:"queries"`
+		Range struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"range"`
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(u.Query().Get("left")), &state))
+	assert.Equal(t, "Loki", state.Datasource)
+	assert.Len(t, state.Queries, 1)
+	assert.Equal(t, `{app="deployd"} | json | delivery_id="9a0d1702-e7c5-448f-8a90-1e5ee29a043b"`, state.Queries[0].Expr)
+	assert.Equal(t, "1572912000000", state.Range.From)
+	assert.Equal(t, "1572998400000", state.Range.To)
+}
+
+func TestTemplate(t *testing.T) {
+	_, err := logproxy.NewTemplate("https://logs.example.com/{cluster}")
+	assert.Error(t, err, "template without delivery ID is rejected")
+
+	template, err := logproxy.NewTemplate("https://logs.example.com/{cluster}/search?q={delivery_id}&team={team}&since={timestamp}")
+	assert.NoError(t, err)
+
+	assert.Equal(t,
+		"https://logs.example.com/dev-fss/search?q=9a0d1702-e7c5-448f-8a90-1e5ee29a043b&team=aura&since=1572942789",
+		template.URL(target),
+	)
+
+	d := target
+	d.DeliveryID = "a&b c"
+	assert.True(t, strings.Contains(template.URL(d), "q=a%26b+c&"), "values are escaped")
+}
@@ -2,104 +2,176 @@ package logproxy
 
 import (
 	"fmt"
+	"html/template"
 	"net/http"
-	"regexp"
+	"net/url"
 	"strconv"
 	"time"
 
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
 	log "github.com/sirupsen/logrus"
-
-	"gopkg.in/sakura-internet/go-rison.v3"
 )
 
-const kibanaFormat = "https://logs.adeo.no/app/kibana#/discover?_a=%s&_g=%s"
-const searchQuery = "+x_delivery_id:\"%s\" -level:\"Trace\""
+// maxDeliveryIDLength guards against absurdly long query strings.
+const maxDeliveryIDLength = 256
+
+// refreshInterval is how often the log page reloads while the deployment is running, in seconds.
+const refreshInterval = 5
 
-type query struct {
-	Language string `json:"language"`
-	Query    string `json:"query"`
+func MakeURL(baseURL, deliveryID string, timestamp time.Time) string {
+	return fmt.Sprintf("%s/logs?delivery_id=%s&ts=%d", baseURL, url.QueryEscape(deliveryID), timestamp.Unix())
 }
 
-type appState struct {
-	Index string `json:"index"`
-	Query query  `json:"query"`
+// Authenticator finds the teams of the user viewing a log.
+type Authenticator interface {
+	// Teams returns the teams the signed in user is a member of.
+	// If the user is not signed in, it responds to the request itself, e.g. by redirecting to the login page, and returns false.
+	Teams(w http.ResponseWriter, r *http.Request) ([]string, bool)
 }
 
-type timeRange struct {
-	From string `json:"from"`
-	Mode string `json:"mode"`
-	To   string `json:"to"`
+// Handler shows the log of a deployment, at /logs?delivery_id=ID&ts=UNIXTIME.
+//
+// Deployments to clusters with a log backend are redirected to the backend. Otherwise, hookd renders the log lines
+// deployd has sent about the deployment, to signed in members of the deployment's team only. The page reloads itself
+// until the deployment history says the deployment has finished.
+type Handler struct {
+	Logs logstore.Store
+	// History is used to show the state of the deployment, and to find its team and cluster. Optional.
+	History history.Store
+	// Backends holds the log backend of each cluster. Clusters without an entry use Default.
+	// Logs are rendered by hookd if the backend is nil.
+	Backends      map[string]Backend
+	Default       Backend
+	Authenticator Authenticator
 }
 
-type globalState struct {
-	Time timeRange `json:"time"`
+type page struct {
+	DeliveryID string
+	State      string
+	Refresh    int
+	Events     []logstore.Event
 }
 
-var uuidRegex = regexp.MustCompile("^[a-fA-F0-9]{8}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{12}$")
+func (h *Handler) backend(cluster string) Backend {
+	if backend, ok := h.Backends[cluster]; ok {
+		return backend
+	}
+	return h.Default
+}
 
-func oneDay(ts time.Time) timeRange {
-	od := time.Hour * 24
-	start := ts.Truncate(od)
-	end := start.Add(od)
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.URL.Query().Get("delivery_id")
+	timestamp := r.URL.Query().Get("ts")
 
-	startStr, _ := start.MarshalText()
-	endStr, _ := end.MarshalText()
+	if len(deliveryID) == 0 || len(deliveryID) > maxDeliveryIDLength {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "delivery_id must be between 1 and %d characters long\n", maxDeliveryIDLength)
+		return
+	}
 
-	return timeRange{
-		From: string(startStr),
-		Mode: "absolute",
-		To:   string(endStr),
+	d := Deployment{
+		DeliveryID: deliveryID,
+		Timestamp:  time.Now(),
 	}
-}
 
-func formatKibana(deliveryID string, ts time.Time) string {
-	as := appState{
-		Index: "logstash-apps-*",
-		Query: query{
-			Language: "lucene",
-			Query:    fmt.Sprintf(searchQuery, deliveryID),
-		},
+	if len(timestamp) > 0 {
+		unixtime, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "ts must be a unix timestamp\n")
+			return
+		}
+		d.Timestamp = time.Unix(unixtime, 0)
 	}
 
-	gs := globalState{
-		Time: oneDay(ts),
+	events, err := h.Logs.List(deliveryID, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "unable to read deployment log\n")
+		log.Errorf("Read log of deployment %s: %s", deliveryID, err)
+		return
 	}
 
-	b, _ := rison.Encode(as, rison.Rison)
-	c, _ := rison.Encode(gs, rison.Rison)
+	p := page{
+		DeliveryID: deliveryID,
+		Events:     events,
+	}
 
-	return fmt.Sprintf(kibanaFormat, string(b), string(c))
-}
+	if h.History != nil {
+		if deployment, err := h.History.Get(deliveryID); err == nil {
+			d.Team = deployment.Team
+			d.Cluster = deployment.Cluster
+			p.State = deployment.State
+			if !deployment.Finished() {
+				p.Refresh = refreshInterval
+			}
+		}
+	}
 
-func MakeURL(baseURL, deliveryID string, timestamp time.Time) string {
-	return fmt.Sprintf("%s/logs?delivery_id=%s&ts=%d", baseURL, deliveryID, timestamp.Unix())
-}
+	if len(d.Cluster) == 0 && len(events) > 0 {
+		d.Team = events[0].Team
+		d.Cluster = events[0].Cluster
+	}
 
-func HandleFunc(w http.ResponseWriter, r *http.Request) {
-	badrequest := func(err error) {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Error(err)
-		_, err = w.Write([]byte(err.Error() + "\n"))
-		if err != nil {
-			log.Errorf("unable to answer http request: %s", err)
-		}
+	if backend := h.backend(d.Cluster); backend != nil {
+		http.Redirect(w, r, backend.URL(d), http.StatusTemporaryRedirect)
+		return
 	}
-	deliveryID := r.URL.Query().Get("delivery_id")
-	timestamp := r.URL.Query().Get("ts")
 
-	if !uuidRegex.MatchString(deliveryID) {
-		badrequest(fmt.Errorf("delivery_id '%s' is not a well-formed UUID", deliveryID))
+	teams, ok := h.Authenticator.Teams(w, r)
+	if !ok {
 		return
 	}
 
-	unixtime, err := strconv.Atoi(timestamp)
-	if err != nil {
-		badrequest(fmt.Errorf("ts '%s' is not a well-formed unix timestamp: %s", timestamp, err))
+	// Deployments belonging to other teams are reported as missing, so that their existence is not revealed.
+	if len(d.Team) == 0 || !contains(teams, d.Team) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "deployment %s does not exist\n", deliveryID)
 		return
 	}
 
-	utctime := time.Unix(int64(unixtime), 0).UTC()
-	url := formatKibana(deliveryID, utctime)
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	if err := pageTemplate.Execute(w, p); err != nil {
+		log.Errorf("Render log of deployment %s: %s", deliveryID, err)
+	}
+}
 
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
+
+var pageTemplate = template.Must(template.New("logs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Deployment {{ .DeliveryID }}</title>
+{{ if .Refresh }}<meta http-equiv="refresh" content="{{ .Refresh }}">{{ end }}
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0.1em 1em 0.1em 0; vertical-align: top; white-space: pre-wrap; }
+.warning { color: #a35b00; }
+.error, .fatal, .panic { color: #c30000; font-weight: bold; }
+.debug { color: #6a6a6a; }
+</style>
+</head>
+<body>
+<h1>Deployment {{ .DeliveryID }}</h1>
+{{ if .State }}<p>State: <strong>{{ .State }}</strong></p>{{ end }}
+{{ if .Events }}
+<table>
+{{ range .Events }}<tr class="{{ .Level }}"><td>{{ .Time.Format "2006-01-02T15:04:05.000Z07:00" }}</td><td>{{ .Cluster }}</td><td>{{ .Level }}</td><td>{{ .Message }}</td></tr>
+{{ end }}</table>
+{{ else }}
+<p>No log lines have been received for this deployment yet.</p>
+{{ end }}
+{{ if .Refresh }}<p><small>This page is refreshed every {{ .Refresh }} seconds.</small></p>{{ end }}
+</body>
+</html>
+`))
@@ -0,0 +1,182 @@
+package logproxy_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/history"
+	"github.com/navikt/deployment/hookd/pkg/logproxy"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
+	"github.com/stretchr/testify/assert"
+)
+
+// authenticator signs in a member of the given teams, or no one if teams is nil.
+type authenticator struct {
+	teams []string
+}
+
+func (a *authenticator) Teams(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	if a.teams == nil {
+		http.Redirect(w, r, "/auth/logout", http.StatusFound)
+		return nil, false
+	}
+	return a.teams, true
+}
+
+func TestMakeURL(t *testing.T) {
+	url := logproxy.MakeURL("https://deployment.nais.io", "a b&c", time.Unix(1572942789, 0))
+	assert.Equal(t, "https://deployment.nais.io/logs?delivery_id=a+b%26c&ts=1572942789", url)
+}
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logs, err := logstore.NewFileStore(filepath.Join(dir, "logs"))
+	assert.NoError(t, err)
+	historyStore, err := history.NewFileStore(filepath.Join(dir, "history.json"), 10)
+	assert.NoError(t, err)
+
+	users := &authenticator{teams: []string{"aura"}}
+	handler := &logproxy.Handler{Logs: logs, History: historyStore, Authenticator: users}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/logs?"+query, nil))
+		return recorder
+	}
+
+	recorder := get("")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = get("delivery_id=abc")
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "deployments of unknown teams are not shown")
+
+	assert.NoError(t, logs.Add("abc", logstore.Event{
+		Time:    time.Now(),
+		Cluster: "dev-fss",
+		Team:    "aura",
+		Level:   "info",
+		Message: "Resource 1: successfully deployed <script>",
+	}))
+
+	recorder = get("delivery_id=abc")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, strings.Contains(recorder.Body.String(), `http-equiv="refresh"`), "page is not refreshed while the state is unknown")
+
+	assert.NoError(t, historyStore.Add(deployment.DeploymentStatus{
+		DeliveryID: "abc",
+		Team:       "aura",
+		Cluster:    "dev-fss",
+		State:      deployment.GithubDeploymentState_in_progress,
+	}, time.Now()))
+
+	recorder = get("delivery_id=abc")
+	assert.Contains(t, recorder.Body.String(), `http-equiv="refresh"`, "page is refreshed while the deployment is running")
+
+	assert.NoError(t, historyStore.Add(deployment.DeploymentStatus{
+		DeliveryID: "abc",
+		State:      deployment.GithubDeploymentState_success,
+	}, time.Now()))
+
+	recorder = get("delivery_id=abc&ts=1572942789")
+	body := recorder.Body.String()
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("content-type"))
+	assert.Contains(t, body, "Resource 1: successfully deployed &lt;script&gt;", "log lines are escaped")
+	assert.Contains(t, body, "dev-fss")
+	assert.Contains(t, body, "<strong>success</strong>")
+	assert.False(t, strings.Contains(body, `http-equiv="refresh"`), "page is not refreshed after the deployment has finished")
+
+	users.teams = []string{"other"}
+	recorder = get("delivery_id=abc")
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "logs are only shown to members of the deployment's team")
+	assert.False(t, strings.Contains(recorder.Body.String(), "Resource 1"))
+
+	users.teams = nil
+	recorder = get("delivery_id=abc")
+	assert.Equal(t, http.StatusFound, recorder.Code, "users must be signed in")
+	assert.False(t, strings.Contains(recorder.Body.String(), "Resource 1"))
+}
+
+func TestHandlerBackends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logs, err := logstore.NewFileStore(filepath.Join(dir, "logs"))
+	assert.NoError(t, err)
+	historyStore, err := history.NewFileStore(filepath.Join(dir, "history.json"), 10)
+	assert.NoError(t, err)
+
+	assert.NoError(t, historyStore.Add(deployment.DeploymentStatus{
+		DeliveryID: "in-history",
+		Team:       "aura",
+		Cluster:    "prod-fss",
+		State:      deployment.GithubDeploymentState_success,
+	}, time.Now()))
+	assert.NoError(t, logs.Add("logs-only", logstore.Event{
+		Time:    time.Now(),
+		Cluster: "dev-fss",
+		Team:    "aura",
+		Message: "Resource 1: applied",
+	}))
+	assert.NoError(t, logs.Add("builtin", logstore.Event{
+		Time:    time.Now(),
+		Cluster: "dev-gcp",
+		Team:    "aura",
+		Message: "Resource 1: applied",
+	}))
+
+	template, err := logproxy.NewTemplate("https://logs.example.com/{cluster}/{team}?q={delivery_id}&ts={timestamp}")
+	assert.NoError(t, err)
+
+	handler := &logproxy.Handler{
+		Logs:    logs,
+		History: historyStore,
+		Backends: map[string]logproxy.Backend{
+			"prod-fss": template,
+			"dev-gcp":  nil,
+		},
+		Default:       template,
+		Authenticator: &authenticator{teams: []string{"aura"}},
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/logs?"+query, nil))
+		return recorder
+	}
+
+	recorder := get("delivery_id=in-history&ts=1572942789")
+	assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+	assert.Equal(t, "https://logs.example.com/prod-fss/aura?q=in-history&ts=1572942789", recorder.Header().Get("location"), "team and cluster are taken from the history")
+
+	recorder = get("delivery_id=logs-only&ts=1572942789")
+	assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+	assert.Equal(t, "https://logs.example.com/dev-fss/aura?q=logs-only&ts=1572942789", recorder.Header().Get("location"), "team and cluster are taken from the log")
+
+	recorder = get("delivery_id=builtin&ts=1572942789")
+	assert.Equal(t, http.StatusOK, recorder.Code, "clusters can use the built-in log viewer")
+	assert.Contains(t, recorder.Body.String(), "Resource 1: applied")
+
+	recorder = get("delivery_id=abc&ts=foo")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	handler.Default = nil
+	recorder = get("delivery_id=logs-only&ts=1572942789")
+	assert.Equal(t, http.StatusOK, recorder.Code, "logs are rendered by hookd without a backend")
+	assert.Contains(t, recorder.Body.String(), "Resource 1: applied")
+}
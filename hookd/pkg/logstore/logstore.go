@@ -0,0 +1,149 @@
+package logstore
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+)
+
+const fileSuffix = ".jsonl"
+
+// maxLineSize is the longest log line that can be read back from a log file.
+const maxLineSize = 1024 * 1024
+
+// Event is a log line about a deployment, as logged by deployd.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Cluster string    `json:"cluster"`
+	Team    string    `json:"team"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// EventFromMessage converts a log event received from deployd.
+func EventFromMessage(msg deployment.LogEvent) Event {
+	return Event{
+		Time:    time.Unix(0, msg.GetTimestamp()).UTC(),
+		Cluster: msg.GetCluster(),
+		Team:    msg.GetTeam(),
+		Level:   msg.GetLevel(),
+		Message: msg.GetMessage(),
+	}
+}
+
+type Store interface {
+	// Add appends a log line to the log of a deployment.
+	Add(deliveryID string, event Event) error
+	// List returns the log lines of a deployment in the order they were added, skipping the first offset lines.
+	// Deployments without any log lines have an empty log.
+	List(deliveryID string, offset int) ([]Event, error)
+	// Prune forgets the logs of deployments that have not been written to since before,
+	// and returns the number of logs removed.
+	Prune(before time.Time) (int, error)
+}
+
+type fileStore struct {
+	lock sync.Mutex
+	dir  string
+}
+
+// NewFileStore returns a log store keeping the log of each deployment in a file of JSON lines in dir.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create log directory: %s", err)
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// path returns the log file of a deployment. Delivery IDs are chosen by API clients,
+// so they are hashed rather than used as file names directly.
+func (s *fileStore) path(deliveryID string) string {
+	sum := sha256.Sum256([]byte(deliveryID))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+fileSuffix)
+}
+
+func (s *fileStore) Add(deliveryID string, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := os.OpenFile(s.path(deliveryID), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *fileStore) List(deliveryID string, offset int) ([]Event, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	events := make([]Event, 0)
+
+	file, err := os.Open(s.path(deliveryID))
+	if os.IsNotExist(err) {
+		return events, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for line := 0; scanner.Scan(); line++ {
+		if line < offset {
+			continue
+		}
+		event := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line+1, err)
+		}
+		events = append(events, event)
+	}
+
+	return events, scanner.Err()
+}
+
+func (s *fileStore) Prune(before time.Time) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileSuffix) || !file.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, file.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
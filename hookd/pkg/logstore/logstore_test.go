@@ -0,0 +1,111 @@
+package logstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/logstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(message string) logstore.Event {
+	return logstore.Event{
+		Time:    time.Now().UTC(),
+		Cluster: "prod-fss",
+		Team:    "aura",
+		Level:   "info",
+		Message: message,
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := logstore.NewFileStore(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Add("1", event("first")))
+	assert.NoError(t, store.Add("1", event("second")))
+	assert.NoError(t, store.Add("2", event("other")))
+	assert.NoError(t, store.Add("../../etc/passwd", event("path traversal")))
+
+	events, err := store.List("1", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "first", events[0].Message)
+	assert.Equal(t, "second", events[1].Message)
+
+	events, err = store.List("1", 1)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1, "lines before the offset are skipped")
+	assert.Equal(t, "second", events[0].Message)
+
+	events, err = store.List("1", 5)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	events, err = store.List("unknown", 0)
+	assert.NoError(t, err)
+	assert.NotNil(t, events)
+	assert.Empty(t, events)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3, "delivery IDs are not used as file names")
+
+	// logs are kept across restarts
+	store, err = logstore.NewFileStore(dir)
+	assert.NoError(t, err)
+	events, err = store.List("2", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := logstore.NewFileStore(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Add("1", event("first")))
+
+	removed, err := store.Prune(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed, "recently written logs are kept")
+
+	removed, err = store.Prune(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	events, err := store.List("1", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestEventFromMessage(t *testing.T) {
+	ts := time.Date(2019, 11, 1, 12, 0, 0, 500, time.UTC)
+	event := logstore.EventFromMessage(deployment.LogEvent{
+		DeliveryID: "1",
+		Team:       "aura",
+		Cluster:    "prod-fss",
+		Timestamp:  ts.UnixNano(),
+		Level:      "warning",
+		Message:    "rollout is slow",
+	})
+	assert.Equal(t, ts, event.Time)
+	assert.Equal(t, "aura", event.Team)
+	assert.Equal(t, "prod-fss", event.Cluster)
+	assert.Equal(t, "warning", event.Level)
+	assert.Equal(t, "rollout is slow", event.Message)
+}
@@ -19,6 +19,9 @@ const (
 	Repository           = "repository"
 	Team                 = "team"
 	Cluster              = "cluster"
+	Reporter             = "reporter"
+	Result               = "result"
+	Cache                = "cache"
 )
 
 var (
@@ -34,6 +37,11 @@ func gauge(name, help string) prometheus.Gauge {
 	})
 }
 
+// Desc describes a metric computed by a custom collector, such as one computing its values on every scrape.
+func Desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, nil)
+}
+
 func WebhookRequest(code int) {
 	webhookRequests.With(prometheus.Labels{
 		LabelStatusCode: strconv.Itoa(code),
@@ -64,6 +72,24 @@ func DeploymentStatus(status deployment.DeploymentStatus, githubReturnCode int)
 	}).Observe(ttd)
 }
 
+func StatusReport(reporter string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	statusReports.With(prometheus.Labels{
+		Reporter: reporter,
+		Result:   result,
+	}).Inc()
+}
+
+func CacheLookup(cache, result string) {
+	cacheLookups.With(prometheus.Labels{
+		Cache:  cache,
+		Result: result,
+	}).Inc()
+}
+
 func UpdateQueue(status deployment.DeploymentStatus) {
 	switch status.GetState() {
 	// These three states are definite and signify the end of a deployment.
@@ -109,6 +135,30 @@ var (
 		},
 	)
 
+	statusReports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "status_reports",
+		Help:      "number of deployment statuses sent to status reporters",
+		Namespace: namespace,
+		Subsystem: subsystem,
+	},
+		[]string{
+			Reporter,
+			Result,
+		},
+	)
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "cache_lookups",
+		Help:      "number of cache lookups, by result",
+		Namespace: namespace,
+		Subsystem: subsystem,
+	},
+		[]string{
+			Cache,
+			Result,
+		},
+	)
+
 	queueSize = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name:      "queue_size",
 		Help:      "number of unfinished deployments",
@@ -157,6 +207,8 @@ var (
 func init() {
 	prometheus.MustRegister(webhookRequests)
 	prometheus.MustRegister(githubStatus)
+	prometheus.MustRegister(statusReports)
+	prometheus.MustRegister(cacheLookups)
 	prometheus.MustRegister(queueSize)
 	prometheus.MustRegister(leadTime)
 	prometheus.MustRegister(Dispatched)
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	chi_middleware "github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,13 +24,27 @@ func (k *contextKey) String() string {
 // LogEntryCtxKey is the context.Context key to store the request log entry.
 var LogEntryCtxKey = &contextKey{"LogEntry"}
 
+// CorrelationIDCtxKey is the context.Context key to store the request correlation ID.
+var CorrelationIDCtxKey = &contextKey{"CorrelationID"}
+
+const (
+	CorrelationIDHeader   = "X-Correlation-Id"
+	LogFieldCorrelationID = "correlation_id"
+)
+
 // RequestLogger returns a logger handler using a custom LogFormatter.
 func RequestLogger() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			requestStartTime := time.Now()
 
+			correlationID := r.Header.Get(CorrelationIDHeader)
+			if len(correlationID) == 0 {
+				correlationID = uuid.New().String()
+			}
+
 			fields := requestLogFields(r)
+			fields[LogFieldCorrelationID] = correlationID
 
 			ww := chi_middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
@@ -41,6 +56,7 @@ func RequestLogger() func(next http.Handler) http.Handler {
 				log.WithFields(fields).Printf("%s %s %d %s", r.Method, r.RequestURI, ww.Status(), dur)
 			}()
 
+			r = r.WithContext(context.WithValue(r.Context(), CorrelationIDCtxKey, correlationID))
 			next.ServeHTTP(ww, withLogFields(r, &fields))
 		}
 		return http.HandlerFunc(fn)
@@ -58,6 +74,13 @@ func RequestLogFields(r *http.Request) log.Fields {
 	}
 }
 
+// CorrelationID returns the correlation ID of a request, either supplied by the client
+// in the X-Correlation-Id header, or generated when the request was received.
+func CorrelationID(r *http.Request) string {
+	id, _ := r.Context().Value(CorrelationIDCtxKey).(string)
+	return id
+}
+
 // withLogFields sets the in-context LogEntry for a request.
 func withLogFields(r *http.Request, fields *log.Fields) *http.Request {
 	r = r.WithContext(context.WithValue(r.Context(), LogEntryCtxKey, fields))
@@ -6,8 +6,10 @@ package middleware
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi"
 	chi_middleware "github.com/go-chi/chi/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -79,8 +81,24 @@ func (m Middleware) handler(next http.Handler) http.Handler {
 		next.ServeHTTP(ww, r)
 		statusCode := strconv.Itoa(ww.Status())
 		duration := time.Since(start)
-		m.reqs.WithLabelValues(statusCode, r.Method, r.URL.Path).Inc()
-		m.latency.WithLabelValues(statusCode, r.Method, r.URL.Path).Observe(duration.Seconds())
+		path := routePattern(r)
+		m.reqs.WithLabelValues(statusCode, r.Method, path).Inc()
+		m.latency.WithLabelValues(statusCode, r.Method, path).Observe(duration.Seconds())
 	}
 	return http.HandlerFunc(fn)
 }
+
+// routePattern returns the pattern of the route that handled the request, such as /api/v1/deploy/{id}/cancel,
+// so that URL parameters do not create a new time series for every request.
+// Requests that did not match a parametrized route are labeled with their URL path.
+func routePattern(r *http.Request) string {
+	rctx, ok := r.Context().Value(chi.RouteCtxKey).(*chi.Context)
+	if !ok {
+		return r.URL.Path
+	}
+	pattern := rctx.RoutePattern()
+	if !strings.Contains(pattern, "{") {
+		return r.URL.Path
+	}
+	return pattern
+}
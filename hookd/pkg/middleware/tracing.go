@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	chi_middleware "github.com/go-chi/chi/middleware"
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/tracing"
+	log "github.com/sirupsen/logrus"
+)
+
+// Tracing starts a server span for each request, continuing the trace from the traceparent header if there is one.
+// The trace ID is added to the request log fields, and handlers can start child spans from the request context.
+func Tracing() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+r.URL.Path, tracing.KindServer)
+
+			if fields, ok := r.Context().Value(LogEntryCtxKey).(*log.Fields); ok {
+				(*fields)[deployment.LogFieldTraceID] = span.Context.TraceID.String()
+			}
+
+			ww := chi_middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				span.Name = r.Method + " " + routePattern(r)
+				span.SetAttribute("http.method", r.Method)
+				span.SetAttribute("http.target", r.URL.RequestURI())
+				span.SetAttribute("http.status_code", ww.Status())
+				if id := CorrelationID(r); len(id) > 0 {
+					span.SetAttribute(LogFieldCorrelationID, id)
+				}
+				if ww.Status() >= http.StatusInternalServerError {
+					span.SetError(fmt.Errorf("%s", http.StatusText(ww.Status())))
+				}
+				span.Finish()
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
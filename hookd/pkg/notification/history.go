@@ -0,0 +1,74 @@
+package notification
+
+import (
+	"sync"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+)
+
+// Delivery records the outcome of sending one notification.
+type Delivery struct {
+	SubscriptionID string    `json:"subscriptionID"`
+	Team           string    `json:"team"`
+	DeliveryID     string    `json:"deliveryID"`
+	State          string    `json:"state"`
+	Attempts       int       `json:"attempts"`
+	StatusCode     int       `json:"statusCode,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Time           time.Time `json:"time"`
+}
+
+func (d Delivery) Succeeded() bool {
+	return len(d.Error) == 0
+}
+
+// History keeps the most recent notification deliveries for each team, persisted to a JSON file.
+type History struct {
+	lock       sync.Mutex
+	path       string
+	maxPerTeam int
+	deliveries map[string][]Delivery
+}
+
+func NewHistory(path string, maxPerTeam int) (*History, error) {
+	h := &History{
+		path:       path,
+		maxPerTeam: maxPerTeam,
+		deliveries: make(map[string][]Delivery),
+	}
+	err := persistence.LoadJSONFile(path, &h.deliveries)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *History) Add(delivery Delivery) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	deliveries := append(h.deliveries[delivery.Team], delivery)
+	if len(deliveries) > h.maxPerTeam {
+		deliveries = deliveries[len(deliveries)-h.maxPerTeam:]
+	}
+	h.deliveries[delivery.Team] = deliveries
+
+	return persistence.SaveJSONFile(h.path, h.deliveries)
+}
+
+// List returns a team's deliveries, newest first, optionally filtered by subscription.
+func (h *History) List(team, subscriptionID string) []Delivery {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	all := h.deliveries[team]
+	deliveries := make([]Delivery, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		if len(subscriptionID) == 0 || all[i].SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, all[i])
+		}
+	}
+
+	return deliveries
+}
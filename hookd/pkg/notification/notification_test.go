@@ -0,0 +1,247 @@
+package notification_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/notification"
+	"github.com/stretchr/testify/assert"
+)
+
+func status(state deployment.GithubDeploymentState) deployment.DeploymentStatus {
+	return deployment.DeploymentStatus{
+		Deployment: &deployment.DeploymentSpec{
+			Repository: &deployment.GithubRepository{
+				Owner: "navikt",
+				Name:  "deployment",
+			},
+			DeploymentID: 1,
+		},
+		DeliveryID:  "delivery",
+		State:       state,
+		Description: "something happened",
+		Team:        "aura",
+		Cluster:     "prod-fss",
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	failure := status(deployment.GithubDeploymentState_failure)
+	success := status(deployment.GithubDeploymentState_success)
+
+	subscription := notification.Subscription{Team: "aura"}
+	assert.True(t, subscription.Matches(&failure))
+	assert.True(t, subscription.Matches(&success))
+
+	subscription = notification.Subscription{Team: "aura", States: []string{"failure", "error"}, Clusters: []string{"prod-fss"}}
+	assert.True(t, subscription.Matches(&failure))
+	assert.False(t, subscription.Matches(&success))
+
+	subscription = notification.Subscription{Team: "aura", Clusters: []string{"dev-fss"}}
+	assert.False(t, subscription.Matches(&failure))
+
+	subscription = notification.Subscription{Team: "other"}
+	assert.False(t, subscription.Matches(&failure))
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	subscription := notification.Subscription{Team: "aura", URL: "https://hooks.slack.com/services/foo", Format: notification.FormatSlack}
+	assert.NoError(t, subscription.Validate())
+
+	subscription.States = []string{"exploded"}
+	assert.Error(t, subscription.Validate())
+
+	subscription = notification.Subscription{Team: "aura", URL: "ftp://example.com", Format: notification.FormatJSON}
+	assert.Error(t, subscription.Validate())
+
+	subscription = notification.Subscription{Team: "aura", URL: "https://example.com", Format: "xml"}
+	assert.Error(t, subscription.Validate())
+}
+
+func TestNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notification")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	received := make(chan notification.Payload, 1)
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		signature, _ := hex.DecodeString(r.Header.Get(api_v1.SignatureHeader))
+		assert.True(t, api_v1.ValidateMAC(body, signature, []byte("secret")))
+
+		payload := notification.Payload{}
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		received <- payload
+	}))
+	defer server.Close()
+
+	storage, err := notification.NewFileStorage(filepath.Join(dir, "subscriptions.json"))
+	assert.NoError(t, err)
+	history, err := notification.NewHistory(filepath.Join(dir, "history.json"), 10)
+	assert.NoError(t, err)
+
+	subscription, err := storage.Create(notification.Subscription{
+		Team:   "aura",
+		URL:    server.URL,
+		Secret: "secret",
+		Format: notification.FormatJSON,
+		States: []string{"failure"},
+	})
+	assert.NoError(t, err)
+
+	notifier := &notification.Notifier{
+		Storage:     storage,
+		History:     history,
+		HTTPClient:  server.Client(),
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		Workers:     2,
+		QueueSize:   10,
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go notifier.Run(stop)
+
+	notifier.Notify(status(deployment.GithubDeploymentState_success))
+	notifier.Notify(status(deployment.GithubDeploymentState_failure))
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, "failure", payload.State)
+		assert.Equal(t, "navikt/deployment", payload.Repository)
+	case <-time.After(time.Second):
+		t.Fatal("notification was not delivered")
+	}
+
+	// history is written after the response has been received
+	var deliveries []notification.Delivery
+	for i := 0; i < 100 && len(deliveries) == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+		deliveries = history.List("aura", subscription.ID)
+	}
+
+	assert.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Succeeded())
+	assert.Equal(t, 2, deliveries[0].Attempts)
+}
+
+func newTestNotifier(t *testing.T, dir, url string, queueSize int) (*notification.Notifier, *notification.History, *notification.Subscription) {
+	storage, err := notification.NewFileStorage(filepath.Join(dir, "subscriptions.json"))
+	assert.NoError(t, err)
+	history, err := notification.NewHistory(filepath.Join(dir, "history.json"), 10)
+	assert.NoError(t, err)
+
+	subscription, err := storage.Create(notification.Subscription{
+		Team:   "aura",
+		URL:    url,
+		Format: notification.FormatJSON,
+	})
+	assert.NoError(t, err)
+
+	notifier := &notification.Notifier{
+		Storage:     storage,
+		History:     history,
+		HTTPClient:  http.DefaultClient,
+		MaxAttempts: 3,
+		Backoff:     time.Hour,
+		Workers:     1,
+		QueueSize:   queueSize,
+	}
+
+	return notifier, history, subscription
+}
+
+func TestNotifierQueueFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notification")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notifier, history, subscription := newTestNotifier(t, dir, "http://localhost", 1)
+
+	// Nothing is delivered until the notifier runs, so the second notification does not fit in the queue.
+	notifier.Notify(status(deployment.GithubDeploymentState_success))
+	notifier.Notify(status(deployment.GithubDeploymentState_failure))
+
+	deliveries := history.List("aura", subscription.ID)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "failure", deliveries[0].State)
+		assert.Equal(t, 0, deliveries[0].Attempts)
+		assert.Equal(t, notification.ErrQueueFull.Error(), deliveries[0].Error)
+	}
+}
+
+func TestNotifierShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notification")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	attempted := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		attempted <- struct{}{}
+	}))
+	defer server.Close()
+
+	notifier, history, subscription := newTestNotifier(t, dir, server.URL, 10)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(stop)
+		close(done)
+	}()
+
+	notifier.Notify(status(deployment.GithubDeploymentState_failure))
+	<-attempted
+
+	// The retry is waiting for an hour; shutting down gives up on it right away.
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notifier did not stop")
+	}
+
+	deliveries := history.List("aura", subscription.ID)
+	if assert.Len(t, deliveries, 1) {
+		assert.False(t, deliveries[0].Succeeded())
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Contains(t, deliveries[0].Error, notification.ErrShutdown.Error())
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	st := status(deployment.GithubDeploymentState_failure)
+	payload := notification.NewPayload(&st, "https://logs")
+
+	data, err := payload.Marshal(notification.FormatSlack)
+	assert.NoError(t, err)
+
+	slack := notification.SlackPayload{}
+	assert.NoError(t, json.Unmarshal(data, &slack))
+	assert.Equal(t, ":x: Deployment of *navikt/deployment* to *prod-fss* by team *aura*: failure\nsomething happened\n<https://logs|Deployment logs>", slack.Text)
+}
@@ -0,0 +1,210 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/api/v1"
+	"github.com/navikt/deployment/hookd/pkg/logproxy"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventHeader            = "X-NAIS-Event"
+	LogFieldSubscriptionID = "subscription_id"
+	eventType              = "deployment_status"
+)
+
+var (
+	ErrQueueFull = fmt.Errorf("notification queue is full")
+	ErrShutdown  = fmt.Errorf("hookd shut down before the notification could be delivered")
+)
+
+// Notifier sends deployment statuses to all matching subscriptions.
+//
+// Deliveries are queued, holding at most QueueSize deliveries, and made by Workers concurrent workers.
+// Failed deliveries are retried with exponential backoff, starting at Backoff,
+// until MaxAttempts have been made. The outcome is recorded in History.
+type Notifier struct {
+	Storage     Storage
+	History     *History
+	HTTPClient  *http.Client
+	BaseURL     string
+	MaxAttempts int
+	Backoff     time.Duration
+	Workers     int
+	QueueSize   int
+
+	once  sync.Once
+	queue chan job
+}
+
+// job is a single notification waiting to be delivered.
+type job struct {
+	subscription Subscription
+	payload      Payload
+	logger       *log.Entry
+}
+
+func (n *Notifier) jobs() chan job {
+	n.once.Do(func() {
+		n.queue = make(chan job, n.QueueSize)
+	})
+	return n.queue
+}
+
+// Run delivers queued notifications until stop is closed.
+// Retries that are waiting when stop is closed are given up, and recorded as failed.
+func (n *Notifier) Run(stop <-chan struct{}) {
+	queue := n.jobs()
+	wg := sync.WaitGroup{}
+
+	workers := n.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case j := <-queue:
+					n.deliver(j.subscription, j.payload, j.logger, stop)
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Notify queues delivery of a deployment status to all matching subscriptions, and returns immediately.
+// If the queue is full, the delivery is recorded as failed.
+func (n *Notifier) Notify(status deployment.DeploymentStatus) {
+	logger := log.WithFields(status.LogFields())
+
+	subscriptions, err := n.Storage.List()
+	if err != nil {
+		logger.Errorf("Unable to retrieve notification subscriptions: %s", err)
+		return
+	}
+
+	logURL := logproxy.MakeURL(n.BaseURL, status.GetDeliveryID(), time.Now())
+	payload := NewPayload(&status, logURL)
+
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(&status) {
+			continue
+		}
+		j := job{
+			subscription: subscription,
+			payload:      payload,
+			logger:       logger.WithField(LogFieldSubscriptionID, subscription.ID),
+		}
+		select {
+		case n.jobs() <- j:
+		default:
+			delivery := newDelivery(subscription, payload)
+			delivery.Error = ErrQueueFull.Error()
+			n.record(subscription, delivery, j.logger)
+		}
+	}
+}
+
+func newDelivery(subscription Subscription, payload Payload) Delivery {
+	return Delivery{
+		SubscriptionID: subscription.ID,
+		Team:           subscription.Team,
+		DeliveryID:     payload.DeliveryID,
+		State:          payload.State,
+	}
+}
+
+func (n *Notifier) deliver(subscription Subscription, payload Payload, logger *log.Entry, stop <-chan struct{}) {
+	delivery := newDelivery(subscription, payload)
+
+	body, err := payload.Marshal(subscription.Format)
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		err = n.attempt(&delivery, subscription, body, logger, stop)
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+	}
+
+	n.record(subscription, delivery, logger)
+}
+
+// attempt posts a notification until it succeeds, MaxAttempts have been made, or stop is closed.
+func (n *Notifier) attempt(delivery *Delivery, subscription Subscription, body []byte, logger *log.Entry, stop <-chan struct{}) error {
+	var err error
+	backoff := n.Backoff
+	for delivery.Attempts = 1; ; delivery.Attempts++ {
+		delivery.StatusCode, err = n.post(subscription, body)
+		if err == nil || delivery.Attempts >= n.MaxAttempts {
+			return err
+		}
+		logger.Warnf("Notification delivery failed; retrying in %s: %s", backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return fmt.Errorf("%s; last error: %s", ErrShutdown, err)
+		}
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) record(subscription Subscription, delivery Delivery, logger *log.Entry) {
+	delivery.Time = time.Now()
+
+	switch {
+	case delivery.Succeeded():
+		logger.Infof("Notification delivered to %s", subscription.URL)
+	case delivery.Attempts == 0:
+		logger.Errorf("Discarding notification: %s", delivery.Error)
+	default:
+		logger.Errorf("Giving up notification delivery after %d attempts: %s", delivery.Attempts, delivery.Error)
+	}
+
+	if err := n.History.Add(delivery); err != nil {
+		logger.Errorf("Unable to record notification delivery: %s", err)
+	}
+}
+
+func (n *Notifier) post(subscription Subscription, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	if len(subscription.Secret) > 0 {
+		signature := api_v1.GenMAC(body, []byte(subscription.Secret))
+		req.Header.Set(api_v1.SignatureHeader, hex.EncodeToString(signature))
+	}
+
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+)
+
+// Payload is the JSON document sent to subscriptions in the 'json' format.
+type Payload struct {
+	DeliveryID   string `json:"deliveryID"`
+	Team         string `json:"team"`
+	Cluster      string `json:"cluster"`
+	Repository   string `json:"repository"`
+	DeploymentID int64  `json:"deploymentID"`
+	State        string `json:"state"`
+	Description  string `json:"description"`
+	LogURL       string `json:"logURL,omitempty"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+// SlackPayload is a message compatible with Slack incoming webhooks.
+type SlackPayload struct {
+	Text string `json:"text"`
+}
+
+var slackIcons = map[deployment.GithubDeploymentState]string{
+	deployment.GithubDeploymentState_success:     ":white_check_mark:",
+	deployment.GithubDeploymentState_error:       ":x:",
+	deployment.GithubDeploymentState_failure:     ":x:",
+	deployment.GithubDeploymentState_inactive:    ":zzz:",
+	deployment.GithubDeploymentState_in_progress: ":hourglass_flowing_sand:",
+	deployment.GithubDeploymentState_queued:      ":inbox_tray:",
+	deployment.GithubDeploymentState_pending:     ":raised_hand:",
+}
+
+func NewPayload(status *deployment.DeploymentStatus, logURL string) Payload {
+	return Payload{
+		DeliveryID:   status.GetDeliveryID(),
+		Team:         status.GetTeam(),
+		Cluster:      status.GetCluster(),
+		Repository:   status.GetDeployment().GetRepository().FullName(),
+		DeploymentID: status.GetDeployment().GetDeploymentID(),
+		State:        status.GetState().String(),
+		Description:  status.GetDescription(),
+		LogURL:       logURL,
+		Timestamp:    status.GetTimestamp(),
+	}
+}
+
+func (p Payload) Slack() SlackPayload {
+	state := deployment.GithubDeploymentState(deployment.GithubDeploymentState_value[p.State])
+	text := fmt.Sprintf("%s Deployment of *%s* to *%s* by team *%s*: %s\n%s",
+		slackIcons[state], p.Repository, p.Cluster, p.Team, p.State, p.Description,
+	)
+	if len(p.LogURL) > 0 {
+		text += fmt.Sprintf("\n<%s|Deployment logs>", p.LogURL)
+	}
+	return SlackPayload{Text: text}
+}
+
+// Marshal returns the payload encoded according to the subscription format.
+func (p Payload) Marshal(format Format) ([]byte, error) {
+	if format == FormatSlack {
+		return json.Marshal(p.Slack())
+	}
+	return json.Marshal(p)
+}
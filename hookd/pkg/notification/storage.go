@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+)
+
+var (
+	ErrNotFound = fmt.Errorf("subscription not found")
+)
+
+type Storage interface {
+	List() ([]Subscription, error)
+	Create(subscription Subscription) (*Subscription, error)
+	Delete(team, id string) error
+}
+
+type fileStorage struct {
+	lock          sync.Mutex
+	path          string
+	subscriptions []Subscription
+}
+
+// NewFileStorage returns a subscription storage backed by a JSON file.
+// Existing subscriptions are loaded from the file on startup.
+func NewFileStorage(path string) (Storage, error) {
+	s := &fileStorage{
+		path:          path,
+		subscriptions: make([]Subscription, 0),
+	}
+	err := persistence.LoadJSONFile(path, &s.subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStorage) List() ([]Subscription, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	subscriptions := make([]Subscription, len(s.subscriptions))
+	copy(subscriptions, s.subscriptions)
+
+	return subscriptions, nil
+}
+
+func (s *fileStorage) Create(subscription Subscription) (*Subscription, error) {
+	if err := subscription.Validate(); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("generate subscription id: %s", err)
+	}
+	subscription.ID = id.String()
+	subscription.Created = time.Now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	subscriptions := append(s.subscriptions, subscription)
+	if err := persistence.SaveJSONFile(s.path, subscriptions); err != nil {
+		return nil, err
+	}
+	s.subscriptions = subscriptions
+
+	return &subscription, nil
+}
+
+// Delete removes a subscription. Teams can only delete their own subscriptions.
+func (s *fileStorage) Delete(team, id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	subscriptions := make([]Subscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		if subscription.ID != id || subscription.Team != team {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+
+	if len(subscriptions) == len(s.subscriptions) {
+		return ErrNotFound
+	}
+
+	if err := persistence.SaveJSONFile(s.path, subscriptions); err != nil {
+		return err
+	}
+	s.subscriptions = subscriptions
+
+	return nil
+}
+
+// ForTeam returns all subscriptions belonging to a team.
+func ForTeam(storage Storage, team string) ([]Subscription, error) {
+	all, err := storage.List()
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := make([]Subscription, 0)
+	for _, subscription := range all {
+		if subscription.Team == team {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
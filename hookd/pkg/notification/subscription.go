@@ -0,0 +1,76 @@
+package notification
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+)
+
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatSlack Format = "slack"
+)
+
+// Subscription is a webhook endpoint registered by a team, receiving notifications
+// about the team's deployments. Empty lists of states and clusters match everything.
+type Subscription struct {
+	ID       string    `json:"id"`
+	Team     string    `json:"team"`
+	URL      string    `json:"url"`
+	Secret   string    `json:"secret,omitempty"`
+	Format   Format    `json:"format"`
+	States   []string  `json:"states,omitempty"`
+	Clusters []string  `json:"clusters,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+func (s *Subscription) Validate() error {
+	if len(s.Team) == 0 {
+		return fmt.Errorf("no team specified")
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must use http or https")
+	}
+
+	switch s.Format {
+	case FormatJSON, FormatSlack:
+	default:
+		return fmt.Errorf("format must be one of '%s' or '%s'", FormatJSON, FormatSlack)
+	}
+
+	for _, state := range s.States {
+		if _, ok := deployment.GithubDeploymentState_value[state]; !ok {
+			return fmt.Errorf("unknown deployment state '%s'", state)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether a deployment status should be delivered to this subscription.
+func (s *Subscription) Matches(status *deployment.DeploymentStatus) bool {
+	return s.Team == status.GetTeam() &&
+		contains(s.States, status.GetState().String()) &&
+		contains(s.Clusters, status.GetCluster())
+}
+
+func contains(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package outbox
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent wraps an error to signal that retrying the item will not help.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	_, ok := err.(*permanentError)
+	return ok
+}
@@ -0,0 +1,386 @@
+package outbox
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LogFieldOutbox = "outbox"
+	LogFieldItemID = "outbox_item_id"
+)
+
+var (
+	ErrFull     = fmt.Errorf("outbox is full")
+	ErrNotFound = fmt.Errorf("outbox item not found")
+	ErrInFlight = fmt.Errorf("outbox item is being processed")
+)
+
+// Policy controls how failed items are retried.
+//
+// The wait before retrying is Backoff, doubled for each attempt up to MaxBackoff, with random jitter.
+// A MaxAttempts of zero means that items are retried forever, and a MaxBackoff of zero that the wait is not limited.
+type Policy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Item is a unit of work waiting in the outbox.
+type Item struct {
+	ID          string    `json:"id"`
+	Key         string    `json:"key,omitempty"`
+	Summary     string    `json:"summary"`
+	Payload     []byte    `json:"payload"`
+	Attempts    int       `json:"attempts"`
+	Created     time.Time `json:"created"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Handler processes an item's payload. Failed items are retried unless the error is Permanent.
+type Handler func(payload []byte) error
+
+// Outbox is a persistent, bounded work queue with retries.
+//
+// Items with the same key are handled in the order they were pushed: an item is not handled
+// while an older item with the same key is waiting to be retried.
+//
+// Items are persisted to a JSON file, and are only removed after they have been handled
+// successfully, so queued work survives restarts. Items that fail permanently or exhaust their
+// attempts are moved to a dead letter list, which holds at most Capacity items.
+type Outbox struct {
+	Name     string
+	Capacity int
+	Policy   Policy
+
+	lock   sync.Mutex
+	path   string
+	state  state
+	wakeup chan struct{}
+	// active is the ID of the item being processed.
+	active string
+}
+
+type state struct {
+	Items []Item `json:"items"`
+	Dead  []Item `json:"dead"`
+}
+
+// New returns an outbox persisted to the specified path. Existing items are loaded from the file.
+func New(path, name string, capacity int, policy Policy) (*Outbox, error) {
+	o := &Outbox{
+		Name:     name,
+		Capacity: capacity,
+		Policy:   policy,
+		path:     path,
+		wakeup:   make(chan struct{}, 1),
+		state: state{
+			Items: make([]Item, 0),
+			Dead:  make([]Item, 0),
+		},
+	}
+	err := persistence.LoadJSONFile(path, &o.state)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Push adds a payload to the outbox. Items with the same non-empty key are handled in order.
+// The summary is shown when inspecting the outbox.
+func (o *Outbox) Push(key, summary string, payload []byte) error {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("generate item id: %s", err)
+	}
+
+	now := time.Now()
+	item := Item{
+		ID:          id.String(),
+		Key:         key,
+		Summary:     summary,
+		Payload:     payload,
+		Created:     now,
+		NextAttempt: now,
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if len(o.state.Items) >= o.Capacity {
+		return ErrFull
+	}
+
+	o.state.Items = append(o.state.Items, item)
+	if err := o.save(); err != nil {
+		o.state.Items = o.state.Items[:len(o.state.Items)-1]
+		return err
+	}
+
+	o.wake()
+
+	return nil
+}
+
+// Run handles items as they become due, until stop is closed.
+func (o *Outbox) Run(handler Handler, stop <-chan struct{}) {
+	logger := log.WithField(LogFieldOutbox, o.Name)
+
+	for {
+		item, wait := o.next(time.Now())
+		if item == nil {
+			timer := time.NewTimer(wait)
+			select {
+			case <-o.wakeup:
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			}
+			timer.Stop()
+			continue
+		}
+
+		err := handler(item.Payload)
+		o.complete(item, err, logger.WithField(LogFieldItemID, item.ID))
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// next returns the first item that is due for processing, or the time to wait until one is.
+// Items waiting behind an older item with the same key are skipped.
+func (o *Outbox) next(now time.Time) (*Item, time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	wait := time.Hour
+	blocked := make(map[string]bool)
+	for i := range o.state.Items {
+		item := o.state.Items[i]
+		if len(item.Key) > 0 {
+			if blocked[item.Key] {
+				continue
+			}
+			blocked[item.Key] = true
+		}
+		if !item.NextAttempt.After(now) {
+			o.active = item.ID
+			return &item, 0
+		}
+		if d := item.NextAttempt.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	return nil, wait
+}
+
+func (o *Outbox) complete(item *Item, err error, logger *log.Entry) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.active = ""
+
+	index := o.indexOf(item.ID)
+	if index < 0 {
+		// purged while being processed
+		return
+	}
+
+	current := &o.state.Items[index]
+	current.Attempts++
+
+	switch {
+	case err == nil:
+		o.remove(index)
+	case IsPermanent(err):
+		logger.Errorf("Giving up on '%s' after non-retriable error: %s", item.Summary, err)
+		current.LastError = err.Error()
+		o.bury(index)
+	case o.Policy.MaxAttempts > 0 && current.Attempts >= o.Policy.MaxAttempts:
+		logger.Errorf("Giving up on '%s' after %d attempts: %s", item.Summary, current.Attempts, err)
+		current.LastError = err.Error()
+		o.bury(index)
+	default:
+		backoff := o.backoff(current.Attempts)
+		logger.Warnf("Processing '%s' failed; retrying in %s: %s", item.Summary, backoff.Round(time.Millisecond), err)
+		current.LastError = err.Error()
+		current.NextAttempt = time.Now().Add(backoff)
+	}
+
+	if err := o.save(); err != nil {
+		logger.Errorf("Unable to persist outbox: %s", err)
+	}
+}
+
+// backoff returns the wait before the next attempt, with jitter between 50% and 100% of the exponential backoff.
+// Without a MaxBackoff, the backoff stops doubling before it overflows.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	limit := o.Policy.MaxBackoff
+	if limit <= 0 {
+		limit = math.MaxInt64 / 2
+	}
+	backoff := o.Policy.Backoff
+	for i := 1; i < attempts && backoff < limit; i++ {
+		if backoff > limit/2 {
+			backoff = limit
+			break
+		}
+		backoff *= 2
+	}
+	if backoff > limit {
+		backoff = limit
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (o *Outbox) indexOf(id string) int {
+	for i := range o.state.Items {
+		if o.state.Items[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (o *Outbox) remove(index int) Item {
+	item := o.state.Items[index]
+	o.state.Items = append(o.state.Items[:index], o.state.Items[index+1:]...)
+	return item
+}
+
+func (o *Outbox) bury(index int) {
+	o.state.Dead = append(o.state.Dead, o.remove(index))
+	if len(o.state.Dead) > o.Capacity {
+		o.state.Dead = o.state.Dead[len(o.state.Dead)-o.Capacity:]
+	}
+}
+
+func (o *Outbox) wake() {
+	select {
+	case o.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) save() error {
+	return persistence.SaveJSONFile(o.path, o.state)
+}
+
+// Stats summarizes the contents of an outbox.
+type Stats struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Queued   int    `json:"queued"`
+	Dead     int    `json:"dead"`
+	Items    []Item `json:"items,omitempty"`
+	DeadList []Item `json:"deadItems,omitempty"`
+}
+
+// Inspect returns the current contents of the outbox, oldest items first.
+func (o *Outbox) Inspect() Stats {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	stats := Stats{
+		Name:     o.Name,
+		Capacity: o.Capacity,
+		Queued:   len(o.state.Items),
+		Dead:     len(o.state.Dead),
+		Items:    make([]Item, len(o.state.Items)),
+		DeadList: make([]Item, len(o.state.Dead)),
+	}
+	copy(stats.Items, o.state.Items)
+	copy(stats.DeadList, o.state.Dead)
+	sort.SliceStable(stats.Items, func(i, j int) bool {
+		return stats.Items[i].Created.Before(stats.Items[j].Created)
+	})
+
+	return stats
+}
+
+// Len returns the number of queued items.
+func (o *Outbox) Len() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return len(o.state.Items)
+}
+
+// Purge removes a single item, queued or dead, from the outbox.
+func (o *Outbox) Purge(id string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if index := o.indexOf(id); index >= 0 {
+		o.remove(index)
+		return o.save()
+	}
+
+	for i := range o.state.Dead {
+		if o.state.Dead[i].ID == id {
+			o.state.Dead = append(o.state.Dead[:i], o.state.Dead[i+1:]...)
+			return o.save()
+		}
+	}
+
+	return ErrNotFound
+}
+
+// Withdraw removes the first queued item that matches, unless it is being processed.
+// Returns ErrNotFound if no queued item matches, and ErrInFlight if the item is being processed,
+// in which case it is left alone.
+func (o *Outbox) Withdraw(match func(item Item) bool) (*Item, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for i := range o.state.Items {
+		if !match(o.state.Items[i]) {
+			continue
+		}
+		if o.state.Items[i].ID == o.active {
+			item := o.state.Items[i]
+			return &item, ErrInFlight
+		}
+		item := o.remove(i)
+		if err := o.save(); err != nil {
+			o.state.Items = append(o.state.Items[:i], append([]Item{item}, o.state.Items[i:]...)...)
+			return nil, err
+		}
+		return &item, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// PurgeAll removes all items from the outbox, returning the number of items removed.
+// If dead is true, only the dead letter list is emptied.
+func (o *Outbox) PurgeAll(dead bool) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	count := len(o.state.Dead)
+	o.state.Dead = make([]Item, 0)
+	if !dead {
+		count += len(o.state.Items)
+		o.state.Items = make([]Item, 0)
+	}
+
+	return count, o.save()
+}
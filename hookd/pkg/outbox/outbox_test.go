@@ -0,0 +1,240 @@
+package outbox_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func tempdir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// wait polls until the condition is met, or fails the test after one second.
+func wait(t *testing.T, condition func() bool) {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("timed out waiting for outbox")
+}
+
+func TestOutboxCapacity(t *testing.T) {
+	dir, cleanup := tempdir(t)
+	defer cleanup()
+
+	box, err := outbox.New(filepath.Join(dir, "outbox.json"), "test", 2, outbox.Policy{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, box.Push("", "first", []byte("1")))
+	assert.NoError(t, box.Push("", "second", []byte("2")))
+	assert.Equal(t, outbox.ErrFull, box.Push("", "third", []byte("3")))
+	assert.Equal(t, 2, box.Len())
+}
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+	dir, cleanup := tempdir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "outbox.json")
+	box, err := outbox.New(path, "test", 10, outbox.Policy{})
+	assert.NoError(t, err)
+	assert.NoError(t, box.Push("", "first", []byte("payload")))
+
+	box, err = outbox.New(path, "test", 10, outbox.Policy{})
+	assert.NoError(t, err)
+
+	handled := make(chan string, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go box.Run(func(payload []byte) error {
+		handled <- string(payload)
+		return nil
+	}, stop)
+
+	assert.Equal(t, "payload", <-handled)
+	wait(t, func() bool { return box.Len() == 0 })
+}
+
+func TestOutboxRetry(t *testing.T) {
+	dir, cleanup := tempdir(t)
+	defer cleanup()
+
+	policy := outbox.Policy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond * 5}
+	box, err := outbox.New(filepath.Join(dir, "outbox.json"), "test", 10, policy)
+	assert.NoError(t, err)
+
+	attempts := make(map[string]int)
+	handled := make(chan string, 16)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go box.Run(func(payload []byte) error {
+		id := string(payload)
+		attempts[id]++
+		handled <- id
+		switch id {
+		case "flaky":
+			if attempts[id] < 2 {
+				return fmt.Errorf("temporary failure")
+			}
+			return nil
+		case "broken":
+			return outbox.Permanent(fmt.Errorf("permanent failure"))
+		default:
+			return fmt.Errorf("always fails")
+		}
+	}, stop)
+
+	assert.NoError(t, box.Push("", "flaky", []byte("flaky")))
+	assert.NoError(t, box.Push("", "broken", []byte("broken")))
+	assert.NoError(t, box.Push("", "failing", []byte("failing")))
+
+	wait(t, func() bool { return box.Len() == 0 })
+
+	stats := box.Inspect()
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, 2, stats.Dead)
+	assert.Len(t, handled, 6)
+
+	for _, item := range stats.DeadList {
+		switch item.Summary {
+		case "broken":
+			assert.Equal(t, 1, item.Attempts)
+		case "failing":
+			assert.Equal(t, 3, item.Attempts)
+			assert.Equal(t, "always fails", item.LastError)
+		default:
+			t.Errorf("unexpected dead item %s", item.Summary)
+		}
+	}
+
+	assert.NoError(t, box.Purge(stats.DeadList[0].ID))
+	assert.Equal(t, outbox.ErrNotFound, box.Purge(stats.DeadList[0].ID))
+
+	count, err := box.PurgeAll(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestOutboxOrdersItemsWithTheSameKey(t *testing.T) {
+	dir, cleanup := tempdir(t)
+	defer cleanup()
+
+	policy := outbox.Policy{MaxAttempts: 3, Backoff: time.Millisecond * 50, MaxBackoff: time.Millisecond * 50}
+	box, err := outbox.New(filepath.Join(dir, "outbox.json"), "test", 10, policy)
+	assert.NoError(t, err)
+
+	assert.NoError(t, box.Push("deployment", "in_progress", []byte("in_progress")))
+	assert.NoError(t, box.Push("deployment", "success", []byte("success")))
+	assert.NoError(t, box.Push("other", "other", []byte("other")))
+
+	handled := make(chan string, 16)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	failed := false
+	go box.Run(func(payload []byte) error {
+		handled <- string(payload)
+		if string(payload) == "in_progress" && !failed {
+			failed = true
+			return fmt.Errorf("temporary failure")
+		}
+		return nil
+	}, stop)
+
+	wait(t, func() bool { return box.Len() == 0 })
+	close(handled)
+
+	order := make([]string, 0)
+	for payload := range handled {
+		order = append(order, payload)
+	}
+
+	// The status of the other deployment is not held up, but the success status waits for the retry.
+	assert.Equal(t, []string{"in_progress", "other", "in_progress", "success"}, order)
+}
+
+func TestOutboxBackoffWithoutLimit(t *testing.T) {
+	dir, cleanup := tempdir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "outbox.json")
+	state := `{"items": [{"id": "1", "summary": "retried", "attempts": 200, "nextAttempt": "2020-01-01T00:00:00Z"}], "dead": []}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(state), 0600))
+
+	box, err := outbox.New(path, "test", 10, outbox.Policy{Backoff: time.Second})
+	assert.NoError(t, err)
+
+	handled := make(chan struct{}, 16)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go box.Run(func(payload []byte) error {
+		handled <- struct{}{}
+		return fmt.Errorf("always fails")
+	}, stop)
+
+	<-handled
+	wait(t, func() bool { return box.Inspect().Items[0].Attempts == 201 })
+
+	// An overflowing backoff would schedule the next attempt right away.
+	item := box.Inspect().Items[0]
+	assert.True(t, item.NextAttempt.After(time.Now().Add(time.Hour*24*365)), "next attempt at %s", item.NextAttempt)
+	assert.Len(t, handled, 0)
+}
+
+func TestOutboxWithdraw(t *testing.T) {
+	dir, cleanup := tempdir(t)
+	defer cleanup()
+
+	box, err := outbox.New(filepath.Join(dir, "outbox.json"), "test", 10, outbox.Policy{})
+	assert.NoError(t, err)
+	assert.NoError(t, box.Push("", "first", []byte("1")))
+	assert.NoError(t, box.Push("", "second", []byte("2")))
+
+	summary := func(summary string) func(item outbox.Item) bool {
+		return func(item outbox.Item) bool { return item.Summary == summary }
+	}
+
+	item, err := box.Withdraw(summary("second"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(item.Payload))
+	assert.Equal(t, 1, box.Len())
+
+	_, err = box.Withdraw(summary("second"))
+	assert.Equal(t, outbox.ErrNotFound, err)
+
+	// Items that are being processed are left alone.
+	processing := make(chan struct{})
+	release := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go box.Run(func(payload []byte) error {
+		close(processing)
+		<-release
+		return nil
+	}, stop)
+
+	<-processing
+	_, err = box.Withdraw(summary("first"))
+	assert.Equal(t, outbox.ErrInFlight, err)
+	assert.Equal(t, 1, box.Len())
+
+	close(release)
+	wait(t, func() bool { return box.Len() == 0 })
+}
@@ -2,6 +2,7 @@ package persistence
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -21,14 +22,23 @@ var (
 )
 
 const (
-	NotFoundMessage          = "The specified key does not exist."
+	ApiKeyBackendVault      = "vault"
+	ApiKeyBackendVaultKV2   = "vault-kv2"
+	ApiKeyBackendKubernetes = "kubernetes"
+	ApiKeyBackendFile       = "file"
+)
+
+const (
 	RefreshIntervalFactor    = 0.8
 	InitialTokenWaitDuration = 1 * time.Millisecond
+	TokenLookupPath          = "/v1/auth/token/lookup-self"
 )
 
 type ApiKeyStorage interface {
 	Read(team string) ([]byte, error)
 	Write(team string, key []byte) error
+	// Delete removes a team's API key. Deleting a key that does not exist is not an error.
+	Delete(team string) error
 	IsErrNotFound(err error) bool
 }
 
@@ -60,10 +70,6 @@ type VaultAuthResponse struct {
 	} `json:"auth"`
 }
 
-type VaultWriteRequest struct {
-	Key string `json:"key"`
-}
-
 func (s *VaultApiKeyStorage) refreshToken() error {
 	u, err := url.Parse(s.Address)
 
@@ -167,8 +173,8 @@ func (s *VaultApiKeyStorage) Write(team string, key []byte) error {
 
 	u.Path = path.Join(s.Path, team)
 
-	writeRequest := &VaultWriteRequest{
-		Key: hex.EncodeToString(key),
+	writeRequest := map[string]string{
+		s.KeyName: hex.EncodeToString(key),
 	}
 	payload, err := json.Marshal(writeRequest)
 	if err != nil {
@@ -198,10 +204,83 @@ func (s *VaultApiKeyStorage) Write(team string, key []byte) error {
 	return nil
 }
 
+func (s *VaultApiKeyStorage) Delete(team string) error {
+	u, err := url.Parse(s.Address)
+
+	if err != nil {
+		return fmt.Errorf("unable to construct URL to Vault: %s", err)
+	}
+
+	u.Path = path.Join(s.Path, team)
+
+	req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+
+	if err != nil {
+		return fmt.Errorf("unable to create HTTP request: %s", err)
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	resp, err := s.HttpClient.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("delete team API key from Vault: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		errmsg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("delete team API key from Vault: %s; %s", resp.Status, errmsg)
+	}
+
+	log.Infof("Deleted Vault team API key for team '%s', response was %s", team, resp.Status)
+
+	return nil
+}
+
+// Ping checks that Vault is reachable, and that the current token is valid.
+func (s *VaultApiKeyStorage) Ping(ctx context.Context) error {
+	u, err := url.Parse(s.Address)
+
+	if err != nil {
+		return fmt.Errorf("unable to construct URL to Vault: %s", err)
+	}
+
+	u.Path = TokenLookupPath
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+
+	if err != nil {
+		return fmt.Errorf("unable to create HTTP request: %s", err)
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	resp, err := s.HttpClient.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return fmt.Errorf("unable to reach Vault: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return fmt.Errorf("Vault token is invalid or expired")
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Vault returned HTTP %d: %s", resp.StatusCode, string(body))
+	}
+}
+
 func (s *VaultApiKeyStorage) IsErrNotFound(err error) bool {
 	return err == ErrNotFound
 }
 
+// StaticKeyApiKeyStorage returns the same key for every team.
 type StaticKeyApiKeyStorage struct {
 	Key []byte
 }
@@ -0,0 +1,48 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/cache"
+)
+
+// CachedApiKeyStorage keeps API keys read from another storage backend in memory.
+// Teams without an API key are cached as well, for a shorter time.
+type CachedApiKeyStorage struct {
+	ApiKeyStorage
+	cache *cache.Cache
+}
+
+func NewCachedApiKeyStorage(storage ApiKeyStorage, ttl, negativeTTL time.Duration) *CachedApiKeyStorage {
+	return &CachedApiKeyStorage{
+		ApiKeyStorage: storage,
+		cache:         cache.New("apikeys", ttl, negativeTTL, storage.IsErrNotFound),
+	}
+}
+
+func (s *CachedApiKeyStorage) Read(team string) ([]byte, error) {
+	key, err := s.cache.Get(team, func() (interface{}, error) {
+		return s.ApiKeyStorage.Read(team)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key.([]byte), nil
+}
+
+// Write stores a new API key, and removes the old one from the cache.
+func (s *CachedApiKeyStorage) Write(team string, key []byte) error {
+	defer s.Invalidate(team)
+	return s.ApiKeyStorage.Write(team, key)
+}
+
+// Delete removes an API key from both the backend and the cache.
+func (s *CachedApiKeyStorage) Delete(team string) error {
+	defer s.Invalidate(team)
+	return s.ApiKeyStorage.Delete(team)
+}
+
+// Invalidate removes a team's API key from the cache.
+func (s *CachedApiKeyStorage) Invalidate(team string) {
+	s.cache.Invalidate(team)
+}
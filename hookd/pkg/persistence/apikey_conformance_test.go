@@ -0,0 +1,357 @@
+package persistence_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const keyName = "apikey"
+
+// fakeVaultKV1 serves a minimal Vault KV version 1 API.
+func fakeVaultKV1() *httptest.Server {
+	lock := sync.Mutex{}
+	secrets := make(map[string]map[string]string)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := secrets[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		case http.MethodPost:
+			data := make(map[string]string)
+			json.NewDecoder(r.Body).Decode(&data)
+			secrets[r.URL.Path] = data
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			delete(secrets, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+// fakeVaultKV2 serves a minimal Vault KV version 2 API, with versioning, soft deletes and check-and-set.
+// Soft deleted versions are kept as nil.
+func fakeVaultKV2() *httptest.Server {
+	lock := sync.Mutex{}
+	secrets := make(map[string][]map[string]string)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if strings.Contains(r.URL.Path, "/metadata/") {
+			path := strings.Replace(r.URL.Path, "/metadata/", "/data/", 1)
+			versions, ok := secrets[path]
+			switch {
+			case r.Method == http.MethodDelete:
+				delete(secrets, path)
+				w.WriteHeader(http.StatusNoContent)
+			case !ok:
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"data": map[string]interface{}{"current_version": len(versions)},
+				})
+			}
+			return
+		}
+
+		if !strings.Contains(r.URL.Path, "/data/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		versions := secrets[r.URL.Path]
+
+		switch r.Method {
+		case http.MethodGet:
+			version := len(versions)
+			if v := r.URL.Query().Get("version"); len(v) > 0 {
+				version, _ = strconv.Atoi(v)
+			}
+			if version < 1 || version > len(versions) || versions[version-1] == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data":     versions[version-1],
+					"metadata": map[string]interface{}{"version": version},
+				},
+			})
+		case http.MethodPost:
+			req := persistence.VaultKV2WriteRequest{}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Options.CAS != len(versions) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["check-and-set parameter did not match the current version"]}`))
+				return
+			}
+			secrets[r.URL.Path] = append(versions, req.Data)
+			w.WriteHeader(http.StatusOK)
+		case http.MethodDelete:
+			if len(versions) > 0 {
+				versions[len(versions)-1] = nil
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+// fakeKubernetes serves a minimal Kubernetes API for secrets in a single namespace.
+func fakeKubernetes() *httptest.Server {
+	lock := sync.Mutex{}
+	secrets := make(map[string]json.RawMessage)
+	prefix := "/api/v1/namespaces/nais/secrets"
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		w.Header().Set("content-type", "application/json")
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+		switch r.Method {
+		case http.MethodGet:
+			secret, ok := secrets[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+				return
+			}
+			w.Write(secret)
+		case http.MethodPost, http.MethodPut:
+			secret := struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			}{}
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &secret)
+			secrets[secret.Metadata.Name] = body
+			w.Write(body)
+		case http.MethodDelete:
+			if _, ok := secrets[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+				return
+			}
+			delete(secrets, name)
+			w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Success"}`))
+		}
+	}))
+}
+
+func testApiKeyStorage(t *testing.T, storage persistence.ApiKeyStorage) {
+	_, err := storage.Read("aura")
+	assert.Error(t, err)
+	assert.True(t, storage.IsErrNotFound(err))
+
+	err = storage.Write("aura", []byte("first"))
+	assert.NoError(t, err)
+
+	key, err := storage.Read("aura")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), key)
+
+	err = storage.Write("aura", []byte("second"))
+	assert.NoError(t, err)
+
+	key, err = storage.Read("aura")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), key)
+
+	_, err = storage.Read("nais")
+	assert.True(t, storage.IsErrNotFound(err))
+
+	err = storage.Write("revoked", []byte("first"))
+	assert.NoError(t, err)
+
+	err = storage.Delete("revoked")
+	assert.NoError(t, err)
+
+	_, err = storage.Read("revoked")
+	assert.True(t, storage.IsErrNotFound(err))
+
+	err = storage.Delete("revoked")
+	assert.NoError(t, err)
+
+	err = storage.Write("revoked", []byte("second"))
+	assert.NoError(t, err)
+
+	key, err = storage.Read("revoked")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), key)
+}
+
+func TestApiKeyStorageConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv1 := fakeVaultKV1()
+	defer kv1.Close()
+
+	kv2 := fakeVaultKV2()
+	defer kv2.Close()
+
+	kube := fakeKubernetes()
+	defer kube.Close()
+
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: kube.URL})
+	assert.NoError(t, err)
+
+	file, err := persistence.NewFileApiKeyStorage(filepath.Join(dir, "apikeys.json"))
+	assert.NoError(t, err)
+
+	backends := map[string]persistence.ApiKeyStorage{
+		"vault": &persistence.VaultApiKeyStorage{
+			Address:    kv1.URL,
+			Path:       "/v1/apikey/nais-deploy",
+			KeyName:    keyName,
+			HttpClient: kv1.Client(),
+		},
+		"vault-kv2": &persistence.VaultKV2ApiKeyStorage{
+			VaultApiKeyStorage: persistence.VaultApiKeyStorage{
+				Address:    kv2.URL,
+				Path:       "/v1/apikey",
+				KeyName:    keyName,
+				HttpClient: kv2.Client(),
+			},
+		},
+		"kubernetes": &persistence.KubernetesApiKeyStorage{
+			Client:    kubeClient,
+			Namespace: "nais",
+			KeyName:   keyName,
+		},
+		"file": file,
+	}
+
+	for name, storage := range backends {
+		t.Run(name, func(t *testing.T) {
+			testApiKeyStorage(t, storage)
+		})
+	}
+
+	t.Run("file backend persists keys", func(t *testing.T) {
+		reloaded, err := persistence.NewFileApiKeyStorage(filepath.Join(dir, "apikeys.json"))
+		assert.NoError(t, err)
+		key, err := reloaded.Read("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("second"), key)
+	})
+}
+
+func TestVaultKV2ApiKeyStorage(t *testing.T) {
+	kv2 := fakeVaultKV2()
+	defer kv2.Close()
+
+	storage := &persistence.VaultKV2ApiKeyStorage{
+		VaultApiKeyStorage: persistence.VaultApiKeyStorage{
+			Address:    kv2.URL,
+			Path:       "/v1/apikey",
+			KeyName:    keyName,
+			HttpClient: kv2.Client(),
+		},
+	}
+
+	assert.NoError(t, storage.Write("aura", []byte("first")))
+	assert.NoError(t, storage.Write("aura", []byte("second")))
+
+	t.Run("previous versions can be read", func(t *testing.T) {
+		key, err := storage.ReadVersion("aura", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("first"), key)
+
+		version, err := storage.CurrentVersion("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, version)
+	})
+
+	t.Run("nonexistent versions are not found", func(t *testing.T) {
+		_, err := storage.ReadVersion("aura", 3)
+		assert.True(t, storage.IsErrNotFound(err))
+	})
+
+	t.Run("writes against a stale version are rejected", func(t *testing.T) {
+		assert.Equal(t, persistence.ErrCheckAndSet, storage.WriteVersion("aura", []byte("stale"), 1))
+		assert.Equal(t, persistence.ErrCheckAndSet, storage.WriteVersion("aura", []byte("stale"), 0))
+
+		key, err := storage.Read("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("second"), key)
+
+		assert.NoError(t, storage.WriteVersion("aura", []byte("third"), 2))
+		key, err = storage.Read("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("third"), key)
+	})
+
+	t.Run("soft deleted keys can be replaced", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, kv2.URL+"/v1/apikey/data/aura", nil)
+		assert.NoError(t, err)
+		resp, err := kv2.Client().Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		_, err = storage.Read("aura")
+		assert.True(t, storage.IsErrNotFound(err))
+
+		version, err := storage.CurrentVersion("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, version, "deleted versions still count")
+
+		assert.NoError(t, storage.Write("aura", []byte("fourth")))
+		key, err := storage.Read("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("fourth"), key)
+	})
+}
+
+func TestCachedApiKeyStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	backend, err := persistence.NewFileApiKeyStorage(filepath.Join(dir, "apikeys.json"))
+	assert.NoError(t, err)
+
+	storage := persistence.NewCachedApiKeyStorage(backend, time.Minute, time.Minute)
+
+	testApiKeyStorage(t, storage)
+
+	t.Run("keys written to the backend directly are not seen until invalidated", func(t *testing.T) {
+		assert.NoError(t, backend.Write("aura", []byte("third")))
+
+		key, err := storage.Read("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("second"), key)
+
+		storage.Invalidate("aura")
+
+		key, err = storage.Read("aura")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("third"), key)
+	})
+}
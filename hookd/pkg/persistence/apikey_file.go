@@ -0,0 +1,86 @@
+package persistence
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// FileApiKeyStorage stores hex encoded API keys in a local JSON file. Intended for local development.
+type FileApiKeyStorage struct {
+	lock sync.Mutex
+	path string
+	keys map[string]string
+}
+
+func NewFileApiKeyStorage(path string) (*FileApiKeyStorage, error) {
+	s := &FileApiKeyStorage{
+		path: path,
+		keys: make(map[string]string),
+	}
+	err := LoadJSONFile(path, &s.keys)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileApiKeyStorage) Read(team string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	encoded, ok := s.keys[team]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode api key for team '%s': %s", team, err)
+	}
+
+	return key, nil
+}
+
+func (s *FileApiKeyStorage) Write(team string, key []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	previous, existed := s.keys[team]
+	s.keys[team] = hex.EncodeToString(key)
+
+	err := SaveJSONFile(s.path, s.keys)
+	if err != nil {
+		if existed {
+			s.keys[team] = previous
+		} else {
+			delete(s.keys, team)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileApiKeyStorage) Delete(team string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	previous, existed := s.keys[team]
+	if !existed {
+		return nil
+	}
+	delete(s.keys, team)
+
+	err := SaveJSONFile(s.path, s.keys)
+	if err != nil {
+		s.keys[team] = previous
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileApiKeyStorage) IsErrNotFound(err error) bool {
+	return err == ErrNotFound
+}
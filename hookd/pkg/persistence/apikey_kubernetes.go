@@ -0,0 +1,128 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	ApiKeySecretPrefix = "apikey-"
+	ApiKeyTeamLabel    = "team"
+)
+
+// KubernetesApiKeyStorage stores API keys as Kubernetes Secrets, one secret per team.
+//
+// Secrets are named 'apikey-<team>' and labeled with the team name.
+// The key is stored in the secret data field KeyName.
+type KubernetesApiKeyStorage struct {
+	Client    kubernetes.Interface
+	Namespace string
+	KeyName   string
+}
+
+// NewKubernetesApiKeyStorage connects to the cluster specified by $KUBECONFIG,
+// or the cluster hookd is running in if it is not set.
+func NewKubernetesApiKeyStorage(namespace, keyName string) (*KubernetesApiKeyStorage, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+	if err != nil {
+		return nil, fmt.Errorf("configure Kubernetes client: %s", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("configure Kubernetes client: %s", err)
+	}
+
+	return &KubernetesApiKeyStorage{
+		Client:    client,
+		Namespace: namespace,
+		KeyName:   keyName,
+	}, nil
+}
+
+func (s *KubernetesApiKeyStorage) secretName(team string) string {
+	return ApiKeySecretPrefix + team
+}
+
+func (s *KubernetesApiKeyStorage) Read(team string) ([]byte, error) {
+	secret, err := s.Client.CoreV1().Secrets(s.Namespace).Get(s.secretName(team), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("get api key secret: %s", err)
+	}
+
+	key, ok := secret.Data[s.KeyName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return key, nil
+}
+
+// Write creates or updates the secret holding a team's API key.
+// Updates are based on the resource version that was read, so concurrent writes result in a conflict error.
+func (s *KubernetesApiKeyStorage) Write(team string, key []byte) error {
+	client := s.Client.CoreV1().Secrets(s.Namespace)
+
+	secret, err := client.Get(s.secretName(team), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.secretName(team),
+				Namespace: s.Namespace,
+				Labels: map[string]string{
+					ApiKeyTeamLabel: team,
+				},
+			},
+			Type: v1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				s.KeyName: key,
+			},
+		}
+		_, err = client.Create(secret)
+		if err != nil {
+			return fmt.Errorf("create api key secret: %s", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get api key secret: %s", err)
+	}
+
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[s.KeyName] = key
+
+	_, err = client.Update(secret)
+	if err != nil {
+		return fmt.Errorf("update api key secret: %s", err)
+	}
+
+	return nil
+}
+
+func (s *KubernetesApiKeyStorage) Delete(team string) error {
+	err := s.Client.CoreV1().Secrets(s.Namespace).Delete(s.secretName(team), &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete api key secret: %s", err)
+	}
+	return nil
+}
+
+// Ping checks that the Kubernetes API server is reachable, and that secrets can be listed.
+func (s *KubernetesApiKeyStorage) Ping(ctx context.Context) error {
+	_, err := s.Client.CoreV1().Secrets(s.Namespace).List(metav1.ListOptions{Limit: 1})
+	return err
+}
+
+func (s *KubernetesApiKeyStorage) IsErrNotFound(err error) bool {
+	return err == ErrNotFound
+}
@@ -1,6 +1,7 @@
 package persistence_test
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -73,6 +74,36 @@ func TestVaultApiKeyStorage(t *testing.T) {
 	})
 }
 
+func TestVaultApiKeyStoragePing(t *testing.T) {
+	defaults := config.DefaultConfig().Vault
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, persistence.TokenLookupPath, r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer "+defaults.Token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = io.WriteString(w, `{"data": {}}`)
+	}))
+
+	defer server.Close()
+
+	vault := persistence.VaultApiKeyStorage{
+		HttpClient: server.Client(),
+		Address:    server.URL,
+		Token:      defaults.Token,
+	}
+
+	t.Run("succeeds with a valid token", func(t *testing.T) {
+		assert.NoError(t, vault.Ping(context.Background()))
+	})
+
+	t.Run("fails with an invalid token", func(t *testing.T) {
+		invalid := vault
+		invalid.Token = "invalid"
+		assert.Error(t, invalid.Ping(context.Background()))
+	})
+}
+
 func teamname(u *url.URL) string {
 	fragments := strings.Split(u.Path, "/")
 	return fragments[len(fragments)-1]
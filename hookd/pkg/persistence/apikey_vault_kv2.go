@@ -0,0 +1,266 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+var (
+	ErrCheckAndSet = fmt.Errorf("api key was modified concurrently")
+)
+
+// VaultKV2ApiKeyStorage stores API keys in a Vault KV version 2 secrets engine.
+//
+// Path is the mount path of the secrets engine, e.g. /v1/apikey.
+// Authentication and token refresh work the same way as for KV version 1.
+type VaultKV2ApiKeyStorage struct {
+	VaultApiKeyStorage
+}
+
+type VaultKV2Metadata struct {
+	Version int `json:"version"`
+}
+
+type VaultKV2Response struct {
+	Data struct {
+		Data     map[string]string `json:"data"`
+		Metadata VaultKV2Metadata  `json:"metadata"`
+	} `json:"data"`
+}
+
+type VaultKV2MetadataResponse struct {
+	Data struct {
+		CurrentVersion int `json:"current_version"`
+	} `json:"data"`
+}
+
+type VaultKV2WriteRequest struct {
+	Options struct {
+		CAS int `json:"cas"`
+	} `json:"options"`
+	Data map[string]string `json:"data"`
+}
+
+func (s *VaultKV2ApiKeyStorage) url(team string, version int) (string, error) {
+	u, err := url.Parse(s.Address)
+	if err != nil {
+		return "", fmt.Errorf("unable to construct URL to Vault: %s", err)
+	}
+
+	u.Path = path.Join(s.Path, "data", team)
+	if version > 0 {
+		u.RawQuery = url.Values{"version": []string{strconv.Itoa(version)}}.Encode()
+	}
+
+	return u.String(), nil
+}
+
+func (s *VaultKV2ApiKeyStorage) metadataURL(team string) (string, error) {
+	u, err := url.Parse(s.Address)
+	if err != nil {
+		return "", fmt.Errorf("unable to construct URL to Vault: %s", err)
+	}
+
+	u.Path = path.Join(s.Path, "metadata", team)
+
+	return u.String(), nil
+}
+
+// Read returns the current version of a team's API key.
+func (s *VaultKV2ApiKeyStorage) Read(team string) ([]byte, error) {
+	return s.ReadVersion(team, 0)
+}
+
+// ReadVersion returns a specific version of a team's API key. Version 0 means the current version.
+func (s *VaultKV2ApiKeyStorage) ReadVersion(team string, version int) ([]byte, error) {
+	resp, err := s.read(team, version)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, ok := resp.Data.Data[s.KeyName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return hex.DecodeString(encoded)
+}
+
+// CurrentVersion returns the version number of a team's latest API key, or 0 if the team has never had a key.
+//
+// The version is taken from the key's metadata, so that it is also known when the latest version has been deleted.
+func (s *VaultKV2ApiKeyStorage) CurrentVersion(team string) (int, error) {
+	u, err := s.metadataURL(team)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create HTTP request: %s", err)
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	resp, err := s.HttpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get key metadata from Vault: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		metadata := &VaultKV2MetadataResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(metadata); err != nil {
+			return 0, fmt.Errorf("unable to unmarshal response from Vault: %s", err)
+		}
+		return metadata.Data.CurrentVersion, nil
+	case http.StatusNotFound:
+		return 0, nil
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return 0, fmt.Errorf("Vault returned HTTP %d: %s", resp.StatusCode, string(body))
+	}
+}
+
+func (s *VaultKV2ApiKeyStorage) read(team string, version int) (*VaultKV2Response, error) {
+	u, err := s.url(team, version)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create HTTP request: %s", err)
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	resp, err := s.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get key from Vault: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		vaultResp := &VaultKV2Response{}
+		if err := json.NewDecoder(resp.Body).Decode(vaultResp); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal response from Vault: %s", err)
+		}
+		// Deleted versions are returned without data.
+		if vaultResp.Data.Data == nil {
+			return nil, ErrNotFound
+		}
+		return vaultResp, nil
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Vault returned HTTP %d: %s", resp.StatusCode, string(body))
+	}
+}
+
+// Write stores a new version of a team's API key, replacing the latest version.
+//
+// The write uses check-and-set against the latest version, so that two concurrent writes cannot both succeed.
+// Callers that must not overwrite a key written after they read it should use WriteVersion instead.
+func (s *VaultKV2ApiKeyStorage) Write(team string, key []byte) error {
+	version, err := s.CurrentVersion(team)
+	if err != nil {
+		return fmt.Errorf("write team API key to Vault: %s", err)
+	}
+
+	return s.WriteVersion(team, key, version)
+}
+
+// WriteVersion stores a new version of a team's API key, provided that the latest version is the given one.
+// Version 0 means that the team must never have had a key. ErrCheckAndSet is returned if the key has been
+// written in the meantime.
+func (s *VaultKV2ApiKeyStorage) WriteVersion(team string, key []byte, version int) error {
+	writeRequest := &VaultKV2WriteRequest{
+		Data: map[string]string{
+			s.KeyName: hex.EncodeToString(key),
+		},
+	}
+	writeRequest.Options.CAS = version
+
+	payload, err := json.Marshal(writeRequest)
+	if err != nil {
+		return fmt.Errorf("create api key payload: %s", err)
+	}
+
+	u, err := s.url(team, 0)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("unable to create HTTP request: %s", err)
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := s.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("write team API key to Vault: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		// Vault responds with 400 Bad Request when the check-and-set version does not match.
+		errmsg, _ := ioutil.ReadAll(resp.Body)
+		if bytes.Contains(errmsg, []byte("check-and-set")) {
+			return ErrCheckAndSet
+		}
+		return fmt.Errorf("write team API key to Vault: %s; %s", resp.Status, errmsg)
+	case resp.StatusCode >= 400:
+		errmsg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("write team API key to Vault: %s; %s", resp.Status, errmsg)
+	}
+
+	return nil
+}
+
+// Delete permanently removes all versions of a team's API key, along with its metadata.
+// A new key written afterwards starts over at version 1.
+func (s *VaultKV2ApiKeyStorage) Delete(team string) error {
+	u, err := s.metadataURL(team)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create HTTP request: %s", err)
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	resp, err := s.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete team API key from Vault: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		errmsg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("delete team API key from Vault: %s; %s", resp.Status, errmsg)
+	}
+
+	return nil
+}
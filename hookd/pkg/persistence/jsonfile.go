@@ -0,0 +1,67 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// LoadJSONFile unmarshals the contents of a JSON file into target.
+// A missing file is not considered an error, and leaves target untouched.
+func LoadJSONFile(path string, target interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read %s: %s", path, err)
+	}
+
+	err = json.Unmarshal(data, target)
+	if err != nil {
+		return fmt.Errorf("unmarshal %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// SaveJSONFile atomically replaces the contents of a file with the JSON representation of source.
+// The parent directory is created if it does not exist.
+func SaveJSONFile(path string, source interface{}) error {
+	data, err := json.MarshalIndent(source, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s: %s", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("create directory %s: %s", dir, err)
+	}
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %s", err)
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write %s: %s", tmp.Name(), err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("replace %s: %s", path, err)
+	}
+
+	return nil
+}
@@ -2,24 +2,27 @@ package persistence
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/minio/minio-go"
 	"github.com/navikt/deployment/hookd/pkg/config"
 	log "github.com/sirupsen/logrus"
 )
 
-type TeamRepositoryStorage interface {
-	Read(repository string) ([]string, error)
-	Write(repository string, teams []string) error
-	IsErrNotFound(err error) bool
-}
+const (
+	s3NoSuchKey = "NoSuchKey"
+)
 
 type s3storage struct {
 	config config.S3
 	client *minio.Client
+
+	lock        sync.Mutex
+	bucketReady bool
 }
 
 func NewS3StorageBackend(cfg config.S3) (TeamRepositoryStorage, error) {
@@ -33,7 +36,25 @@ func NewS3StorageBackend(cfg config.S3) (TeamRepositoryStorage, error) {
 	}, nil
 }
 
+// ensureBucket creates the bucket if it does not exist.
+// Once the bucket is known to exist, it is not checked again.
 func (s *s3storage) ensureBucket() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.bucketReady {
+		return nil
+	}
+
+	if err := s.createBucket(); err != nil {
+		return err
+	}
+	s.bucketReady = true
+	return nil
+}
+
+// createBucket checks whether the bucket exists, and creates it if it does not.
+func (s *s3storage) createBucket() error {
 	exists, err := s.client.BucketExists(s.config.BucketName)
 	if err != nil {
 		return fmt.Errorf("unable to query S3 bucket status: %s", err)
@@ -42,16 +63,21 @@ func (s *s3storage) ensureBucket() error {
 		return nil
 	}
 	err = s.client.MakeBucket(s.config.BucketName, s.config.BucketLocation)
-	if err == nil {
-		log.Tracef("S3: created bucket '%s' at location '%s'", s.config.BucketName, s.config.BucketLocation)
+	if err != nil {
+		return fmt.Errorf("S3 bucket '%s' does not exist, and can not be created: %s", s.config.BucketName, err)
 	}
-	return err
+	log.Tracef("S3: created bucket '%s' at location '%s'", s.config.BucketName, s.config.BucketLocation)
+	return nil
 }
 
 func (s *s3storage) Read(repository string) ([]string, error) {
 	if err := s.ensureBucket(); err != nil {
 		return nil, err
 	}
+	return s.read(repository)
+}
+
+func (s *s3storage) read(repository string) ([]string, error) {
 	obj, err := s.client.GetObject(s.config.BucketName, repository, minio.GetObjectOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("while locating s3 object: %s", err)
@@ -66,10 +92,8 @@ func (s *s3storage) Read(repository string) ([]string, error) {
 
 	stat, err := obj.Stat()
 	if err != nil {
-		// We need to be able to return an error message saying that the object wasn't found.
-		// minio doesn't return this as a custom error message, so we use a string comparison instead.
-		if err.Error() == NotFoundMessage {
-			return nil, ErrNotFound
+		if minio.ToErrorResponse(err).Code == s3NoSuchKey {
+			return nil, ErrRepositoryNotFound
 		}
 		return nil, fmt.Errorf("while querying s3 object stats: %s", err)
 	}
@@ -109,6 +133,46 @@ func (s *s3storage) Write(repository string, teams []string) error {
 	return err
 }
 
+func (s *s3storage) List() (map[string][]string, error) {
+	if err := s.ensureBucket(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	contents := make(map[string][]string)
+	for object := range s.client.ListObjectsV2(s.config.BucketName, "", true, done) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("while listing s3 objects: %s", object.Err)
+		}
+		teams, err := s.read(object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", object.Key, err)
+		}
+		contents[object.Key] = teams
+	}
+
+	return contents, nil
+}
+
+// Ping checks that the S3 endpoint is reachable and that the bucket exists.
+// A missing bucket is created, like on the first read or write; Ping fails if that is not possible.
+// The S3 client does not take a context, so Ping returns when the context is done without waiting for the request.
+func (s *s3storage) Ping(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.createBucket()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("unable to query S3 bucket status: %s", ctx.Err())
+	}
+}
+
 func (s *s3storage) IsErrNotFound(err error) bool {
-	return err.Error() == ErrNotFound.Error()
+	return err == ErrRepositoryNotFound
 }
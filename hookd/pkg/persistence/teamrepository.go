@@ -0,0 +1,76 @@
+package persistence
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/navikt/deployment/hookd/pkg/config"
+)
+
+const (
+	TeamRepositoryBackendS3     = "s3"
+	TeamRepositoryBackendFile   = "file"
+	TeamRepositoryBackendSQLite = "sqlite"
+	TeamRepositoryBackendMemory = "memory"
+)
+
+var (
+	ErrRepositoryNotFound = fmt.Errorf("repository not found")
+)
+
+// TeamRepositoryStorage keeps track of which teams are allowed to deploy from a repository.
+type TeamRepositoryStorage interface {
+	Read(repository string) ([]string, error)
+	Write(repository string, teams []string) error
+	List() (map[string][]string, error)
+	IsErrNotFound(err error) bool
+}
+
+// NewTeamRepositoryStorage sets up the configured storage backend.
+// Backends storing data locally default to a location within dataDir.
+func NewTeamRepositoryStorage(cfg config.TeamRepositories, s3 config.S3, dataDir string) (TeamRepositoryStorage, error) {
+	switch cfg.Backend {
+	case TeamRepositoryBackendS3:
+		return NewS3StorageBackend(s3)
+
+	case TeamRepositoryBackendFile:
+		path := cfg.Path
+		if len(path) == 0 {
+			path = filepath.Join(dataDir, "team-repositories")
+		}
+		return NewFileTeamRepositoryStorage(path)
+
+	case TeamRepositoryBackendSQLite:
+		path := cfg.Path
+		if len(path) == 0 {
+			path = filepath.Join(dataDir, "team-repositories.db")
+		}
+		return NewSQLiteTeamRepositoryStorage(path)
+
+	case TeamRepositoryBackendMemory:
+		return NewMemoryTeamRepositoryStorage(), nil
+
+	default:
+		return nil, fmt.Errorf("unknown team repository storage backend '%s'", cfg.Backend)
+	}
+}
+
+// MigrateTeamRepositories copies all repository mappings from one storage backend to another.
+// Returns the number of repositories copied.
+func MigrateTeamRepositories(source, destination TeamRepositoryStorage) (int, error) {
+	contents, err := source.List()
+	if err != nil {
+		return 0, fmt.Errorf("list source repositories: %s", err)
+	}
+
+	copied := 0
+	for repository, teams := range contents {
+		err = destination.Write(repository, teams)
+		if err != nil {
+			return copied, fmt.Errorf("write repository '%s': %s", repository, err)
+		}
+		copied++
+	}
+
+	return copied, nil
+}
@@ -0,0 +1,101 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const teamRepositoryFileSuffix = ".json"
+
+type fileTeamRepositoryStorage struct {
+	lock sync.RWMutex
+	dir  string
+}
+
+// NewFileTeamRepositoryStorage returns a storage backend that keeps
+// one JSON file per repository in a local directory.
+func NewFileTeamRepositoryStorage(dir string) (TeamRepositoryStorage, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("create directory %s: %s", dir, err)
+	}
+	return &fileTeamRepositoryStorage{
+		dir: dir,
+	}, nil
+}
+
+// Repository names contain slashes, so they are escaped to get a flat directory structure.
+func (s *fileTeamRepositoryStorage) path(repository string) string {
+	return filepath.Join(s.dir, url.PathEscape(repository)+teamRepositoryFileSuffix)
+}
+
+func (s *fileTeamRepositoryStorage) Read(repository string) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.read(s.path(repository))
+}
+
+func (s *fileTeamRepositoryStorage) read(path string) ([]string, error) {
+	var teams []string
+	err := LoadJSONFile(path, &teams)
+	if err != nil {
+		return nil, err
+	}
+	if teams == nil {
+		return nil, ErrRepositoryNotFound
+	}
+	return teams, nil
+}
+
+func (s *fileTeamRepositoryStorage) Write(repository string, teams []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return SaveJSONFile(s.path(repository), copyTeams(teams))
+}
+
+func (s *fileTeamRepositoryStorage) List() (map[string][]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read directory %s: %s", s.dir, err)
+	}
+
+	contents := make(map[string][]string)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, teamRepositoryFileSuffix) {
+			continue
+		}
+		repository, err := url.PathUnescape(strings.TrimSuffix(name, teamRepositoryFileSuffix))
+		if err != nil {
+			continue
+		}
+		teams, err := s.read(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		contents[repository] = teams
+	}
+
+	return contents, nil
+}
+
+// Ping checks that the storage directory is still accessible.
+func (s *fileTeamRepositoryStorage) Ping(ctx context.Context) error {
+	_, err := ioutil.ReadDir(s.dir)
+	return err
+}
+
+func (s *fileTeamRepositoryStorage) IsErrNotFound(err error) bool {
+	return err == ErrRepositoryNotFound
+}
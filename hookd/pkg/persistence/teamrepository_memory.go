@@ -0,0 +1,55 @@
+package persistence
+
+import (
+	"sync"
+)
+
+type memoryTeamRepositoryStorage struct {
+	lock     sync.Mutex
+	contents map[string][]string
+}
+
+// NewMemoryTeamRepositoryStorage returns a storage backend that only keeps its data in memory.
+func NewMemoryTeamRepositoryStorage() TeamRepositoryStorage {
+	return &memoryTeamRepositoryStorage{
+		contents: make(map[string][]string),
+	}
+}
+
+func (s *memoryTeamRepositoryStorage) Read(repository string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	teams, ok := s.contents[repository]
+	if !ok {
+		return nil, ErrRepositoryNotFound
+	}
+	return copyTeams(teams), nil
+}
+
+func (s *memoryTeamRepositoryStorage) Write(repository string, teams []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.contents[repository] = copyTeams(teams)
+	return nil
+}
+
+func (s *memoryTeamRepositoryStorage) List() (map[string][]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	contents := make(map[string][]string, len(s.contents))
+	for repository, teams := range s.contents {
+		contents[repository] = copyTeams(teams)
+	}
+	return contents, nil
+}
+
+func (s *memoryTeamRepositoryStorage) IsErrNotFound(err error) bool {
+	return err == ErrRepositoryNotFound
+}
+
+func copyTeams(teams []string) []string {
+	return append(make([]string, 0, len(teams)), teams...)
+}
@@ -0,0 +1,109 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS team_repositories (
+	repository TEXT PRIMARY KEY,
+	teams      TEXT NOT NULL
+)`
+
+type sqliteTeamRepositoryStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteTeamRepositoryStorage returns a storage backend using an embedded SQLite database.
+// The database and its schema are created if they do not exist.
+func NewSQLiteTeamRepositoryStorage(path string) (TeamRepositoryStorage, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("open SQLite database %s: %s", path, err)
+	}
+
+	// SQLite only supports one writer at a time.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create SQLite schema: %s", err)
+	}
+
+	return &sqliteTeamRepositoryStorage{
+		db: db,
+	}, nil
+}
+
+func (s *sqliteTeamRepositoryStorage) Read(repository string) ([]string, error) {
+	var data string
+	err := s.db.QueryRow("SELECT teams FROM team_repositories WHERE repository = ?", repository).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrRepositoryNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("query repository: %s", err)
+	}
+
+	return decodeTeams(data)
+}
+
+func (s *sqliteTeamRepositoryStorage) Write(repository string, teams []string) error {
+	data, err := json.Marshal(copyTeams(teams))
+	if err != nil {
+		return fmt.Errorf("encode teams: %s", err)
+	}
+
+	_, err = s.db.Exec("INSERT OR REPLACE INTO team_repositories (repository, teams) VALUES (?, ?)", repository, string(data))
+	if err != nil {
+		return fmt.Errorf("store repository: %s", err)
+	}
+
+	return nil
+}
+
+func (s *sqliteTeamRepositoryStorage) List() (map[string][]string, error) {
+	rows, err := s.db.Query("SELECT repository, teams FROM team_repositories")
+	if err != nil {
+		return nil, fmt.Errorf("query repositories: %s", err)
+	}
+	defer rows.Close()
+
+	contents := make(map[string][]string)
+	for rows.Next() {
+		var repository, data string
+		err = rows.Scan(&repository, &data)
+		if err != nil {
+			return nil, fmt.Errorf("scan repository: %s", err)
+		}
+		contents[repository], err = decodeTeams(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return contents, rows.Err()
+}
+
+// Ping checks that the database is still accessible.
+func (s *sqliteTeamRepositoryStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqliteTeamRepositoryStorage) IsErrNotFound(err error) bool {
+	return err == ErrRepositoryNotFound
+}
+
+func decodeTeams(data string) ([]string, error) {
+	teams := make([]string, 0)
+	err := json.Unmarshal([]byte(data), &teams)
+	if err != nil {
+		return nil, fmt.Errorf("decode teams: %s", err)
+	}
+	return teams, nil
+}
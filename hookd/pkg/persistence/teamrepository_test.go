@@ -0,0 +1,140 @@
+package persistence_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/navikt/deployment/hookd/pkg/config"
+	"github.com/navikt/deployment/hookd/pkg/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func teamRepositoryBackends(t *testing.T, dir string) map[string]persistence.TeamRepositoryStorage {
+	file, err := persistence.NewFileTeamRepositoryStorage(filepath.Join(dir, "files"))
+	assert.NoError(t, err)
+
+	sqlite, err := persistence.NewSQLiteTeamRepositoryStorage(filepath.Join(dir, "team-repositories.db"))
+	assert.NoError(t, err)
+
+	return map[string]persistence.TeamRepositoryStorage{
+		"memory": persistence.NewMemoryTeamRepositoryStorage(),
+		"file":   file,
+		"sqlite": sqlite,
+	}
+}
+
+func TestTeamRepositoryStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "team-repositories")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, storage := range teamRepositoryBackends(t, dir) {
+		t.Run(name, func(t *testing.T) {
+			_, err := storage.Read("navikt/deployment")
+			assert.True(t, storage.IsErrNotFound(err))
+
+			err = storage.Write("navikt/deployment", []string{"aura"})
+			assert.NoError(t, err)
+
+			err = storage.Write("navikt/deployment", []string{"aura", "nais"})
+			assert.NoError(t, err)
+
+			err = storage.Write("navikt/other", []string{})
+			assert.NoError(t, err)
+
+			teams, err := storage.Read("navikt/deployment")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"aura", "nais"}, teams)
+
+			teams, err = storage.Read("navikt/other")
+			assert.NoError(t, err)
+			assert.Empty(t, teams)
+
+			contents, err := storage.List()
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]string{
+				"navikt/deployment": {"aura", "nais"},
+				"navikt/other":      {},
+			}, contents)
+		})
+	}
+}
+
+func TestMigrateTeamRepositories(t *testing.T) {
+	source := persistence.NewMemoryTeamRepositoryStorage()
+	destination := persistence.NewMemoryTeamRepositoryStorage()
+
+	assert.NoError(t, source.Write("navikt/deployment", []string{"aura"}))
+	assert.NoError(t, source.Write("navikt/other", []string{"nais"}))
+
+	copied, err := persistence.MigrateTeamRepositories(source, destination)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, copied)
+
+	expected, _ := source.List()
+	contents, err := destination.List()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, contents)
+}
+
+func TestS3StoragePing(t *testing.T) {
+	exists := false
+	delay := time.Duration(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		_, location := r.URL.Query()["location"]
+		switch {
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+		case !exists:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>`))
+		case location:
+			w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LocationConstraint>`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	endpoint, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	storage, err := persistence.NewS3StorageBackend(config.S3{
+		Endpoint:   endpoint.Host,
+		BucketName: "teams",
+	})
+	assert.NoError(t, err)
+	pinger := storage.(interface {
+		Ping(ctx context.Context) error
+	})
+
+	t.Run("fails if the bucket does not exist and can not be created", func(t *testing.T) {
+		err := pinger.Ping(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "does not exist")
+	})
+
+	t.Run("succeeds if the bucket exists", func(t *testing.T) {
+		exists = true
+		assert.NoError(t, pinger.Ping(context.Background()))
+	})
+
+	t.Run("returns when the context is done", func(t *testing.T) {
+		delay = 200 * time.Millisecond
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		assert.Error(t, pinger.Ping(ctx))
+		assert.True(t, time.Since(start) < delay)
+	})
+}
@@ -0,0 +1,48 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/notification"
+)
+
+// FileReporter appends deployment statuses to a file, one JSON document per line.
+type FileReporter struct {
+	Path string
+
+	lock sync.Mutex
+}
+
+func (r *FileReporter) Name() string {
+	return "file"
+}
+
+func (r *FileReporter) Report(status deployment.DeploymentStatus) error {
+	line, err := json.Marshal(notification.NewPayload(&status, ""))
+	if err != nil {
+		return Permanent(err)
+	}
+	line = append(line, '\n')
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	file, err := os.OpenFile(r.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open %s: %s", r.Path, err)
+	}
+
+	_, err = file.Write(line)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %s", r.Path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package reporter
+
+import (
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/github"
+	"github.com/navikt/deployment/hookd/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// GithubReporter posts deployment statuses to the GitHub Deployments API.
+type GithubReporter struct {
+	Client  *gh.Client
+	BaseURL string
+}
+
+func (r *GithubReporter) Name() string {
+	return "github"
+}
+
+func (r *GithubReporter) Report(status deployment.DeploymentStatus) error {
+	ghs, resp, err := github.CreateDeploymentStatus(r.Client, &status, r.BaseURL)
+
+	code := 0
+	if resp != nil {
+		code = resp.StatusCode
+	}
+	metrics.DeploymentStatus(status, code)
+
+	switch {
+	case err == github.ErrEmptyRepository || err == github.ErrEmptyDeployment:
+		return Permanent(err)
+	case err != nil:
+		return err
+	}
+
+	log.WithFields(status.LogFields()).WithField(deployment.LogFieldDeploymentStatusID, ghs.GetID()).Infof("Published deployment status to GitHub: %s", status.GetDescription())
+
+	return nil
+}
+
+// GithubInactiveReporter marks earlier GitHub deployments to the same repository and environment
+// as inactive when a deployment succeeds, so that only the latest one is shown as active.
+type GithubInactiveReporter struct {
+	Client *gh.Client
+}
+
+func (r *GithubInactiveReporter) Name() string {
+	return "github-inactive"
+}
+
+func (r *GithubInactiveReporter) Report(status deployment.DeploymentStatus) error {
+	if status.GetState() != deployment.GithubDeploymentState_success {
+		return nil
+	}
+
+	inactivated, err := github.InactivatePreviousDeployments(r.Client, &status)
+
+	switch {
+	case err == github.ErrEmptyRepository || err == github.ErrEmptyDeployment:
+		return Permanent(err)
+	case err != nil:
+		return err
+	}
+
+	if len(inactivated) > 0 {
+		log.WithFields(status.LogFields()).Infof("Marked previous GitHub deployments %v as inactive", inactivated)
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package reporter
+
+import (
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/history"
+)
+
+// HistoryReporter records deployment statuses in the local deployment history.
+type HistoryReporter struct {
+	Store history.Store
+}
+
+func (r *HistoryReporter) Name() string {
+	return "history"
+}
+
+func (r *HistoryReporter) Report(status deployment.DeploymentStatus) error {
+	return r.Store.Add(status, time.Now())
+}
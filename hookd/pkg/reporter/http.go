@@ -0,0 +1,45 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/logproxy"
+	"github.com/navikt/deployment/hookd/pkg/notification"
+)
+
+// HTTPReporter posts deployment statuses as JSON documents to a fixed URL.
+// The document format is the same as for notification subscriptions.
+type HTTPReporter struct {
+	URL     string
+	BaseURL string
+	Client  *http.Client
+}
+
+func (r *HTTPReporter) Name() string {
+	return "http"
+}
+
+func (r *HTTPReporter) Report(status deployment.DeploymentStatus) error {
+	logURL := logproxy.MakeURL(r.BaseURL, status.GetDeliveryID(), time.Now())
+	body, err := json.Marshal(notification.NewPayload(&status, logURL))
+	if err != nil {
+		return Permanent(err)
+	}
+
+	resp, err := r.Client.Post(r.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+
+	return nil
+}
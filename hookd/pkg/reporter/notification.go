@@ -0,0 +1,21 @@
+package reporter
+
+import (
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/notification"
+)
+
+// NotificationReporter sends deployment statuses to teams' notification subscriptions.
+// Deliveries are queued and retried by the notifier itself, per subscription.
+type NotificationReporter struct {
+	Notifier *notification.Notifier
+}
+
+func (r *NotificationReporter) Name() string {
+	return "notification"
+}
+
+func (r *NotificationReporter) Report(status deployment.DeploymentStatus) error {
+	r.Notifier.Notify(status)
+	return nil
+}
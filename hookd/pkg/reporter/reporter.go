@@ -0,0 +1,127 @@
+package reporter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/metrics"
+	"github.com/navikt/deployment/hookd/pkg/outbox"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LogFieldReporter = "reporter"
+)
+
+// StatusReporter publishes deployment statuses somewhere, e.g. GitHub or a local file.
+type StatusReporter interface {
+	Name() string
+	Report(status deployment.DeploymentStatus) error
+}
+
+// Permanent wraps an error to signal that retrying the report will not help.
+func Permanent(err error) error {
+	return outbox.Permanent(err)
+}
+
+// Reporter runs a StatusReporter on its own outbox, which retries failed reports according to its policy.
+type Reporter struct {
+	StatusReporter
+	Outbox *outbox.Outbox
+}
+
+func New(r StatusReporter, box *outbox.Outbox) *Reporter {
+	return &Reporter{
+		StatusReporter: r,
+		Outbox:         box,
+	}
+}
+
+// Enqueue schedules a status for reporting.
+func (r *Reporter) Enqueue(status deployment.DeploymentStatus) error {
+	payload, err := proto.Marshal(&status)
+	if err != nil {
+		return fmt.Errorf("encode deployment status: %s", err)
+	}
+	summary := fmt.Sprintf("%s: %s", status.GetDeliveryID(), status.GetState())
+	return r.Outbox.Push(status.GetDeliveryID(), summary, payload)
+}
+
+// Run processes the reporter's outbox until stop is closed.
+func (r *Reporter) Run(stop <-chan struct{}) {
+	r.Outbox.Run(r.handle, stop)
+}
+
+func (r *Reporter) handle(payload []byte) error {
+	status := deployment.DeploymentStatus{}
+	if err := proto.Unmarshal(payload, &status); err != nil {
+		return Permanent(fmt.Errorf("decode deployment status: %s", err))
+	}
+
+	logger := log.WithFields(status.LogFields()).WithField(LogFieldReporter, r.Name())
+
+	err := r.Report(status)
+	metrics.StatusReport(r.Name(), err)
+
+	if err == nil {
+		logger.Tracef("Deployment status reported")
+	}
+
+	return err
+}
+
+// Dispatcher sends every deployment status to several reporters running side by side.
+type Dispatcher struct {
+	Reporters []*Reporter
+
+	wg sync.WaitGroup
+}
+
+// Start launches all reporters in the background.
+func (d *Dispatcher) Start(stop <-chan struct{}) {
+	for _, r := range d.Reporters {
+		d.wg.Add(1)
+		go func(r *Reporter) {
+			defer d.wg.Done()
+			r.Run(stop)
+		}(r)
+	}
+}
+
+// Wait blocks until all reporters have stopped.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) Dispatch(status deployment.DeploymentStatus) {
+	for _, r := range d.Reporters {
+		err := r.Enqueue(status)
+		if err != nil {
+			log.WithFields(status.LogFields()).WithField(LogFieldReporter, r.Name()).Errorf("Discarding deployment status: %s", err)
+		}
+	}
+}
+
+// Names returns the names of all configured reporters.
+func (d *Dispatcher) Names() []string {
+	names := make([]string, len(d.Reporters))
+	for i, r := range d.Reporters {
+		names[i] = r.Name()
+	}
+	return names
+}
+
+// Outboxes returns the outboxes of all configured reporters.
+func (d *Dispatcher) Outboxes() []*outbox.Outbox {
+	boxes := make([]*outbox.Outbox, len(d.Reporters))
+	for i, r := range d.Reporters {
+		boxes[i] = r.Outbox
+	}
+	return boxes
+}
+
+func (d *Dispatcher) String() string {
+	return fmt.Sprintf("%v", d.Names())
+}
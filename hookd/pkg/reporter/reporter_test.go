@@ -0,0 +1,189 @@
+package reporter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/v27/github"
+	"github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/hookd/pkg/outbox"
+	"github.com/navikt/deployment/hookd/pkg/reporter"
+	"github.com/stretchr/testify/assert"
+)
+
+type flakyReporter struct {
+	failures int
+	err      error
+	reports  chan int
+	attempts int
+}
+
+func (r *flakyReporter) Name() string {
+	return "flaky"
+}
+
+func (r *flakyReporter) Report(status deployment.DeploymentStatus) error {
+	r.attempts++
+	r.reports <- r.attempts
+	if r.attempts <= r.failures {
+		return r.err
+	}
+	return nil
+}
+
+func attempts(reports chan int) int {
+	n := 0
+	for {
+		select {
+		case n = <-reports:
+		case <-time.After(time.Millisecond * 100):
+			return n
+		}
+	}
+}
+
+func TestReporterRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		failures int
+		policy   outbox.Policy
+		attempts int
+	}{
+		{
+			name:     "retried until success",
+			err:      fmt.Errorf("temporary"),
+			failures: 2,
+			policy:   outbox.Policy{MaxAttempts: 0, Backoff: time.Millisecond},
+			attempts: 3,
+		},
+		{
+			name:     "gives up after max attempts",
+			err:      fmt.Errorf("temporary"),
+			failures: 10,
+			policy:   outbox.Policy{MaxAttempts: 4, Backoff: time.Millisecond},
+			attempts: 4,
+		},
+		{
+			name:     "permanent errors are not retried",
+			err:      reporter.Permanent(fmt.Errorf("broken")),
+			failures: 10,
+			policy:   outbox.Policy{MaxAttempts: 0, Backoff: time.Millisecond},
+			attempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "reporter")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			box, err := outbox.New(filepath.Join(dir, "outbox.json"), "flaky", 4, test.policy)
+			assert.NoError(t, err)
+
+			stop := make(chan struct{})
+			defer close(stop)
+
+			flaky := &flakyReporter{failures: test.failures, err: test.err, reports: make(chan int, 16)}
+			dispatcher := &reporter.Dispatcher{
+				Reporters: []*reporter.Reporter{reporter.New(flaky, box)},
+			}
+			dispatcher.Start(stop)
+			dispatcher.Dispatch(deployment.DeploymentStatus{})
+			assert.Equal(t, test.attempts, attempts(flaky.reports))
+		})
+	}
+}
+
+func TestFileReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "statuses.jsonl")
+	r := &reporter.FileReporter{Path: path}
+
+	assert.NoError(t, r.Report(deployment.DeploymentStatus{DeliveryID: "first", State: deployment.GithubDeploymentState_queued}))
+	assert.NoError(t, r.Report(deployment.DeploymentStatus{DeliveryID: "second", State: deployment.GithubDeploymentState_success}))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"deliveryID":"first"`)
+	assert.Contains(t, lines[1], `"state":"success"`)
+}
+
+func TestGithubInactiveReporter(t *testing.T) {
+	inactivated := make([]string, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/navikt/app/deployments/5", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 5, "environment": "prod-fss"}`)
+	})
+	mux.HandleFunc("/repos/navikt/app/deployments", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "prod-fss", r.URL.Query().Get("environment"))
+		fmt.Fprint(w, `[{"id": 6}, {"id": 5}, {"id": 4}, {"id": 3}, {"id": 2}]`)
+	})
+	statuses := map[string]string{
+		"6": `[{"state": "success"}]`,
+		"4": `[{"state": "success"}]`,
+		"3": `[{"state": "failure"}]`,
+		"2": `[]`,
+	}
+	for id, body := range statuses {
+		id, body := id, body
+		mux.HandleFunc("/repos/navikt/app/deployments/"+id+"/statuses", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, body)
+				return
+			}
+			request := gh.DeploymentStatusRequest{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, "inactive", request.GetState())
+			inactivated = append(inactivated, id)
+			fmt.Fprint(w, `{}`)
+		})
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := gh.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	r := &reporter.GithubInactiveReporter{Client: client}
+	status := deployment.DeploymentStatus{
+		DeliveryID: "abc",
+		State:      deployment.GithubDeploymentState_in_progress,
+		Deployment: &deployment.DeploymentSpec{
+			Repository: &deployment.GithubRepository{
+				Owner: "navikt",
+				Name:  "app",
+			},
+			DeploymentID: 5,
+		},
+	}
+
+	assert.NoError(t, r.Report(status))
+	assert.Empty(t, inactivated, "only successful deployments mark others inactive")
+
+	status.State = deployment.GithubDeploymentState_success
+	assert.NoError(t, r.Report(status))
+	assert.Equal(t, []string{"4"}, inactivated, "only earlier successful deployments are marked inactive")
+}
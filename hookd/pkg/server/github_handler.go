@@ -8,8 +8,12 @@ import (
 
 	gh "github.com/google/go-github/v27/github"
 	types "github.com/navikt/deployment/common/pkg/deployment"
+	"github.com/navikt/deployment/common/pkg/tracing"
 	"github.com/navikt/deployment/hookd/pkg/api/v1"
 	"github.com/navikt/deployment/hookd/pkg/api/v1/deploy"
+	"github.com/navikt/deployment/hookd/pkg/approval"
+	"github.com/navikt/deployment/hookd/pkg/audit"
+	"github.com/navikt/deployment/hookd/pkg/freeze"
 	"github.com/navikt/deployment/hookd/pkg/metrics"
 	"github.com/navikt/deployment/hookd/pkg/persistence"
 	log "github.com/sirupsen/logrus"
@@ -26,6 +30,14 @@ type GithubDeploymentHandler struct {
 	DeploymentStatus      chan types.DeploymentStatus
 	DeploymentRequest     chan types.DeploymentRequest
 	Clusters              api_v1.ClusterList
+	FreezeStorage         freeze.Storage
+	ApprovalGate          *approval.Gate
+	AuditLog              *audit.Log
+}
+
+// Extra fields in the GitHub deployment payload that are not part of the deployment protocol.
+type payloadOptions struct {
+	FreezeOverride bool `json:"freezeOverride"`
 }
 
 func (h *GithubDeploymentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -78,6 +90,59 @@ func (h *GithubDeploymentHandler) validateTeamAccess(req *types.DeploymentReques
 	return fmt.Errorf("the repository '%s' does not have access to deploy as team '%s'", fullName, team)
 }
 
+// checkFreeze returns an error if an active freeze window blocks the deployment,
+// unless the deployment payload explicitly overrides the freeze.
+func (h *GithubDeploymentHandler) checkFreeze(req *types.DeploymentRequest, event *gh.DeploymentEvent) error {
+	payload := event.GetDeployment().Payload
+
+	window, err := freeze.Check(h.FreezeStorage, req.GetCluster(), req.GetPayloadSpec().GetTeam())
+	if err != nil {
+		return fmt.Errorf("unable to check deployment freeze windows: %s", err)
+	}
+
+	if window == nil {
+		return nil
+	}
+
+	options := &payloadOptions{}
+	_ = json.Unmarshal(payload, options)
+
+	if !options.FreezeOverride {
+		h.audit(req, event, audit.ActionDeploy, audit.OutcomeDenied, map[string]string{
+			"reason":           "deployment freeze",
+			"freeze_window_id": window.ID,
+		})
+		return fmt.Errorf("%s", window)
+	}
+
+	h.log.WithFields(log.Fields{
+		api_v1.LogFieldFreezeWindowID: window.ID,
+		api_v1.LogFieldAudit:          true,
+	}).Warnf("Deployment freeze overridden by team '%s': %s", req.GetPayloadSpec().GetTeam(), window.Reason)
+	h.audit(req, event, audit.ActionFreezeOverride, audit.OutcomeSuccess, map[string]string{
+		"freeze_window_id": window.ID,
+	})
+
+	return nil
+}
+
+// audit records an action taken on behalf of the GitHub user who created the deployment.
+func (h *GithubDeploymentHandler) audit(req *types.DeploymentRequest, event *gh.DeploymentEvent, action, outcome string, details map[string]string) {
+	if details == nil {
+		details = make(map[string]string)
+	}
+	details["team"] = req.GetPayloadSpec().GetTeam()
+	details["cluster"] = req.GetCluster()
+	h.AuditLog.Record(audit.Record{
+		Actor:         audit.UserActor(event.GetDeployment().GetCreator().GetLogin()),
+		Action:        action,
+		Target:        req.GetDeployment().GetRepository().FullName(),
+		Outcome:       outcome,
+		CorrelationID: req.GetDeliveryID(),
+		Details:       details,
+	})
+}
+
 func (h *GithubDeploymentHandler) handler(r *http.Request) (int, error) {
 	var err error
 
@@ -103,6 +168,16 @@ func (h *GithubDeploymentHandler) handler(r *http.Request) (int, error) {
 
 	err = gh.ValidateSignature(sig, data, []byte(h.SecretToken))
 	if err != nil {
+		h.AuditLog.Record(audit.Record{
+			Actor:         audit.ActorWebhook,
+			Action:        audit.ActionAuthenticate,
+			Target:        r.URL.Path,
+			Outcome:       audit.OutcomeDenied,
+			CorrelationID: deliveryID,
+			Details: map[string]string{
+				"reason": err.Error(),
+			},
+		})
 		return http.StatusForbidden, err
 	}
 
@@ -125,6 +200,7 @@ func (h *GithubDeploymentHandler) handler(r *http.Request) (int, error) {
 		return http.StatusBadRequest, err
 	}
 
+	deploymentRequest.Traceparent = tracing.Traceparent(r.Context())
 	h.log = h.log.WithFields(deploymentRequest.LogFields())
 
 	if len(deploymentRequest.GetPayloadSpec().GetTeam()) == 0 {
@@ -134,12 +210,33 @@ func (h *GithubDeploymentHandler) handler(r *http.Request) (int, error) {
 	}
 
 	if err := h.validateTeamAccess(deploymentRequest); err != nil {
+		h.audit(deploymentRequest, deploymentEvent, audit.ActionDeploy, audit.OutcomeDenied, map[string]string{
+			"reason": err.Error(),
+		})
 		h.DeploymentStatus <- *types.NewErrorStatus(*deploymentRequest, err)
 		return http.StatusForbidden, err
 	}
 
+	if err := h.checkFreeze(deploymentRequest, deploymentEvent); err != nil {
+		h.DeploymentStatus <- *types.NewErrorStatus(*deploymentRequest, err)
+		return http.StatusLocked, err
+	}
+
+	if h.ApprovalGate.Required(deploymentRequest.GetCluster()) {
+		_, err := h.ApprovalGate.Hold(*deploymentRequest, deploymentEvent.GetDeployment().GetCreator().GetLogin())
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("unable to store deployment request for approval: %s", err)
+		}
+		h.audit(deploymentRequest, deploymentEvent, audit.ActionDeploy, audit.OutcomeSuccess, map[string]string{
+			"status": "waiting for approval",
+		})
+		return http.StatusAccepted, fmt.Errorf("deployment request is waiting for approval")
+	}
+
 	h.log.Infof("Validation successful; dispatching deployment")
 	h.DeploymentRequest <- *deploymentRequest
 
+	h.audit(deploymentRequest, deploymentEvent, audit.ActionDeploy, audit.OutcomeSuccess, nil)
+
 	return http.StatusCreated, nil
 }
@@ -42,6 +42,10 @@ func (s *mockRepository) Write(repository string, teams []string) error {
 	return nil
 }
 
+func (s *mockRepository) List() (map[string][]string, error) {
+	return s.Contents, nil
+}
+
 func (s *mockRepository) IsErrNotFound(err error) bool {
 	return false
 }